@@ -0,0 +1,273 @@
+// Package access guards the custom API routes so users only reach the data
+// of workspaces they own or are members of, within their role.
+package access
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"lifehub/backend/internal/http/respond"
+	"lifehub/backend/internal/services/members"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/hook"
+)
+
+// App holds the PocketBase instance
+var App *pocketbase.PocketBase
+
+// DefaultWorkspaceMiddlewarePriority runs RequireWorkspace after PocketBase
+// has loaded the auth token. The per-route options run just before it.
+const DefaultWorkspaceMiddlewarePriority = 0
+
+const (
+	workspaceKey = "access.workspace"
+	resolverKey  = "access.resolver"
+	optionalKey  = "access.optional"
+	roleKey      = "access.role"
+)
+
+// References are the request fields, in the query or the body, that name
+// records of a collection. Each referenced record must live in the request's
+// workspace; requests without a workspace param are scoped by them.
+var References = map[string]string{
+	"account":         "finance_accounts",
+	"match_account":   "finance_accounts",
+	"category_id":     "finance_categories",
+	"match_category":  "finance_categories",
+	"parent":          "finance_categories",
+	"parent_id":       "finance_categories",
+	"merchant_id":     "finance_merchants",
+	"match_merchant":  "finance_merchants",
+	"budget":          "finance_budgets",
+	"budget_item":     "finance_budget_items",
+	"income_source":   "finance_income_sources",
+	"ids":             "finance_transactions",
+	"transaction_ids": "finance_transactions",
+	"portfolio":       "investment_portfolios",
+}
+
+// workspaceParams name the workspace directly
+var workspaceParams = []string{"workspace", "workspace_id"}
+
+// Resolver returns the workspace of the record a route acts on, or "" when
+// the record belongs to none. found is false when there is no such record.
+type Resolver func(e *core.RequestEvent) (workspaceID string, found bool, err error)
+
+// Allowed reports whether the user has at least the role min in the workspace
+func Allowed(workspaceID, userID string, min members.Role) (bool, error) {
+	if App == nil {
+		return false, fmt.Errorf("PocketBase app not initialized")
+	}
+	role, err := members.RoleOf(workspaceID, userID)
+	if err != nil {
+		return false, err
+	}
+	return role.Includes(min), nil
+}
+
+// WorkspaceID returns the workspace RequireWorkspace authorized the request for
+func WorkspaceID(e *core.RequestEvent) string {
+	id, _ := e.Get(workspaceKey).(string)
+	return id
+}
+
+// RequireWorkspace requires an authenticated user, resolves the single
+// workspace the request is about and answers 403 unless the user's role
+// there is high enough: viewer for reads, editor for anything else unless
+// the route sets MinRole. Superusers may access every workspace.
+func RequireWorkspace() *hook.Handler[*core.RequestEvent] {
+	return &hook.Handler[*core.RequestEvent]{
+		Id:       "lifehubRequireWorkspace",
+		Priority: DefaultWorkspaceMiddlewarePriority,
+		Func:     requireWorkspace,
+	}
+}
+
+// Record scopes a route to the workspace of the record in its {id} path value
+func Record(collection string) *hook.Handler[*core.RequestEvent] {
+	return Resolve(func(e *core.RequestEvent) (string, bool, error) {
+		record, err := App.FindRecordById(collection, e.Request.PathValue("id"))
+		if err != nil {
+			return "", false, nil
+		}
+		return record.GetString("workspace"), true, nil
+	})
+}
+
+// Workspace scopes a route to the workspace in its {workspace} path value
+func Workspace() *hook.Handler[*core.RequestEvent] {
+	return Resolve(func(e *core.RequestEvent) (string, bool, error) {
+		ws, err := App.FindRecordById("workspaces", e.Request.PathValue("workspace"))
+		if err != nil {
+			return "", false, nil
+		}
+		return ws.Id, true, nil
+	})
+}
+
+// Resolve scopes a route to the workspace returned by resolve
+func Resolve(resolve Resolver) *hook.Handler[*core.RequestEvent] {
+	return &hook.Handler[*core.RequestEvent]{
+		Priority: DefaultWorkspaceMiddlewarePriority - 1,
+		Func: func(e *core.RequestEvent) error {
+			e.Set(resolverKey, resolve)
+			return e.Next()
+		},
+	}
+}
+
+// Optional lets a route run without a workspace, for data shared by all
+// users such as the built-in templates. A workspace named by the request is
+// still checked.
+func Optional() *hook.Handler[*core.RequestEvent] {
+	return &hook.Handler[*core.RequestEvent]{
+		Priority: DefaultWorkspaceMiddlewarePriority - 1,
+		Func: func(e *core.RequestEvent) error {
+			e.Set(optionalKey, true)
+			return e.Next()
+		},
+	}
+}
+
+// Superuser limits a route to superusers, for data shared by every
+// workspace such as the exchange rates
+func Superuser() *hook.Handler[*core.RequestEvent] {
+	return &hook.Handler[*core.RequestEvent]{
+		Priority: DefaultWorkspaceMiddlewarePriority + 1,
+		Func: func(e *core.RequestEvent) error {
+			if !e.HasSuperuserAuth() {
+				return respond.Fail(e, http.StatusForbidden, "superuser access required")
+			}
+			return e.Next()
+		},
+	}
+}
+
+// MinRole sets the role a route requires instead of the one implied by its method
+func MinRole(role members.Role) *hook.Handler[*core.RequestEvent] {
+	return &hook.Handler[*core.RequestEvent]{
+		Priority: DefaultWorkspaceMiddlewarePriority - 1,
+		Func: func(e *core.RequestEvent) error {
+			e.Set(roleKey, role)
+			return e.Next()
+		},
+	}
+}
+
+func requireWorkspace(e *core.RequestEvent) error {
+	if e.Auth == nil {
+		return respond.Fail(e, http.StatusUnauthorized, "Authentication required")
+	}
+
+	workspaces, status, err := requestWorkspaces(e)
+	if err != nil {
+		return respond.Fail(e, status, err.Error())
+	}
+
+	min, ok := e.Get(roleKey).(members.Role)
+	if !ok {
+		min = members.Editor
+		if e.Request.Method == http.MethodGet || e.Request.Method == http.MethodHead {
+			min = members.Viewer
+		}
+	}
+	if !e.HasSuperuserAuth() {
+		for _, id := range workspaces {
+			role, err := members.RoleOf(id, e.Auth.Id)
+			if err != nil {
+				return respond.Fail(e, http.StatusInternalServerError, err.Error())
+			}
+			if role == "" {
+				return respond.Fail(e, http.StatusForbidden, "access to the workspace denied")
+			}
+			if !role.Includes(min) {
+				return respond.Fail(e, http.StatusForbidden, fmt.Sprintf("the %s role is required", min))
+			}
+		}
+	}
+
+	switch {
+	case len(workspaces) > 1:
+		return respond.Fail(e, http.StatusBadRequest, "the request refers to several workspaces")
+	case len(workspaces) == 1:
+		e.Set(workspaceKey, workspaces[0])
+	case e.Get(optionalKey) != true:
+		return respond.Fail(e, http.StatusBadRequest, "workspace required")
+	}
+	return e.Next()
+}
+
+// requestWorkspaces collects the distinct workspaces named by the request's
+// params and owning the records it refers to. Unknown referenced IDs are
+// skipped; there is nothing to reach through them.
+func requestWorkspaces(e *core.RequestEvent) ([]string, int, error) {
+	var workspaces []string
+	add := func(id string) {
+		if !slices.Contains(workspaces, id) {
+			workspaces = append(workspaces, id)
+		}
+	}
+
+	if resolve, ok := e.Get(resolverKey).(Resolver); ok {
+		id, found, err := resolve(e)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		if !found {
+			return nil, http.StatusNotFound, fmt.Errorf("not found")
+		}
+		if id != "" {
+			add(id)
+		}
+	}
+
+	info, err := e.RequestInfo()
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid request body")
+	}
+	param := func(name string) []string {
+		return append(values(info.Query[name]), values(info.Body[name])...)
+	}
+
+	for _, name := range workspaceParams {
+		for _, id := range param(name) {
+			add(id)
+		}
+	}
+	for name, collection := range References {
+		for _, id := range param(name) {
+			record, err := App.FindRecordById(collection, id)
+			if err != nil || record.GetString("workspace") == "" {
+				continue
+			}
+			add(record.GetString("workspace"))
+		}
+	}
+	return workspaces, 0, nil
+}
+
+// values flattens a query or body value into its non-empty strings
+func values(v any) []string {
+	var out []string
+	switch v := v.(type) {
+	case nil:
+	case string:
+		if v != "" {
+			out = append(out, v)
+		}
+	case []string:
+		for _, s := range v {
+			out = append(out, values(s)...)
+		}
+	case []any:
+		for _, s := range v {
+			out = append(out, values(s)...)
+		}
+	default:
+		out = append(out, fmt.Sprint(v))
+	}
+	return out
+}
@@ -1,6 +1,10 @@
 package domain
 
-import "time"
+import (
+	"time"
+
+	"lifehub/backend/internal/match"
+)
 
 // ItemType defines the categories of data LifeHub handles
 type ItemType string
@@ -38,6 +42,25 @@ type FinancialRecord struct {
 	Tags           []string  `json:"tags,omitempty"`
 	BalanceAfter   float64   `json:"balance_after,omitempty"`
 	ExternalID     string    `json:"external_id,omitempty"`
+	IsTransfer     bool      `json:"is_transfer,omitempty"`
+	TransferPairID string    `json:"transfer_pair_id,omitempty"`
+	// Account on the other side of the payment, e.g. "2400123456/2010"
+	CounterpartyAccount string `json:"counterparty_account,omitempty"`
+	// Set when Amount/Currency were converted to the workspace base currency
+	OriginalAmount   float64 `json:"original_amount,omitempty"`
+	OriginalCurrency string  `json:"original_currency,omitempty"`
+	// SplitID is set when the record is one split allocation of the transaction ID
+	SplitID        string    `json:"split_id,omitempty"`
+}
+
+// TransactionSplit allocates part of a transaction to its own category and merchant
+type TransactionSplit struct {
+	ID            string  `json:"id"`
+	TransactionID string  `json:"transaction_id"`
+	Amount        float64 `json:"amount"`
+	CategoryID    string  `json:"category_id,omitempty"`
+	MerchantID    string  `json:"merchant_id,omitempty"`
+	Note          string  `json:"note,omitempty"`
 }
 
 // Account represents a bank account or cash account
@@ -65,6 +88,18 @@ type Category struct {
 	IsSystem bool   `json:"is_system"`
 }
 
+// CategoryTotal is a node of the category tree with amounts rolled up from its descendants
+type CategoryTotal struct {
+	CategoryID string          `json:"category_id,omitempty"`
+	Name       string          `json:"name"`
+	Icon       string          `json:"icon,omitempty"`
+	Color      string          `json:"color,omitempty"`
+	ParentID   string          `json:"parent_id,omitempty"`
+	Amount     float64         `json:"amount"` // booked directly on this category
+	Total      float64         `json:"total"`  // including all descendants
+	Children   []CategoryTotal `json:"children,omitempty"`
+}
+
 // Merchant represents a payee/vendor
 type Merchant struct {
 	ID             string   `json:"id"`
@@ -107,15 +142,22 @@ type ImportRule struct {
 // BankTemplate defines a bank's CSV export format
 type BankTemplate struct {
 	ID                 string            `json:"id"`
+	WorkspaceID        string            `json:"workspace_id,omitempty"`
 	Name               string            `json:"name"`
 	Code               string            `json:"code"` // csob, fio, generic
+	Format             string            `json:"format,omitempty"` // csv (default), camt, ofx, qif
 	Delimiter          string            `json:"delimiter"`
 	Encoding           string            `json:"encoding"`
 	SkipRows           int               `json:"skip_rows"`
+	HeaderMarker       string            `json:"header_marker,omitempty"`
 	DateFormat         string            `json:"date_format"`
+	DecimalSeparator   string            `json:"decimal_separator"`
+	AmountNegativeIsExpense bool         `json:"amount_negative_is_expense"`
 	FieldMapping       map[string]int    `json:"field_mapping"`
 	CategoryMapping    map[string]string `json:"category_mapping,omitempty"`
 	MerchantExtraction map[string]string `json:"merchant_extraction,omitempty"`
+	StateColumn        int               `json:"state_column,omitempty"`
+	StateRequired      string            `json:"state_required,omitempty"`
 	IsSystem           bool              `json:"is_system"`
 }
 
@@ -129,6 +171,22 @@ type ImportResult struct {
 	Errors              []ImportError `json:"errors,omitempty"`
 }
 
+// FinanceImport is one recorded import batch (a single uploaded file)
+type FinanceImport struct {
+	ID                   string    `json:"id"`
+	WorkspaceID          string    `json:"workspace_id"`
+	AccountID            string    `json:"account_id,omitempty"`
+	SourceID             string    `json:"source_id,omitempty"`
+	Name                 string    `json:"name"` // uploaded file name
+	BankName             string    `json:"bank_name,omitempty"`
+	Template             string    `json:"template,omitempty"`
+	FileHash             string    `json:"file_hash"`
+	TransactionsImported int       `json:"transactions_imported"`
+	TransactionsSkipped  int       `json:"transactions_skipped"`
+	DuplicatesFound      int       `json:"duplicates_found"`
+	ImportedAt           time.Time `json:"imported_at"`
+}
+
 // ImportError represents an error during import
 type ImportError struct {
 	Row     int    `json:"row"`
@@ -155,12 +213,48 @@ type FinanceStats struct {
 	RecurringCount  int                `json:"recurring_count"`
 	TopMerchants    []MerchantSpend    `json:"top_merchants"`
 	AccountBalances []AccountBalance   `json:"account_balances,omitempty"`
+	CategoryTree    []CategoryTotal    `json:"category_tree"`
+	// Currency all totals are converted to; ByCurrency keeps the unconverted sums
+	Currency        string                    `json:"currency"`
+	ByCurrency      map[string]CurrencyTotals `json:"by_currency"`
+	MissingRates    []string                  `json:"missing_rates"`
+}
+
+// CurrencyTotals are income and expenses in one original currency
+type CurrencyTotals struct {
+	Income   float64 `json:"income"`
+	Expenses float64 `json:"expenses"`
 }
 
 // TrendPoint represents a point in time-series data
 type TrendPoint struct {
 	Date   string  `json:"date"`
 	Amount float64 `json:"amount"`
+	// Change against the previous bucket (month-over-month for monthly buckets)
+	Delta      float64 `json:"delta"`
+	DeltaPct   float64 `json:"delta_pct"`
+	RollingAvg float64 `json:"rolling_avg"`
+}
+
+// TrendSeries is the time series of one category, merchant or account
+type TrendSeries struct {
+	Key    string       `json:"key"`
+	Name   string       `json:"name"`
+	Total  float64      `json:"total"`
+	Points []TrendPoint `json:"points"`
+}
+
+// TrendReport holds bucketed totals per group over a date range
+type TrendReport struct {
+	Interval string        `json:"interval"` // day, week, month, year
+	GroupBy  string        `json:"group_by"` // category, merchant, account
+	Type     string        `json:"type"`     // expense, income
+	Currency string        `json:"currency"`
+	From     string        `json:"from"`
+	To       string        `json:"to"`
+	Series   []TrendSeries `json:"series"`
+	// Currencies without a rate, whose amounts are counted unconverted
+	MissingRates []string `json:"missing_rates"`
 }
 
 // MerchantSpend tracks spending by merchant
@@ -177,6 +271,7 @@ type AccountBalance struct {
 	AccountName string  `json:"account_name"`
 	Balance     float64 `json:"balance"`
 	Currency    string  `json:"currency"`
+	BalanceBase float64 `json:"balance_base"`
 }
 
 // IncomeSource represents a source of income (salary, freelance, etc.)
@@ -218,7 +313,10 @@ type BudgetItem struct {
 	Name             string  `json:"name"`
 	BudgetedAmount   float64 `json:"budgeted_amount"`
 	Currency         string  `json:"currency"`
-	Frequency        string  `json:"frequency"` // "monthly" or "yearly"
+	Frequency        string  `json:"frequency"`                // weekly, monthly, quarterly, semiannual, yearly, custom
+	FrequencyDays    int     `json:"frequency_days,omitempty"` // period of the custom frequency
+	DueMonth         int     `json:"due_month,omitempty"`      // 1-12, month a quarterly or longer amount is expected in
+	Rollover         string  `json:"rollover"`                 // none, surplus, deficit, both
 	MatchPattern     string  `json:"match_pattern,omitempty"`
 	MatchPatternType string  `json:"match_pattern_type,omitempty"` // contains, regex, exact
 	MatchField       string  `json:"match_field,omitempty"`        // description, raw_description, counterparty_account
@@ -226,9 +324,13 @@ type BudgetItem struct {
 	MatchMerchantID  string  `json:"match_merchant_id,omitempty"`
 	MatchAccountID   string  `json:"match_account_id,omitempty"`
 	IsExpense        bool    `json:"is_expense"`
+	Priority         int     `json:"priority"` // higher claims matching transactions first
 	SortOrder        int     `json:"sort_order"`
 	IsActive         bool    `json:"is_active"`
 	Notes            string  `json:"notes,omitempty"`
+
+	// Further conditions every matched transaction must meet
+	MatchExpression *match.Expr `json:"match_expression,omitempty"`
 }
 
 // BudgetItemStatus represents the computed status of a budget item against actual transactions
@@ -239,6 +341,25 @@ type BudgetItemStatus struct {
 	Difference          float64           `json:"difference"`
 	MatchedTransactions []FinancialRecord `json:"matched_transactions"`
 	Status              string            `json:"status"` // on_track, over_budget, under_budget, paid
+	// Envelope over the calendar months of the period: what the item opened
+	// with from earlier months, was assigned, spent and closes with
+	OpeningBalance float64 `json:"opening_balance"`
+	Assigned       float64 `json:"assigned"`
+	Spent          float64 `json:"spent"`
+	ClosingBalance float64 `json:"closing_balance"`
+}
+
+// BudgetLedgerEntry is one month of a budget item's envelope. The next month
+// opens with the part of ClosingBalance the item's rollover mode carries.
+type BudgetLedgerEntry struct {
+	ID             string  `json:"id"`
+	BudgetItemID   string  `json:"budget_item_id"`
+	Year           int     `json:"year"`
+	Month          int     `json:"month"`
+	OpeningBalance float64 `json:"opening_balance"`
+	Assigned       float64 `json:"assigned"`
+	Spent          float64 `json:"spent"`
+	ClosingBalance float64 `json:"closing_balance"`
 }
 
 // BudgetGroupStatus represents the computed status of an entire budget group
@@ -249,6 +370,42 @@ type BudgetGroupStatus struct {
 	TotalActual   float64            `json:"total_actual"`
 }
 
+// BudgetMonth compares what was budgeted for one calendar month, "2006-01",
+// with what was spent
+type BudgetMonth struct {
+	Month      string  `json:"month"`
+	Budgeted   float64 `json:"budgeted"`
+	Actual     float64 `json:"actual"`
+	Difference float64 `json:"difference"`
+}
+
+// BudgetItemHistory is a budget item month by month with its variance
+type BudgetItemHistory struct {
+	BudgetItem       BudgetItem    `json:"budget_item"`
+	Months           []BudgetMonth `json:"months"`
+	TotalBudgeted    float64       `json:"total_budgeted"`
+	TotalActual      float64       `json:"total_actual"`
+	AverageActual    float64       `json:"average_actual"`
+	MonthsOverBudget int           `json:"months_over_budget"`
+	// Mean of what was spent beyond the budget in the months over it
+	AverageOverspend float64 `json:"average_overspend"`
+}
+
+// BudgetGroupHistory is a budget group month by month
+type BudgetGroupHistory struct {
+	Budget Budget              `json:"budget"`
+	Months []BudgetMonth       `json:"months"`
+	Items  []BudgetItemHistory `json:"items"`
+}
+
+// BudgetHistory is the budget status of every calendar month of a range
+type BudgetHistory struct {
+	Months       []string             `json:"months"`
+	Budgets      []BudgetGroupHistory `json:"budgets"`
+	Currency     string               `json:"currency"`
+	MissingRates []string             `json:"missing_rates,omitempty"`
+}
+
 // IncomeSourceStatus represents computed income for a period
 type IncomeSourceStatus struct {
 	IncomeSource     IncomeSource `json:"income_source"`
@@ -256,6 +413,26 @@ type IncomeSourceStatus struct {
 	HoursThisMonth   float64      `json:"hours_this_month,omitempty"`
 }
 
+// BudgetConflict is a transaction matched by several budget items, listed
+// in priority order, and the items it was counted toward
+type BudgetConflict struct {
+	Transaction   FinancialRecord `json:"transaction"`
+	BudgetItemIDs []string        `json:"budget_item_ids"`
+	AssignedTo    []string        `json:"assigned_to"`
+}
+
+// BudgetAssignment ties a transaction, or one split allocation of it, to
+// the budget item it counts toward. Manual assignments override the match
+// rules; one without a budget item leaves the transaction out of budgets.
+type BudgetAssignment struct {
+	ID            string  `json:"id"`
+	TransactionID string  `json:"transaction_id"`
+	SplitID       string  `json:"split_id,omitempty"`
+	BudgetItemID  string  `json:"budget_item_id"`
+	Amount        float64 `json:"amount"`
+	Manual        bool    `json:"manual"`
+}
+
 // BudgetSummary is the top-level budget status response
 type BudgetSummary struct {
 	TotalIncome       float64              `json:"total_income"`
@@ -265,6 +442,12 @@ type BudgetSummary struct {
 	TotalActual       float64              `json:"total_actual"`
 	Remaining         float64              `json:"remaining"`
 	UnmatchedExpenses []FinancialRecord    `json:"unmatched_expenses"`
+	// Transactions matched by several items and whom they were assigned to
+	Conflicts []BudgetConflict `json:"conflicts"`
+	MatchMode string           `json:"match_mode"`
+	// Currency all amounts are converted to; MissingRates lists currencies left unconverted
+	Currency          string               `json:"currency"`
+	MissingRates      []string             `json:"missing_rates,omitempty"`
 }
 
 // CalendarEvent represents a calendar event (Google Calendar, Outlook, etc.)
@@ -296,3 +479,14 @@ type Result struct {
 	SourceName string      `json:"source_name"`
 	Items      interface{} `json:"items"` // Will be []Task, []FinancialRecord, etc.
 }
+
+// WorkspaceMember is a user sharing a workspace, or a pending invitation
+type WorkspaceMember struct {
+	ID          string `json:"id"`
+	WorkspaceID string `json:"workspace_id"`
+	UserID      string `json:"user_id,omitempty"` // empty while the invitation is pending
+	Email       string `json:"email,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Role        string `json:"role"`
+	Pending     bool   `json:"pending"`
+}
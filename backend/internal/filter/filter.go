@@ -0,0 +1,106 @@
+// Package filter builds PocketBase filter expressions whose values are bound
+// as parameters, so request input never becomes part of the filter itself.
+package filter
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Filter is a conjunction of conditions together with their bound values
+type Filter struct {
+	conds  []string
+	params dbx.Params
+}
+
+// New returns an empty filter, which matches every record
+func New() *Filter {
+	return &Filter{params: dbx.Params{}}
+}
+
+// Eq returns a filter matching field = value
+func Eq(field string, value any) *Filter {
+	return New().Eq(field, value)
+}
+
+// bind stores value under a fresh placeholder and returns the placeholder
+func (f *Filter) bind(value any) string {
+	key := "p" + strconv.Itoa(len(f.params))
+	f.params[key] = value
+	return "{:" + key + "}"
+}
+
+// compare adds field op value. PocketBase does not match a bound "" against
+// empty fields, so empty strings are written as a literal instead.
+func (f *Filter) compare(field, op string, value any) *Filter {
+	if value == "" {
+		f.conds = append(f.conds, field+" "+op+" ''")
+		return f
+	}
+	f.conds = append(f.conds, field+" "+op+" "+f.bind(value))
+	return f
+}
+
+// Eq adds field = value
+func (f *Filter) Eq(field string, value any) *Filter { return f.compare(field, "=", value) }
+
+// Neq adds field != value
+func (f *Filter) Neq(field string, value any) *Filter { return f.compare(field, "!=", value) }
+
+// Gte adds field >= value
+func (f *Filter) Gte(field string, value any) *Filter { return f.compare(field, ">=", value) }
+
+// Lte adds field <= value
+func (f *Filter) Lte(field string, value any) *Filter { return f.compare(field, "<=", value) }
+
+// EqIf adds field = value unless value is empty, for optional query params
+func (f *Filter) EqIf(field, value string) *Filter {
+	if value == "" {
+		return f
+	}
+	return f.Eq(field, value)
+}
+
+// In adds a match of field against any of values. No values match nothing.
+func (f *Filter) In(field string, values ...string) *Filter {
+	if len(values) == 0 {
+		f.conds = append(f.conds, "id = '' && id != ''")
+		return f
+	}
+	terms := make([]string, len(values))
+	for i, v := range values {
+		terms[i] = field + " = " + f.bind(v)
+	}
+	f.conds = append(f.conds, "("+strings.Join(terms, " || ")+")")
+	return f
+}
+
+// Where adds a fixed expression such as "is_active = true". It must not
+// contain request values; use the comparison methods for those.
+func (f *Filter) Where(expr string) *Filter {
+	f.conds = append(f.conds, "("+expr+")")
+	return f
+}
+
+// String returns the filter expression with placeholders for the values
+func (f *Filter) String() string {
+	return strings.Join(f.conds, " && ")
+}
+
+// Params returns the values bound to the expression's placeholders
+func (f *Filter) Params() dbx.Params {
+	return f.params
+}
+
+// Find runs the filter against a collection
+func (f *Filter) Find(app core.App, collection, sort string, limit, offset int) ([]*core.Record, error) {
+	return app.FindRecordsByFilter(collection, f.String(), sort, limit, offset, f.params)
+}
+
+// First returns the first record matching the filter
+func (f *Filter) First(app core.App, collection string) (*core.Record, error) {
+	return app.FindFirstRecordByFilter(collection, f.String(), f.params)
+}
@@ -0,0 +1,40 @@
+package filter
+
+import (
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter *Filter
+		want   string
+		params int
+	}{
+		{"empty", New(), "", 0},
+		{"eq", Eq("workspace", "' || 1=1 || '"), "workspace = {:p0}", 1},
+		{"chain", Eq("workspace", "w").Gte("date", "2025-01-01").Lte("date", "2025-01-31").Neq("type", "x"),
+			"workspace = {:p0} && date >= {:p1} && date <= {:p2} && type != {:p3}", 4},
+		{"empty value", Eq("workspace", "w").Eq("user", "").Neq("email", ""), "workspace = {:p0} && user = '' && email != ''", 1},
+		{"eqif empty", Eq("workspace", "w").EqIf("account", ""), "workspace = {:p0}", 1},
+		{"eqif", Eq("workspace", "w").EqIf("account", "a"), "workspace = {:p0} && account = {:p1}", 2},
+		{"in", New().In("workspace", "a", "b"), "(workspace = {:p0} || workspace = {:p1})", 2},
+		{"in none", New().In("workspace"), "id = '' && id != ''", 0},
+		{"where", Eq("workspace", "w").Where("a = 1 || b = 2"), "workspace = {:p0} && (a = 1 || b = 2)", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+			if got := len(tt.filter.Params()); got != tt.params {
+				t.Errorf("len(Params()) = %d, want %d", got, tt.params)
+			}
+		})
+	}
+
+	f := Eq("workspace", "' || 1=1 || '")
+	if got := f.Params()["p0"]; got != "' || 1=1 || '" {
+		t.Errorf("Params()[p0] = %v, want the raw value", got)
+	}
+}
@@ -0,0 +1,308 @@
+// Package apitest serves the custom API routes from a throwaway PocketBase
+// app with a seeded demo workspace, for the httptest-based route tests.
+package apitest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"lifehub/backend/internal/http/server"
+	"lifehub/backend/internal/services/budget"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// IDs of the records seeded into the demo workspace
+const (
+	Workspace  = "demowsxxxxxxxxx"
+	Account    = "demoaccountxxxx"
+	Category   = "democategoryxxx"
+	Merchant   = "demomerchantxxx"
+	Recurring  = "demorecurringxx"
+	Income     = "demoincomexxxxx"
+	Hours      = "demohoursxxxxxx"
+	Budget     = "demobudgetxxxxx"
+	BudgetItem = "demobudgetitemx"
+	Portfolio  = "demoportfolioxx"
+	Template   = "demotemplatexxx"
+	Import     = "demoimportxxxxx"
+	Snapshot   = "demosnapshotxxx"
+	OwnerEmail = "owner@example.com"
+)
+
+// Transaction returns the ID of the i-th of the 20 seeded transactions
+func Transaction(i int) string {
+	return fmt.Sprintf("demotx%09d", i)
+}
+
+// schema lists the columns the custom routes read, by collection. Relations
+// are plain text columns here; the filters compare them the same way.
+var schema = map[string]string{
+	"workspaces":                 "name slug owner base_currency budget_match_mode",
+	"workspace_members":          "workspace user email role invited_by",
+	"sources":                    "workspace name type active:b config:j",
+	"finance_accounts":           "workspace name bank_name account_number currency account_type icon color initial_balance:n is_active:b",
+	"finance_categories":         "workspace name icon color parent is_system:b",
+	"finance_merchants":          "workspace name display_name patterns:j category is_subscription:b",
+	"finance_transactions":       "workspace account source type amount:n date:d description raw_description category category_rel merchant external_id counterparty_account variable_symbol balance_after:n import_ref tags:j merged_external_ids:j is_transfer:b transfer_pair",
+	"finance_transaction_splits": "transaction workspace amount:n category merchant note sort_order:n",
+	"finance_recurring":          "workspace merchant account expected_amount:n frequency frequency_days:n next_due:d last_paid:d status notes",
+	"finance_import_rules":       "workspace name pattern pattern_type match_field match_expression:j category merchant priority:n active:b",
+	"finance_imports":            "workspace account source name bank_name template file_hash transactions_imported:n transactions_skipped:n duplicates_found:n imported_at:d",
+	"finance_bank_templates":     "workspace name code format delimiter encoding date_format skip_rows:n header_marker decimal_separator amount_negative_is_expense:b state_column:n state_required field_mapping:j category_mapping:j merchant_extraction:j is_system:b",
+	"finance_exchange_rates":     "base_currency target_currency rate:n date:d",
+	"finance_income_sources":     "workspace name income_type amount:n currency default_hours:n is_active:b notes",
+	"finance_income_hours":       "workspace income_source year:n month:n hours:n",
+	"finance_budgets":            "workspace name icon color sort_order:n is_active:b",
+	"finance_budget_items":       "workspace budget name budgeted_amount:n currency frequency frequency_days:n due_month:n rollover match_pattern match_pattern_type match_field match_category match_merchant match_account match_expression:j is_expense:b priority:n sort_order:n is_active:b notes",
+	"finance_budget_assignments": "workspace transaction split budget_item amount:n manual:b",
+	"finance_budget_ledger":      "workspace budget_item year:n month:n opening:n assigned:n spent:n closing:n",
+	"finance_loans":              "workspace name current_balance:n monthly_payment:n is_active:b",
+	"investment_portfolios":      "workspace provider name contract_id currency",
+	"investment_snapshots":       "workspace portfolio report_date:d period_start:d period_end:d start_value:n end_value:n invested:n gain_loss:n fees:n",
+	"investment_holdings":        "workspace snapshot name isin category units:n price_per_unit:n price_currency total_value:n value_currency",
+}
+
+// Row is a record inserted as is, bypassing validation and hooks
+type Row struct {
+	Table string
+	Data  dbx.Params
+}
+
+// NewServer creates the collections, seeds the demo workspace and returns
+// the app with a handler serving the custom routes
+func NewServer(t testing.TB) (*pocketbase.PocketBase, http.Handler) {
+	t.Helper()
+	app := pocketbase.NewWithConfig(pocketbase.Config{DefaultDataDir: t.TempDir()})
+	if err := app.Bootstrap(); err != nil {
+		t.Fatalf("Bootstrap() error = %v", err)
+	}
+	t.Cleanup(func() { app.ResetBootstrapState() })
+
+	for name, columns := range schema {
+		c := core.NewBaseCollection(name)
+		for _, column := range strings.Fields(columns) {
+			name, kind, _ := strings.Cut(column, ":")
+			switch kind {
+			case "n":
+				c.Fields.Add(&core.NumberField{Name: name})
+			case "b":
+				c.Fields.Add(&core.BoolField{Name: name})
+			case "d":
+				c.Fields.Add(&core.DateField{Name: name})
+			case "j":
+				c.Fields.Add(&core.JSONField{Name: name})
+			default:
+				c.Fields.Add(&core.TextField{Name: name})
+			}
+		}
+		if err := app.Save(c); err != nil {
+			t.Fatalf("failed to create %s: %v", name, err)
+		}
+	}
+	seedDemo(t, app)
+
+	server.Register(app)
+	// Budget changes left pending would otherwise be refreshed in the app of
+	// the next test, which reuses the same IDs
+	t.Cleanup(func() {
+		if err := budget.Flush(); err != nil {
+			t.Errorf("failed to refresh budgets: %v", err)
+		}
+	})
+	router, err := apis.NewRouter(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mux http.Handler
+	err = app.OnServe().Trigger(&core.ServeEvent{App: app, Router: router}, func(e *core.ServeEvent) error {
+		mux, err = e.Router.BuildMux()
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return app, mux
+}
+
+// Insert adds rows in one transaction
+func Insert(t testing.TB, app core.App, rows ...Row) {
+	t.Helper()
+	err := app.RunInTransaction(func(txApp core.App) error {
+		for _, r := range rows {
+			if _, err := txApp.DB().Insert(r.Table, r.Data).Execute(); err != nil {
+				return fmt.Errorf("%s: %w", r.Table, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+}
+
+// NewUser creates a verified user and returns it with an auth token
+func NewUser(t testing.TB, app core.App, email string) (*core.Record, string) {
+	t.Helper()
+	users, err := app.FindCollectionByNameOrId("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := core.NewRecord(users)
+	user.SetEmail(email)
+	user.SetPassword("password123456")
+	user.SetVerified(true)
+	if err := app.Save(user); err != nil {
+		t.Fatalf("failed to create %s: %v", email, err)
+	}
+	token, err := user.NewAuthToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user, token
+}
+
+// OwnerToken returns an auth token of the demo workspace owner
+func OwnerToken(t testing.TB, app core.App) string {
+	t.Helper()
+	owner, err := app.FindAuthRecordByEmail("users", OwnerEmail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := owner.NewAuthToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// SuperuserToken creates a superuser and returns its auth token
+func SuperuserToken(t testing.TB, app core.App) string {
+	t.Helper()
+	superusers, err := app.FindCollectionByNameOrId(core.CollectionNameSuperusers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	superuser := core.NewRecord(superusers)
+	superuser.SetEmail("admin@example.com")
+	superuser.SetPassword("password123456")
+	if err := app.Save(superuser); err != nil {
+		t.Fatalf("failed to create superuser: %v", err)
+	}
+	token, err := superuser.NewAuthToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// seedDemo inserts one record of each kind into the demo workspace, owned
+// by OwnerEmail
+func seedDemo(t testing.TB, app core.App) {
+	t.Helper()
+	owner, _ := NewUser(t, app, OwnerEmail)
+	now := time.Now().UTC()
+	day := func(d time.Time) string { return d.Format("2006-01-02") + " 00:00:00.000Z" }
+	rows := []Row{
+		{"workspaces", dbx.Params{"id": Workspace, "name": "Demo", "slug": "demo", "owner": owner.Id, "base_currency": "CZK"}},
+		{"finance_accounts", dbx.Params{"id": Account, "workspace": Workspace, "name": "Checking", "currency": "CZK", "is_active": true}},
+		{"finance_categories", dbx.Params{"id": Category, "workspace": Workspace, "name": "Groceries"}},
+		{"finance_merchants", dbx.Params{"id": Merchant, "workspace": Workspace, "name": "Shop", "patterns": `["shop"]`, "category": Category}},
+		{"finance_import_rules", dbx.Params{"id": "demorulexxxxxxx", "workspace": Workspace, "name": "Shop", "pattern": "shop", "pattern_type": "contains", "category": Category, "active": true}},
+		{"finance_recurring", dbx.Params{"id": Recurring, "workspace": Workspace, "merchant": Merchant, "account": Account, "expected_amount": 100, "frequency": "monthly", "next_due": day(now.AddDate(0, 0, 3)), "status": "active"}},
+		{"finance_income_sources", dbx.Params{"id": Income, "workspace": Workspace, "name": "Salary", "income_type": "fixed", "amount": 50000, "currency": "CZK", "is_active": true}},
+		{"finance_income_hours", dbx.Params{"id": Hours, "workspace": Workspace, "income_source": Income, "year": now.Year(), "month": int(now.Month()), "hours": 160}},
+		{"finance_budgets", dbx.Params{"id": Budget, "workspace": Workspace, "name": "Living", "is_active": true}},
+		{"finance_budget_items", dbx.Params{"id": BudgetItem, "workspace": Workspace, "budget": Budget, "name": "Food", "budgeted_amount": 5000, "frequency": "monthly", "match_category": Category, "is_expense": true, "is_active": true}},
+		{"investment_portfolios", dbx.Params{"id": Portfolio, "workspace": Workspace, "provider": "conseq", "name": "Pension", "currency": "CZK"}},
+		{"finance_bank_templates", dbx.Params{"id": Template, "workspace": Workspace, "name": "My bank", "code": "mybank"}},
+		{"finance_imports", dbx.Params{"id": Import, "workspace": Workspace, "account": Account, "name": "statement.csv"}},
+		{"investment_snapshots", dbx.Params{"id": Snapshot, "workspace": Workspace, "portfolio": Portfolio, "report_date": day(now), "end_value": 1000}},
+	}
+	for i := 0; i < 20; i++ {
+		rows = append(rows, Row{"finance_transactions", dbx.Params{
+			"id":          Transaction(i),
+			"workspace":   Workspace,
+			"account":     Account,
+			"type":        "expense",
+			"amount":      100 + i,
+			"date":        day(now.AddDate(0, -i%3, -i)),
+			"description": "SHOP",
+			"category":    "Groceries",
+			"merchant":    Merchant,
+		}})
+	}
+	Insert(t, app, rows...)
+}
+
+// Request serves a request with an optional auth token and a JSON or
+// url-encoded form body
+func Request(t testing.TB, h http.Handler, method, target, token, body string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	switch {
+	case strings.HasPrefix(body, "{"), strings.HasPrefix(body, "["):
+		req.Header.Set("Content-Type", "application/json")
+	case body != "":
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	return serve(h, req, token)
+}
+
+// Expect serves a request like Request and fails the test unless it
+// responds with the status want. It returns the response body.
+func Expect(t testing.TB, h http.Handler, method, target, token, body string, want int) string {
+	t.Helper()
+	code, resp := Request(t, h, method, target, token, body)
+	if code != want {
+		t.Fatalf("%s %s: status %d, want %d: %s", method, target, code, want, resp)
+	}
+	return resp
+}
+
+// Upload serves a multipart request with the form fields and, unless nil,
+// file as the "file" part
+func Upload(t testing.TB, h http.Handler, target, token string, fields map[string]string, fileName string, file []byte) (int, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		if err := w.WriteField(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if file != nil {
+		part, err := w.CreateFormFile("file", fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(file)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, target, &buf)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return serve(h, req, token)
+}
+
+func serve(h http.Handler, req *http.Request, token string) (int, string) {
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	resp, _ := io.ReadAll(rec.Result().Body)
+	return rec.Code, string(resp)
+}
@@ -0,0 +1,129 @@
+// Package eink serves /api/eink/relevant, the aggregated source data shown
+// on e-ink displays and the web dashboard.
+package eink
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"lifehub/backend/internal/access"
+	"lifehub/backend/internal/domain"
+	"lifehub/backend/internal/filter"
+	"lifehub/backend/internal/http/respond"
+	"lifehub/backend/internal/services/members"
+	"lifehub/backend/internal/sources"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+// relevantResponse holds the data of every active source the caller may see
+type relevantResponse struct {
+	Status string          `json:"status"`
+	Data   []domain.Result `json:"data"`
+}
+
+// Register binds the e-ink route. It authorizes users and device tokens
+// itself instead of going through access.RequireWorkspace.
+func Register(r *router.Router[*core.RequestEvent]) {
+	r.GET("/api/eink/relevant", relevant)
+}
+
+// relevant authenticates a user session (web dashboard) or a device token
+// and fetches the active sources of the workspaces it may read
+func relevant(e *core.RequestEvent) error {
+	token := e.Request.URL.Query().Get("token")
+	workspaceID := e.Request.URL.Query().Get("workspace")
+
+	var allowedWorkspaces []string
+	var permsMap map[string]any
+
+	switch {
+	case e.Auth != nil:
+		if workspaceID != "" {
+			ok, err := access.Allowed(workspaceID, e.Auth.Id, members.Device)
+			if err != nil {
+				return respond.Internal(e, err)
+			}
+			if !ok && !e.HasSuperuserAuth() {
+				return respond.Fail(e, http.StatusForbidden, "access to the workspace denied")
+			}
+			allowedWorkspaces = []string{workspaceID}
+		} else if e.HasSuperuserAuth() {
+			records, _ := e.App.FindRecordsByFilter("workspaces", "", "name", 0, 0)
+			for _, r := range records {
+				allowedWorkspaces = append(allowedWorkspaces, r.Id)
+			}
+		} else {
+			allowedWorkspaces, _ = members.Workspaces(e.Auth.Id, members.Device)
+		}
+		permsMap = map[string]any{}
+	case token != "":
+		device, err := e.App.FindRecordById("devices", token)
+		if err != nil {
+			device, err = e.App.FindFirstRecordByData("devices", "token", token)
+			if err != nil {
+				return respond.Fail(e, http.StatusUnauthorized, "Invalid device token")
+			}
+		}
+		allowedWorkspaces = device.GetStringSlice("allowed_workspaces")
+		permsMap, _ = device.Get("permissions").(map[string]any)
+
+		device.Set("last_active", "now")
+		e.App.Save(device)
+	default:
+		return respond.Fail(e, http.StatusUnauthorized, "Authentication required")
+	}
+
+	if len(allowedWorkspaces) == 0 {
+		return e.JSON(http.StatusOK, relevantResponse{Status: "ok", Data: []domain.Result{}})
+	}
+
+	records, err := filter.New().Where("active = true").In("workspace", allowedWorkspaces...).Find(e.App, "sources", "name", 0, 0)
+	if err != nil {
+		log.Printf("Error fetching sources: %v", err)
+		return err
+	}
+
+	log.Printf("Found %d active sources for workspaces %v", len(records), allowedWorkspaces)
+
+	allData := []domain.Result{}
+	for _, record := range records {
+		sourceType := record.GetString("type")
+		log.Printf("Processing source: %s (type: %s)", record.GetString("name"), sourceType)
+
+		allowedOps := []sources.Operation{sources.OpRead, sources.OpMask} // Default for web
+
+		if perms, ok := permsMap[sourceType].(map[string]any); ok {
+			if enabled, exists := perms["enabled"].(bool); exists && !enabled {
+				continue
+			}
+			allowedOps = []sources.Operation{}
+			if canRead, _ := perms["can_read"].(bool); canRead {
+				allowedOps = append(allowedOps, sources.OpRead)
+			}
+			if showFinance, _ := perms["show_finance_amounts"].(bool); showFinance {
+				allowedOps = append(allowedOps, sources.OpMask)
+			}
+		}
+
+		configMap, _ := record.Get("config").(map[string]any)
+		typedCfg := sources.SourceConfig{
+			SourceID:    record.Id,
+			WorkspaceID: record.GetString("workspace"),
+			RawConfig:   configMap,
+		}
+
+		if factory, ok := sources.Registry[sourceType]; ok {
+			payload, err := factory().FetchTypedData(context.Background(), typedCfg, allowedOps)
+			if err == nil {
+				// Override the default source name with the custom name from DB
+				payload.SourceName = record.GetString("name")
+				allData = append(allData, payload)
+			}
+		}
+	}
+
+	return e.JSON(http.StatusOK, relevantResponse{Status: "ok", Data: allData})
+}
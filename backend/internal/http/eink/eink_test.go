@@ -0,0 +1,35 @@
+package eink_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"lifehub/backend/internal/http/apitest"
+)
+
+func TestRelevant(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	owner := apitest.OwnerToken(t, app)
+	_, stranger := apitest.NewUser(t, app, "stranger@example.com")
+
+	tests := []struct {
+		name, target, token string
+		want                int
+		contains            string
+	}{
+		{"owner", "/api/eink/relevant", owner, http.StatusOK, `"status":"ok","data":[]`},
+		{"owner workspace", "/api/eink/relevant?workspace=" + apitest.Workspace, owner, http.StatusOK, `"status":"ok"`},
+		{"stranger", "/api/eink/relevant?workspace=" + apitest.Workspace, stranger, http.StatusForbidden, `"error":"access to the workspace denied"`},
+		{"anonymous", "/api/eink/relevant", "", http.StatusUnauthorized, `"error":"Authentication required"`},
+		{"bad device token", "/api/eink/relevant?token=nope", "", http.StatusUnauthorized, `"error":"Invalid device token"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := apitest.Request(t, h, "GET", tt.target, tt.token, "")
+			if code != tt.want || !strings.Contains(body, tt.contains) {
+				t.Errorf("status %d: %.300s, want %d with %s", code, body, tt.want, tt.contains)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package finance
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"lifehub/backend/internal/domain"
+	"lifehub/backend/internal/filter"
+	"lifehub/backend/internal/http/respond"
+	"lifehub/backend/internal/services/stats"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// accountRequest is the body of POST /accounts
+type accountRequest struct {
+	Workspace      string  `json:"workspace"`
+	Name           string  `json:"name"`
+	BankName       string  `json:"bank_name"`
+	AccountNumber  string  `json:"account_number"`
+	Currency       string  `json:"currency"`
+	AccountType    string  `json:"account_type"`
+	Icon           string  `json:"icon"`
+	Color          string  `json:"color"`
+	InitialBalance float64 `json:"initial_balance"`
+	IsActive       bool    `json:"is_active"`
+}
+
+// categoryRequest is the body of POST /categories
+type categoryRequest struct {
+	Workspace string `json:"workspace"`
+	Name      string `json:"name"`
+	Icon      string `json:"icon"`
+	Color     string `json:"color"`
+	ParentID  string `json:"parent_id"`
+}
+
+func listAccounts(e *core.RequestEvent) error {
+	workspaceID := e.Request.URL.Query().Get("workspace")
+	if workspaceID == "" {
+		return respond.BadRequest(e, "workspace required")
+	}
+
+	records, err := filter.Eq("workspace", workspaceID).Find(e.App, "finance_accounts", "name", 100, 0)
+	if err != nil {
+		return e.JSON(http.StatusOK, []domain.Account{})
+	}
+	balances, _ := stats.Balances(workspaceID)
+
+	accounts := []domain.Account{}
+	for _, r := range records {
+		accounts = append(accounts, domain.Account{
+			ID:             r.Id,
+			Name:           r.GetString("name"),
+			BankName:       r.GetString("bank_name"),
+			AccountNumber:  r.GetString("account_number"),
+			Currency:       r.GetString("currency"),
+			AccountType:    r.GetString("account_type"),
+			Icon:           r.GetString("icon"),
+			Color:          r.GetString("color"),
+			InitialBalance: r.GetFloat("initial_balance"),
+			CurrentBalance: r.GetFloat("initial_balance") + balances[r.Id],
+			IsActive:       r.GetBool("is_active"),
+		})
+	}
+	return e.JSON(http.StatusOK, accounts)
+}
+
+func createAccount(e *core.RequestEvent) error {
+	var body accountRequest
+	if err := json.NewDecoder(e.Request.Body).Decode(&body); err != nil {
+		return respond.InvalidJSON(e)
+	}
+	if body.Name == "" {
+		return respond.BadRequest(e, "name required")
+	}
+
+	return create(e, "finance_accounts", func(r *core.Record) {
+		r.Set("workspace", body.Workspace)
+		r.Set("name", body.Name)
+		r.Set("bank_name", body.BankName)
+		r.Set("account_number", body.AccountNumber)
+		r.Set("currency", body.Currency)
+		r.Set("account_type", body.AccountType)
+		r.Set("icon", body.Icon)
+		r.Set("color", body.Color)
+		r.Set("initial_balance", body.InitialBalance)
+		r.Set("is_active", body.IsActive)
+	})
+}
+
+func listCategories(e *core.RequestEvent) error {
+	workspaceID := e.Request.URL.Query().Get("workspace")
+	if workspaceID == "" {
+		return respond.BadRequest(e, "workspace required")
+	}
+
+	records, err := filter.Eq("workspace", workspaceID).Find(e.App, "finance_categories", "name", 100, 0)
+	if err != nil {
+		return e.JSON(http.StatusOK, []domain.Category{})
+	}
+
+	categories := []domain.Category{}
+	for _, r := range records {
+		categories = append(categories, domain.Category{
+			ID:       r.Id,
+			Name:     r.GetString("name"),
+			Icon:     r.GetString("icon"),
+			Color:    r.GetString("color"),
+			ParentID: r.GetString("parent"),
+			IsSystem: r.GetBool("is_system"),
+		})
+	}
+	return e.JSON(http.StatusOK, categories)
+}
+
+func createCategory(e *core.RequestEvent) error {
+	var body categoryRequest
+	if err := json.NewDecoder(e.Request.Body).Decode(&body); err != nil {
+		return respond.InvalidJSON(e)
+	}
+	if body.Name == "" {
+		return respond.BadRequest(e, "name required")
+	}
+
+	return create(e, "finance_categories", func(r *core.Record) {
+		r.Set("workspace", body.Workspace)
+		r.Set("name", body.Name)
+		r.Set("icon", body.Icon)
+		r.Set("color", body.Color)
+		r.Set("parent", body.ParentID)
+	})
+}
+
+func listMerchants(e *core.RequestEvent) error {
+	workspaceID := e.Request.URL.Query().Get("workspace")
+	if workspaceID == "" {
+		return respond.BadRequest(e, "workspace required")
+	}
+
+	records, err := filter.Eq("workspace", workspaceID).Find(e.App, "finance_merchants", "name", 200, 0)
+	if err != nil {
+		return e.JSON(http.StatusOK, []domain.Merchant{})
+	}
+
+	merchants := []domain.Merchant{}
+	for _, r := range records {
+		m := domain.Merchant{
+			ID:             r.Id,
+			Name:           r.GetString("name"),
+			DisplayName:    r.GetString("display_name"),
+			CategoryID:     r.GetString("category"),
+			IsSubscription: r.GetBool("is_subscription"),
+		}
+		_ = r.UnmarshalJSONField("patterns", &m.Patterns)
+		merchants = append(merchants, m)
+	}
+	return e.JSON(http.StatusOK, merchants)
+}
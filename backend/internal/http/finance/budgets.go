@@ -0,0 +1,382 @@
+package finance
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"lifehub/backend/internal/domain"
+	"lifehub/backend/internal/filter"
+	"lifehub/backend/internal/http/respond"
+	"lifehub/backend/internal/match"
+	"lifehub/backend/internal/services/budget"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// budgetFields are the editable fields of a budget. Fields left out of an
+// update keep their value.
+type budgetFields struct {
+	Name      *string `json:"name"`
+	Icon      *string `json:"icon"`
+	Color     *string `json:"color"`
+	SortOrder *int    `json:"sort_order"`
+	IsActive  *bool   `json:"is_active"`
+}
+
+// budgetRequest is the body of POST /budgets
+type budgetRequest struct {
+	Workspace string `json:"workspace"`
+	budgetFields
+}
+
+// budgetItemFields are the editable fields of a budget item. Fields left
+// out of an update keep their value.
+type budgetItemFields struct {
+	Budget           *string  `json:"budget"`
+	Name             *string  `json:"name"`
+	BudgetedAmount   *float64 `json:"budgeted_amount"`
+	Currency         *string  `json:"currency"`
+	Frequency        *string  `json:"frequency"`
+	FrequencyDays    *int     `json:"frequency_days"`
+	DueMonth         *int     `json:"due_month"`
+	Rollover         *string  `json:"rollover"`
+	MatchPattern     *string  `json:"match_pattern"`
+	MatchPatternType *string  `json:"match_pattern_type"`
+	MatchField       *string  `json:"match_field"`
+	MatchCategory    *string  `json:"match_category"`
+	MatchMerchant    *string  `json:"match_merchant"`
+	MatchAccount     *string  `json:"match_account"`
+	IsExpense        *bool    `json:"is_expense"`
+	Priority         *int     `json:"priority"`
+	SortOrder        *int     `json:"sort_order"`
+	IsActive         *bool    `json:"is_active"`
+	Notes            *string  `json:"notes"`
+
+	// Parsed by match.Parse; null removes the expression
+	MatchExpression json.RawMessage `json:"match_expression"`
+}
+
+// budgetItemRequest is the body of POST /budget-items
+type budgetItemRequest struct {
+	Workspace string `json:"workspace"`
+	budgetItemFields
+}
+
+// assignmentRequest is the body of PUT /transactions/{id}/budget-item. An
+// empty budget_item leaves the transaction out of budgets.
+type assignmentRequest struct {
+	BudgetItem *string `json:"budget_item"`
+	SplitID    string  `json:"split_id"`
+}
+
+// budgetResponse is a budget with its items, listed even when empty
+type budgetResponse struct {
+	domain.Budget
+	Items []domain.BudgetItem `json:"items"`
+}
+
+func (f budgetFields) apply(r *core.Record) {
+	set(r, "name", f.Name)
+	set(r, "icon", f.Icon)
+	set(r, "color", f.Color)
+	set(r, "sort_order", f.SortOrder)
+	set(r, "is_active", f.IsActive)
+}
+
+func (f budgetItemFields) validate() string {
+	if f.Name != nil && *f.Name == "" {
+		return "name required"
+	}
+	if f.BudgetedAmount != nil && *f.BudgetedAmount < 0 {
+		return "budgeted_amount must not be negative"
+	}
+	if f.Frequency != nil && !slices.Contains(budget.Frequencies, *f.Frequency) {
+		return fmt.Sprintf("frequency must be one of: %s", strings.Join(budget.Frequencies, ", "))
+	}
+	if f.FrequencyDays != nil && *f.FrequencyDays < 1 {
+		return "frequency_days must be at least 1"
+	}
+	if f.DueMonth != nil && (*f.DueMonth < 0 || *f.DueMonth > 12) {
+		return "due_month must be between 1 and 12, or 0 for none"
+	}
+	if f.Rollover != nil && !slices.Contains(budget.RolloverModes, *f.Rollover) {
+		return fmt.Sprintf("rollover must be one of: %s", strings.Join(budget.RolloverModes, ", "))
+	}
+	if f.MatchPatternType != nil {
+		switch *f.MatchPatternType {
+		case "", "contains", "exact":
+		case "regex":
+			if f.MatchPattern != nil {
+				if _, err := regexp.Compile(*f.MatchPattern); err != nil {
+					return "invalid match_pattern: " + err.Error()
+				}
+			}
+		default:
+			return "match_pattern_type must be one of: contains, exact, regex"
+		}
+	}
+	if _, err := match.Parse(f.MatchExpression); err != nil {
+		return "invalid match_expression: " + err.Error()
+	}
+	return ""
+}
+
+func (f budgetItemFields) apply(r *core.Record) {
+	set(r, "budget", f.Budget)
+	set(r, "name", f.Name)
+	set(r, "budgeted_amount", f.BudgetedAmount)
+	set(r, "currency", f.Currency)
+	set(r, "frequency", f.Frequency)
+	set(r, "frequency_days", f.FrequencyDays)
+	set(r, "due_month", f.DueMonth)
+	set(r, "rollover", f.Rollover)
+	set(r, "match_pattern", f.MatchPattern)
+	set(r, "match_pattern_type", f.MatchPatternType)
+	set(r, "match_field", f.MatchField)
+	set(r, "match_category", f.MatchCategory)
+	set(r, "match_merchant", f.MatchMerchant)
+	set(r, "match_account", f.MatchAccount)
+	if f.MatchExpression != nil {
+		expr, _ := match.Parse(f.MatchExpression)
+		r.Set("match_expression", expr)
+	}
+	set(r, "is_expense", f.IsExpense)
+	set(r, "priority", f.Priority)
+	set(r, "sort_order", f.SortOrder)
+	set(r, "is_active", f.IsActive)
+	set(r, "notes", f.Notes)
+}
+
+func listBudgets(e *core.RequestEvent) error {
+	workspaceID := e.Request.URL.Query().Get("workspace")
+	if workspaceID == "" {
+		return respond.BadRequest(e, "workspace required")
+	}
+
+	records, err := filter.Eq("workspace", workspaceID).Find(e.App, "finance_budgets", "sort_order", 100, 0)
+	if err != nil {
+		return e.JSON(http.StatusOK, []budgetResponse{})
+	}
+
+	budgets := []budgetResponse{}
+	for _, r := range records {
+		b := budgetResponse{Budget: budget.BudgetFromRecord(r), Items: []domain.BudgetItem{}}
+		items, _ := filter.Eq("budget", r.Id).Eq("workspace", workspaceID).Find(e.App, "finance_budget_items", "sort_order", 100, 0)
+		for _, item := range items {
+			b.Items = append(b.Items, budget.BudgetItemFromRecord(item))
+		}
+		budgets = append(budgets, b)
+	}
+	return e.JSON(http.StatusOK, budgets)
+}
+
+func createBudget(e *core.RequestEvent) error {
+	var body budgetRequest
+	if err := json.NewDecoder(e.Request.Body).Decode(&body); err != nil {
+		return respond.InvalidJSON(e)
+	}
+	if missing(body.Name) {
+		return respond.BadRequest(e, "name required")
+	}
+
+	return create(e, "finance_budgets", func(r *core.Record) {
+		r.Set("workspace", body.Workspace)
+		body.apply(r)
+	})
+}
+
+func updateBudget(e *core.RequestEvent) error {
+	var body budgetFields
+	if err := json.NewDecoder(e.Request.Body).Decode(&body); err != nil {
+		return respond.InvalidJSON(e)
+	}
+	if body.Name != nil && *body.Name == "" {
+		return respond.BadRequest(e, "name required")
+	}
+	return update(e, "finance_budgets", body.apply)
+}
+
+// deleteBudget deletes a budget with its items
+func deleteBudget(e *core.RequestEvent) error {
+	record, err := e.App.FindRecordById("finance_budgets", e.Request.PathValue("id"))
+	if err != nil {
+		return respond.NotFound(e)
+	}
+
+	items, _ := filter.Eq("budget", record.Id).Find(e.App, "finance_budget_items", "", 0, 0)
+	for _, item := range items {
+		if err := e.App.Delete(item); err != nil {
+			return respond.Internal(e, err)
+		}
+	}
+	if err := e.App.Delete(record); err != nil {
+		return respond.Internal(e, err)
+	}
+	return respond.OK(e)
+}
+
+func createBudgetItem(e *core.RequestEvent) error {
+	var body budgetItemRequest
+	if err := json.NewDecoder(e.Request.Body).Decode(&body); err != nil {
+		return respond.InvalidJSON(e)
+	}
+	switch {
+	case missing(body.Budget):
+		return respond.BadRequest(e, "budget required")
+	case missing(body.Name):
+		return respond.BadRequest(e, "name required")
+	case body.Frequency != nil && *body.Frequency == "custom" && body.FrequencyDays == nil:
+		return respond.BadRequest(e, "frequency_days required for the custom frequency")
+	}
+	if problem := body.validate(); problem != "" {
+		return respond.BadRequest(e, problem)
+	}
+
+	return create(e, "finance_budget_items", func(r *core.Record) {
+		r.Set("workspace", body.Workspace)
+		r.Set("frequency", "monthly")
+		r.Set("rollover", "none")
+		body.apply(r)
+	})
+}
+
+func updateBudgetItem(e *core.RequestEvent) error {
+	var body budgetItemFields
+	if err := json.NewDecoder(e.Request.Body).Decode(&body); err != nil {
+		return respond.InvalidJSON(e)
+	}
+	if body.Budget != nil && *body.Budget == "" {
+		return respond.BadRequest(e, "budget required")
+	}
+	if problem := body.validate(); problem != "" {
+		return respond.BadRequest(e, problem)
+	}
+	if body.Frequency != nil && *body.Frequency == "custom" && body.FrequencyDays == nil {
+		if record, err := e.App.FindRecordById("finance_budget_items", e.Request.PathValue("id")); err == nil && record.GetFloat("frequency_days") < 1 {
+			return respond.BadRequest(e, "frequency_days required for the custom frequency")
+		}
+	}
+	return update(e, "finance_budget_items", body.apply)
+}
+
+func deleteBudgetItem(e *core.RequestEvent) error {
+	return remove(e, "finance_budget_items")
+}
+
+func getBudgetStatus(e *core.RequestEvent) error {
+	startDate, endDate, problem := period(e)
+	if problem != "" {
+		return respond.BadRequest(e, problem)
+	}
+
+	summary, err := budget.ComputeStatus(e.Request.URL.Query().Get("workspace"), startDate, endDate)
+	if err != nil {
+		return respond.Internal(e, err)
+	}
+	return e.JSON(http.StatusOK, summary)
+}
+
+// getBudgetHistory compares budgeted and actual amounts month by month
+func getBudgetHistory(e *core.RequestEvent) error {
+	startDate, endDate, problem := period(e)
+	if problem != "" {
+		return respond.BadRequest(e, problem)
+	}
+	if endDate.Before(startDate) {
+		return respond.BadRequest(e, "end_date must not be before start_date")
+	}
+
+	history, err := budget.ComputeHistory(e.Request.URL.Query().Get("workspace"), startDate, endDate)
+	if err != nil {
+		return respond.Internal(e, err)
+	}
+	return e.JSON(http.StatusOK, history)
+}
+
+// period parses the start_date and end_date query params, returning what
+// is wrong with them if anything
+func period(e *core.RequestEvent) (time.Time, time.Time, string) {
+	query := e.Request.URL.Query()
+	if query.Get("workspace") == "" || query.Get("start_date") == "" || query.Get("end_date") == "" {
+		return time.Time{}, time.Time{}, "workspace, start_date, and end_date required"
+	}
+
+	startDate, err := time.Parse("2006-01-02", query.Get("start_date"))
+	if err != nil {
+		return time.Time{}, time.Time{}, "invalid start_date format"
+	}
+	endDate, err := time.Parse("2006-01-02", query.Get("end_date"))
+	if err != nil {
+		return time.Time{}, time.Time{}, "invalid end_date format"
+	}
+	return startDate, endDate, ""
+}
+
+// getBudgetLedger lists the monthly envelopes of the budget items, of one
+// item with ?budget_item=
+func getBudgetLedger(e *core.RequestEvent) error {
+	query := e.Request.URL.Query()
+	entries, err := budget.Ledger(query.Get("workspace"), query.Get("budget_item"))
+	if err != nil {
+		return respond.Internal(e, err)
+	}
+	return e.JSON(http.StatusOK, entries)
+}
+
+// listAssignments lists which budget item each transaction counts toward,
+// for one transaction with ?transaction=
+func listAssignments(e *core.RequestEvent) error {
+	query := e.Request.URL.Query()
+	assignments, err := budget.Assignments(query.Get("workspace"), query.Get("transaction"))
+	if err != nil {
+		return respond.Internal(e, err)
+	}
+	return e.JSON(http.StatusOK, assignments)
+}
+
+// listConflicts lists the transactions of a period matched by several
+// budget items
+func listConflicts(e *core.RequestEvent) error {
+	startDate, endDate, problem := period(e)
+	if problem != "" {
+		return respond.BadRequest(e, problem)
+	}
+
+	conflicts, err := budget.Conflicts(e.Request.URL.Query().Get("workspace"), startDate, endDate)
+	if err != nil {
+		return respond.Internal(e, err)
+	}
+	return e.JSON(http.StatusOK, conflicts)
+}
+
+// assignBudgetItem overrides the budget item a transaction, or one split
+// allocation of it, counts toward
+func assignBudgetItem(e *core.RequestEvent) error {
+	var body assignmentRequest
+	if err := json.NewDecoder(e.Request.Body).Decode(&body); err != nil {
+		return respond.InvalidJSON(e)
+	}
+	if body.BudgetItem == nil {
+		return respond.BadRequest(e, "budget_item required")
+	}
+
+	assignment, err := budget.Assign(e.Request.PathValue("id"), body.SplitID, *body.BudgetItem)
+	if err != nil {
+		return respond.BadRequest(e, err.Error())
+	}
+	return e.JSON(http.StatusOK, assignment)
+}
+
+// unassignBudgetItem returns a transaction, or with ?split_id= one split
+// allocation of it, to the match rules
+func unassignBudgetItem(e *core.RequestEvent) error {
+	if err := budget.Unassign(e.Request.PathValue("id"), e.Request.URL.Query().Get("split_id")); err != nil {
+		return respond.Internal(e, err)
+	}
+	return respond.OK(e)
+}
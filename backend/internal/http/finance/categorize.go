@@ -0,0 +1,115 @@
+package finance
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"lifehub/backend/internal/filter"
+	"lifehub/backend/internal/http/respond"
+	"lifehub/backend/internal/services/categorization"
+	"lifehub/backend/internal/services/csvimport"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// bulkRequest is the body of POST /categorize/bulk
+type bulkRequest struct {
+	TransactionIDs []string `json:"transaction_ids"`
+	CategoryID     string   `json:"category_id"`
+	MerchantID     string   `json:"merchant_id"`
+	CreateRule     bool     `json:"create_rule"`
+	Pattern        string   `json:"pattern"`
+	WorkspaceID    string   `json:"workspace_id"`
+}
+
+// bulkResponse counts the transactions a bulk categorization touched
+type bulkResponse struct {
+	Status  string `json:"status"`
+	Updated int    `json:"updated"`
+}
+
+// categorizeResponse counts the transactions checked and recategorized
+type categorizeResponse struct {
+	Status  string `json:"status"`
+	Checked int    `json:"checked"`
+	Updated int    `json:"updated"`
+}
+
+func listSuggestions(e *core.RequestEvent) error {
+	workspaceID := e.Request.URL.Query().Get("workspace")
+	accountID := e.Request.URL.Query().Get("account")
+	if workspaceID == "" {
+		return respond.BadRequest(e, "workspace required")
+	}
+
+	suggestions, err := categorization.GetSuggestions(workspaceID, accountID)
+	if err != nil {
+		return respond.Internal(e, err)
+	}
+	return e.JSON(http.StatusOK, suggestions)
+}
+
+func categorizeBulk(e *core.RequestEvent) error {
+	var body bulkRequest
+	if err := json.NewDecoder(e.Request.Body).Decode(&body); err != nil {
+		return respond.InvalidJSON(e)
+	}
+	if len(body.TransactionIDs) == 0 {
+		return respond.BadRequest(e, "transaction_ids required")
+	}
+
+	if err := categorization.ApplyBulkCategorization(body.TransactionIDs, body.CategoryID, body.MerchantID); err != nil {
+		return respond.Internal(e, err)
+	}
+
+	// Optionally create rule
+	if body.CreateRule && body.Pattern != "" && body.WorkspaceID != "" {
+		_ = categorization.CreateRuleFromCorrection(body.WorkspaceID, body.Pattern, body.CategoryID, body.MerchantID)
+	}
+	return e.JSON(http.StatusOK, bulkResponse{Status: "ok", Updated: len(body.TransactionIDs)})
+}
+
+// recategorizeAll maps the bank category of uncategorized transactions
+func recategorizeAll(e *core.RequestEvent) error {
+	workspaceID := e.Request.URL.Query().Get("workspace")
+	if workspaceID == "" {
+		return respond.BadRequest(e, "workspace required")
+	}
+
+	// Get CSOB template for category mapping
+	template := csvimport.CSOBTemplate()
+
+	// Get all transactions with bank category but no internal category
+	records, err := filter.Eq("workspace", workspaceID).
+		Where("category != '' && category_rel = ''").
+		Find(e.App, "finance_transactions", "", 0, 0)
+	if err != nil {
+		return respond.Internal(e, err)
+	}
+
+	updated := 0
+	for _, r := range records {
+		catID := categorization.MapBankCategory(workspaceID, r.GetString("category"), template.CategoryMapping)
+		if catID != "" {
+			r.Set("category_rel", catID)
+			if err := e.App.Save(r); err == nil {
+				updated++
+			}
+		}
+	}
+	return e.JSON(http.StatusOK, categorizeResponse{Status: "ok", Checked: len(records), Updated: updated})
+}
+
+func applyRules(e *core.RequestEvent) error {
+	workspaceID := e.Request.URL.Query().Get("workspace")
+	if workspaceID == "" {
+		return respond.BadRequest(e, "workspace required")
+	}
+	overrideExisting := e.Request.URL.Query().Get("override") == "true"
+
+	checked, updated, err := categorization.ApplyRulesToTransactions(workspaceID, overrideExisting)
+	if err != nil {
+		return respond.Internal(e, err)
+	}
+	return e.JSON(http.StatusOK, categorizeResponse{Status: "ok", Checked: checked, Updated: updated})
+}
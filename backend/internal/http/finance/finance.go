@@ -0,0 +1,134 @@
+// Package finance serves the /api/finance routes: accounts, imports,
+// categorization, statistics, income and budgets of a workspace.
+package finance
+
+import (
+	"lifehub/backend/internal/access"
+	"lifehub/backend/internal/http/respond"
+	"lifehub/backend/internal/services/members"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+// Register binds the finance routes. Every route is scoped to one workspace
+// by access.RequireWorkspace.
+func Register(r *router.Router[*core.RequestEvent]) {
+	api := r.Group("/api/finance").Bind(access.RequireWorkspace())
+
+	api.GET("/accounts", listAccounts)
+	api.POST("/accounts", createAccount)
+	api.GET("/categories", listCategories)
+	api.POST("/categories", createCategory)
+	api.GET("/merchants", listMerchants)
+
+	api.GET("/templates", listTemplates).Bind(access.Optional())
+	api.GET("/templates/{id}", getTemplate).Bind(access.Record("finance_bank_templates"), access.Optional())
+	api.POST("/templates", createTemplate)
+	api.PUT("/templates/{id}", updateTemplate).Bind(access.Record("finance_bank_templates"), access.Optional())
+	api.DELETE("/templates/{id}", deleteTemplate).Bind(access.Record("finance_bank_templates"), access.Optional())
+
+	api.POST("/import/preview", previewImport).Bind(access.Optional(), access.MinRole(members.Viewer))
+	api.POST("/import", importStatement)
+	api.GET("/import/jobs/{id}", getImportJob).Bind(access.Resolve(importJobWorkspace))
+	api.GET("/imports", listImports)
+	api.DELETE("/imports/{id}", rollbackImport).Bind(access.Record("finance_imports"))
+	api.GET("/duplicates", listDuplicates)
+	api.POST("/transactions/merge", mergeTransactions)
+
+	api.POST("/transfers/detect", detectTransfers)
+	api.POST("/transfers", linkTransfer)
+	api.DELETE("/transfers/{id}", unlinkTransfer).Bind(access.Record("finance_transactions"))
+	api.GET("/transactions/{id}/splits", getSplits).Bind(access.Record("finance_transactions"))
+	api.PUT("/transactions/{id}/splits", replaceSplits).Bind(access.Record("finance_transactions"))
+	api.DELETE("/transactions/{id}/splits", deleteSplits).Bind(access.Record("finance_transactions"))
+
+	api.GET("/categorize/suggestions", listSuggestions)
+	api.POST("/categorize/bulk", categorizeBulk)
+	api.POST("/categorize/recategorize-all", recategorizeAll)
+	api.POST("/categorize/apply-rules", applyRules)
+
+	api.GET("/recurring", listRecurring)
+	api.POST("/recurring/detect", detectRecurring).Bind(access.MinRole(members.Viewer))
+	api.GET("/recurring/upcoming", listUpcoming)
+
+	api.GET("/stats", getStats)
+	api.GET("/trends", getTrends)
+	api.GET("/net-worth", getNetWorth)
+	api.POST("/exchange-rates/import", importRates).Bind(access.Optional(), access.Superuser())
+	api.GET("/exchange-rates/gaps", listRateGaps)
+
+	api.GET("/income-sources", listIncomeSources)
+	api.POST("/income-sources", createIncomeSource)
+	api.PUT("/income-sources/{id}", updateIncomeSource).Bind(access.Record("finance_income_sources"))
+	api.DELETE("/income-sources/{id}", deleteIncomeSource).Bind(access.Record("finance_income_sources"))
+	api.GET("/income-hours", listIncomeHours)
+	api.PUT("/income-hours", upsertIncomeHours)
+
+	api.GET("/budgets", listBudgets)
+	api.POST("/budgets", createBudget)
+	api.PUT("/budgets/{id}", updateBudget).Bind(access.Record("finance_budgets"))
+	api.DELETE("/budgets/{id}", deleteBudget).Bind(access.Record("finance_budgets"))
+	api.POST("/budget-items", createBudgetItem)
+	api.PUT("/budget-items/{id}", updateBudgetItem).Bind(access.Record("finance_budget_items"))
+	api.DELETE("/budget-items/{id}", deleteBudgetItem).Bind(access.Record("finance_budget_items"))
+	api.GET("/budget/status", getBudgetStatus)
+	api.GET("/budget/history", getBudgetHistory)
+	api.GET("/budget/ledger", getBudgetLedger)
+	api.GET("/budget/conflicts", listConflicts)
+	api.GET("/budget/assignments", listAssignments)
+	api.PUT("/transactions/{id}/budget-item", assignBudgetItem).Bind(access.Record("finance_transactions"))
+	api.DELETE("/transactions/{id}/budget-item", unassignBudgetItem).Bind(access.Record("finance_transactions"))
+}
+
+// create saves a new record of the collection with the fields set by apply
+// and answers with its ID
+func create(e *core.RequestEvent, collection string, apply func(*core.Record)) error {
+	c, err := e.App.FindCollectionByNameOrId(collection)
+	if err != nil {
+		return respond.Internal(e, err)
+	}
+	record := core.NewRecord(c)
+	apply(record)
+	if err := e.App.Save(record); err != nil {
+		return respond.Internal(e, err)
+	}
+	return respond.ID(e, record.Id)
+}
+
+// update applies changes to the record of the collection in the {id} path value
+func update(e *core.RequestEvent, collection string, apply func(*core.Record)) error {
+	record, err := e.App.FindRecordById(collection, e.Request.PathValue("id"))
+	if err != nil {
+		return respond.NotFound(e)
+	}
+	apply(record)
+	if err := e.App.Save(record); err != nil {
+		return respond.Internal(e, err)
+	}
+	return respond.OK(e)
+}
+
+// remove deletes the record of the collection in the {id} path value
+func remove(e *core.RequestEvent, collection string) error {
+	record, err := e.App.FindRecordById(collection, e.Request.PathValue("id"))
+	if err != nil {
+		return respond.NotFound(e)
+	}
+	if err := e.App.Delete(record); err != nil {
+		return respond.Internal(e, err)
+	}
+	return respond.OK(e)
+}
+
+// set copies a field of a partial update onto the record unless it was omitted
+func set[T any](r *core.Record, field string, v *T) {
+	if v != nil {
+		r.Set(field, *v)
+	}
+}
+
+// missing reports whether a required string field is absent or blank
+func missing(v *string) bool {
+	return v == nil || *v == ""
+}
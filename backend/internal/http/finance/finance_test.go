@@ -0,0 +1,857 @@
+package finance_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"lifehub/backend/internal/domain"
+	"lifehub/backend/internal/http/apitest"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const ws = "workspace=" + apitest.Workspace
+
+type routeTest struct {
+	name, method, target, body string
+	want                       int
+	contains                   string // in the response body
+}
+
+func run(t *testing.T, h http.Handler, token string, tests []routeTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := apitest.Request(t, h, tt.method, tt.target, token, tt.body)
+			if code != tt.want {
+				t.Fatalf("%s %s: status %d, want %d: %.300s", tt.method, tt.target, code, tt.want, body)
+			}
+			if !strings.Contains(body, tt.contains) {
+				t.Errorf("%s %s: body %.300s, want it to contain %s", tt.method, tt.target, body, tt.contains)
+			}
+			if code >= 400 {
+				var envelope struct {
+					Error string `json:"error"`
+				}
+				if err := json.Unmarshal([]byte(body), &envelope); err != nil || envelope.Error == "" {
+					t.Errorf("error body %q lacks the error envelope", body)
+				}
+			}
+		})
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	token := apitest.OwnerToken(t, app)
+	today := time.Now().Format("2006-01-02")
+	tx0, tx1 := apitest.Transaction(0), apitest.Transaction(1)
+
+	run(t, h, token, []routeTest{
+		{"list accounts", "GET", "/api/finance/accounts?" + ws, "", 200, `"current_balance":`},
+		{"create account", "POST", "/api/finance/accounts", `{"workspace":"` + apitest.Workspace + `","name":"Savings","currency":"EUR","is_active":true}`, 200, `"id":`},
+		{"list categories", "GET", "/api/finance/categories?" + ws, "", 200, `"name":"Groceries"`},
+		{"create subcategory", "POST", "/api/finance/categories", `{"workspace":"` + apitest.Workspace + `","name":"Fruit","parent_id":"` + apitest.Category + `"}`, 200, `"id":`},
+		{"subcategory listed", "GET", "/api/finance/categories?" + ws, "", 200, `"parent_id":"` + apitest.Category + `"`},
+		{"list merchants", "GET", "/api/finance/merchants?" + ws, "", 200, `"patterns":["shop"]`},
+		{"list templates", "GET", "/api/finance/templates?" + ws, "", 200, `"code":"mybank","name":"My bank","format":"","custom":true`},
+		{"get template", "GET", "/api/finance/templates/" + apitest.Template, "", 200, `"code":"mybank"`},
+		{"list imports", "GET", "/api/finance/imports?" + ws, "", 200, apitest.Import},
+		{"list duplicates", "GET", "/api/finance/duplicates?account=" + apitest.Account, "", 200, ``},
+		{"detect transfers", "POST", "/api/finance/transfers/detect?" + ws + "&dry_run=true", "", 200, `"applied":false`},
+		{"get splits", "GET", "/api/finance/transactions/" + tx0 + "/splits", "", 200, `[`},
+		{"split", "PUT", "/api/finance/transactions/" + tx0 + "/splits", `{"splits":[{"amount":50,"category_id":"` + apitest.Category + `"},{"amount":50}]}`, 200, `"amount":50`},
+		{"unsplit", "DELETE", "/api/finance/transactions/" + tx0 + "/splits", "", 200, `"status":"ok"`},
+		{"suggestions", "GET", "/api/finance/categorize/suggestions?" + ws, "", 200, ``},
+		{"bulk categorize", "POST", "/api/finance/categorize/bulk", `{"transaction_ids":["` + tx0 + `","` + tx1 + `"],"category_id":"` + apitest.Category + `"}`, 200, `"updated":2`},
+		{"recategorize all", "POST", "/api/finance/categorize/recategorize-all?" + ws, "", 200, `"checked":`},
+		{"apply rules", "POST", "/api/finance/categorize/apply-rules?" + ws, "", 200, `"checked":`},
+		{"list recurring", "GET", "/api/finance/recurring?" + ws, "", 200, `"merchant_name":"Shop"`},
+		{"detect recurring", "POST", "/api/finance/recurring/detect?" + ws, "", 200, ``},
+		{"upcoming", "GET", "/api/finance/recurring/upcoming?" + ws, "", 200, ``},
+		{"stats", "GET", "/api/finance/stats?" + ws, "", 200, `"total_expenses":`},
+		{"trends", "GET", "/api/finance/trends?" + ws + "&interval=month", "", 200, `"series":`},
+		{"net worth", "GET", "/api/finance/net-worth?" + ws, "", 200, `"investments":1000`},
+		{"rate gaps", "GET", "/api/finance/exchange-rates/gaps?" + ws, "", 200, ``},
+		{"list income sources", "GET", "/api/finance/income-sources?" + ws, "", 200, `"income_type":"fixed"`},
+		{"create income source", "POST", "/api/finance/income-sources", `{"workspace":"` + apitest.Workspace + `","name":"Consulting","income_type":"hourly","amount":800}`, 200, `"id":`},
+		{"update income source", "PUT", "/api/finance/income-sources/" + apitest.Income, `{"amount":60000}`, 200, `"status":"ok"`},
+		{"updated income source", "GET", "/api/finance/income-sources?" + ws, "", 200, `"name":"Salary","income_type":"fixed","amount":60000`},
+		{"list income hours", "GET", "/api/finance/income-hours?" + ws, "", 200, `"income_source":"` + apitest.Income + `"`},
+		{"create income hours", "PUT", "/api/finance/income-hours", `{"workspace":"` + apitest.Workspace + `","income_source":"` + apitest.Income + `","year":2020,"month":1,"hours":100}`, 200, `"status":"created"`},
+		{"update income hours", "PUT", "/api/finance/income-hours", `{"workspace":"` + apitest.Workspace + `","income_source":"` + apitest.Income + `","year":2020,"month":1,"hours":120}`, 200, `"status":"updated"`},
+		{"list budgets", "GET", "/api/finance/budgets?" + ws, "", 200, `"items":[{"id":"` + apitest.BudgetItem + `"`},
+		{"create budget", "POST", "/api/finance/budgets", `{"workspace":"` + apitest.Workspace + `","name":"Fun","sort_order":2,"is_active":true}`, 200, `"id":`},
+		{"empty budget lists items", "GET", "/api/finance/budgets?" + ws, "", 200, `"name":"Fun","sort_order":2,"is_active":true,"items":[]`},
+		{"update budget", "PUT", "/api/finance/budgets/" + apitest.Budget, `{"color":"#00ff00"}`, 200, `"status":"ok"`},
+		{"create budget item", "POST", "/api/finance/budget-items", `{"workspace":"` + apitest.Workspace + `","budget":"` + apitest.Budget + `","name":"Rent","budgeted_amount":12000,"frequency":"monthly","match_pattern":"^RENT","match_pattern_type":"regex"}`, 200, `"id":`},
+		{"update budget item", "PUT", "/api/finance/budget-items/" + apitest.BudgetItem, `{"budgeted_amount":6000,"frequency":"yearly","due_month":9}`, 200, `"status":"ok"`},
+		{"updated budget item", "GET", "/api/finance/budgets?" + ws, "", 200, `"name":"Food","budgeted_amount":6000,"currency":"","frequency":"yearly","due_month":9`},
+		{"custom budget item", "PUT", "/api/finance/budget-items/" + apitest.BudgetItem, `{"frequency":"custom","frequency_days":14}`, 200, `"status":"ok"`},
+		{"budget status", "GET", "/api/finance/budget/status?" + ws + "&start_date=2025-01-01&end_date=" + today, "", 200, `"budgets":`},
+		{"delete budget item", "DELETE", "/api/finance/budget-items/" + apitest.BudgetItem, "", 200, `"status":"ok"`},
+		{"delete budget", "DELETE", "/api/finance/budgets/" + apitest.Budget, "", 200, `"status":"ok"`},
+		{"delete income source", "DELETE", "/api/finance/income-sources/" + apitest.Income, "", 200, `"status":"ok"`},
+		{"rollback import", "DELETE", "/api/finance/imports/" + apitest.Import, "", 200, `"transactions_deleted":0`},
+		{"delete template", "DELETE", "/api/finance/templates/" + apitest.Template, "", 200, `"status":"ok"`},
+	})
+}
+
+func TestValidation(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	token := apitest.OwnerToken(t, app)
+	wsBody := `"workspace":"` + apitest.Workspace + `"`
+
+	run(t, h, token, []routeTest{
+		{"account without workspace", "GET", "/api/finance/accounts", "", 400, `"error":"workspace required"`},
+		{"account body", "POST", "/api/finance/accounts", `{` + wsBody + `,"name":`, 400, `"error":"invalid request body"`},
+		{"account name", "POST", "/api/finance/accounts", `{` + wsBody + `}`, 400, `"error":"name required"`},
+		{"account types", "POST", "/api/finance/accounts", `{` + wsBody + `,"name":"x","initial_balance":"many"}`, 400, `"error":"invalid JSON"`},
+		{"category name", "POST", "/api/finance/categories", `{` + wsBody + `,"name":""}`, 400, `"error":"name required"`},
+		{"template workspace", "POST", "/api/finance/templates", `{"name":"x","code":"x"}`, 400, `"error":"workspace required"`},
+		{"invalid template", "POST", "/api/finance/templates", `{"workspace_id":"` + apitest.Workspace + `","code":"x"}`, 400, `"validation_errors":[`},
+		{"missing template", "GET", "/api/finance/templates/missingxxxxxxxx", "", 404, `"error":"not found"`},
+		{"import without file", "POST", "/api/finance/import", ws + "&account=" + apitest.Account, 400, `"error":"file required"`},
+		{"preview without file", "POST", "/api/finance/import/preview", ws, 400, `"error":"file required"`},
+		{"missing import job", "GET", "/api/finance/import/jobs/missing", "", 404, `"error":"not found"`},
+		{"duplicates without account", "GET", "/api/finance/duplicates?" + ws, "", 400, `"error":"account required"`},
+		{"merge one", "POST", "/api/finance/transactions/merge", `{"ids":["` + apitest.Transaction(0) + `"]}`, 400, `"error":"exactly two transaction ids required"`},
+		{"transfer one", "POST", "/api/finance/transfers", `{"ids":["` + apitest.Transaction(0) + `"]}`, 400, `"error":"exactly two transaction ids required"`},
+		{"splits body", "PUT", "/api/finance/transactions/" + apitest.Transaction(0) + "/splits", `{"splits":{}}`, 400, `"error":"invalid JSON"`},
+		{"splits over amount", "PUT", "/api/finance/transactions/" + apitest.Transaction(0) + "/splits", `{"splits":[{"amount":5000},{"amount":1}]}`, 400, `"error":`},
+		{"bulk without transactions", "POST", "/api/finance/categorize/bulk", `{` + wsBody + `,"transaction_ids":[]}`, 400, `"error":"transaction_ids required"`},
+		{"trends interval", "GET", "/api/finance/trends?" + ws + "&interval=fortnight", "", 400, `"error":`},
+		{"income type", "POST", "/api/finance/income-sources", `{` + wsBody + `,"name":"x","income_type":"weekly"}`, 400, `"error":"income_type must be fixed or hourly"`},
+		{"income name", "PUT", "/api/finance/income-sources/" + apitest.Income, `{"name":""}`, 400, `"error":"name required"`},
+		{"missing income source", "PUT", "/api/finance/income-sources/missingxxxxxxxx", `{"name":"x"}`, 404, `"error":"not found"`},
+		{"income hours month", "PUT", "/api/finance/income-hours", `{` + wsBody + `,"income_source":"` + apitest.Income + `","year":2025,"month":13}`, 400, `"error":"invalid month"`},
+		{"income hours source", "PUT", "/api/finance/income-hours", `{` + wsBody + `,"year":2025,"month":1}`, 400, `"error":"income_source required"`},
+		{"income hours query", "GET", "/api/finance/income-hours?" + ws + "&year=last", "", 400, `"error":"invalid year"`},
+		{"budget name", "POST", "/api/finance/budgets", `{` + wsBody + `}`, 400, `"error":"name required"`},
+		{"budget sort order", "PUT", "/api/finance/budgets/" + apitest.Budget, `{"sort_order":"first"}`, 400, `"error":"invalid JSON"`},
+		{"budget item budget", "POST", "/api/finance/budget-items", `{` + wsBody + `,"name":"x"}`, 400, `"error":"budget required"`},
+		{"budget item frequency", "POST", "/api/finance/budget-items", `{` + wsBody + `,"budget":"` + apitest.Budget + `","name":"x","frequency":"daily"}`, 400, `"error":"frequency must be one of: `},
+		{"budget item frequency days", "POST", "/api/finance/budget-items", `{` + wsBody + `,"budget":"` + apitest.Budget + `","name":"x","frequency":"custom"}`, 400, `"error":"frequency_days required for the custom frequency"`},
+		{"budget item custom frequency", "PUT", "/api/finance/budget-items/" + apitest.BudgetItem, `{"frequency":"custom"}`, 400, `"error":"frequency_days required for the custom frequency"`},
+		{"budget item zero days", "PUT", "/api/finance/budget-items/" + apitest.BudgetItem, `{"frequency":"custom","frequency_days":0}`, 400, `"error":"frequency_days must be at least 1"`},
+		{"budget item due month", "PUT", "/api/finance/budget-items/" + apitest.BudgetItem, `{"frequency":"yearly","due_month":13}`, 400, `"error":"due_month must be between 1 and 12, or 0 for none"`},
+		{"budget item regex", "PUT", "/api/finance/budget-items/" + apitest.BudgetItem, `{"match_pattern":"(","match_pattern_type":"regex"}`, 400, `"error":"invalid match_pattern: `},
+		{"budget item pattern type", "PUT", "/api/finance/budget-items/" + apitest.BudgetItem, `{"match_pattern_type":"glob"}`, 400, `"error":"match_pattern_type must be one of: contains, exact, regex"`},
+		{"budget status dates", "GET", "/api/finance/budget/status?" + ws, "", 400, `"error":"workspace, start_date, and end_date required"`},
+		{"budget status format", "GET", "/api/finance/budget/status?" + ws + "&start_date=01/01/2025&end_date=2025-01-31", "", 400, `"error":"invalid start_date format"`},
+	})
+}
+
+func TestImportRates(t *testing.T) {
+	app, h := apitest.NewServer(t)
+
+	// The rates are shared by every workspace, so even an owner may not replace them
+	run(t, h, apitest.OwnerToken(t, app), []routeTest{
+		{"owner", "POST", "/api/finance/exchange-rates/import", ws, 403, `"error":"superuser access required"`},
+		{"owner without workspace", "POST", "/api/finance/exchange-rates/import", "", 403, `"error":"superuser access required"`},
+	})
+	run(t, h, apitest.SuperuserToken(t, app), []routeTest{
+		{"rates without file", "POST", "/api/finance/exchange-rates/import", "", 400, `"error":"file or source required"`},
+	})
+}
+
+func TestTemplateCode(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	template := func(code string) string {
+		return `{"workspace_id":"` + apitest.Workspace + `","name":"Bank","code":"` + code + `","delimiter":",","encoding":"utf-8",` +
+			`"date_format":"2006-01-02","decimal_separator":".","field_mapping":{"date":0,"amount":1}}`
+	}
+
+	run(t, h, apitest.OwnerToken(t, app), []routeTest{
+		{"create", "POST", "/api/finance/templates", template("otherbank"), 200, `"id":`},
+		{"create taken", "POST", "/api/finance/templates", template("mybank"), 409, `"id":"` + apitest.Template + `"`},
+		{"rename to taken", "PUT", "/api/finance/templates/" + apitest.Template, template("otherbank"), 409, `"error":"a template with this code already exists"`},
+		{"keep own code", "PUT", "/api/finance/templates/" + apitest.Template, template("mybank"), 200, `"status":"ok"`},
+		{"rename", "PUT", "/api/finance/templates/" + apitest.Template, template("renamed"), 200, `"status":"ok"`},
+	})
+}
+
+func TestImport(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	token := apitest.OwnerToken(t, app)
+	data, err := os.ReadFile("../../services/csvimport/testdata/fio_sample.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]string{"workspace": apitest.Workspace, "account": apitest.Account}
+
+	code, body := apitest.Upload(t, h, "/api/finance/import/preview", token, fields, "fio.csv", data)
+	if code != http.StatusOK || !strings.Contains(body, `"detected_template":"fio"`) {
+		t.Fatalf("preview: status %d: %.300s", code, body)
+	}
+
+	code, body = apitest.Upload(t, h, "/api/finance/import", token, fields, "fio.csv", data)
+	if code != http.StatusOK || !strings.Contains(body, `"import_id":`) {
+		t.Fatalf("import: status %d: %.300s", code, body)
+	}
+
+	// The same statement again is refused with the earlier import
+	code, body = apitest.Upload(t, h, "/api/finance/import", token, fields, "fio.csv", data)
+	if code != http.StatusConflict || !strings.Contains(body, `"error":"this file was already imported into the account"`) || !strings.Contains(body, `"previous_import":{`) {
+		t.Fatalf("reimport: status %d, want 409 with the previous import: %.300s", code, body)
+	}
+
+	code, body = apitest.Upload(t, h, "/api/finance/import", token, map[string]string{"workspace": apitest.Workspace}, "fio.csv", data)
+	if code != http.StatusBadRequest || !strings.Contains(body, `"error":"account and workspace required"`) {
+		t.Fatalf("import without account: status %d: %.300s", code, body)
+	}
+
+	// A stored template that can't be used is reported, not replaced by the generic one
+	if _, err := app.DB().Update("finance_bank_templates", dbx.Params{"delimiter": ";;"}, dbx.HashExp{"id": apitest.Template}).Execute(); err != nil {
+		t.Fatal(err)
+	}
+	fields["template"] = "mybank"
+	code, body = apitest.Upload(t, h, "/api/finance/import/preview", token, fields, "fio.csv", data)
+	if code != http.StatusBadRequest || !strings.Contains(body, `"error":"template \"mybank\" is invalid: delimiter must be a single character`) {
+		t.Errorf("invalid stored template: status %d: %.300s", code, body)
+	}
+}
+
+func TestImportTransfers(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	token := apitest.OwnerToken(t, app)
+	const savings = "savingsaccountx"
+	row := func(id, account, kind, date string, amount float64) apitest.Row {
+		return apitest.Row{Table: "finance_transactions", Data: dbx.Params{
+			"id": id, "workspace": apitest.Workspace, "account": account, "type": kind, "amount": amount, "date": date + " 00:00:00.000Z",
+		}}
+	}
+	apitest.Insert(t, app,
+		apitest.Row{Table: "finance_accounts", Data: dbx.Params{"id": savings, "workspace": apitest.Workspace, "name": "Savings", "account_number": "123456789/0300", "currency": "CZK", "is_active": true}},
+		// The rent payment of the statement names the savings account
+		row("savingsrent0001", savings, "income", "2025-03-11", 12000),
+		// Same amount as the salary, but nothing ties them together
+		row("savingsspend001", savings, "expense", "2025-03-15", 11000),
+		// Outside the statement's dates
+		row("savingsold00001", savings, "income", "2025-01-03", 328.5),
+	)
+	data, err := os.ReadFile("../../services/csvimport/testdata/fio_sample.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, body := apitest.Upload(t, h, "/api/finance/import", token, map[string]string{"workspace": apitest.Workspace, "account": apitest.Account}, "fio.csv", data)
+	var result struct {
+		TransfersLinked    int              `json:"transfers_linked"`
+		TransferCandidates []map[string]any `json:"transfer_candidates"`
+	}
+	if err := json.Unmarshal([]byte(body), &result); code != http.StatusOK || err != nil {
+		t.Fatalf("import: status %d: %.300s", code, body)
+	}
+	if result.TransfersLinked != 1 || len(result.TransferCandidates) != 1 || result.TransferCandidates[0]["outgoing_id"] != "savingsspend001" {
+		t.Fatalf("linked %d, candidates %v, want the rent linked and the salary proposed", result.TransfersLinked, result.TransferCandidates)
+	}
+	for id, want := range map[string]bool{"savingsrent0001": true, "savingsspend001": false, "savingsold00001": false} {
+		if r, err := app.FindRecordById("finance_transactions", id); err != nil || r.GetBool("is_transfer") != want {
+			t.Errorf("%s: is_transfer %v, want %v", id, r.GetBool("is_transfer"), want)
+		}
+	}
+}
+
+func TestLinkTransfer(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	token := apitest.OwnerToken(t, app)
+	const savings = "savingsaccountx"
+	row := func(id, kind string) apitest.Row {
+		return apitest.Row{Table: "finance_transactions", Data: dbx.Params{
+			"id": id, "workspace": apitest.Workspace, "account": savings, "type": kind, "amount": 100, "date": "2025-03-01 00:00:00.000Z",
+		}}
+	}
+	apitest.Insert(t, app,
+		apitest.Row{Table: "finance_accounts", Data: dbx.Params{"id": savings, "workspace": apitest.Workspace, "name": "Savings", "currency": "CZK", "is_active": true}},
+		row("savingsin000001", "income"),
+		row("savingsin000002", "income"),
+		row("savingsout00001", "expense"),
+	)
+	link := func(first, second string) string {
+		return `{"ids":["` + first + `","` + second + `"]}`
+	}
+	tx0, tx1 := apitest.Transaction(0), apitest.Transaction(1)
+
+	run(t, h, token, []routeTest{
+		{"same account", "POST", "/api/finance/transfers", link(tx0, tx1), 400, `"error":"both legs of a transfer cannot be in the same account"`},
+		{"two expenses", "POST", "/api/finance/transfers", link(tx0, "savingsout00001"), 400, `"error":"a transfer needs one expense and one income"`},
+		{"link", "POST", "/api/finance/transfers", link(tx0, "savingsin000001"), 200, `"status":"ok"`},
+		{"relink", "POST", "/api/finance/transfers", link("savingsin000002", tx0), 200, `"status":"ok"`},
+	})
+
+	// The first income lost its partner when the expense was linked again
+	for id, want := range map[string]string{tx0: "savingsin000002", "savingsin000002": tx0, "savingsin000001": ""} {
+		r, err := app.FindRecordById("finance_transactions", id)
+		if err != nil || r.GetString("transfer_pair") != want || r.GetBool("is_transfer") != (want != "") {
+			t.Errorf("%s: pair %q, is_transfer %v, want %q", id, r.GetString("transfer_pair"), r.GetBool("is_transfer"), want)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	token := apitest.OwnerToken(t, app)
+	const manual, imported, other, partner = "mergemanual0001", "mergeimport0001", "mergeimport0002", "mergepartner001"
+	const otherImport = "mergeimportbxxx"
+	tx := func(id string, data dbx.Params) apitest.Row {
+		row := dbx.Params{"id": id, "workspace": apitest.Workspace, "account": apitest.Account, "type": "expense", "amount": 250, "description": "BAKERY"}
+		for k, v := range data {
+			row[k] = v
+		}
+		return apitest.Row{Table: "finance_transactions", Data: row}
+	}
+	apitest.Insert(t, app,
+		apitest.Row{Table: "finance_imports", Data: dbx.Params{"id": otherImport, "workspace": apitest.Workspace, "account": apitest.Account, "name": "other.csv"}},
+		tx(manual, dbx.Params{"date": "2024-03-01 00:00:00.000Z", "raw_description": "Bakery, Brno", "category_rel": apitest.Category}),
+		tx(imported, dbx.Params{"date": "2024-03-02 00:00:00.000Z", "external_id": "bank-1", "import_ref": apitest.Import,
+			"raw_description": "Bakery, Brno", "counterparty_account": "123/0100", "is_transfer": true, "transfer_pair": partner}),
+		tx(other, dbx.Params{"date": "2024-03-03 00:00:00.000Z", "external_id": "bank-2", "import_ref": otherImport}),
+		tx(partner, dbx.Params{"date": "2024-03-02 00:00:00.000Z", "type": "income", "is_transfer": true, "transfer_pair": imported}),
+		tx("mergelater0001", dbx.Params{"date": "2024-03-20 00:00:00.000Z"}),
+		tx("mergeamount001", dbx.Params{"date": "2024-03-01 00:00:00.000Z", "amount": 260}),
+		apitest.Row{Table: "finance_accounts", Data: dbx.Params{"id": "mergesavingsxxx", "workspace": apitest.Workspace, "name": "Savings"}},
+		tx("mergeaccount01", dbx.Params{"date": "2024-03-01 00:00:00.000Z", "account": "mergesavingsxxx"}),
+	)
+	merge := func(a, b string) string { return `{"ids":["` + a + `","` + b + `"]}` }
+
+	run(t, h, token, []routeTest{
+		{"other direction", "POST", "/api/finance/transactions/merge", merge(manual, partner), 400, `"error":"transactions differ in amount or direction"`},
+		{"other amount", "POST", "/api/finance/transactions/merge", merge(manual, "mergeamount001"), 400, `"error":"transactions differ in amount or direction"`},
+		{"far apart", "POST", "/api/finance/transactions/merge", merge(manual, "mergelater0001"), 400, `"error":"transactions are more than 3 days apart"`},
+		{"other account", "POST", "/api/finance/transactions/merge", merge(manual, "mergeaccount01"), 400, `"error":"transactions belong to different accounts"`},
+
+		// The manual transaction is kept, without taking over the import of the other one
+		{"merge manual", "POST", "/api/finance/transactions/merge", merge(other, manual), 200, `"id":"` + manual + `"`},
+		// The imported one is kept, but belongs to neither import any more
+		{"merge imported", "POST", "/api/finance/transactions/merge", merge(imported, manual), 200, `"id":"` + imported + `"`},
+		{"rollback import", "DELETE", "/api/finance/imports/" + apitest.Import, "", 200, `"transactions_deleted":0`},
+		{"rollback other import", "DELETE", "/api/finance/imports/" + otherImport, "", 200, `"transactions_deleted":0`},
+	})
+
+	kept, err := app.FindRecordById("finance_transactions", imported)
+	if err != nil {
+		t.Fatalf("merged transaction deleted with an import: %v", err)
+	}
+	if kept.GetString("import_ref") != "" || fmt.Sprint(kept.GetStringSlice("merged_external_ids")) != "[bank-2]" || kept.GetString("category_rel") != apitest.Category {
+		t.Errorf("import_ref %q, merged external IDs %v, category %q", kept.GetString("import_ref"), kept.GetStringSlice("merged_external_ids"), kept.GetString("category_rel"))
+	}
+	if r, err := app.FindRecordById("finance_transactions", partner); err != nil || r.GetString("transfer_pair") != imported {
+		t.Errorf("transfer partner no longer linked to the kept record")
+	}
+}
+
+func TestRollbackImport(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	token := apitest.OwnerToken(t, app)
+	const imported, partner = "rollbackimport1", "rollbackmanual1"
+	apitest.Insert(t, app,
+		apitest.Row{Table: "finance_transactions", Data: dbx.Params{
+			"id": imported, "workspace": apitest.Workspace, "account": apitest.Account, "type": "expense", "amount": 500,
+			"date": "2024-03-01 00:00:00.000Z", "import_ref": apitest.Import, "is_transfer": true, "transfer_pair": partner,
+		}},
+		apitest.Row{Table: "finance_transactions", Data: dbx.Params{
+			"id": partner, "workspace": apitest.Workspace, "account": apitest.Account, "type": "income", "amount": 500,
+			"date": "2024-03-01 00:00:00.000Z", "is_transfer": true, "transfer_pair": imported,
+		}},
+	)
+
+	run(t, h, token, []routeTest{
+		{"rollback import", "DELETE", "/api/finance/imports/" + apitest.Import, "", 200, `"transactions_deleted":1`},
+	})
+
+	// The partner left behind counts as a regular transaction again
+	r, err := app.FindRecordById("finance_transactions", partner)
+	if err != nil || r.GetBool("is_transfer") || r.GetString("transfer_pair") != "" {
+		t.Errorf("partner still linked: %v", err)
+	}
+}
+
+// spend is an expense in the category of the seeded budget item
+func spend(id, date string, amount float64) apitest.Row {
+	return apitest.Row{Table: "finance_transactions", Data: dbx.Params{
+		"id": id, "workspace": apitest.Workspace, "account": apitest.Account, "type": "expense",
+		"amount": amount, "date": date + " 00:00:00.000Z", "category_rel": apitest.Category,
+	}}
+}
+
+func TestBudgetRollover(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	token := apitest.OwnerToken(t, app)
+	apitest.Insert(t, app,
+		spend("rollovertx00001", "2024-01-10", 3000),
+		spend("rollovertx00002", "2024-02-10", 4000),
+		spend("rollovertx00003", "2024-02-29", 2500),
+	)
+
+	ledger := func() []domain.BudgetLedgerEntry {
+		t.Helper()
+		code, body := apitest.Request(t, h, "GET", "/api/finance/budget/ledger?"+ws+"&budget_item="+apitest.BudgetItem, token, "")
+		var entries []domain.BudgetLedgerEntry
+		if err := json.Unmarshal([]byte(body), &entries); code != http.StatusOK || err != nil {
+			t.Fatalf("ledger: status %d: %.300s", code, body)
+		}
+		return entries
+	}
+
+	envelope := func(start, end string) (opening, assigned, spent, closing float64) {
+		t.Helper()
+		code, body := apitest.Request(t, h, "GET", "/api/finance/budget/status?"+ws+"&start_date="+start+"&end_date="+end, token, "")
+		if code != http.StatusOK {
+			t.Fatalf("status: %d: %.300s", code, body)
+		}
+		var summary domain.BudgetSummary
+		if err := json.Unmarshal([]byte(body), &summary); err != nil || len(summary.Budgets) == 0 || len(summary.Budgets[0].Items) == 0 {
+			t.Fatalf("unexpected summary %.300s", body)
+		}
+		s := summary.Budgets[0].Items[0]
+		return s.OpeningBalance, s.Assigned, s.Spent, s.ClosingBalance
+	}
+
+	// Reading the status doesn't write the ledger, however far back it starts
+	envelope("2020-01-01", "2024-02-29")
+	if entries := ledger(); len(entries) != 0 {
+		t.Fatalf("ledger written on read: %+v", entries)
+	}
+
+	// Changing the item brings the ledger up to date, starting with the
+	// first month anything was spent
+	code, body := apitest.Request(t, h, "PUT", "/api/finance/budget-items/"+apitest.BudgetItem, token, `{"rollover":"surplus"}`)
+	if code != http.StatusOK {
+		t.Fatalf("set rollover: status %d: %s", code, body)
+	}
+	if opening, _, spent, closing := envelope("2024-02-01", "2024-02-29"); opening != 2000 || spent != 6500 || closing != 500 {
+		t.Errorf("February alone: opening %v, spent %v, closing %v, want 2000, 6500, 500", opening, spent, closing)
+	}
+	if opening, assigned, spent, closing := envelope("2024-01-01", "2024-01-31"); opening != 0 || assigned != 5000 || spent != 3000 || closing != 2000 {
+		t.Errorf("January: %v, %v, %v, %v, want 0, 5000, 3000, 2000", opening, assigned, spent, closing)
+	}
+	if opening, _, _, closing := envelope("2024-02-01", "2024-02-29"); opening != 2000 || closing != 500 {
+		t.Errorf("February: opening %v, closing %v, want 2000, 500", opening, closing)
+	}
+	// Overspending is not carried with the surplus mode
+	if opening, _, _, _ := envelope("2024-03-01", "2024-03-31"); opening != 500 {
+		t.Errorf("March: opening %v, want 500", opening)
+	}
+	if opening, assigned, _, closing := envelope("2024-01-01", "2024-03-31"); opening != 0 || assigned != 15000 || closing != 5500 {
+		t.Errorf("quarter: opening %v, assigned %v, closing %v, want 0, 15000, 5500", opening, assigned, closing)
+	}
+
+	// The ledger runs from January 2024 through the current month
+	entries := ledger()
+	var closings []float64
+	for _, entry := range entries[:min(3, len(entries))] {
+		closings = append(closings, entry.ClosingBalance)
+	}
+	if entries[0].Year != 2024 || entries[0].Month != 1 || fmt.Sprint(closings) != "[2000 500 5500]" {
+		t.Errorf("ledger closings %v, want [2000 500 5500] from January 2024", closings)
+	}
+	if last, now := entries[len(entries)-1], time.Now(); last.Year != now.Year() || last.Month != int(now.Month()) {
+		t.Errorf("ledger ends with %d-%02d, want the current month", last.Year, last.Month)
+	}
+
+	code, body = apitest.Request(t, h, "PUT", "/api/finance/budget-items/"+apitest.BudgetItem, token, `{"rollover":"monthly"}`)
+	if code != http.StatusBadRequest || !strings.Contains(body, `"error":"rollover must be one of: none, surplus, deficit, both"`) {
+		t.Errorf("invalid rollover: status %d: %s", code, body)
+	}
+}
+
+func TestBudgetEnvelopeBuildsUp(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	token := apitest.OwnerToken(t, app)
+	row := spend("insurancetx0001", "2024-06-15", 5000)
+	row.Data["description"] = "INSURANCE"
+	apitest.Insert(t, app, spend("envelopetx00001", "2024-01-10", 100), row)
+
+	// A yearly bill funded monthly; nothing is spent on it until June
+	var item struct{ ID string }
+	body := apitest.Expect(t, h, "POST", "/api/finance/budget-items", token, `{"workspace":"`+apitest.Workspace+`","budget":"`+apitest.Budget+`",`+
+		`"name":"Insurance","budgeted_amount":12000,"frequency":"yearly","rollover":"surplus","match_pattern":"INSURANCE","is_active":true,"priority":5}`, http.StatusOK)
+	if err := json.Unmarshal([]byte(body), &item); err != nil {
+		t.Fatal(err)
+	}
+
+	body = apitest.Expect(t, h, "GET", "/api/finance/budget/ledger?"+ws+"&budget_item="+item.ID, token, "", http.StatusOK)
+	var entries []domain.BudgetLedgerEntry
+	if err := json.Unmarshal([]byte(body), &entries); err != nil || len(entries) < 6 {
+		t.Fatalf("ledger %.300s", body)
+	}
+	var closings []float64
+	for _, entry := range entries[:6] {
+		closings = append(closings, entry.ClosingBalance)
+	}
+	if entries[0].Year != 2024 || entries[0].Month != 1 || fmt.Sprint(closings) != "[1000 2000 3000 4000 5000 1000]" {
+		t.Errorf("ledger from %d-%02d closes with %v, want [1000 2000 3000 4000 5000 1000] from January 2024", entries[0].Year, entries[0].Month, closings)
+	}
+}
+
+func TestTrendsMissingRates(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	row := spend("eurospendtx0001", "2025-10-16", 10)
+	row.Data["account"] = "euroaccountxxxx"
+	apitest.Insert(t, app,
+		apitest.Row{Table: "finance_accounts", Data: dbx.Params{"id": "euroaccountxxxx", "workspace": apitest.Workspace, "name": "Euro", "currency": "EUR", "is_active": true}},
+		row,
+	)
+
+	run(t, h, apitest.OwnerToken(t, app), []routeTest{
+		{"without rates", "GET", "/api/finance/trends?" + ws + "&group_by=account&start_date=2025-10-01&end_date=2025-10-31", "", 200, `"missing_rates":["EUR"]`},
+		{"unconverted", "GET", "/api/finance/trends?" + ws + "&group_by=account&start_date=2025-10-01&end_date=2025-10-31", "", 200, `"key":"euroaccountxxxx","name":"Euro","total":10`},
+		{"base currency only", "GET", "/api/finance/trends?" + ws + "&account=" + apitest.Account, "", 200, `"missing_rates":[]`},
+	})
+}
+
+func TestBudgetFollowsRates(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	token := apitest.OwnerToken(t, app)
+	superuser := apitest.SuperuserToken(t, app)
+	row := spend("eurospendtx0001", "2025-10-16", 10)
+	row.Data["account"], row.Data["category_rel"], row.Data["description"] = "euroaccountxxxx", "", "TRAVEL"
+	apitest.Insert(t, app,
+		apitest.Row{Table: "finance_accounts", Data: dbx.Params{"id": "euroaccountxxxx", "workspace": apitest.Workspace, "name": "Euro", "currency": "EUR", "is_active": true}},
+		row,
+	)
+
+	var item struct{ ID string }
+	body := apitest.Expect(t, h, "POST", "/api/finance/budget-items", token, `{"workspace":"`+apitest.Workspace+`","budget":"`+apitest.Budget+`",`+
+		`"name":"Travel","budgeted_amount":1000,"frequency":"monthly","match_pattern":"TRAVEL","is_active":true,"priority":5}`, http.StatusOK)
+	if err := json.Unmarshal([]byte(body), &item); err != nil {
+		t.Fatal(err)
+	}
+	spent := func() float64 {
+		t.Helper()
+		body := apitest.Expect(t, h, "GET", "/api/finance/budget/ledger?"+ws+"&budget_item="+item.ID, token, "", http.StatusOK)
+		var entries []domain.BudgetLedgerEntry
+		if err := json.Unmarshal([]byte(body), &entries); err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			if entry.Year == 2025 && entry.Month == 10 {
+				return entry.Spent
+			}
+		}
+		t.Fatalf("no October 2025 in the ledger %.300s", body)
+		return 0
+	}
+	before := spent()
+
+	data, err := os.ReadFile("../../services/currency/testdata/denni_kurz.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, body := apitest.Upload(t, h, "/api/finance/exchange-rates/import", superuser, nil, "denni_kurz.txt", data); code != http.StatusOK {
+		t.Fatalf("import rates: status %d: %.300s", code, body)
+	}
+	if got := spent(); math.Abs(got-243.65) > 0.005 {
+		t.Errorf("spent %v after importing the EUR rate, want 243.65 (%v before)", got, before)
+	}
+
+	// The account turns out to be kept in crowns
+	apitest.Expect(t, h, "PATCH", "/api/collections/finance_accounts/records/euroaccountxxxx", superuser, `{"currency":"CZK"}`, http.StatusOK)
+	if got := spent(); got != 10 {
+		t.Errorf("spent %v after the account changed to CZK, want 10", got)
+	}
+}
+
+func TestBudgetRefreshScope(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	token := apitest.OwnerToken(t, app)
+	superuser := apitest.SuperuserToken(t, app)
+	unmatched := spend("scopetx00000001", "2024-01-10", 300)
+	unmatched.Data["category_rel"] = ""
+	gym := spend("scopetx00000003", "2024-06-10", 700)
+	gym.Data["description"] = "GYM"
+	apitest.Insert(t, app, unmatched, spend("scopetx00000002", "2024-02-10", 500), gym,
+		apitest.Row{Table: "finance_categories", Data: dbx.Params{"id": "scopeparentxxxx", "workspace": apitest.Workspace, "name": "Household"}},
+	)
+	apitest.Expect(t, h, "PUT", "/api/finance/budget-items/"+apitest.BudgetItem, token, `{"rollover":"surplus"}`, http.StatusOK)
+
+	spent := func() []float64 {
+		t.Helper()
+		body := apitest.Expect(t, h, "GET", "/api/finance/budget/ledger?"+ws+"&budget_item="+apitest.BudgetItem, token, "", http.StatusOK)
+		var entries []domain.BudgetLedgerEntry
+		if err := json.Unmarshal([]byte(body), &entries); err != nil || len(entries) < 6 {
+			t.Fatalf("ledger %.300s", body)
+		}
+		var spent []float64
+		for _, entry := range entries[:6] {
+			spent = append(spent, entry.Spent)
+		}
+		return spent
+	}
+	if got := fmt.Sprint(spent()); got != "[0 500 0 0 0 700]" {
+		t.Fatalf("spent %s, want [0 500 0 0 0 700]", got)
+	}
+
+	// January and February are marked before each change; the months a
+	// change refreshes lose the mark
+	for _, step := range []struct {
+		name, method, target, token, body, want string
+	}{
+		{"rename item", "PUT", "/api/finance/budget-items/" + apitest.BudgetItem, token, `{"name":"Groceries"}`, "[1 1 0 0 0 700]"},
+		// From February, the first month the item was assigned
+		{"match pattern", "PUT", "/api/finance/budget-items/" + apitest.BudgetItem, token, `{"match_pattern":"GYM"}`, "[1 0 0 0 0 700]"},
+		{"rename category", "PATCH", "/api/collections/finance_categories/records/" + apitest.Category, superuser, `{"name":"Food"}`, "[1 1 0 0 0 700]"},
+		// From February, the first month with a transaction filed under it
+		{"move category", "PATCH", "/api/collections/finance_categories/records/" + apitest.Category, superuser, `{"parent":"scopeparentxxxx"}`, "[1 0 0 0 0 700]"},
+		{"budgeted amount", "PUT", "/api/finance/budget-items/" + apitest.BudgetItem, token, `{"budgeted_amount":4000}`, "[0 0 0 0 0 700]"},
+	} {
+		mark := dbx.NewExp("budget_item = {:item} AND year = 2024 AND month <= 2", dbx.Params{"item": apitest.BudgetItem})
+		if _, err := app.DB().Update("finance_budget_ledger", dbx.Params{"spent": 1}, mark).Execute(); err != nil {
+			t.Fatal(err)
+		}
+		apitest.Expect(t, h, step.method, step.target, step.token, step.body, http.StatusOK)
+		if got := fmt.Sprint(spent()); got != step.want {
+			t.Errorf("%s: spent %s, want %s", step.name, got, step.want)
+		}
+	}
+}
+
+func TestBudgetHistory(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	token := apitest.OwnerToken(t, app)
+	apitest.Insert(t, app,
+		spend("historytx000001", "2024-01-10", 3000),
+		spend("historytx000002", "2024-02-10", 4000),
+		spend("historytx000003", "2024-02-29", 2500),
+		spend("historytx000004", "2024-03-31", 6000),
+		spend("historytx000005", "2024-05-01", 9999), // after the range
+	)
+
+	code, body := apitest.Request(t, h, "GET", "/api/finance/budget/history?"+ws+"&start_date=2024-01-15&end_date=2024-04-30", token, "")
+	var history domain.BudgetHistory
+	if err := json.Unmarshal([]byte(body), &history); code != http.StatusOK || err != nil {
+		t.Fatalf("history: status %d: %.300s", code, body)
+	}
+	if fmt.Sprint(history.Months) != "[2024-01 2024-02 2024-03 2024-04]" {
+		t.Fatalf("months %v", history.Months)
+	}
+	if len(history.Budgets) != 1 || len(history.Budgets[0].Items) != 1 {
+		t.Fatalf("unexpected budgets %.300s", body)
+	}
+
+	item := history.Budgets[0].Items[0]
+	var actuals []float64
+	for _, m := range item.Months {
+		actuals = append(actuals, m.Actual)
+	}
+	if fmt.Sprint(actuals) != "[3000 6500 6000 0]" {
+		t.Errorf("actuals %v, want [3000 6500 6000 0]", actuals)
+	}
+	if item.TotalBudgeted != 20000 || item.TotalActual != 15500 || item.AverageActual != 3875 {
+		t.Errorf("totals %v / %v, average %v, want 20000 / 15500, 3875", item.TotalBudgeted, item.TotalActual, item.AverageActual)
+	}
+	if item.MonthsOverBudget != 2 || item.AverageOverspend != 1250 {
+		t.Errorf("%d months over by %v on average, want 2 by 1250", item.MonthsOverBudget, item.AverageOverspend)
+	}
+	if group := history.Budgets[0].Months[1]; group.Budgeted != 5000 || group.Difference != -1500 {
+		t.Errorf("group February %+v, want 5000 budgeted, -1500 difference", group)
+	}
+
+	code, body = apitest.Request(t, h, "GET", "/api/finance/budget/history?"+ws+"&start_date=2024-04-01&end_date=2024-01-31", token, "")
+	if code != http.StatusBadRequest || !strings.Contains(body, `"error":"end_date must not be before start_date"`) {
+		t.Errorf("reversed range: status %d: %s", code, body)
+	}
+	code, body = apitest.Request(t, h, "GET", "/api/finance/budget/history?"+ws+"&start_date=2024-04-01", token, "")
+	if code != http.StatusBadRequest || !strings.Contains(body, `"error":"workspace, start_date, and end_date required"`) {
+		t.Errorf("missing end_date: status %d: %s", code, body)
+	}
+}
+
+func TestBudgetAssignments(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	token := apitest.OwnerToken(t, app)
+	const shopItem, tx = "shopbudgetitemx", "assigntx0000001"
+	row := spend(tx, "2024-01-10", 1000)
+	row.Data["description"] = "SHOP"
+	apitest.Insert(t, app, row, apitest.Row{Table: "finance_budget_items", Data: dbx.Params{
+		"id": shopItem, "workspace": apitest.Workspace, "budget": apitest.Budget, "name": "Shop", "budgeted_amount": 1000,
+		"frequency": "monthly", "match_pattern": "shop", "match_pattern_type": "contains", "sort_order": 1, "is_active": true,
+	}})
+
+	// status returns what the seeded item and the shop item count of the transaction
+	status := func() (food, shop float64, summary domain.BudgetSummary) {
+		t.Helper()
+		code, body := apitest.Request(t, h, "GET", "/api/finance/budget/status?"+ws+"&start_date=2024-01-01&end_date=2024-01-31", token, "")
+		if err := json.Unmarshal([]byte(body), &summary); code != http.StatusOK || err != nil {
+			t.Fatalf("status: %d: %.300s", code, body)
+		}
+		for _, item := range summary.Budgets[0].Items {
+			switch item.BudgetItem.ID {
+			case apitest.BudgetItem:
+				food = item.ActualAmount
+			case shopItem:
+				shop = item.ActualAmount
+			}
+		}
+		return food, shop, summary
+	}
+
+	// Both items match; the first in budget order takes it
+	if food, shop, summary := status(); food != 1000 || shop != 0 || len(summary.Conflicts) != 1 || summary.MatchMode != "first" {
+		t.Fatalf("first match: food %v, shop %v, conflicts %+v", food, shop, summary.Conflicts)
+	}
+	apitest.Expect(t, h, "PUT", "/api/finance/budget-items/"+shopItem, token, `{"priority":5}`, http.StatusOK)
+	if food, shop, summary := status(); food != 0 || shop != 1000 || fmt.Sprint(summary.Conflicts[0].AssignedTo) != "["+shopItem+"]" {
+		t.Fatalf("priority: food %v, shop %v, conflicts %+v", food, shop, summary.Conflicts)
+	}
+	workspace, err := app.FindRecordById("workspaces", apitest.Workspace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	workspace.Set("budget_match_mode", "split")
+	if err := app.Save(workspace); err != nil {
+		t.Fatal(err)
+	}
+	// Saved outside of a request, the change is still refreshed before the next read
+	if food, shop, _ := status(); food != 500 || shop != 500 {
+		t.Fatalf("split: food %v, shop %v, want 500 each", food, shop)
+	}
+	body := apitest.Expect(t, h, "GET", "/api/finance/budget/assignments?"+ws+"&transaction="+tx, token, "", http.StatusOK)
+	if strings.Count(body, `"amount":500`) != 2 || strings.Contains(body, `"manual":true`) {
+		t.Errorf("stored split assignments %s", body)
+	}
+
+	// A manual assignment overrides the rules
+	apitest.Expect(t, h, "PUT", "/api/finance/transactions/"+tx+"/budget-item", token, `{"budget_item":"`+apitest.BudgetItem+`"}`, http.StatusOK)
+	if food, shop, summary := status(); food != 1000 || shop != 0 || len(summary.Conflicts) != 0 {
+		t.Fatalf("manual: food %v, shop %v, conflicts %+v", food, shop, summary.Conflicts)
+	}
+	body = apitest.Expect(t, h, "GET", "/api/finance/budget/assignments?"+ws+"&transaction="+tx, token, "", http.StatusOK)
+	if !strings.Contains(body, `"budget_item_id":"`+apitest.BudgetItem+`","amount":1000,"manual":true`) || strings.Count(body, `"id":`) != 1 {
+		t.Errorf("stored manual assignment %s", body)
+	}
+
+	// An empty budget item leaves the transaction out of budgets
+	apitest.Expect(t, h, "PUT", "/api/finance/transactions/"+tx+"/budget-item", token, `{"budget_item":""}`, http.StatusOK)
+	if food, shop, summary := status(); food != 0 || shop != 0 || len(summary.UnmatchedExpenses) != 0 {
+		t.Fatalf("left out: food %v, shop %v, unmatched %d", food, shop, len(summary.UnmatchedExpenses))
+	}
+
+	apitest.Expect(t, h, "DELETE", "/api/finance/transactions/"+tx+"/budget-item", token, "", http.StatusOK)
+	if food, shop, _ := status(); food != 500 || shop != 500 {
+		t.Fatalf("unassigned: food %v, shop %v, want 500 each", food, shop)
+	}
+	body = apitest.Expect(t, h, "GET", "/api/finance/budget/conflicts?"+ws+"&start_date=2024-01-01&end_date=2024-01-31", token, "", http.StatusOK)
+	if !strings.Contains(body, `"budget_item_ids":["`+shopItem+`","`+apitest.BudgetItem+`"]`) {
+		t.Errorf("conflicts %s", body)
+	}
+
+	for _, tt := range []struct{ body, err string }{
+		{`{"split_id":""}`, "budget_item required"},
+		{`{"budget_item":"missingxxxxxxxx"}`, "budget item not found"},
+		{`{"budget_item":"","split_id":"missingxxxxxxxx"}`, "split not found"},
+	} {
+		if body := apitest.Expect(t, h, "PUT", "/api/finance/transactions/"+tx+"/budget-item", token, tt.body, http.StatusBadRequest); !strings.Contains(body, `"error":"`+tt.err+`"`) {
+			t.Errorf("%s: %s, want %s", tt.body, body, tt.err)
+		}
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	token := apitest.OwnerToken(t, app)
+	const landlord = "2400123456/2010"
+	payment := func(id, description string, amount float64) apitest.Row {
+		return apitest.Row{Table: "finance_transactions", Data: dbx.Params{
+			"id": id, "workspace": apitest.Workspace, "account": apitest.Account, "type": "expense", "amount": amount,
+			"date": "2024-01-10 00:00:00.000Z", "description": description, "counterparty_account": landlord,
+		}}
+	}
+	apitest.Insert(t, app, payment("matchexprtx0001", "Rent January", 1200), payment("matchexprtx0002", "Late fee", 50))
+
+	create := func(body string) string {
+		t.Helper()
+		var item struct{ ID string }
+		_ = json.Unmarshal([]byte(apitest.Expect(t, h, "POST", "/api/finance/budget-items", token, `{"workspace":"`+apitest.Workspace+`","budget":"`+apitest.Budget+`",`+body+`}`, http.StatusOK)), &item)
+		return item.ID
+	}
+	actual := func() map[string]float64 {
+		t.Helper()
+		var summary domain.BudgetSummary
+		body := apitest.Expect(t, h, "GET", "/api/finance/budget/status?"+ws+"&start_date=2024-01-01&end_date=2024-01-31", token, "", http.StatusOK)
+		if err := json.Unmarshal([]byte(body), &summary); err != nil {
+			t.Fatal(err)
+		}
+		amounts := map[string]float64{}
+		for _, item := range summary.Budgets[0].Items {
+			amounts[item.BudgetItem.ID] = item.ActualAmount
+		}
+		return amounts
+	}
+
+	account := create(`"name":"Landlord","budgeted_amount":2000,"frequency":"monthly","is_active":true,` +
+		`"match_field":"counterparty_account","match_pattern":"` + landlord + `","match_pattern_type":"exact"`)
+	if got := actual()[account]; got != 1250 {
+		t.Fatalf("counterparty account item: %v, want 1250", got)
+	}
+
+	rent := create(`"name":"Rent","budgeted_amount":1200,"frequency":"monthly","is_active":true,"priority":5,"match_expression":{"all":[
+		{"field":"counterparty_account","op":"equals","value":"` + landlord + `"},
+		{"field":"amount","op":"between","min":1000,"max":1500},
+		{"field":"description","op":"contains","value":"rent"}]}`)
+	if got := actual(); got[rent] != 1200 || got[account] != 50 {
+		t.Fatalf("expression item: rent %v, landlord %v, want 1200, 50", got[rent], got[account])
+	}
+	body := apitest.Expect(t, h, "GET", "/api/finance/budgets?"+ws, token, "", http.StatusOK)
+	if !strings.Contains(body, `"match_expression":{"all":[{"field":"counterparty_account","op":"equals","value":"`+landlord+`"}`) {
+		t.Errorf("stored expression missing from %.500s", body)
+	}
+
+	// Without the expression the rent item has no criteria left
+	apitest.Expect(t, h, "PUT", "/api/finance/budget-items/"+rent, token, `{"match_expression":null}`, http.StatusOK)
+	if got := actual(); got[rent] != 0 || got[account] != 1250 {
+		t.Fatalf("expression removed: rent %v, landlord %v, want 0, 1250", got[rent], got[account])
+	}
+
+	body = apitest.Expect(t, h, "PUT", "/api/finance/budget-items/"+rent, token, `{"match_expression":{"field":"amount","op":"equals","value":"5"}}`, http.StatusBadRequest)
+	if !strings.Contains(body, `"error":"invalid match_expression: amount needs op between with min, max or both"`) {
+		t.Errorf("invalid expression: %s", body)
+	}
+
+	// Import rules take an expression in place of a pattern
+	rules, err := app.FindCollectionByNameOrId("finance_import_rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := core.NewRecord(rules)
+	rule.Load(map[string]any{"workspace": apitest.Workspace, "name": "Rent", "category": apitest.Category, "active": true, "priority": 10})
+	if err := app.Save(rule); err == nil || !strings.Contains(err.Error(), "pattern or match_expression required") {
+		t.Fatalf("rule without criteria: %v", err)
+	}
+	rule.Set("match_expression", `{"field":"amount","op":"more","value":"1000"}`)
+	if err := app.Save(rule); err == nil || !strings.Contains(err.Error(), "invalid match_expression") {
+		t.Fatalf("rule with invalid expression: %v", err)
+	}
+	rule.Set("match_expression", `{"all":[{"field":"counterparty_account","op":"equals","value":"`+landlord+`"},{"field":"amount","op":"between","min":1000}]}`)
+	if err := app.Save(rule); err != nil {
+		t.Fatal(err)
+	}
+	apitest.Expect(t, h, "POST", "/api/finance/categorize/apply-rules?"+ws, token, "", http.StatusOK)
+	for id, want := range map[string]string{"matchexprtx0001": apitest.Category, "matchexprtx0002": ""} {
+		if tx, err := app.FindRecordById("finance_transactions", id); err != nil || tx.GetString("category_rel") != want {
+			t.Errorf("%s category %q, want %q", id, tx.GetString("category_rel"), want)
+		}
+	}
+
+	// Rules see the merchant a transaction already has
+	if _, err := app.DB().Update("finance_transactions", dbx.Params{"merchant": apitest.Merchant}, dbx.HashExp{"id": "matchexprtx0002"}).Execute(); err != nil {
+		t.Fatal(err)
+	}
+	byMerchant := core.NewRecord(rules)
+	byMerchant.Load(map[string]any{"workspace": apitest.Workspace, "name": "Shop fees", "category": apitest.Category, "active": true, "priority": 5,
+		"match_expression": `{"field":"merchant","op":"equals","value":"` + apitest.Merchant + `"}`})
+	if err := app.Save(byMerchant); err != nil {
+		t.Fatal(err)
+	}
+	apitest.Expect(t, h, "POST", "/api/finance/categorize/apply-rules?"+ws, token, "", http.StatusOK)
+	if tx, err := app.FindRecordById("finance_transactions", "matchexprtx0002"); err != nil || tx.GetString("category_rel") != apitest.Category {
+		t.Errorf("merchant rule: category %q, want %q", tx.GetString("category_rel"), apitest.Category)
+	}
+}
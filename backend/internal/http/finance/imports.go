@@ -0,0 +1,270 @@
+package finance
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lifehub/backend/internal/domain"
+	"lifehub/backend/internal/http/respond"
+	"lifehub/backend/internal/services/categorization"
+	"lifehub/backend/internal/services/csvimport"
+	"lifehub/backend/internal/services/transfers"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// importConflict answers an upload of a statement imported before
+type importConflict struct {
+	respond.Error
+	PreviousImport *domain.FinanceImport `json:"previous_import"`
+}
+
+// rollbackResponse reports the transactions removed with an import
+type rollbackResponse struct {
+	Status              string `json:"status"`
+	TransactionsDeleted int    `json:"transactions_deleted"`
+}
+
+// pairRequest names the two transactions to merge or link as a transfer
+type pairRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// mergeResponse returns the transaction kept by a merge
+type mergeResponse struct {
+	Status string `json:"status"`
+	ID     string `json:"id"`
+}
+
+func previewImport(e *core.RequestEvent) error {
+	file, _, err := e.Request.FormFile("file")
+	if err != nil {
+		return respond.BadRequest(e, "file required")
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return respond.BadRequest(e, "failed to read file")
+	}
+
+	// Detect or get template (workspace templates take precedence over built-ins)
+	workspaceID := e.Request.FormValue("workspace")
+	templateCode := e.Request.FormValue("template")
+	if templateCode == "" {
+		templateCode = csvimport.DetectTemplate(data)
+	}
+	template, err := csvimport.ResolveTemplate(workspaceID, templateCode)
+	if err != nil {
+		return respond.BadRequest(e, err.Error())
+	}
+
+	// Unknown layout: propose a template and preview with it when confident
+	var inference *csvimport.InferenceResult
+	if templateCode == "generic" && template.Code == "generic" {
+		if inferred, err := csvimport.InferTemplate(data); err == nil {
+			inference = inferred
+			if inferred.Confidence >= csvimport.MinInferenceConfidence {
+				if t, err := csvimport.TemplateFromDomain(inferred.Template); err == nil {
+					template, templateCode = t, t.Code
+				}
+			}
+		}
+	}
+
+	result, err := csvimport.Parse(data, template)
+	if err != nil {
+		return respond.BadRequest(e, err.Error())
+	}
+
+	result.DetectedTemplate = templateCode
+	result.Inference = inference
+	if workspaceID != "" {
+		result.PreviousImport, _ = csvimport.FindImportByHash(workspaceID, "", csvimport.FileHash(data))
+	}
+	// Flag rows already present from another export of the same account
+	if accountID := e.Request.FormValue("account"); accountID != "" {
+		result.PossibleDuplicates, _ = csvimport.FindPreviewDuplicates(accountID, result.Transactions)
+	}
+	return e.JSON(http.StatusOK, result)
+}
+
+func importStatement(e *core.RequestEvent) error {
+	file, header, err := e.Request.FormFile("file")
+	if err != nil {
+		return respond.BadRequest(e, "file required")
+	}
+	defer file.Close()
+
+	accountID := e.Request.FormValue("account")
+	workspaceID := e.Request.FormValue("workspace")
+	sourceID := e.Request.FormValue("source")
+	templateCode := e.Request.FormValue("template")
+
+	if accountID == "" || workspaceID == "" {
+		return respond.BadRequest(e, "account and workspace required")
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return respond.BadRequest(e, "failed to read file")
+	}
+
+	// Refuse re-uploading the same statement unless explicitly forced
+	fileHash := csvimport.FileHash(data)
+	if e.Request.FormValue("force") != "true" {
+		if previous, _ := csvimport.FindImportByHash(workspaceID, accountID, fileHash); previous != nil {
+			return e.JSON(http.StatusConflict, importConflict{
+				Error:          respond.Error{Message: "this file was already imported into the account"},
+				PreviousImport: previous,
+			})
+		}
+	}
+
+	if templateCode == "" {
+		templateCode = csvimport.DetectTemplate(data)
+	}
+	template, err := csvimport.ResolveTemplate(workspaceID, templateCode)
+	if err != nil {
+		return respond.BadRequest(e, err.Error())
+	}
+
+	// Accept the template proposed by the preview without saving it first
+	if templateCode == "inferred" && template.Code == "generic" {
+		inferred, err := csvimport.InferTemplate(data)
+		if err != nil {
+			return respond.BadRequest(e, err.Error())
+		}
+		if template, err = csvimport.TemplateFromDomain(inferred.Template); err != nil {
+			return respond.BadRequest(e, err.Error())
+		}
+	}
+
+	parseResult, err := csvimport.Parse(data, template)
+	if err != nil {
+		return respond.BadRequest(e, err.Error())
+	}
+
+	// Category resolver using template mapping
+	categoryResolver := func(bankCategory string) string {
+		return categorization.MapBankCategory(workspaceID, bankCategory, template.CategoryMapping)
+	}
+
+	batch := csvimport.ImportBatch{
+		FileName:     header.Filename,
+		FileHash:     fileHash,
+		TemplateCode: templateCode,
+		TemplateName: template.Name,
+	}
+	run := func(progress func(processed, total int)) (*csvimport.ImportResult, error) {
+		result, err := csvimport.ImportTransactions(
+			parseResult.Transactions,
+			accountID,
+			workspaceID,
+			sourceID,
+			batch,
+			categoryResolver,
+			progress,
+		)
+		if err == nil && result.TransactionsImported > 0 {
+			// New rows may complete transfers with the workspace's other accounts
+			linked, candidates, err := transfers.DetectImported(workspaceID, result.ImportID)
+			if err != nil {
+				log.Printf("transfer detection after import %s failed: %v", result.ImportID, err)
+			}
+			result.TransfersLinked = len(linked)
+			result.TransferCandidates = candidates
+		}
+		return result, err
+	}
+
+	// Large statements run in the background; poll the job for progress
+	if e.Request.FormValue("async") == "true" {
+		job := csvimport.StartImportJob(workspaceID, len(parseResult.Transactions), run)
+		return e.JSON(http.StatusAccepted, job)
+	}
+
+	result, err := run(nil)
+	if err != nil {
+		return respond.Internal(e, err)
+	}
+	return e.JSON(http.StatusOK, result)
+}
+
+func getImportJob(e *core.RequestEvent) error {
+	job, ok := csvimport.GetImportJob(e.Request.PathValue("id"))
+	if !ok {
+		return respond.NotFound(e)
+	}
+	return e.JSON(http.StatusOK, job)
+}
+
+// importJobWorkspace scopes the job routes to the workspace the job imports into
+func importJobWorkspace(e *core.RequestEvent) (string, bool, error) {
+	job, ok := csvimport.GetImportJob(e.Request.PathValue("id"))
+	return job.WorkspaceID, ok, nil
+}
+
+func listImports(e *core.RequestEvent) error {
+	workspaceID := e.Request.URL.Query().Get("workspace")
+	if workspaceID == "" {
+		return respond.BadRequest(e, "workspace required")
+	}
+
+	imports, err := csvimport.ListImports(workspaceID)
+	if err != nil {
+		return respond.Internal(e, err)
+	}
+	return e.JSON(http.StatusOK, imports)
+}
+
+func rollbackImport(e *core.RequestEvent) error {
+	id := e.Request.PathValue("id")
+	if _, err := e.App.FindRecordById("finance_imports", id); err != nil {
+		return respond.NotFound(e)
+	}
+
+	deleted, err := csvimport.RollbackImport(id)
+	if err != nil {
+		return respond.Internal(e, err)
+	}
+	return e.JSON(http.StatusOK, rollbackResponse{Status: "ok", TransactionsDeleted: deleted})
+}
+
+func listDuplicates(e *core.RequestEvent) error {
+	accountID := e.Request.URL.Query().Get("account")
+	if accountID == "" {
+		return respond.BadRequest(e, "account required")
+	}
+
+	days := csvimport.DefaultDuplicateDays
+	if d, err := strconv.Atoi(e.Request.URL.Query().Get("days")); err == nil && d >= 0 {
+		days = d
+	}
+
+	transactions, err := csvimport.LoadStoredTransactions(accountID, time.Time{}, time.Now().AddDate(1, 0, 0))
+	if err != nil {
+		return respond.Internal(e, err)
+	}
+	return e.JSON(http.StatusOK, csvimport.FindStoredDuplicates(transactions, days))
+}
+
+func mergeTransactions(e *core.RequestEvent) error {
+	var body pairRequest
+	if err := json.NewDecoder(e.Request.Body).Decode(&body); err != nil {
+		return respond.InvalidJSON(e)
+	}
+	if len(body.IDs) != 2 {
+		return respond.BadRequest(e, "exactly two transaction ids required")
+	}
+
+	keptID, err := csvimport.MergeTransactions(body.IDs[0], body.IDs[1])
+	if err != nil {
+		return respond.BadRequest(e, err.Error())
+	}
+	return e.JSON(http.StatusOK, mergeResponse{Status: "ok", ID: keptID})
+}
@@ -0,0 +1,214 @@
+package finance
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"lifehub/backend/internal/domain"
+	"lifehub/backend/internal/filter"
+	"lifehub/backend/internal/http/respond"
+	"lifehub/backend/internal/services/budget"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// incomeSourceFields are the editable fields of an income source. Fields
+// left out of an update keep their value.
+type incomeSourceFields struct {
+	Name         *string  `json:"name"`
+	IncomeType   *string  `json:"income_type"`
+	Amount       *float64 `json:"amount"`
+	Currency     *string  `json:"currency"`
+	DefaultHours *float64 `json:"default_hours"`
+	IsActive     *bool    `json:"is_active"`
+	Notes        *string  `json:"notes"`
+}
+
+// incomeSourceRequest is the body of POST /income-sources
+type incomeSourceRequest struct {
+	Workspace string `json:"workspace"`
+	incomeSourceFields
+}
+
+// incomeHours is the hours worked for an hourly income source in a month
+type incomeHours struct {
+	ID           string  `json:"id"`
+	IncomeSource string  `json:"income_source"`
+	Year         int     `json:"year"`
+	Month        int     `json:"month"`
+	Hours        float64 `json:"hours"`
+}
+
+// incomeHoursRequest is the body of PUT /income-hours
+type incomeHoursRequest struct {
+	IncomeSource string  `json:"income_source"`
+	Year         int     `json:"year"`
+	Month        int     `json:"month"`
+	Hours        float64 `json:"hours"`
+	Workspace    string  `json:"workspace"`
+}
+
+// upsertResponse tells whether PUT /income-hours created or updated the record
+type upsertResponse struct {
+	ID     string `json:"id"`
+	Status string `json:"status"` // created, updated
+}
+
+func (f incomeSourceFields) validate() string {
+	if f.IncomeType != nil && *f.IncomeType != "fixed" && *f.IncomeType != "hourly" {
+		return "income_type must be fixed or hourly"
+	}
+	if f.Amount != nil && *f.Amount < 0 {
+		return "amount must not be negative"
+	}
+	return ""
+}
+
+func (f incomeSourceFields) apply(r *core.Record) {
+	set(r, "name", f.Name)
+	set(r, "income_type", f.IncomeType)
+	set(r, "amount", f.Amount)
+	set(r, "currency", f.Currency)
+	set(r, "default_hours", f.DefaultHours)
+	set(r, "is_active", f.IsActive)
+	set(r, "notes", f.Notes)
+}
+
+func listIncomeSources(e *core.RequestEvent) error {
+	workspaceID := e.Request.URL.Query().Get("workspace")
+	if workspaceID == "" {
+		return respond.BadRequest(e, "workspace required")
+	}
+
+	records, err := filter.Eq("workspace", workspaceID).Find(e.App, "finance_income_sources", "name", 100, 0)
+	if err != nil {
+		return e.JSON(http.StatusOK, []domain.IncomeSource{})
+	}
+
+	items := []domain.IncomeSource{}
+	for _, r := range records {
+		items = append(items, budget.IncomeSourceFromRecord(r))
+	}
+	return e.JSON(http.StatusOK, items)
+}
+
+func createIncomeSource(e *core.RequestEvent) error {
+	var body incomeSourceRequest
+	if err := json.NewDecoder(e.Request.Body).Decode(&body); err != nil {
+		return respond.InvalidJSON(e)
+	}
+	if missing(body.Name) {
+		return respond.BadRequest(e, "name required")
+	}
+	if problem := body.validate(); problem != "" {
+		return respond.BadRequest(e, problem)
+	}
+
+	return create(e, "finance_income_sources", func(r *core.Record) {
+		r.Set("workspace", body.Workspace)
+		body.apply(r)
+	})
+}
+
+func updateIncomeSource(e *core.RequestEvent) error {
+	var body incomeSourceFields
+	if err := json.NewDecoder(e.Request.Body).Decode(&body); err != nil {
+		return respond.InvalidJSON(e)
+	}
+	if body.Name != nil && *body.Name == "" {
+		return respond.BadRequest(e, "name required")
+	}
+	if problem := body.validate(); problem != "" {
+		return respond.BadRequest(e, problem)
+	}
+	return update(e, "finance_income_sources", body.apply)
+}
+
+func deleteIncomeSource(e *core.RequestEvent) error {
+	return remove(e, "finance_income_sources")
+}
+
+func listIncomeHours(e *core.RequestEvent) error {
+	query := e.Request.URL.Query()
+	workspaceID := query.Get("workspace")
+	if workspaceID == "" {
+		return respond.BadRequest(e, "workspace required")
+	}
+
+	f := filter.Eq("workspace", workspaceID)
+	for _, param := range []string{"year", "month"} {
+		if query.Get(param) == "" {
+			continue
+		}
+		n, err := strconv.Atoi(query.Get(param))
+		if err != nil {
+			return respond.BadRequest(e, "invalid "+param)
+		}
+		f.Eq(param, n)
+	}
+
+	records, err := f.Find(e.App, "finance_income_hours", "", 100, 0)
+	if err != nil {
+		return e.JSON(http.StatusOK, []incomeHours{})
+	}
+
+	items := []incomeHours{}
+	for _, r := range records {
+		items = append(items, incomeHours{
+			ID:           r.Id,
+			IncomeSource: r.GetString("income_source"),
+			Year:         int(r.GetFloat("year")),
+			Month:        int(r.GetFloat("month")),
+			Hours:        r.GetFloat("hours"),
+		})
+	}
+	return e.JSON(http.StatusOK, items)
+}
+
+// upsertIncomeHours sets the hours of a source in a month, creating the
+// record on first use
+func upsertIncomeHours(e *core.RequestEvent) error {
+	var body incomeHoursRequest
+	if err := json.NewDecoder(e.Request.Body).Decode(&body); err != nil {
+		return respond.InvalidJSON(e)
+	}
+	switch {
+	case body.IncomeSource == "":
+		return respond.BadRequest(e, "income_source required")
+	case body.Year < 1:
+		return respond.BadRequest(e, "invalid year")
+	case body.Month < 1 || body.Month > 12:
+		return respond.BadRequest(e, "invalid month")
+	case body.Hours < 0:
+		return respond.BadRequest(e, "hours must not be negative")
+	}
+
+	existing, err := filter.Eq("workspace", body.Workspace).
+		Eq("income_source", body.IncomeSource).
+		Eq("year", body.Year).
+		Eq("month", body.Month).
+		First(e.App, "finance_income_hours")
+	if err == nil {
+		existing.Set("hours", body.Hours)
+		if err := e.App.Save(existing); err != nil {
+			return respond.Internal(e, err)
+		}
+		return e.JSON(http.StatusOK, upsertResponse{ID: existing.Id, Status: "updated"})
+	}
+
+	collection, err := e.App.FindCollectionByNameOrId("finance_income_hours")
+	if err != nil {
+		return respond.Internal(e, err)
+	}
+	record := core.NewRecord(collection)
+	record.Set("income_source", body.IncomeSource)
+	record.Set("year", body.Year)
+	record.Set("month", body.Month)
+	record.Set("hours", body.Hours)
+	record.Set("workspace", body.Workspace)
+	if err := e.App.Save(record); err != nil {
+		return respond.Internal(e, err)
+	}
+	return e.JSON(http.StatusOK, upsertResponse{ID: record.Id, Status: "created"})
+}
@@ -0,0 +1,78 @@
+package finance
+
+import (
+	"net/http"
+
+	"lifehub/backend/internal/domain"
+	"lifehub/backend/internal/filter"
+	"lifehub/backend/internal/http/respond"
+	"lifehub/backend/internal/services/recurring"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+func listRecurring(e *core.RequestEvent) error {
+	workspaceID := e.Request.URL.Query().Get("workspace")
+	if workspaceID == "" {
+		return respond.BadRequest(e, "workspace required")
+	}
+
+	records, err := filter.Eq("workspace", workspaceID).Find(e.App, "finance_recurring", "next_due", 100, 0)
+	if err != nil {
+		return e.JSON(http.StatusOK, []domain.RecurringPayment{})
+	}
+
+	items := []domain.RecurringPayment{}
+	for _, r := range records {
+		p := domain.RecurringPayment{
+			ID:             r.Id,
+			MerchantID:     r.GetString("merchant"),
+			ExpectedAmount: r.GetFloat("expected_amount"),
+			Frequency:      r.GetString("frequency"),
+			Status:         r.GetString("status"),
+		}
+		if merchant, err := e.App.FindRecordById("finance_merchants", p.MerchantID); err == nil {
+			p.MerchantName = merchant.GetString("display_name")
+			if p.MerchantName == "" {
+				p.MerchantName = merchant.GetString("name")
+			}
+		}
+		if d := r.GetDateTime("next_due"); !d.IsZero() {
+			t := d.Time()
+			p.NextDue = &t
+		}
+		if d := r.GetDateTime("last_paid"); !d.IsZero() {
+			t := d.Time()
+			p.LastPaid = &t
+		}
+		items = append(items, p)
+	}
+	return e.JSON(http.StatusOK, items)
+}
+
+func detectRecurring(e *core.RequestEvent) error {
+	workspaceID := e.Request.URL.Query().Get("workspace")
+	accountID := e.Request.URL.Query().Get("account")
+	if workspaceID == "" {
+		return respond.BadRequest(e, "workspace required")
+	}
+
+	results, err := recurring.DetectRecurring(workspaceID, accountID, 3)
+	if err != nil {
+		return respond.Internal(e, err)
+	}
+	return e.JSON(http.StatusOK, results)
+}
+
+func listUpcoming(e *core.RequestEvent) error {
+	workspaceID := e.Request.URL.Query().Get("workspace")
+	if workspaceID == "" {
+		return respond.BadRequest(e, "workspace required")
+	}
+
+	upcoming, err := recurring.GetUpcomingPayments(workspaceID, 14) // Next 2 weeks
+	if err != nil {
+		return respond.Internal(e, err)
+	}
+	return e.JSON(http.StatusOK, upcoming)
+}
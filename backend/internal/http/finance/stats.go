@@ -0,0 +1,222 @@
+package finance
+
+import (
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"lifehub/backend/internal/filter"
+	"lifehub/backend/internal/http/respond"
+	"lifehub/backend/internal/services/currency"
+	"lifehub/backend/internal/services/stats"
+	"lifehub/backend/internal/services/trends"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// netWorth is cash, investments and debt in the workspace base currency
+type netWorth struct {
+	Currency     string         `json:"currency"`
+	Cash         float64        `json:"cash"`
+	Investments  float64        `json:"investments"`
+	Debt         float64        `json:"debt"`
+	TotalAssets  float64        `json:"total_assets"`
+	NetWorth     float64        `json:"net_worth"`
+	Items        []netWorthItem `json:"items"`
+	MissingRates []string       `json:"missing_rates"`
+}
+
+// netWorthItem is one account, portfolio or loan with its original amount
+type netWorthItem struct {
+	Kind       string  `json:"kind"` // cash, investments, debt
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	Amount     float64 `json:"amount"`
+	Currency   string  `json:"currency"`
+	BaseAmount float64 `json:"base_amount"`
+}
+
+func getStats(e *core.RequestEvent) error {
+	query := e.Request.URL.Query()
+	q := stats.Query{
+		WorkspaceID: query.Get("workspace"),
+		AccountID:   query.Get("account"),
+		CategoryID:  query.Get("category"),
+		StartDate:   query.Get("start_date"),
+		EndDate:     query.Get("end_date"),
+	}
+	if q.WorkspaceID == "" {
+		return respond.BadRequest(e, "workspace required")
+	}
+
+	// Totals are summed by SQL; only the grouped rows are converted and named here
+	result, err := stats.Compute(q)
+	if err != nil {
+		return respond.Internal(e, err)
+	}
+
+	// Optional per-category series, e.g. trend=month
+	if interval := query.Get("trend"); interval != "" {
+		tq := trends.Query{Interval: interval, AccountID: q.AccountID}
+		tq.From, _ = time.Parse("2006-01-02", q.StartDate)
+		tq.To, _ = time.Parse("2006-01-02", q.EndDate)
+		if report, err := trends.Load(q.WorkspaceID, tq); err == nil {
+			result.ByCategoryTrend = trends.ByName(report)
+		}
+	}
+	return e.JSON(http.StatusOK, result)
+}
+
+// getTrends reports per-category, merchant or account totals bucketed by
+// day/week/month/year
+func getTrends(e *core.RequestEvent) error {
+	query := e.Request.URL.Query()
+	workspaceID := query.Get("workspace")
+	if workspaceID == "" {
+		return respond.BadRequest(e, "workspace required")
+	}
+
+	q := trends.Query{
+		Interval:  query.Get("interval"),
+		GroupBy:   query.Get("group_by"),
+		Type:      query.Get("type"),
+		AccountID: query.Get("account"),
+	}
+	q.From, _ = time.Parse("2006-01-02", query.Get("start_date"))
+	q.To, _ = time.Parse("2006-01-02", query.Get("end_date"))
+	if w, err := strconv.Atoi(query.Get("window")); err == nil && w > 0 {
+		q.Window = w
+	}
+
+	report, err := trends.Load(workspaceID, q)
+	if err != nil {
+		return respond.BadRequest(e, err.Error())
+	}
+	return e.JSON(http.StatusOK, report)
+}
+
+// getNetWorth converts cash, investments and debt to the workspace base
+// currency, each item reported with its original amount and currency
+func getNetWorth(e *core.RequestEvent) error {
+	workspaceID := e.Request.URL.Query().Get("workspace")
+	if workspaceID == "" {
+		return respond.BadRequest(e, "workspace required")
+	}
+
+	conv := currency.ForWorkspace(workspaceID)
+	now := time.Now()
+	result := netWorth{Currency: conv.Base, Items: []netWorthItem{}, MissingRates: []string{}}
+	missingRates := map[string]bool{}
+
+	add := func(kind, id, name string, amount float64, cur string) {
+		converted, ok := conv.ToBase(amount, cur, now)
+		if !ok {
+			missingRates[strings.ToUpper(cur)] = true
+		}
+		switch kind {
+		case "cash":
+			result.Cash += converted
+		case "investments":
+			result.Investments += converted
+		case "debt":
+			result.Debt += converted
+		}
+		result.Items = append(result.Items, netWorthItem{
+			Kind:       kind,
+			ID:         id,
+			Name:       name,
+			Amount:     amount,
+			Currency:   cur,
+			BaseAmount: converted,
+		})
+	}
+
+	balances, err := stats.Balances(workspaceID)
+	if err != nil {
+		return respond.Internal(e, err)
+	}
+	accounts, _ := filter.Eq("workspace", workspaceID).Find(e.App, "finance_accounts", "name", 0, 0)
+	for _, acc := range accounts {
+		add("cash", acc.Id, acc.GetString("name"), acc.GetFloat("initial_balance")+balances[acc.Id], acc.GetString("currency"))
+	}
+
+	portfolios, _ := filter.Eq("workspace", workspaceID).Find(e.App, "investment_portfolios", "name", 0, 0)
+	for _, p := range portfolios {
+		snapshots, err := filter.Eq("portfolio", p.Id).Find(e.App, "investment_snapshots", "-report_date", 1, 0)
+		if err != nil || len(snapshots) == 0 {
+			continue
+		}
+		add("investments", p.Id, p.GetString("name"), snapshots[0].GetFloat("end_value"), p.GetString("currency"))
+	}
+
+	loans, _ := filter.Eq("workspace", workspaceID).Where("is_active = true").Find(e.App, "finance_loans", "name", 0, 0)
+	for _, l := range loans {
+		add("debt", l.Id, l.GetString("name"), l.GetFloat("current_balance"), l.GetString("currency"))
+	}
+
+	for cur := range missingRates {
+		result.MissingRates = append(result.MissingRates, cur)
+	}
+	sort.Strings(result.MissingRates)
+
+	result.TotalAssets = result.Cash + result.Investments
+	result.NetWorth = result.TotalAssets - result.Debt
+	return e.JSON(http.StatusOK, result)
+}
+
+// importRates imports a CNB or ECB rate file; without a file the source's
+// configured URL is downloaded
+func importRates(e *core.RequestEvent) error {
+	source := e.Request.FormValue("source")
+
+	var data []byte
+	file, _, err := e.Request.FormFile("file")
+	if err == nil {
+		defer file.Close()
+		data, err = io.ReadAll(file)
+		if err != nil {
+			return respond.BadRequest(e, "failed to read file")
+		}
+	} else {
+		if source == "" {
+			return respond.BadRequest(e, "file or source required")
+		}
+		data, err = currency.FetchRates(source)
+		if err != nil {
+			return respond.Fail(e, http.StatusBadGateway, err.Error())
+		}
+	}
+
+	result, err := currency.Ingest(source, data)
+	if err != nil {
+		return respond.BadRequest(e, err.Error())
+	}
+	return e.JSON(http.StatusOK, result)
+}
+
+// listRateGaps lists business days without rates for the currencies of the
+// workspace's accounts
+func listRateGaps(e *core.RequestEvent) error {
+	workspaceID := e.Request.URL.Query().Get("workspace")
+	if workspaceID == "" {
+		return respond.BadRequest(e, "workspace required")
+	}
+
+	to := time.Now()
+	if t, err := time.Parse("2006-01-02", e.Request.URL.Query().Get("to")); err == nil {
+		to = t
+	}
+	from := to.AddDate(-1, 0, 0)
+	if t, err := time.Parse("2006-01-02", e.Request.URL.Query().Get("from")); err == nil {
+		from = t
+	}
+
+	gaps, err := currency.FindGaps(workspaceID, from, to)
+	if err != nil {
+		return respond.BadRequest(e, err.Error())
+	}
+	return e.JSON(http.StatusOK, gaps)
+}
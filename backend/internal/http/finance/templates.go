@@ -0,0 +1,145 @@
+package finance
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"lifehub/backend/internal/domain"
+	"lifehub/backend/internal/filter"
+	"lifehub/backend/internal/http/respond"
+	"lifehub/backend/internal/services/csvimport"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// templateSummary lists a built-in or custom bank template
+type templateSummary struct {
+	ID     string `json:"id,omitempty"`
+	Code   string `json:"code"`
+	Name   string `json:"name"`
+	Format string `json:"format"`
+	Custom bool   `json:"custom,omitempty"`
+}
+
+// templateError explains why a template was rejected
+type templateError struct {
+	respond.Error
+	ValidationErrors []string `json:"validation_errors,omitempty"`
+	ID               string   `json:"id,omitempty"` // of the template already using the code
+}
+
+func listTemplates(e *core.RequestEvent) error {
+	workspaceID := e.Request.URL.Query().Get("workspace")
+
+	result := []templateSummary{}
+	for code, t := range csvimport.GetTemplates() {
+		result = append(result, templateSummary{Code: code, Name: t.Name, Format: t.Format})
+	}
+
+	// Custom templates override built-ins with the same code
+	if workspaceID != "" {
+		custom, err := csvimport.LoadCustomTemplates(workspaceID)
+		if err != nil {
+			return respond.Internal(e, err)
+		}
+		for _, t := range custom {
+			entry := templateSummary{ID: t.ID, Code: t.Code, Name: t.Name, Format: t.Format, Custom: true}
+			replaced := false
+			for i, existing := range result {
+				if existing.Code == t.Code {
+					result[i] = entry
+					replaced = true
+				}
+			}
+			if !replaced {
+				result = append(result, entry)
+			}
+		}
+	}
+	return e.JSON(http.StatusOK, result)
+}
+
+func getTemplate(e *core.RequestEvent) error {
+	record, err := e.App.FindRecordById("finance_bank_templates", e.Request.PathValue("id"))
+	if err != nil {
+		return respond.NotFound(e)
+	}
+	return e.JSON(http.StatusOK, csvimport.TemplateRecordToDomain(record))
+}
+
+func createTemplate(e *core.RequestEvent) error {
+	var body domain.BankTemplate
+	if err := json.NewDecoder(e.Request.Body).Decode(&body); err != nil {
+		return respond.InvalidJSON(e)
+	}
+	if body.WorkspaceID == "" {
+		return respond.BadRequest(e, "workspace_id required")
+	}
+	if problems := csvimport.ValidateTemplate(body); len(problems) > 0 {
+		return e.JSON(http.StatusBadRequest, templateError{
+			Error:            respond.Error{Message: "invalid template"},
+			ValidationErrors: problems,
+		})
+	}
+
+	if dupe, err := filter.Eq("workspace", body.WorkspaceID).Eq("code", body.Code).First(e.App, "finance_bank_templates"); err == nil {
+		return codeTaken(e, dupe)
+	}
+
+	return create(e, "finance_bank_templates", func(r *core.Record) {
+		csvimport.ApplyTemplateToRecord(r, body)
+	})
+}
+
+func updateTemplate(e *core.RequestEvent) error {
+	record, err := e.App.FindRecordById("finance_bank_templates", e.Request.PathValue("id"))
+	if err != nil {
+		return respond.NotFound(e)
+	}
+	if record.GetBool("is_system") || record.GetString("workspace") == "" {
+		return respond.Fail(e, http.StatusForbidden, "system templates cannot be modified")
+	}
+
+	var body domain.BankTemplate
+	if err := json.NewDecoder(e.Request.Body).Decode(&body); err != nil {
+		return respond.InvalidJSON(e)
+	}
+	body.WorkspaceID = record.GetString("workspace")
+	if problems := csvimport.ValidateTemplate(body); len(problems) > 0 {
+		return e.JSON(http.StatusBadRequest, templateError{
+			Error:            respond.Error{Message: "invalid template"},
+			ValidationErrors: problems,
+		})
+	}
+	if dupe, err := filter.Eq("workspace", body.WorkspaceID).Eq("code", body.Code).Neq("id", record.Id).First(e.App, "finance_bank_templates"); err == nil {
+		return codeTaken(e, dupe)
+	}
+
+	csvimport.ApplyTemplateToRecord(record, body)
+	if err := e.App.Save(record); err != nil {
+		return respond.Internal(e, err)
+	}
+	return respond.OK(e)
+}
+
+// codeTaken answers 409 with the template of the workspace already using a code
+func codeTaken(e *core.RequestEvent, dupe *core.Record) error {
+	return e.JSON(http.StatusConflict, templateError{
+		Error: respond.Error{Message: "a template with this code already exists"},
+		ID:    dupe.Id,
+	})
+}
+
+func deleteTemplate(e *core.RequestEvent) error {
+	record, err := e.App.FindRecordById("finance_bank_templates", e.Request.PathValue("id"))
+	if err != nil {
+		return respond.NotFound(e)
+	}
+	if record.GetBool("is_system") || record.GetString("workspace") == "" {
+		return respond.Fail(e, http.StatusForbidden, "system templates cannot be deleted")
+	}
+	if err := e.App.Delete(record); err != nil {
+		return respond.Internal(e, err)
+	}
+	return respond.OK(e)
+}
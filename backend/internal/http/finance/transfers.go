@@ -0,0 +1,95 @@
+package finance
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"lifehub/backend/internal/domain"
+	"lifehub/backend/internal/http/respond"
+	"lifehub/backend/internal/services/splits"
+	"lifehub/backend/internal/services/transfers"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// detectResponse lists the transfer pairs found, linked unless a dry run
+type detectResponse struct {
+	Pairs   []transfers.Pair `json:"pairs"`
+	Count   int              `json:"count"`
+	Applied bool             `json:"applied"`
+}
+
+// splitsRequest is the body of PUT /transactions/{id}/splits
+type splitsRequest struct {
+	Splits []domain.TransactionSplit `json:"splits"`
+}
+
+func detectTransfers(e *core.RequestEvent) error {
+	workspaceID := e.Request.URL.Query().Get("workspace")
+	if workspaceID == "" {
+		return respond.BadRequest(e, "workspace required")
+	}
+
+	days := transfers.DefaultWindowDays
+	if d, err := strconv.Atoi(e.Request.URL.Query().Get("days")); err == nil && d >= 0 {
+		days = d
+	}
+	dryRun := e.Request.URL.Query().Get("dry_run") == "true"
+
+	pairs, err := transfers.DetectTransfers(workspaceID, days, dryRun)
+	if err != nil {
+		return respond.Internal(e, err)
+	}
+	return e.JSON(http.StatusOK, detectResponse{Pairs: pairs, Count: len(pairs), Applied: !dryRun})
+}
+
+func linkTransfer(e *core.RequestEvent) error {
+	var body pairRequest
+	if err := json.NewDecoder(e.Request.Body).Decode(&body); err != nil {
+		return respond.InvalidJSON(e)
+	}
+	if len(body.IDs) != 2 {
+		return respond.BadRequest(e, "exactly two transaction ids required")
+	}
+	if err := transfers.LinkTransfer(body.IDs[0], body.IDs[1]); err != nil {
+		return respond.BadRequest(e, err.Error())
+	}
+	return respond.OK(e)
+}
+
+func unlinkTransfer(e *core.RequestEvent) error {
+	if err := transfers.UnlinkTransfer(e.Request.PathValue("id")); err != nil {
+		return respond.BadRequest(e, err.Error())
+	}
+	return respond.OK(e)
+}
+
+func getSplits(e *core.RequestEvent) error {
+	result, err := splits.Get(e.Request.PathValue("id"))
+	if err != nil {
+		return respond.Internal(e, err)
+	}
+	return e.JSON(http.StatusOK, result)
+}
+
+// replaceSplits replaces all splits of a transaction; an empty list removes the split
+func replaceSplits(e *core.RequestEvent) error {
+	var body splitsRequest
+	if err := json.NewDecoder(e.Request.Body).Decode(&body); err != nil {
+		return respond.InvalidJSON(e)
+	}
+
+	result, err := splits.Replace(e.Request.PathValue("id"), body.Splits)
+	if err != nil {
+		return respond.BadRequest(e, err.Error())
+	}
+	return e.JSON(http.StatusOK, result)
+}
+
+func deleteSplits(e *core.RequestEvent) error {
+	if _, err := splits.Replace(e.Request.PathValue("id"), nil); err != nil {
+		return respond.BadRequest(e, err.Error())
+	}
+	return respond.OK(e)
+}
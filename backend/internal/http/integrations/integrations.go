@@ -0,0 +1,171 @@
+// Package integrations serves the source marketplace and the OAuth2 flows
+// that connect third-party sources to a workspace.
+package integrations
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+
+	"lifehub/backend/internal/access"
+	"lifehub/backend/internal/http/respond"
+	"lifehub/backend/internal/services/members"
+	"lifehub/backend/internal/sources"
+	"lifehub/backend/internal/sources/google_calendar"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+	"golang.org/x/oauth2"
+)
+
+// oauthSources maps the source types connected through OAuth2 to the route
+// starting the flow
+var oauthSources = map[string]string{
+	"google_calendar": "/api/oauth/google/initiate",
+}
+
+// availableSource describes a source type users can add
+type availableSource struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Icon        string `json:"icon"`
+	AuthType    string `json:"auth_type,omitempty"`
+	AuthURL     string `json:"auth_url,omitempty"`
+}
+
+// oauthState travels through the provider to the callback
+type oauthState struct {
+	Workspace string `json:"workspace"`
+	UserID    string `json:"user_id"`
+}
+
+// redirectResponse holds the provider URL to send the user to
+type redirectResponse struct {
+	URL string `json:"url"`
+}
+
+// Register binds the marketplace and OAuth2 routes
+func Register(r *router.Router[*core.RequestEvent]) {
+	r.GET("/api/sources/available", listAvailable)
+	r.GET("/api/oauth/google/initiate", initiateGoogle)
+	r.GET("/api/oauth/google/callback", googleCallback)
+}
+
+func listAvailable(e *core.RequestEvent) error {
+	available := []availableSource{}
+	for _, factory := range sources.Registry {
+		s := factory()
+		entry := availableSource{
+			ID:          s.ID(),
+			Name:        s.Name(),
+			Description: s.Description(),
+			Icon:        s.Icon(),
+		}
+		if authURL, ok := oauthSources[s.ID()]; ok {
+			entry.AuthType = "oauth2"
+			entry.AuthURL = authURL
+		}
+		available = append(available, entry)
+	}
+	return e.JSON(http.StatusOK, available)
+}
+
+// initiateGoogle returns the Google consent URL for connecting a calendar to
+// a workspace the user may edit
+func initiateGoogle(e *core.RequestEvent) error {
+	if e.Auth == nil {
+		return respond.Fail(e, http.StatusUnauthorized, "Authentication required")
+	}
+
+	workspaceID := e.Request.URL.Query().Get("workspace")
+	if workspaceID == "" {
+		return respond.BadRequest(e, "workspace required")
+	}
+	ok, err := access.Allowed(workspaceID, e.Auth.Id, members.Editor)
+	if err != nil {
+		return respond.Internal(e, err)
+	}
+	if !ok {
+		return respond.Fail(e, http.StatusForbidden, "access to the workspace denied")
+	}
+
+	stateJSON, _ := json.Marshal(oauthState{Workspace: workspaceID, UserID: e.Auth.Id})
+	state := base64.URLEncoding.EncodeToString(stateJSON)
+
+	url := google_calendar.GetOAuthConfig().AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.ApprovalForce)
+	return e.JSON(http.StatusOK, redirectResponse{URL: url})
+}
+
+// googleCallback exchanges the code for tokens, saves the calendar source
+// and sends the user back to the frontend
+func googleCallback(e *core.RequestEvent) error {
+	code := e.Request.URL.Query().Get("code")
+	stateStr := e.Request.URL.Query().Get("state")
+	if code == "" || stateStr == "" {
+		return respond.BadRequest(e, "missing code or state")
+	}
+
+	stateJSON, err := base64.URLEncoding.DecodeString(stateStr)
+	if err != nil {
+		return respond.BadRequest(e, "invalid state")
+	}
+	var state oauthState
+	if err := json.Unmarshal(stateJSON, &state); err != nil {
+		return respond.BadRequest(e, "invalid state JSON")
+	}
+	if state.Workspace == "" {
+		return respond.BadRequest(e, "missing workspace in state")
+	}
+
+	oauthCfg := google_calendar.GetOAuthConfig()
+	tok, err := oauthCfg.Exchange(context.Background(), code)
+	if err != nil {
+		log.Printf("OAuth exchange error: %v", err)
+		return respond.Fail(e, http.StatusInternalServerError, "failed to exchange token")
+	}
+
+	// Fetch user info to get email for the source name
+	client := oauthCfg.Client(context.Background(), tok)
+	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
+	sourceName := "Google Calendar"
+	if err == nil {
+		defer resp.Body.Close()
+		var userInfo struct {
+			Email string `json:"email"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&userInfo) == nil && userInfo.Email != "" {
+			sourceName = "Calendar (" + userInfo.Email + ")"
+		}
+	}
+
+	collection, err := e.App.FindCollectionByNameOrId("sources")
+	if err != nil {
+		return respond.Fail(e, http.StatusInternalServerError, "sources collection not found")
+	}
+
+	record := core.NewRecord(collection)
+	record.Set("name", sourceName)
+	record.Set("type", "google_calendar")
+	record.Set("workspace", state.Workspace)
+	record.Set("active", true)
+	record.Set("config", map[string]any{
+		"access_token":  tok.AccessToken,
+		"refresh_token": tok.RefreshToken,
+		"token_expiry":  tok.Expiry.Format("2006-01-02T15:04:05Z07:00"),
+		"token_type":    tok.TokenType,
+	})
+	if err := e.App.Save(record); err != nil {
+		log.Printf("Failed to save Google Calendar source: %v", err)
+		return respond.Fail(e, http.StatusInternalServerError, "failed to save source")
+	}
+
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:3000"
+	}
+	return e.Redirect(http.StatusTemporaryRedirect, frontendURL)
+}
@@ -0,0 +1,40 @@
+package integrations_test
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+
+	"lifehub/backend/internal/http/apitest"
+)
+
+func TestRoutes(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	owner := apitest.OwnerToken(t, app)
+	_, stranger := apitest.NewUser(t, app, "stranger@example.com")
+	noWorkspace := base64.URLEncoding.EncodeToString([]byte(`{"user_id":"x"}`))
+
+	tests := []struct {
+		name, target, token string
+		want                int
+		contains            string
+	}{
+		{"available", "/api/sources/available", "", http.StatusOK, `"auth_type":"oauth2","auth_url":"/api/oauth/google/initiate"`},
+		{"initiate", "/api/oauth/google/initiate?workspace=" + apitest.Workspace, owner, http.StatusOK, `"url":"https://accounts.google.com/`},
+		{"initiate anonymously", "/api/oauth/google/initiate?workspace=" + apitest.Workspace, "", http.StatusUnauthorized, `"error":"Authentication required"`},
+		{"initiate without workspace", "/api/oauth/google/initiate", owner, http.StatusBadRequest, `"error":"workspace required"`},
+		{"initiate for a foreign workspace", "/api/oauth/google/initiate?workspace=" + apitest.Workspace, stranger, http.StatusForbidden, `"error":"access to the workspace denied"`},
+		{"callback without code", "/api/oauth/google/callback?state=x", "", http.StatusBadRequest, `"error":"missing code or state"`},
+		{"callback with bad state", "/api/oauth/google/callback?code=x&state=%25", "", http.StatusBadRequest, `"error":"invalid state"`},
+		{"callback without workspace", "/api/oauth/google/callback?code=x&state=" + noWorkspace, "", http.StatusBadRequest, `"error":"missing workspace in state"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := apitest.Request(t, h, "GET", tt.target, tt.token, "")
+			if code != tt.want || !strings.Contains(body, tt.contains) {
+				t.Errorf("status %d: %.300s, want %d with %s", code, body, tt.want, tt.contains)
+			}
+		})
+	}
+}
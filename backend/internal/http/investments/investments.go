@@ -0,0 +1,401 @@
+// Package investments serves the /api/investments routes: statement imports
+// and the portfolio and snapshot history of a workspace.
+package investments
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"lifehub/backend/internal/access"
+	"lifehub/backend/internal/filter"
+	"lifehub/backend/internal/http/respond"
+	invest "lifehub/backend/internal/services/investments"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+// providers are the statement formats POST /import understands
+var providers = []string{"fondee", "amundi", "revolut-stocks", "revolut-crypto"}
+
+// validationError returns what a statement was parsed into along with what
+// is missing from it
+type validationError struct {
+	respond.Error
+	ValidationErrors []string                  `json:"validation_errors"`
+	PartialSnapshot  *invest.PortfolioSnapshot `json:"partial_snapshot"`
+}
+
+// duplicateError points at the snapshot already imported for the report date
+type duplicateError struct {
+	respond.Error
+	Detail      string `json:"message"`
+	SnapshotID  string `json:"snapshot_id"`
+	PortfolioID string `json:"portfolio_id"`
+}
+
+// importResponse returns the snapshot saved by POST /import
+type importResponse struct {
+	Status      string                    `json:"status"`
+	PortfolioID string                    `json:"portfolio_id"`
+	SnapshotID  string                    `json:"snapshot_id"`
+	Snapshot    *invest.PortfolioSnapshot `json:"snapshot"`
+}
+
+// portfolio is a portfolio with its most recent snapshot
+type portfolio struct {
+	ID             string    `json:"id"`
+	Provider       string    `json:"provider"`
+	Name           string    `json:"name"`
+	ContractID     string    `json:"contract_id"`
+	Currency       string    `json:"currency"`
+	LatestSnapshot *snapshot `json:"latest_snapshot,omitempty"`
+}
+
+// snapshot is the state of a portfolio at a report date
+type snapshot struct {
+	ID          string    `json:"id"`
+	ReportDate  time.Time `json:"report_date"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	StartValue  float64   `json:"start_value"`
+	EndValue    float64   `json:"end_value"`
+	Invested    float64   `json:"invested"`
+	GainLoss    float64   `json:"gain_loss"`
+	Fees        float64   `json:"fees"`
+	Holdings    []holding `json:"holdings,omitempty"`
+}
+
+// holding is one fund or asset position of a snapshot
+type holding struct {
+	ID            string  `json:"id"`
+	Name          string  `json:"name"`
+	ISIN          string  `json:"isin"`
+	Category      string  `json:"category"`
+	Units         float64 `json:"units"`
+	PricePerUnit  float64 `json:"price_per_unit"`
+	PriceCurrency string  `json:"price_currency"`
+	TotalValue    float64 `json:"total_value"`
+	ValueCurrency string  `json:"value_currency"`
+}
+
+// Register binds the investment routes, scoped to one workspace by
+// access.RequireWorkspace
+func Register(r *router.Router[*core.RequestEvent]) {
+	api := r.Group("/api/investments").Bind(access.RequireWorkspace())
+
+	api.POST("/import", importStatement)
+	api.GET("/portfolios", listPortfolios)
+	api.GET("/snapshots", listSnapshots)
+}
+
+// importStatement parses a provider statement and saves it as a snapshot of
+// the matching portfolio, created on first import
+func importStatement(e *core.RequestEvent) error {
+	file, _, err := e.Request.FormFile("file")
+	if err != nil {
+		return respond.BadRequest(e, "file required")
+	}
+	defer file.Close()
+
+	workspaceID := e.Request.FormValue("workspace")
+	provider := e.Request.FormValue("provider")
+	password := e.Request.FormValue("password")
+
+	if workspaceID == "" || provider == "" {
+		return respond.BadRequest(e, "workspace and provider required")
+	}
+	if !slices.Contains(providers, provider) {
+		return respond.BadRequest(e, "provider must be one of: "+strings.Join(providers, ", "))
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return respond.BadRequest(e, "failed to read file")
+	}
+
+	var snap *invest.PortfolioSnapshot
+	switch provider {
+	case "revolut-stocks", "revolut-crypto":
+		if provider == "revolut-stocks" {
+			snap, err = invest.ParseRevolutStocks(data)
+		} else {
+			snap, err = invest.ParseRevolutCrypto(data)
+		}
+		if err != nil {
+			return respond.BadRequest(e, "failed to parse CSV: "+err.Error())
+		}
+	default:
+		text, status, err := pdfText(data, password)
+		if err != nil {
+			return respond.Fail(e, status, err.Error())
+		}
+		if provider == "fondee" {
+			snap, err = invest.ParseFondee(text)
+		} else {
+			snap, err = invest.ParseAmundi(text)
+		}
+		if err != nil {
+			return respond.BadRequest(e, "failed to parse PDF: "+err.Error())
+		}
+	}
+
+	if problems := validateSnapshot(provider, snap); len(problems) > 0 {
+		return e.JSON(http.StatusBadRequest, validationError{
+			Error:            respond.Error{Message: "parsed data validation failed"},
+			ValidationErrors: problems,
+			PartialSnapshot:  snap,
+		})
+	}
+
+	// Find or create portfolio
+	var portfolioID string
+	existing, err := filter.Eq("provider", provider).Eq("workspace", workspaceID).EqIf("name", snap.PortfolioName).
+		First(e.App, "investment_portfolios")
+	if err == nil {
+		portfolioID = existing.Id
+	} else {
+		portfolioCol, err := e.App.FindCollectionByNameOrId("investment_portfolios")
+		if err != nil {
+			return respond.Fail(e, http.StatusInternalServerError, "investment_portfolios collection not found")
+		}
+		portfolioRec := core.NewRecord(portfolioCol)
+		portfolioRec.Set("provider", provider)
+		portfolioRec.Set("name", snap.PortfolioName)
+		portfolioRec.Set("contract_id", snap.ContractID)
+		portfolioRec.Set("currency", snap.Currency)
+		portfolioRec.Set("workspace", workspaceID)
+		if err := e.App.Save(portfolioRec); err != nil {
+			return respond.Fail(e, http.StatusInternalServerError, "failed to create portfolio: "+err.Error())
+		}
+		portfolioID = portfolioRec.Id
+	}
+
+	// Check for duplicate snapshot (same portfolio + report_date)
+	reportDate := snap.ReportDate.Format("2006-01-02 15:04:05.000Z")
+	if dupe, err := filter.Eq("portfolio", portfolioID).Eq("report_date", reportDate).First(e.App, "investment_snapshots"); err == nil {
+		return e.JSON(http.StatusConflict, duplicateError{
+			Error:       respond.Error{Message: "duplicate snapshot"},
+			Detail:      "A snapshot for this portfolio with report date " + snap.ReportDate.Format("2006-01-02") + " already exists",
+			SnapshotID:  dupe.Id,
+			PortfolioID: portfolioID,
+		})
+	}
+
+	snapshotCol, err := e.App.FindCollectionByNameOrId("investment_snapshots")
+	if err != nil {
+		return respond.Fail(e, http.StatusInternalServerError, "investment_snapshots collection not found")
+	}
+	snapshotRec := core.NewRecord(snapshotCol)
+	snapshotRec.Set("portfolio", portfolioID)
+	snapshotRec.Set("report_date", snap.ReportDate)
+	snapshotRec.Set("period_start", snap.PeriodStart)
+	snapshotRec.Set("period_end", snap.PeriodEnd)
+	snapshotRec.Set("start_value", snap.StartValue)
+	snapshotRec.Set("end_value", snap.EndValue)
+	snapshotRec.Set("invested", snap.Invested)
+	snapshotRec.Set("gain_loss", snap.GainLoss)
+	snapshotRec.Set("fees", snap.Fees)
+	snapshotRec.Set("workspace", workspaceID)
+	if err := e.App.Save(snapshotRec); err != nil {
+		return respond.Fail(e, http.StatusInternalServerError, "failed to create snapshot: "+err.Error())
+	}
+
+	// Create holdings (Amundi has individual fund holdings)
+	if len(snap.Holdings) > 0 {
+		holdingCol, err := e.App.FindCollectionByNameOrId("investment_holdings")
+		if err != nil {
+			return respond.Fail(e, http.StatusInternalServerError, "investment_holdings collection not found")
+		}
+		for _, h := range snap.Holdings {
+			holdingRec := core.NewRecord(holdingCol)
+			holdingRec.Set("snapshot", snapshotRec.Id)
+			holdingRec.Set("name", h.Name)
+			holdingRec.Set("isin", h.ISIN)
+			holdingRec.Set("category", h.Category)
+			holdingRec.Set("units", h.Units)
+			holdingRec.Set("price_per_unit", h.PricePerUnit)
+			holdingRec.Set("price_currency", h.PriceCurrency)
+			holdingRec.Set("total_value", h.TotalValue)
+			holdingRec.Set("value_currency", h.ValueCurrency)
+			holdingRec.Set("workspace", workspaceID)
+			if err := e.App.Save(holdingRec); err != nil {
+				log.Printf("Failed to save holding %s: %v", h.Name, err)
+			}
+		}
+	}
+
+	return e.JSON(http.StatusOK, importResponse{
+		Status:      "ok",
+		PortfolioID: portfolioID,
+		SnapshotID:  snapshotRec.Id,
+		Snapshot:    snap,
+	})
+}
+
+// pdfText extracts the text of a PDF statement with pdftotext, decrypting it
+// with qpdf first when a password is given. The status goes with the error.
+func pdfText(data []byte, password string) (string, int, error) {
+	tmpDir, err := os.MkdirTemp("", "investment-import-*")
+	if err != nil {
+		return "", http.StatusInternalServerError, fmt.Errorf("failed to create temp dir")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	pdfPath := filepath.Join(tmpDir, "upload.pdf")
+	if err := os.WriteFile(pdfPath, data, 0600); err != nil {
+		return "", http.StatusInternalServerError, fmt.Errorf("failed to save file")
+	}
+
+	if password != "" {
+		decryptedPath := filepath.Join(tmpDir, "decrypted.pdf")
+		cmd := exec.Command("qpdf", "--password="+password, "--decrypt", pdfPath, decryptedPath)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			log.Printf("qpdf decrypt failed: %s", string(out))
+			return "", http.StatusBadRequest, fmt.Errorf("failed to decrypt PDF")
+		}
+		pdfPath = decryptedPath
+	}
+
+	text, err := exec.Command("pdftotext", "-layout", pdfPath, "-").Output()
+	if err != nil {
+		return "", http.StatusInternalServerError, fmt.Errorf("failed to extract text from PDF")
+	}
+	return string(text), 0, nil
+}
+
+// validateSnapshot lists what the provider's statements always contain but
+// the parser did not find
+func validateSnapshot(provider string, s *invest.PortfolioSnapshot) []string {
+	var problems []string
+	if s.ReportDate.IsZero() {
+		problems = append(problems, "report date not found")
+	}
+
+	switch provider {
+	case "fondee":
+		if s.EndValue == 0 {
+			problems = append(problems, "end value not found or zero")
+		}
+		if s.PortfolioName == "" {
+			problems = append(problems, "portfolio name not found")
+		}
+		if s.PeriodStart.IsZero() || s.PeriodEnd.IsZero() {
+			problems = append(problems, "period dates not found")
+		}
+		if s.StartValue == 0 {
+			problems = append(problems, "start value not found or zero")
+		}
+	case "amundi":
+		if s.EndValue == 0 {
+			problems = append(problems, "end value not found or zero")
+		}
+		if s.ContractID == "" {
+			problems = append(problems, "contract ID not found")
+		}
+		if s.Invested == 0 {
+			problems = append(problems, "invested amount not found or zero")
+		}
+		if len(s.Holdings) == 0 {
+			problems = append(problems, "no holdings found")
+		}
+		for i, h := range s.Holdings {
+			if h.Name == "" {
+				problems = append(problems, fmt.Sprintf("holding %d: name missing", i+1))
+			}
+			if h.TotalValue == 0 {
+				problems = append(problems, fmt.Sprintf("holding %d (%s): total value is zero", i+1, h.Name))
+			}
+		}
+	case "revolut-stocks", "revolut-crypto":
+		if len(s.Holdings) == 0 {
+			problems = append(problems, "no holdings found")
+		}
+	}
+	return problems
+}
+
+func listPortfolios(e *core.RequestEvent) error {
+	workspaceID := e.Request.URL.Query().Get("workspace")
+	if workspaceID == "" {
+		return respond.BadRequest(e, "workspace required")
+	}
+
+	records, err := filter.Eq("workspace", workspaceID).Find(e.App, "investment_portfolios", "name", 100, 0)
+	if err != nil {
+		return e.JSON(http.StatusOK, []portfolio{})
+	}
+
+	result := []portfolio{}
+	for _, r := range records {
+		p := portfolio{
+			ID:         r.Id,
+			Provider:   r.GetString("provider"),
+			Name:       r.GetString("name"),
+			ContractID: r.GetString("contract_id"),
+			Currency:   r.GetString("currency"),
+		}
+		if latest, err := filter.Eq("portfolio", r.Id).Find(e.App, "investment_snapshots", "-report_date", 1, 0); err == nil && len(latest) > 0 {
+			s := snapshotFromRecord(latest[0])
+			p.LatestSnapshot = &s
+		}
+		result = append(result, p)
+	}
+	return e.JSON(http.StatusOK, result)
+}
+
+// listSnapshots returns the snapshot history of a portfolio with holdings
+func listSnapshots(e *core.RequestEvent) error {
+	portfolioID := e.Request.URL.Query().Get("portfolio")
+	if portfolioID == "" {
+		return respond.BadRequest(e, "portfolio required")
+	}
+
+	records, err := filter.Eq("portfolio", portfolioID).Find(e.App, "investment_snapshots", "-report_date", 100, 0)
+	if err != nil {
+		return e.JSON(http.StatusOK, []snapshot{})
+	}
+
+	result := []snapshot{}
+	for _, r := range records {
+		s := snapshotFromRecord(r)
+		holdings, _ := filter.Eq("snapshot", r.Id).Find(e.App, "investment_holdings", "name", 100, 0)
+		for _, h := range holdings {
+			s.Holdings = append(s.Holdings, holding{
+				ID:            h.Id,
+				Name:          h.GetString("name"),
+				ISIN:          h.GetString("isin"),
+				Category:      h.GetString("category"),
+				Units:         h.GetFloat("units"),
+				PricePerUnit:  h.GetFloat("price_per_unit"),
+				PriceCurrency: h.GetString("price_currency"),
+				TotalValue:    h.GetFloat("total_value"),
+				ValueCurrency: h.GetString("value_currency"),
+			})
+		}
+		result = append(result, s)
+	}
+	return e.JSON(http.StatusOK, result)
+}
+
+func snapshotFromRecord(r *core.Record) snapshot {
+	return snapshot{
+		ID:          r.Id,
+		ReportDate:  r.GetDateTime("report_date").Time(),
+		PeriodStart: r.GetDateTime("period_start").Time(),
+		PeriodEnd:   r.GetDateTime("period_end").Time(),
+		StartValue:  r.GetFloat("start_value"),
+		EndValue:    r.GetFloat("end_value"),
+		Invested:    r.GetFloat("invested"),
+		GainLoss:    r.GetFloat("gain_loss"),
+		Fees:        r.GetFloat("fees"),
+	}
+}
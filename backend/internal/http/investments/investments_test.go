@@ -0,0 +1,71 @@
+package investments_test
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"lifehub/backend/internal/http/apitest"
+)
+
+func TestRoutes(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	token := apitest.OwnerToken(t, app)
+
+	code, body := apitest.Request(t, h, "GET", "/api/investments/portfolios?workspace="+apitest.Workspace, token, "")
+	if code != http.StatusOK || !strings.Contains(body, `"id":"`+apitest.Portfolio+`"`) || !strings.Contains(body, `"latest_snapshot":{"id":"`+apitest.Snapshot+`"`) {
+		t.Fatalf("portfolios: status %d: %.300s", code, body)
+	}
+	code, body = apitest.Request(t, h, "GET", "/api/investments/snapshots?portfolio="+apitest.Portfolio, token, "")
+	if code != http.StatusOK || !strings.Contains(body, `"end_value":1000`) {
+		t.Fatalf("snapshots: status %d: %.300s", code, body)
+	}
+
+	data, err := os.ReadFile("../../services/investments/testdata/revolut_crypto_sample.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]string{"workspace": apitest.Workspace, "provider": "revolut-crypto"}
+	code, body = apitest.Upload(t, h, "/api/investments/import", token, fields, "crypto.csv", data)
+	if code != http.StatusOK || !strings.Contains(body, `"snapshot_id":`) {
+		t.Fatalf("import: status %d: %.300s", code, body)
+	}
+	code, body = apitest.Upload(t, h, "/api/investments/import", token, fields, "crypto.csv", data)
+	if code != http.StatusConflict || !strings.Contains(body, `"error":"duplicate snapshot"`) {
+		t.Fatalf("reimport: status %d, want 409: %.300s", code, body)
+	}
+}
+
+func TestValidation(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	token := apitest.OwnerToken(t, app)
+	file := []byte("not a statement")
+
+	tests := []struct {
+		name     string
+		fields   map[string]string
+		file     []byte
+		want     int
+		contains string
+	}{
+		{"no file", map[string]string{"workspace": apitest.Workspace, "provider": "fondee"}, nil, 400, `"error":"file required"`},
+		{"no provider", map[string]string{"workspace": apitest.Workspace}, file, 400, `"error":"workspace and provider required"`},
+		{"unknown provider", map[string]string{"workspace": apitest.Workspace, "provider": "conseq"}, file, 400, `"error":"provider must be one of: fondee, amundi, revolut-stocks, revolut-crypto"`},
+		{"no workspace", map[string]string{"provider": "fondee"}, file, 400, `"error":"workspace required"`},
+		{"unparsable CSV", map[string]string{"workspace": apitest.Workspace, "provider": "revolut-crypto"}, file, 400, `"error":"failed to parse CSV: `},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := apitest.Upload(t, h, "/api/investments/import", token, tt.fields, "statement", tt.file)
+			if code != tt.want || !strings.Contains(body, tt.contains) {
+				t.Errorf("status %d: %.300s, want %d with %s", code, body, tt.want, tt.contains)
+			}
+		})
+	}
+
+	code, body := apitest.Request(t, h, "GET", "/api/investments/snapshots?workspace="+apitest.Workspace, token, "")
+	if code != http.StatusBadRequest || !strings.Contains(body, `"error":"portfolio required"`) {
+		t.Errorf("snapshots without portfolio: status %d: %.300s", code, body)
+	}
+}
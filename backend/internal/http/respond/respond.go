@@ -0,0 +1,60 @@
+// Package respond writes the JSON bodies shared by the custom API routes so
+// every error carries its message under "error".
+package respond
+
+import (
+	"net/http"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Error is the body of every error response. Routes reporting more than a
+// message embed it in their own error struct.
+type Error struct {
+	Message string `json:"error"`
+}
+
+// Status confirms an action that returns nothing else
+type Status struct {
+	Status string `json:"status"`
+}
+
+// Created returns the ID of a new record
+type Created struct {
+	ID string `json:"id"`
+}
+
+// Fail answers with status and an error envelope holding message
+func Fail(e *core.RequestEvent, status int, message string) error {
+	return e.JSON(status, Error{Message: message})
+}
+
+// BadRequest answers 400 with message
+func BadRequest(e *core.RequestEvent, message string) error {
+	return Fail(e, http.StatusBadRequest, message)
+}
+
+// InvalidJSON answers 400 for a request body that failed to decode
+func InvalidJSON(e *core.RequestEvent) error {
+	return BadRequest(e, "invalid JSON")
+}
+
+// NotFound answers 404 for a missing record
+func NotFound(e *core.RequestEvent) error {
+	return Fail(e, http.StatusNotFound, "not found")
+}
+
+// Internal answers 500 with the message of err
+func Internal(e *core.RequestEvent, err error) error {
+	return Fail(e, http.StatusInternalServerError, err.Error())
+}
+
+// OK answers 200 with {"status": "ok"}
+func OK(e *core.RequestEvent) error {
+	return e.JSON(http.StatusOK, Status{Status: "ok"})
+}
+
+// ID answers 200 with the ID of a new record
+func ID(e *core.RequestEvent, id string) error {
+	return e.JSON(http.StatusOK, Created{ID: id})
+}
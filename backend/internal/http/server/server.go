@@ -0,0 +1,175 @@
+// Package server wires the services to the PocketBase app and binds the
+// custom API routes of every domain package.
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"lifehub/backend/internal/access"
+	"lifehub/backend/internal/http/eink"
+	"lifehub/backend/internal/http/finance"
+	"lifehub/backend/internal/http/integrations"
+	"lifehub/backend/internal/http/investments"
+	"lifehub/backend/internal/http/workspaces"
+	"lifehub/backend/internal/match"
+	"lifehub/backend/internal/services/budget"
+	"lifehub/backend/internal/services/categories"
+	"lifehub/backend/internal/services/categorization"
+	"lifehub/backend/internal/services/csvimport"
+	"lifehub/backend/internal/services/currency"
+	"lifehub/backend/internal/services/members"
+	"lifehub/backend/internal/services/recurring"
+	"lifehub/backend/internal/services/splits"
+	"lifehub/backend/internal/services/stats"
+	"lifehub/backend/internal/services/transfers"
+	"lifehub/backend/internal/services/trends"
+	"lifehub/backend/internal/sources/debug"
+	financesource "lifehub/backend/internal/sources/finance"
+	"lifehub/backend/internal/sources/google_calendar"
+	"lifehub/backend/internal/sources/internal_tasks"
+	_ "lifehub/backend/internal/sources/slack"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Register wires the services to app and binds the custom API routes
+func Register(app *pocketbase.PocketBase) {
+	internal_tasks.App = app
+	financesource.App = app
+	debug.App = app
+	google_calendar.App = app
+	csvimport.App = app
+	categorization.App = app
+	recurring.App = app
+	budget.App = app
+	transfers.App = app
+	splits.App = app
+	currency.App = app
+	categories.App = app
+	trends.App = app
+	stats.App = app
+	access.App = app
+	members.App = app
+
+	// Pending workspace invitations become memberships once the invitee has
+	// verified the address they were sent to
+	claim := func(e *core.RecordEvent) error {
+		if e.Record.Verified() {
+			if err := members.ClaimInvitations(e.Record.Id, e.Record.Email()); err != nil {
+				log.Printf("failed to claim invitations of user %s: %v", e.Record.Id, err)
+			}
+		}
+		return e.Next()
+	}
+	app.OnRecordAfterCreateSuccess("users").BindFunc(claim)
+	app.OnRecordAfterUpdateSuccess("users").BindFunc(claim)
+
+	// Import rules are saved through the records API, so their match
+	// expression is checked here
+	app.OnRecordValidate("finance_import_rules").BindFunc(func(e *core.RecordEvent) error {
+		raw, _ := e.Record.Get("match_expression").(types.JSONRaw)
+		expr, err := match.Parse(raw)
+		if err != nil {
+			return fmt.Errorf("invalid match_expression: %w", err)
+		}
+		if expr == nil && e.Record.GetString("pattern") == "" {
+			return errors.New("pattern or match_expression required")
+		}
+		return e.Next()
+	})
+
+	bindBudgetRefresh(app)
+
+	app.OnServe().BindFunc(func(e *core.ServeEvent) error {
+		integrations.Register(e.Router)
+		finance.Register(e.Router)
+		investments.Register(e.Router)
+		workspaces.Register(e.Router)
+		eink.Register(e.Router)
+		return e.Next()
+	})
+}
+
+// bindBudgetRefresh marks the budget of a workspace changed whenever a
+// record the automatic assignments and the ledger derive from changes
+func bindBudgetRefresh(app *pocketbase.PocketBase) {
+	// From the earlier of the old and the new date of a transaction
+	transaction := func(e *core.RecordEvent) error {
+		date := e.Record.GetDateTime("date").Time()
+		if old := e.Record.Original().GetDateTime("date").Time(); !old.IsZero() && old.Before(date) {
+			date = old
+		}
+		budget.Changed(e.Record.GetString("workspace"), date)
+		return e.Next()
+	}
+	app.OnRecordAfterCreateSuccess("finance_transactions").BindFunc(transaction)
+	app.OnRecordAfterUpdateSuccess("finance_transactions").BindFunc(transaction)
+	app.OnRecordAfterDeleteSuccess("finance_transactions").BindFunc(transaction)
+
+	split := func(e *core.RecordEvent) error {
+		// Splits deleted along with their transaction are covered by it
+		if tx, err := e.App.FindRecordById("finance_transactions", e.Record.GetString("transaction")); err == nil {
+			budget.Changed(tx.GetString("workspace"), tx.GetDateTime("date").Time())
+		}
+		return e.Next()
+	}
+	app.OnRecordAfterCreateSuccess("finance_transaction_splits").BindFunc(split)
+	app.OnRecordAfterUpdateSuccess("finance_transaction_splits").BindFunc(split)
+	app.OnRecordAfterDeleteSuccess("finance_transaction_splits").BindFunc(split)
+
+	// Budgets, and items and categories created or deleted, can change the
+	// match of every month
+	all := func(e *core.RecordEvent) error {
+		budget.Changed(e.Record.GetString("workspace"), time.Time{})
+		return e.Next()
+	}
+	app.OnRecordAfterCreateSuccess("finance_budgets").BindFunc(all)
+	app.OnRecordAfterUpdateSuccess("finance_budgets").BindFunc(all)
+	app.OnRecordAfterDeleteSuccess("finance_budgets").BindFunc(all)
+	app.OnRecordAfterCreateSuccess("finance_budget_items").BindFunc(all)
+	app.OnRecordAfterDeleteSuccess("finance_budget_items").BindFunc(all)
+	app.OnRecordAfterDeleteSuccess("finance_categories").BindFunc(all)
+
+	app.OnRecordAfterUpdateSuccess("finance_budget_items").BindFunc(func(e *core.RecordEvent) error {
+		budget.ItemChanged(e.Record.Original(), e.Record)
+		return e.Next()
+	})
+	app.OnRecordAfterUpdateSuccess("finance_categories").BindFunc(func(e *core.RecordEvent) error {
+		budget.CategoryChanged(e.Record.Original(), e.Record)
+		return e.Next()
+	})
+
+	app.OnRecordAfterUpdateSuccess("workspaces").BindFunc(func(e *core.RecordEvent) error {
+		old := e.Record.Original()
+		if old.GetString("budget_match_mode") != e.Record.GetString("budget_match_mode") ||
+			old.GetString("base_currency") != e.Record.GetString("base_currency") {
+			budget.Changed(e.Record.Id, time.Time{})
+		}
+		return e.Next()
+	})
+
+	// Transactions inherit the currency of their account
+	app.OnRecordAfterUpdateSuccess("finance_accounts").BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.Original().GetString("currency") != e.Record.GetString("currency") {
+			budget.Changed(e.Record.GetString("workspace"), time.Time{})
+		}
+		return e.Next()
+	})
+
+	// The rates are shared, so they can change the amounts of every workspace
+	currency.RatesChanged = func(from time.Time) {
+		workspaces, err := app.FindAllRecords("workspaces")
+		if err != nil {
+			log.Printf("failed to load workspaces: %v", err)
+			return
+		}
+		for _, ws := range workspaces {
+			budget.Changed(ws.Id, from)
+		}
+	}
+}
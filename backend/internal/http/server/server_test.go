@@ -0,0 +1,407 @@
+package server_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"lifehub/backend/internal/domain"
+	"lifehub/backend/internal/http/apitest"
+	"lifehub/backend/internal/services/csvimport"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// hostile are query values that would escape a string-concatenated filter
+var hostile = []string{
+	"' || 1=1 || '",
+	"x' || workspace != 'x",
+	`" || 1=1 || "`,
+	"') || ('1'='1",
+	"1 || 1=1",
+	"%' || '1'='1",
+	"{:workspace}",
+	"'; DROP TABLE finance_transactions; --",
+}
+
+// fingerprint captures the finance data a hostile request must leave untouched
+func fingerprint(t *testing.T, app core.App) string {
+	t.Helper()
+	var parts []string
+	for _, query := range []string{
+		"SELECT COUNT(*) || ':' || COALESCE(GROUP_CONCAT(id || category_rel || merchant || is_transfer, ','), '') FROM finance_transactions",
+		"SELECT COUNT(*) || ':' || COALESCE(GROUP_CONCAT(id, ','), '') FROM finance_budget_items",
+		"SELECT COUNT(*) || ':' || COALESCE(GROUP_CONCAT(id, ','), '') FROM finance_recurring",
+	} {
+		var s string
+		if err := app.DB().NewQuery(query).Row(&s); err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, "|")
+}
+
+func TestHostileFilterValues(t *testing.T) {
+	app, h := apitest.NewServer(t)
+
+	ws, err := app.FindFirstRecordByFilter("workspaces", "slug = 'demo'")
+	if err != nil {
+		t.Fatalf("demo workspace not seeded: %v", err)
+	}
+	// Every seeded record ID that must not show up for another workspace
+	var seeded []string
+	for _, collection := range []string{"finance_accounts", "finance_categories", "finance_merchants", "finance_recurring",
+		"finance_income_sources", "finance_income_hours", "finance_budgets", "investment_portfolios"} {
+		records, _ := app.FindRecordsByFilter(collection, "workspace = {:ws}", "", 0, 0, map[string]any{"ws": ws.Id})
+		for _, r := range records {
+			seeded = append(seeded, r.Id)
+		}
+	}
+	if len(seeded) == 0 {
+		t.Fatal("no demo data seeded")
+	}
+	token := apitest.OwnerToken(t, app)
+	before := fingerprint(t, app)
+
+	// The same routes do return the data for the workspace itself
+	for _, route := range []string{"/api/finance/accounts", "/api/finance/recurring", "/api/finance/budgets", "/api/investments/portfolios"} {
+		if code, body := apitest.Request(t, h, http.MethodGet, route+"?workspace="+ws.Id, token, ""); code != http.StatusOK || !strings.Contains(body, `"id":"demo`) {
+			t.Fatalf("GET %s: status %d, want the demo records: %.200s", route, code, body)
+		}
+	}
+
+	reads := []string{
+		"/api/finance/accounts?workspace={v}",
+		"/api/finance/categories?workspace={v}",
+		"/api/finance/merchants?workspace={v}",
+		"/api/finance/templates?workspace={v}",
+		"/api/finance/imports?workspace={v}",
+		"/api/finance/duplicates?workspace={v}",
+		"/api/finance/categorize/suggestions?workspace={v}&account={v}",
+		"/api/finance/recurring?workspace={v}",
+		"/api/finance/recurring/upcoming?workspace={v}",
+		"/api/finance/stats?workspace={v}&account={v}&category={v}&start_date={v}&end_date={v}",
+		"/api/finance/trends?workspace={v}&account={v}",
+		"/api/finance/net-worth?workspace={v}",
+		"/api/finance/exchange-rates/gaps?workspace={v}",
+		"/api/finance/income-sources?workspace={v}",
+		"/api/finance/income-hours?workspace={v}&year={v}&month={v}",
+		"/api/finance/budgets?workspace={v}",
+		"/api/finance/budget/status?workspace={v}",
+		"/api/finance/transactions/{p}/splits",
+		"/api/finance/templates/{p}",
+		"/api/investments/portfolios?workspace={v}",
+		"/api/investments/snapshots?portfolio={v}",
+	}
+	writes := []string{
+		"POST /api/finance/categorize/recategorize-all?workspace={v}",
+		"POST /api/finance/categorize/apply-rules?workspace={v}&override=true",
+		"POST /api/finance/recurring/detect?workspace={v}",
+		"POST /api/finance/transfers/detect?workspace={v}",
+		"DELETE /api/finance/budgets/{p}",
+		"DELETE /api/finance/transactions/{p}/splits",
+	}
+
+	expand := func(route, value string) string {
+		route = strings.ReplaceAll(route, "{v}", url.QueryEscape(value))
+		return strings.ReplaceAll(route, "{p}", url.PathEscape(value))
+	}
+	for _, value := range hostile {
+		for _, route := range reads {
+			target := expand(route, value)
+			code, body := apitest.Request(t, h, http.MethodGet, target, token, "")
+			if code >= 500 {
+				t.Errorf("GET %s: status %d: %s", target, code, body)
+			}
+			for _, id := range seeded {
+				if strings.Contains(body, id) {
+					t.Errorf("GET %s: leaked record %s of workspace %s", target, id, ws.Id)
+					break
+				}
+			}
+		}
+		for _, route := range writes {
+			method, path, _ := strings.Cut(route, " ")
+			target := expand(path, value)
+			if code, body := apitest.Request(t, h, method, target, token, ""); code >= 500 {
+				t.Errorf("%s %s: status %d: %s", method, target, code, body)
+			}
+			if after := fingerprint(t, app); after != before {
+				t.Fatalf("%s %s modified finance data of another workspace", method, target)
+			}
+		}
+	}
+
+	// Hostile optional filters within a real workspace must narrow the result to nothing
+	for _, value := range hostile {
+		target := "/api/finance/stats?workspace=" + ws.Id + "&account=" + url.QueryEscape(value)
+		code, body := apitest.Request(t, h, http.MethodGet, target, token, "")
+		if code != http.StatusOK || !strings.Contains(body, `"total_income":0,`) || !strings.Contains(body, `"total_expenses":0,`) {
+			t.Errorf("GET %s: status %d, want zero totals: %.200s", target, code, body)
+		}
+	}
+}
+
+func TestWorkspaceAuthorization(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	owner := apitest.OwnerToken(t, app)
+	other, otherToken := apitest.NewUser(t, app, "other@example.com")
+
+	// The other user has a workspace of their own to smuggle references through
+	apitest.Insert(t, app,
+		apitest.Row{Table: "workspaces", Data: dbx.Params{"id": "otherwsxxxxxxxx", "name": "Other", "slug": "other", "owner": other.Id}},
+		apitest.Row{Table: "finance_budgets", Data: dbx.Params{"id": "otherbudgetxxxx", "workspace": "otherwsxxxxxxxx", "name": "Other"}},
+	)
+	job := csvimport.StartImportJob("demowsxxxxxxxxx", 0, func(func(int, int)) (*csvimport.ImportResult, error) {
+		return &csvimport.ImportResult{}, nil
+	})
+
+	const (
+		ws   = "workspace=demowsxxxxxxxxx"
+		mine = "otherwsxxxxxxxx"
+		tx   = "demotx000000000"
+	)
+	tests := []struct {
+		method, target, body string
+	}{
+		{"GET", "/api/finance/accounts?" + ws, ""},
+		{"POST", "/api/finance/accounts", `{"workspace":"demowsxxxxxxxxx","name":"x"}`},
+		{"GET", "/api/finance/categories?" + ws, ""},
+		{"POST", "/api/finance/categories", `{"workspace":"demowsxxxxxxxxx","name":"x"}`},
+		{"POST", "/api/finance/categories", `{"workspace":"` + mine + `","name":"x","parent_id":"democategoryxxx"}`},
+		{"GET", "/api/finance/merchants?" + ws, ""},
+		{"GET", "/api/finance/templates?" + ws, ""},
+		{"GET", "/api/finance/templates/demotemplatexxx", ""},
+		{"POST", "/api/finance/templates", `{"workspace_id":"demowsxxxxxxxxx","name":"x","code":"x"}`},
+		{"PUT", "/api/finance/templates/demotemplatexxx", `{"name":"x"}`},
+		{"DELETE", "/api/finance/templates/demotemplatexxx", ""},
+		{"POST", "/api/finance/import/preview", ws},
+		{"POST", "/api/finance/import", ws + "&account=demoaccountxxxx"},
+		{"POST", "/api/finance/import", "workspace=" + mine + "&account=demoaccountxxxx"},
+		{"GET", "/api/finance/import/jobs/" + job.ID, ""},
+		{"GET", "/api/finance/imports?" + ws, ""},
+		{"DELETE", "/api/finance/imports/demoimportxxxxx", ""},
+		{"GET", "/api/finance/duplicates?account=demoaccountxxxx", ""},
+		{"POST", "/api/finance/transactions/merge", `{"ids":["demotx000000000","demotx000000001"]}`},
+		{"POST", "/api/finance/transfers/detect?" + ws, ""},
+		{"POST", "/api/finance/transfers", `{"ids":["demotx000000000","demotx000000001"]}`},
+		{"DELETE", "/api/finance/transfers/" + tx, ""},
+		{"GET", "/api/finance/transactions/" + tx + "/splits", ""},
+		{"PUT", "/api/finance/transactions/" + tx + "/splits", `{"splits":[]}`},
+		{"DELETE", "/api/finance/transactions/" + tx + "/splits", ""},
+		{"GET", "/api/finance/categorize/suggestions?" + ws, ""},
+		{"POST", "/api/finance/categorize/bulk", `{"workspace":"` + mine + `","transaction_ids":["demotx000000000"]}`},
+		{"POST", "/api/finance/categorize/recategorize-all?" + ws, ""},
+		{"POST", "/api/finance/categorize/apply-rules?" + ws, ""},
+		{"GET", "/api/finance/recurring?" + ws, ""},
+		{"POST", "/api/finance/recurring/detect?" + ws, ""},
+		{"GET", "/api/finance/recurring/upcoming?" + ws, ""},
+		{"GET", "/api/finance/stats?" + ws, ""},
+		{"GET", "/api/finance/trends?" + ws, ""},
+		{"GET", "/api/finance/net-worth?" + ws, ""},
+		{"GET", "/api/finance/exchange-rates/gaps?" + ws, ""},
+		{"GET", "/api/finance/income-sources?" + ws, ""},
+		{"POST", "/api/finance/income-sources", `{"workspace":"demowsxxxxxxxxx","name":"x"}`},
+		{"PUT", "/api/finance/income-sources/demoincomexxxxx", `{"name":"x"}`},
+		{"DELETE", "/api/finance/income-sources/demoincomexxxxx", ""},
+		{"GET", "/api/finance/income-hours?" + ws, ""},
+		{"PUT", "/api/finance/income-hours", `{"workspace":"demowsxxxxxxxxx","income_source":"demoincomexxxxx","year":2025,"month":1}`},
+		{"PUT", "/api/finance/income-hours", `{"workspace":"` + mine + `","income_source":"demoincomexxxxx","year":2025,"month":1}`},
+		{"GET", "/api/finance/budgets?" + ws, ""},
+		{"POST", "/api/finance/budgets", `{"workspace":"demowsxxxxxxxxx","name":"x"}`},
+		{"PUT", "/api/finance/budgets/demobudgetxxxxx", `{"name":"x"}`},
+		{"PUT", "/api/finance/budgets/otherbudgetxxxx", `{"workspace":"demowsxxxxxxxxx"}`},
+		{"DELETE", "/api/finance/budgets/demobudgetxxxxx", ""},
+		{"POST", "/api/finance/budget-items", `{"workspace":"` + mine + `","budget":"demobudgetxxxxx","name":"x"}`},
+		{"PUT", "/api/finance/budget-items/demobudgetitemx", `{"name":"x"}`},
+		{"DELETE", "/api/finance/budget-items/demobudgetitemx", ""},
+		{"GET", "/api/finance/budget/status?" + ws + "&start_date=2025-01-01&end_date=2025-01-31", ""},
+		{"POST", "/api/investments/import", ws + "&provider=conseq"},
+		{"GET", "/api/investments/portfolios?" + ws, ""},
+		{"GET", "/api/investments/snapshots?portfolio=demoportfolioxx", ""},
+	}
+
+	before := fingerprint(t, app)
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			if code, body := apitest.Request(t, h, tt.method, tt.target, "", tt.body); code != http.StatusUnauthorized {
+				t.Errorf("anonymous: status %d, want 401: %.200s", code, body)
+			}
+			if code, body := apitest.Request(t, h, tt.method, tt.target, otherToken, tt.body); code != http.StatusForbidden {
+				t.Errorf("other user: status %d, want 403: %.200s", code, body)
+			}
+			if after := fingerprint(t, app); after != before {
+				t.Fatal("finance data of the demo workspace was modified")
+			}
+		})
+	}
+
+	// The owner gets through to the handlers
+	for _, target := range []string{
+		"/api/finance/accounts?" + ws,
+		"/api/finance/templates",
+		"/api/finance/templates/demotemplatexxx",
+		"/api/finance/import/jobs/" + job.ID,
+		"/api/finance/duplicates?account=demoaccountxxxx",
+		"/api/finance/transactions/" + tx + "/splits",
+		"/api/finance/stats?" + ws,
+		"/api/investments/snapshots?portfolio=demoportfolioxx",
+	} {
+		if code, body := apitest.Request(t, h, http.MethodGet, target, owner, ""); code != http.StatusOK {
+			t.Errorf("owner: GET %s: status %d, want 200: %.200s", target, code, body)
+		}
+	}
+	if code, _ := apitest.Request(t, h, http.MethodDelete, "/api/finance/budgets/missingxxxxxxxx", owner, ""); code != http.StatusNotFound {
+		t.Errorf("DELETE missing budget: status %d, want 404", code)
+	}
+	if code, _ := apitest.Request(t, h, http.MethodGet, "/api/finance/accounts", owner, ""); code != http.StatusBadRequest {
+		t.Errorf("no workspace: status %d, want 400", code)
+	}
+}
+
+func TestWorkspaceRoles(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	owner := apitest.OwnerToken(t, app)
+	editor, editorToken := apitest.NewUser(t, app, "editor@example.com")
+	_, viewerToken := apitest.NewUser(t, app, "viewer@example.com")
+	_, deviceToken := apitest.NewUser(t, app, "device@example.com")
+	_, strangerToken := apitest.NewUser(t, app, "stranger@example.com")
+
+	const members = "/api/workspaces/demowsxxxxxxxxx/members"
+	invite := func(token, body string) (int, string) {
+		t.Helper()
+		return apitest.Request(t, h, http.MethodPost, members, token, body)
+	}
+	for _, body := range []string{
+		`{"user_id":"` + editor.Id + `","role":"editor"}`,
+		`{"email":"Viewer@example.com","role":"viewer"}`,
+		`{"email":"device@example.com","role":"device"}`,
+		`{"email":"later@example.com","role":"viewer"}`,
+	} {
+		if code, resp := invite(owner, body); code != http.StatusOK {
+			t.Fatalf("invite %s: status %d: %s", body, code, resp)
+		}
+	}
+	if code, _ := invite(owner, `{"email":"viewer@example.com","role":"editor"}`); code != http.StatusBadRequest {
+		t.Errorf("repeated invitation: status %d, want 400", code)
+	}
+	if code, _ := invite(owner, `{"email":"x@example.com","role":"admin"}`); code != http.StatusBadRequest {
+		t.Errorf("unknown role: status %d, want 400", code)
+	}
+	if code, _ := invite(editorToken, `{"email":"x@example.com","role":"viewer"}`); code != http.StatusForbidden {
+		t.Errorf("editor inviting: status %d, want 403", code)
+	}
+
+	// The pending invitation is claimed once the address is verified
+	users, err := app.FindCollectionByNameOrId("users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := core.NewRecord(users)
+	later.SetEmail("later@example.com")
+	later.SetPassword("password123456")
+	if err := app.Save(later); err != nil {
+		t.Fatal(err)
+	}
+	laterToken, err := later.NewAuthToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := apitest.Request(t, h, http.MethodGet, "/api/finance/stats?workspace=demowsxxxxxxxxx", laterToken, ""); code != http.StatusForbidden {
+		t.Errorf("unverified signup: status %d, want 403", code)
+	}
+	later.SetVerified(true)
+	if err := app.Save(later); err != nil {
+		t.Fatal(err)
+	}
+
+	const (
+		stats        = "/api/finance/stats?workspace=demowsxxxxxxxxx"
+		recategorize = "/api/finance/categorize/recategorize-all?workspace=demowsxxxxxxxxx"
+		detect       = "/api/finance/recurring/detect?workspace=demowsxxxxxxxxx"
+		eink         = "/api/eink/relevant?workspace=demowsxxxxxxxxx"
+	)
+	tests := []struct {
+		name, token, method, target string
+		want                        int
+	}{
+		{"owner reads", owner, "GET", stats, 200},
+		{"owner writes", owner, "POST", recategorize, 200},
+		{"editor reads", editorToken, "GET", stats, 200},
+		{"editor writes", editorToken, "POST", recategorize, 200},
+		{"viewer reads", viewerToken, "GET", stats, 200},
+		{"viewer detects", viewerToken, "POST", detect, 200},
+		{"viewer cannot recategorize", viewerToken, "POST", recategorize, 403},
+		{"viewer cannot import", viewerToken, "POST", "/api/finance/import", 403},
+		{"viewer cannot delete budgets", viewerToken, "DELETE", "/api/finance/budgets/demobudgetxxxxx", 403},
+		{"viewer lists members", viewerToken, "GET", members, 200},
+		{"claimed invitation reads", laterToken, "GET", stats, 200},
+		{"device cannot read stats", deviceToken, "GET", stats, 403},
+		{"device feeds e-ink", deviceToken, "GET", eink, 200},
+		{"stranger cannot read e-ink", strangerToken, "GET", eink, 403},
+		{"stranger cannot list members", strangerToken, "GET", members, 403},
+	}
+	for _, tt := range tests {
+		body := ""
+		if strings.HasSuffix(tt.target, "/import") {
+			body = "workspace=demowsxxxxxxxxx"
+		}
+		if code, resp := apitest.Request(t, h, tt.method, tt.target, tt.token, body); code != tt.want {
+			t.Errorf("%s: %s %s: status %d, want %d: %.200s", tt.name, tt.method, tt.target, code, tt.want, resp)
+		}
+	}
+
+	code, resp := apitest.Request(t, h, http.MethodGet, members, viewerToken, "")
+	var list []domain.WorkspaceMember
+	if err := json.Unmarshal([]byte(resp), &list); err != nil || code != http.StatusOK {
+		t.Fatalf("members: status %d: %s", code, resp)
+	}
+	roles := map[string]string{}
+	byEmail := map[string]domain.WorkspaceMember{}
+	for _, m := range list {
+		roles[m.Email] = m.Role
+		byEmail[m.Email] = m
+	}
+	want := map[string]string{
+		"owner@example.com":  "owner",
+		"editor@example.com": "editor",
+		"viewer@example.com": "viewer",
+		"device@example.com": "device",
+		"later@example.com":  "viewer",
+	}
+	if fmt.Sprint(roles) != fmt.Sprint(want) {
+		t.Errorf("members = %v, want %v", roles, want)
+	}
+	if byEmail["later@example.com"].Pending {
+		t.Error("claimed invitation still pending")
+	}
+
+	// Demoting the editor takes write access away
+	editorMember := members + "/" + byEmail["editor@example.com"].ID
+	if code, _ := apitest.Request(t, h, http.MethodPatch, editorMember, viewerToken, `{"role":"viewer"}`); code != http.StatusForbidden {
+		t.Errorf("viewer changing roles: status %d, want 403", code)
+	}
+	if code, resp := apitest.Request(t, h, http.MethodPatch, editorMember, owner, `{"role":"viewer"}`); code != http.StatusOK {
+		t.Fatalf("demote: status %d: %s", code, resp)
+	}
+	if code, _ := apitest.Request(t, h, http.MethodPost, recategorize, editorToken, ""); code != http.StatusForbidden {
+		t.Errorf("demoted editor writes: status %d, want 403", code)
+	}
+
+	// Members may leave, but not remove others
+	viewerMember := members + "/" + byEmail["viewer@example.com"].ID
+	if code, _ := apitest.Request(t, h, http.MethodDelete, viewerMember, laterToken, ""); code != http.StatusForbidden {
+		t.Errorf("removing another member: status %d, want 403", code)
+	}
+	if code, resp := apitest.Request(t, h, http.MethodDelete, viewerMember, viewerToken, ""); code != http.StatusOK {
+		t.Fatalf("leave: status %d: %s", code, resp)
+	}
+	if code, _ := apitest.Request(t, h, http.MethodGet, stats, viewerToken, ""); code != http.StatusForbidden {
+		t.Errorf("former member reads: status %d, want 403", code)
+	}
+}
@@ -0,0 +1,108 @@
+// Package workspaces serves /api/workspaces/{workspace}/members, through
+// which owners share a workspace and members leave it.
+package workspaces
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"lifehub/backend/internal/access"
+	"lifehub/backend/internal/http/respond"
+	"lifehub/backend/internal/services/members"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/router"
+)
+
+// inviteRequest is the body of POST /members; exactly one of UserID and
+// Email is set
+type inviteRequest struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+}
+
+// roleRequest is the body of PATCH /members/{member}
+type roleRequest struct {
+	Role string `json:"role"`
+}
+
+// Register binds the member routes, scoped to the workspace in the path
+func Register(r *router.Router[*core.RequestEvent]) {
+	api := r.Group("/api/workspaces/{workspace}").Bind(access.RequireWorkspace(), access.Workspace())
+
+	api.GET("/members", listMembers)
+	api.POST("/members", inviteMember).Bind(access.MinRole(members.Owner))
+	api.PATCH("/members/{member}", setRole).Bind(access.MinRole(members.Owner))
+	api.DELETE("/members/{member}", removeMember).Bind(access.MinRole(members.Device))
+}
+
+func listMembers(e *core.RequestEvent) error {
+	result, err := members.List(e.Request.PathValue("workspace"))
+	if err != nil {
+		return respond.Internal(e, err)
+	}
+	return e.JSON(http.StatusOK, result)
+}
+
+// inviteMember invites by user_id or email; unknown emails stay pending
+// until sign-up
+func inviteMember(e *core.RequestEvent) error {
+	var body inviteRequest
+	if err := json.NewDecoder(e.Request.Body).Decode(&body); err != nil {
+		return respond.InvalidJSON(e)
+	}
+	role, err := members.ParseRole(body.Role)
+	if err != nil {
+		return respond.BadRequest(e, err.Error())
+	}
+
+	member, err := members.Invite(e.Request.PathValue("workspace"), e.Auth.Id, body.UserID, body.Email, role)
+	if err != nil {
+		return respond.BadRequest(e, err.Error())
+	}
+	return e.JSON(http.StatusOK, member)
+}
+
+func setRole(e *core.RequestEvent) error {
+	record, err := members.Find(e.Request.PathValue("workspace"), e.Request.PathValue("member"))
+	if err != nil {
+		return respond.NotFound(e)
+	}
+	var body roleRequest
+	if err := json.NewDecoder(e.Request.Body).Decode(&body); err != nil {
+		return respond.InvalidJSON(e)
+	}
+	role, err := members.ParseRole(body.Role)
+	if err != nil {
+		return respond.BadRequest(e, err.Error())
+	}
+
+	if err := members.SetRole(record, role); err != nil {
+		return respond.Internal(e, err)
+	}
+	return respond.OK(e)
+}
+
+// removeMember lets owners remove anyone and every member leave
+func removeMember(e *core.RequestEvent) error {
+	workspaceID := e.Request.PathValue("workspace")
+	record, err := members.Find(workspaceID, e.Request.PathValue("member"))
+	if err != nil {
+		return respond.NotFound(e)
+	}
+	if record.GetString("user") != e.Auth.Id && !e.HasSuperuserAuth() {
+		role, err := members.RoleOf(workspaceID, e.Auth.Id)
+		if err != nil {
+			return respond.Internal(e, err)
+		}
+		if !role.Includes(members.Owner) {
+			return respond.Fail(e, http.StatusForbidden, "the owner role is required")
+		}
+	}
+
+	if err := e.App.Delete(record); err != nil {
+		return respond.Internal(e, err)
+	}
+	return respond.OK(e)
+}
@@ -0,0 +1,77 @@
+package workspaces_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"lifehub/backend/internal/http/apitest"
+)
+
+const members = "/api/workspaces/" + apitest.Workspace + "/members"
+
+func TestMembers(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	owner := apitest.OwnerToken(t, app)
+	_, viewerToken := apitest.NewUser(t, app, "viewer@example.com")
+
+	code, body := apitest.Request(t, h, "POST", members, owner, `{"email":"viewer@example.com","role":"viewer"}`)
+	if code != http.StatusOK {
+		t.Fatalf("invite: status %d: %.300s", code, body)
+	}
+	var member struct {
+		ID   string `json:"id"`
+		Role string `json:"role"`
+	}
+	if err := json.Unmarshal([]byte(body), &member); err != nil || member.ID == "" || member.Role != "viewer" {
+		t.Fatalf("invite: unexpected member %.300s", body)
+	}
+
+	code, body = apitest.Request(t, h, "GET", members, viewerToken, "")
+	if code != http.StatusOK || !strings.Contains(body, "viewer@example.com") {
+		t.Fatalf("list: status %d: %.300s", code, body)
+	}
+
+	code, body = apitest.Request(t, h, "PATCH", members+"/"+member.ID, viewerToken, `{"role":"owner"}`)
+	if code != http.StatusForbidden {
+		t.Fatalf("viewer promoting itself: status %d, want 403: %.300s", code, body)
+	}
+	code, body = apitest.Request(t, h, "PATCH", members+"/"+member.ID, owner, `{"role":"editor"}`)
+	if code != http.StatusOK || body != `{"status":"ok"}`+"\n" {
+		t.Fatalf("set role: status %d: %.300s", code, body)
+	}
+
+	// Members may leave on their own
+	code, body = apitest.Request(t, h, "DELETE", members+"/"+member.ID, viewerToken, "")
+	if code != http.StatusOK {
+		t.Fatalf("leave: status %d: %.300s", code, body)
+	}
+}
+
+func TestValidation(t *testing.T) {
+	app, h := apitest.NewServer(t)
+	owner := apitest.OwnerToken(t, app)
+
+	tests := []struct {
+		name, method, target, body string
+		want                       int
+		contains                   string
+	}{
+		{"invite body", "POST", members, `{"email":`, http.StatusBadRequest, `"error":"invalid request body"`},
+		{"invite role", "POST", members, `{"email":"a@example.com","role":"admin"}`, http.StatusBadRequest, `"error":"unknown role \"admin\"`},
+		{"invite nobody", "POST", members, `{"role":"viewer"}`, http.StatusBadRequest, `"error":"either user_id or email required"`},
+		{"invite unknown user", "POST", members, `{"user_id":"missingxxxxxxxx","role":"viewer"}`, http.StatusBadRequest, `"error":"user not found"`},
+		{"role of missing member", "PATCH", members + "/missingxxxxxxxx", `{"role":"viewer"}`, http.StatusNotFound, `"error":"not found"`},
+		{"remove missing member", "DELETE", members + "/missingxxxxxxxx", "", http.StatusNotFound, `"error":"not found"`},
+		{"unknown workspace", "GET", "/api/workspaces/missingxxxxxxxx/members", "", http.StatusNotFound, `"error":"not found"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := apitest.Request(t, h, tt.method, tt.target, owner, tt.body)
+			if code != tt.want || !strings.Contains(body, tt.contains) {
+				t.Errorf("status %d: %.300s, want %d with %s", code, body, tt.want, tt.contains)
+			}
+		})
+	}
+}
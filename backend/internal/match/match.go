@@ -0,0 +1,173 @@
+// Package match evaluates boolean match expressions over transaction
+// fields, as used by budget items and import rules, e.g.
+//
+//	{"all": [
+//	  {"field": "counterparty_account", "op": "equals", "value": "2400123456/2010"},
+//	  {"field": "amount", "op": "between", "min": 1000, "max": 1500},
+//	  {"field": "description", "op": "contains", "value": "rent"}
+//	]}
+package match
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// TextFields are the fields compared with equals, contains and regex
+var TextFields = []string{"description", "raw_description", "counterparty_account", "account", "category", "merchant"}
+
+// Expr is a match expression. A node either combines others with All, Any
+// or Not, or compares one Field: text fields with equals and contains
+// (both case-insensitive) or regex, amount with between an inclusive Min
+// and Max, either of which may be left out.
+type Expr struct {
+	All   []*Expr  `json:"all,omitempty"`
+	Any   []*Expr  `json:"any,omitempty"`
+	Not   *Expr    `json:"not,omitempty"`
+	Field string   `json:"field,omitempty"`
+	Op    string   `json:"op,omitempty"`
+	Value string   `json:"value,omitempty"`
+	Min   *float64 `json:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// Fields are the transaction fields an expression is evaluated against
+type Fields struct {
+	Description         string
+	RawDescription      string
+	CounterpartyAccount string
+	AccountID           string
+	CategoryID          string
+	MerchantID          string
+	Amount              float64
+}
+
+// Parse decodes and validates an expression. Empty input and JSON null
+// give a nil expression.
+func Parse(data []byte) (*Expr, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	var e Expr
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if err := e.compile(); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// compile checks the expression and compiles its regular expressions
+func (e *Expr) compile() error {
+	nodes := 0
+	for _, set := range []bool{e.All != nil, e.Any != nil, e.Not != nil, e.Field != ""} {
+		if set {
+			nodes++
+		}
+	}
+	if nodes != 1 {
+		return fmt.Errorf("each node needs exactly one of all, any, not or field")
+	}
+	if e.All != nil && len(e.All) == 0 || e.Any != nil && len(e.Any) == 0 {
+		return fmt.Errorf("all and any need at least one node")
+	}
+
+	for _, sub := range append(slices.Clone(e.All), e.Any...) {
+		if sub == nil {
+			return fmt.Errorf("empty node")
+		}
+		if err := sub.compile(); err != nil {
+			return err
+		}
+	}
+	if e.Not != nil {
+		return e.Not.compile()
+	}
+	if e.Field == "" {
+		return nil
+	}
+
+	if e.Field == "amount" {
+		if e.Op != "between" || e.Min == nil && e.Max == nil {
+			return fmt.Errorf("amount needs op between with min, max or both")
+		}
+		return nil
+	}
+	if !slices.Contains(TextFields, e.Field) {
+		return fmt.Errorf("unknown field %q, expected amount or one of: %s", e.Field, strings.Join(TextFields, ", "))
+	}
+	switch e.Op {
+	case "equals", "contains":
+	case "regex":
+		re, err := regexp.Compile(e.Value)
+		if err != nil {
+			return fmt.Errorf("invalid regex %q: %w", e.Value, err)
+		}
+		e.re = re
+	default:
+		return fmt.Errorf("unknown op %q for %s, expected equals, contains or regex", e.Op, e.Field)
+	}
+	return nil
+}
+
+// Match reports whether f satisfies the expression. A nil expression
+// matches everything.
+func (e *Expr) Match(f Fields) bool {
+	switch {
+	case e == nil:
+		return true
+	case e.All != nil:
+		for _, sub := range e.All {
+			if !sub.Match(f) {
+				return false
+			}
+		}
+		return true
+	case e.Any != nil:
+		for _, sub := range e.Any {
+			if sub.Match(f) {
+				return true
+			}
+		}
+		return false
+	case e.Not != nil:
+		return !e.Not.Match(f)
+	case e.Field == "amount":
+		return (e.Min == nil || f.Amount >= *e.Min) && (e.Max == nil || f.Amount <= *e.Max)
+	}
+
+	value := f.text(e.Field)
+	switch e.Op {
+	case "equals":
+		return strings.EqualFold(strings.TrimSpace(value), strings.TrimSpace(e.Value))
+	case "contains":
+		return strings.Contains(strings.ToUpper(value), strings.ToUpper(e.Value))
+	case "regex":
+		return e.re != nil && e.re.MatchString(value)
+	}
+	return false
+}
+
+func (f Fields) text(field string) string {
+	switch field {
+	case "description":
+		return f.Description
+	case "raw_description":
+		return f.RawDescription
+	case "counterparty_account":
+		return f.CounterpartyAccount
+	case "account":
+		return f.AccountID
+	case "category":
+		return f.CategoryID
+	case "merchant":
+		return f.MerchantID
+	}
+	return ""
+}
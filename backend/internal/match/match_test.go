@@ -0,0 +1,74 @@
+package match
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name, expr, err string
+	}{
+		{"empty", ``, ""},
+		{"null", `null`, ""},
+		{"field", `{"field":"description","op":"contains","value":"rent"}`, ""},
+		{"nested", `{"any":[{"not":{"field":"account","op":"equals","value":"a"}},{"field":"amount","op":"between","min":1}]}`, ""},
+		{"not json", `{`, "invalid JSON"},
+		{"no node", `{}`, "exactly one of"},
+		{"two nodes", `{"all":[],"field":"description","op":"equals"}`, "exactly one of"},
+		{"empty all", `{"all":[]}`, "at least one node"},
+		{"empty any", `{"not":{"any":[]}}`, "at least one node"},
+		{"null child", `{"all":[null]}`, "empty node"},
+		{"unknown field", `{"field":"memo","op":"equals","value":"x"}`, `unknown field "memo"`},
+		{"unknown op", `{"field":"description","op":"starts","value":"x"}`, `unknown op "starts"`},
+		{"bad regex", `{"all":[{"field":"description","op":"regex","value":"("}]}`, "invalid regex"},
+		{"amount op", `{"field":"amount","op":"equals","value":"5"}`, "amount needs op between"},
+		{"amount bounds", `{"field":"amount","op":"between"}`, "amount needs op between"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.expr))
+			if tt.err == "" && err != nil {
+				t.Errorf("Parse() error %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("Parse() error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	rent := Fields{Description: "Rent March", CounterpartyAccount: "2400123456/2010", AccountID: "acc1", Amount: 1200}
+	tests := []struct {
+		name, expr string
+		want       bool
+	}{
+		{"nil", `null`, true},
+		{"equals ignores case", `{"field":"description","op":"equals","value":"rent march"}`, true},
+		{"contains", `{"field":"description","op":"contains","value":"MARCH"}`, true},
+		{"regex", `{"field":"counterparty_account","op":"regex","value":"/2010$"}`, true},
+		{"regex miss", `{"field":"raw_description","op":"regex","value":"."}`, false},
+		{"between", `{"field":"amount","op":"between","min":1000,"max":1500}`, true},
+		{"below min", `{"field":"amount","op":"between","min":1201}`, false},
+		{"above max", `{"field":"amount","op":"between","max":1199.99}`, false},
+		{"all", `{"all":[
+			{"field":"counterparty_account","op":"equals","value":"2400123456/2010"},
+			{"field":"amount","op":"between","min":1000,"max":1500},
+			{"field":"description","op":"contains","value":"rent"}]}`, true},
+		{"all one fails", `{"all":[{"field":"account","op":"equals","value":"acc1"},{"field":"account","op":"equals","value":"acc2"}]}`, false},
+		{"any", `{"any":[{"field":"account","op":"equals","value":"acc2"},{"field":"account","op":"equals","value":"acc1"}]}`, true},
+		{"not", `{"not":{"field":"merchant","op":"equals","value":""}}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Parse([]byte(tt.expr))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := e.Match(rent); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package budget
+
+import (
+	"fmt"
+	"time"
+
+	"lifehub/backend/internal/domain"
+	"lifehub/backend/internal/filter"
+	"lifehub/backend/internal/services/categories"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// allocator assigns transactions to budget items. A manual assignment
+// wins over the match rules; otherwise items are tried in priority order.
+type allocator struct {
+	items  []domain.BudgetItem
+	tree   *categories.Tree
+	mode   string            // a transaction matched by several items counts toward the "first" or is "split" between them
+	manual map[string]string // allocation key -> budget item, "" to leave out
+}
+
+// share is the part of a transaction counted toward one budget item
+type share struct {
+	itemID string
+	amount float64
+}
+
+// allocation is the outcome of assigning a set of transactions
+type allocation struct {
+	matched   map[string][]domain.FinancialRecord // item -> its shares of transactions
+	assigned  map[string][]share                  // allocation key -> shares
+	conflicts []domain.BudgetConflict
+}
+
+// newAllocator loads the match mode and the manual assignments of a
+// workspace for matching transactions to items
+func newAllocator(workspaceID string, items []domain.BudgetItem, tree *categories.Tree) (allocator, error) {
+	a := allocator{items: items, tree: tree, mode: "first", manual: map[string]string{}}
+
+	// The field allows "first" and "split"; anything else counts as "first"
+	if ws, err := App.FindRecordById("workspaces", workspaceID); err == nil && ws.GetString("budget_match_mode") == "split" {
+		a.mode = "split"
+	}
+
+	records, err := filter.Eq("workspace", workspaceID).Where("manual = true").Find(App, "finance_budget_assignments", "", 0, 0)
+	if err != nil {
+		return a, fmt.Errorf("failed to load budget assignments: %w", err)
+	}
+	for _, r := range records {
+		a.manual[assignmentKey(r)] = r.GetString("budget_item")
+	}
+	return a, nil
+}
+
+func assignmentKey(r *core.Record) string {
+	return allocationKey(domain.FinancialRecord{ID: r.GetString("transaction"), SplitID: r.GetString("split")})
+}
+
+// allocate assigns every transaction, or split allocation of one, to at
+// most one item in the "first" mode, or shares it between all the items it
+// matches in the "split" mode. Transactions matched by several items are
+// reported as conflicts.
+func (a allocator) allocate(transactions []domain.FinancialRecord) allocation {
+	result := allocation{
+		matched:   make(map[string][]domain.FinancialRecord),
+		assigned:  make(map[string][]share),
+		conflicts: []domain.BudgetConflict{},
+	}
+	assign := func(tx domain.FinancialRecord, itemID string, amount float64) {
+		key := allocationKey(tx)
+		result.assigned[key] = append(result.assigned[key], share{itemID: itemID, amount: amount})
+		tx.Amount = amount
+		result.matched[itemID] = append(result.matched[itemID], tx)
+	}
+
+	for _, tx := range transactions {
+		if itemID, ok := a.manual[allocationKey(tx)]; ok {
+			if itemID == "" {
+				// Left out of budgets on purpose
+				result.assigned[allocationKey(tx)] = []share{}
+			} else {
+				assign(tx, itemID, tx.Amount)
+			}
+			continue
+		}
+
+		var matches []string
+		for _, item := range a.items {
+			if matchesItem(item, tx, a.tree) {
+				matches = append(matches, item.ID)
+			}
+		}
+		if len(matches) == 0 {
+			continue
+		}
+
+		winners := matches[:1]
+		if a.mode == "split" {
+			winners = matches
+		}
+		for _, itemID := range winners {
+			assign(tx, itemID, tx.Amount/float64(len(winners)))
+		}
+		if len(matches) > 1 {
+			result.conflicts = append(result.conflicts, domain.BudgetConflict{
+				Transaction:   tx,
+				BudgetItemIDs: matches,
+				AssignedTo:    winners,
+			})
+		}
+	}
+	return result
+}
+
+// claimed reports whether tx counts toward a budget item or was left out
+// of budgets manually
+func (r allocation) claimed(tx domain.FinancialRecord) bool {
+	_, ok := r.assigned[allocationKey(tx)]
+	return ok
+}
+
+// save stores the automatic assignments of the transactions booked since
+// start, replacing the stored ones that changed. Stored ones of other
+// transactions since start, such as ones now marked as transfers, are dropped.
+func (a allocator) save(txApp core.App, workspaceID string, start time.Time, transactions []domain.FinancialRecord, result allocation) error {
+	existing, err := txApp.FindAllRecords("finance_budget_assignments",
+		dbx.HashExp{"workspace": workspaceID, "manual": false},
+		dbx.NewExp("[[transaction]] IN (SELECT [[id]] FROM {{finance_transactions}} WHERE [[workspace]] = {:workspace} AND [[date]] >= {:start})", dbx.Params{
+			"workspace": workspaceID,
+			"start":     start.Format("2006-01-02"),
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to load budget assignments: %w", err)
+	}
+	collection, err := txApp.FindCollectionByNameOrId("finance_budget_assignments")
+	if err != nil {
+		return err
+	}
+
+	want := make(map[string]float64) // allocation key/item -> amount
+	for key, shares := range result.assigned {
+		if _, ok := a.manual[key]; ok {
+			continue
+		}
+		for _, s := range shares {
+			want[key+"|"+s.itemID] = s.amount
+		}
+	}
+
+	for _, r := range existing {
+		key := assignmentKey(r) + "|" + r.GetString("budget_item")
+		amount, ok := want[key]
+		delete(want, key)
+		switch {
+		case !ok:
+			if err := txApp.Delete(r); err != nil {
+				return err
+			}
+		case r.GetFloat("amount") != amount:
+			r.Set("amount", amount)
+			if err := txApp.Save(r); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, tx := range transactions {
+		key := allocationKey(tx)
+		for _, s := range result.assigned[key] {
+			amount, ok := want[key+"|"+s.itemID]
+			if !ok {
+				continue // stored already
+			}
+			r := core.NewRecord(collection)
+			r.Set("workspace", workspaceID)
+			r.Set("transaction", tx.ID)
+			r.Set("split", tx.SplitID)
+			r.Set("budget_item", s.itemID)
+			r.Set("amount", amount)
+			if err := txApp.Save(r); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Assign pins a transaction, or one split allocation of it, to a budget
+// item of its workspace. An empty itemID leaves it out of budgets.
+func Assign(transactionID, splitID, itemID string) (*domain.BudgetAssignment, error) {
+	if App == nil {
+		return nil, fmt.Errorf("PocketBase app not initialized")
+	}
+	tx, err := App.FindRecordById("finance_transactions", transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("transaction not found")
+	}
+	workspaceID := tx.GetString("workspace")
+
+	amount := tx.GetFloat("amount")
+	if splitID != "" {
+		split, err := App.FindRecordById("finance_transaction_splits", splitID)
+		if err != nil || split.GetString("transaction") != tx.Id {
+			return nil, fmt.Errorf("split not found")
+		}
+		amount = split.GetFloat("amount")
+	}
+	if itemID != "" {
+		item, err := App.FindRecordById("finance_budget_items", itemID)
+		if err != nil || item.GetString("workspace") != workspaceID {
+			return nil, fmt.Errorf("budget item not found")
+		}
+	} else {
+		amount = 0
+	}
+
+	collection, err := App.FindCollectionByNameOrId("finance_budget_assignments")
+	if err != nil {
+		return nil, err
+	}
+	record := core.NewRecord(collection)
+	err = App.RunInTransaction(func(txApp core.App) error {
+		if err := deleteAssignments(txApp, transactionID, splitID); err != nil {
+			return err
+		}
+		record.Set("workspace", workspaceID)
+		record.Set("transaction", transactionID)
+		record.Set("split", splitID)
+		record.Set("budget_item", itemID)
+		record.Set("amount", amount)
+		record.Set("manual", true)
+		return txApp.Save(record)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save budget assignment: %w", err)
+	}
+	Changed(workspaceID, tx.GetDateTime("date").Time())
+	a := assignmentFromRecord(record)
+	return &a, nil
+}
+
+// Unassign returns a transaction, or one split allocation of it, to the
+// match rules
+func Unassign(transactionID, splitID string) error {
+	if App == nil {
+		return fmt.Errorf("PocketBase app not initialized")
+	}
+	err := App.RunInTransaction(func(txApp core.App) error {
+		return deleteAssignments(txApp, transactionID, splitID)
+	})
+	if err != nil {
+		return err
+	}
+	// The match rules assign it again
+	if tx, err := App.FindRecordById("finance_transactions", transactionID); err == nil {
+		Changed(tx.GetString("workspace"), tx.GetDateTime("date").Time())
+	}
+	return nil
+}
+
+func deleteAssignments(app core.App, transactionID, splitID string) error {
+	records, err := app.FindAllRecords("finance_budget_assignments", dbx.HashExp{"transaction": transactionID, "split": splitID})
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		if err := app.Delete(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Assignments lists the stored assignments of a workspace, of one
+// transaction when transactionID is set
+func Assignments(workspaceID, transactionID string) ([]domain.BudgetAssignment, error) {
+	if err := settle(workspaceID); err != nil {
+		return nil, fmt.Errorf("failed to refresh budget: %w", err)
+	}
+	records, err := filter.Eq("workspace", workspaceID).EqIf("transaction", transactionID).Find(App, "finance_budget_assignments", "transaction,split", 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load budget assignments: %w", err)
+	}
+	assignments := make([]domain.BudgetAssignment, 0, len(records))
+	for _, r := range records {
+		assignments = append(assignments, assignmentFromRecord(r))
+	}
+	return assignments, nil
+}
+
+func assignmentFromRecord(r *core.Record) domain.BudgetAssignment {
+	return domain.BudgetAssignment{
+		ID:            r.Id,
+		TransactionID: r.GetString("transaction"),
+		SplitID:       r.GetString("split"),
+		BudgetItemID:  r.GetString("budget_item"),
+		Amount:        r.GetFloat("amount"),
+		Manual:        r.GetBool("manual"),
+	}
+}
+
+// Conflicts reports the transactions in [startDate, endDate] matched by
+// several active budget items and the items they were counted toward
+func Conflicts(workspaceID string, startDate, endDate time.Time) ([]domain.BudgetConflict, error) {
+	budgets, err := loadBudgets(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := loadTransactions(workspaceID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	tree, _ := categories.Load(workspaceID)
+	a, err := newAllocator(workspaceID, activeItems(budgets), tree)
+	if err != nil {
+		return nil, err
+	}
+	return a.allocate(transactions).conflicts, nil
+}
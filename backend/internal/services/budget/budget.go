@@ -1,25 +1,42 @@
 package budget
 
 import (
+	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
 	"lifehub/backend/internal/domain"
+	"lifehub/backend/internal/filter"
+	"lifehub/backend/internal/match"
+	"lifehub/backend/internal/services/categories"
+	"lifehub/backend/internal/services/currency"
+	"lifehub/backend/internal/services/splits"
 
+	"github.com/pocketbase/dbx"
 	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
 )
 
 var App *pocketbase.PocketBase
 
 // ComputeStatus calculates the full budget summary for a workspace over a date range.
 func ComputeStatus(workspaceID string, startDate, endDate time.Time) (*domain.BudgetSummary, error) {
-	// Calculate the number of months in the period for frequency normalization
+	if err := settle(workspaceID); err != nil {
+		return nil, fmt.Errorf("failed to refresh budget: %w", err)
+	}
+
+	// Calculate the number of months in the period for income normalization
 	months := monthsBetween(startDate, endDate)
 	if months < 1 {
 		months = 1
 	}
 
+	// All amounts are compared in the workspace base currency
+	conv := newConverter(workspaceID)
+
 	// 1. Load active income sources
 	incomeSources, err := loadIncomeSources(workspaceID)
 	if err != nil {
@@ -27,10 +44,12 @@ func ComputeStatus(workspaceID string, startDate, endDate time.Time) (*domain.Bu
 	}
 
 	// 2. Compute income status
+	hours := loadHours(workspaceID, startDate, endDate)
 	incomeStatuses := []domain.IncomeSourceStatus{}
 	var totalIncome float64
 	for _, src := range incomeSources {
-		status := computeIncomeStatus(workspaceID, src, startDate, endDate, months)
+		status := computeIncomeStatus(src, hours[src.ID], startDate, endDate, months)
+		status.CalculatedAmount = conv.toBase(status.CalculatedAmount, src.Currency, endDate)
 		incomeStatuses = append(incomeStatuses, status)
 		totalIncome += status.CalculatedAmount
 	}
@@ -46,48 +65,55 @@ func ComputeStatus(workspaceID string, startDate, endDate time.Time) (*domain.Bu
 	if err != nil {
 		return nil, err
 	}
+	for i := range transactions {
+		conv.normalize(&transactions[i])
+	}
 
-	// 5. Match transactions to budget items (single-claim, first match wins)
-	claimed := make(map[string]bool) // transaction ID -> claimed
-	budgetStatuses := []domain.BudgetGroupStatus{}
+	// Items targeting a parent category also match its subcategories
+	tree, _ := categories.Load(workspaceID)
+
+	// 5. Assign transactions to budget items by priority, keeping manual assignments
+	a, err := newAllocator(workspaceID, activeItems(budgets), tree)
+	if err != nil {
+		return nil, err
+	}
+	result := a.allocate(transactions)
+
+	// 6. Read the envelope of every item over the calendar months from the ledger
+	envelopes, err := ledgerEnvelopes(workspaceID, a.items, conv, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
 
+	budgetStatuses := []domain.BudgetGroupStatus{}
 	for _, b := range budgets {
 		groupStatus := domain.BudgetGroupStatus{
 			Budget: b,
 		}
-		b.Items = sortByOrder(b.Items)
 
 		for _, item := range b.Items {
 			if !item.IsActive {
 				continue
 			}
 
+			env := envelopes[item.ID]
 			itemStatus := domain.BudgetItemStatus{
-				BudgetItem: item,
+				BudgetItem:     item,
+				OpeningBalance: env.OpeningBalance,
+				Assigned:       env.Assigned,
+				Spent:          env.Spent,
+				ClosingBalance: env.ClosingBalance,
 			}
 
-			// Normalize budgeted amount based on frequency
-			normalized := item.BudgetedAmount
-			if item.Frequency == "yearly" {
-				normalized = item.BudgetedAmount / 12 * float64(months)
-			} else {
-				normalized = item.BudgetedAmount * float64(months)
-			}
+			// Prorate the budgeted amount to the days of the period
+			normalized := conv.toBase(budgetedAmount(item, startDate, endDate), item.Currency, endDate)
 			itemStatus.NormalizedAmount = normalized
 
-			// Match transactions
 			var actualAmount float64
-			for i := range transactions {
-				tx := &transactions[i]
-				if claimed[tx.ID] {
-					continue
-				}
-				if matchesItem(item, *tx) {
-					claimed[tx.ID] = true
-					actualAmount += tx.Amount
-					itemStatus.MatchedTransactions = append(itemStatus.MatchedTransactions, *tx)
-				}
+			for _, tx := range result.matched[item.ID] {
+				actualAmount += tx.Amount
 			}
+			itemStatus.MatchedTransactions = result.matched[item.ID]
 
 			itemStatus.ActualAmount = actualAmount
 			itemStatus.Difference = normalized - actualAmount
@@ -111,7 +137,7 @@ func ComputeStatus(workspaceID string, startDate, endDate time.Time) (*domain.Bu
 		budgetStatuses = append(budgetStatuses, groupStatus)
 	}
 
-	// 6. Collect unmatched expenses
+	// 7. Collect unmatched expenses
 	var unmatchedExpenses []domain.FinancialRecord
 	var totalBudgeted, totalActual float64
 	for _, gs := range budgetStatuses {
@@ -120,7 +146,7 @@ func ComputeStatus(workspaceID string, startDate, endDate time.Time) (*domain.Bu
 	}
 
 	for _, tx := range transactions {
-		if !claimed[tx.ID] && tx.IsExpense {
+		if !result.claimed(tx) && tx.IsExpense {
 			unmatchedExpenses = append(unmatchedExpenses, tx)
 		}
 	}
@@ -133,52 +159,34 @@ func ComputeStatus(workspaceID string, startDate, endDate time.Time) (*domain.Bu
 		TotalActual:       totalActual,
 		Remaining:         totalIncome - totalActual,
 		UnmatchedExpenses: unmatchedExpenses,
+		Conflicts:         result.conflicts,
+		MatchMode:         a.mode,
+		Currency:          conv.Base,
+		MissingRates:      conv.missingRates(),
 	}, nil
 }
 
 func loadIncomeSources(workspaceID string) ([]domain.IncomeSource, error) {
-	filter := "workspace = '" + workspaceID + "' && is_active = true"
-	records, err := App.FindRecordsByFilter("finance_income_sources", filter, "name", 100, 0)
+	records, err := filter.Eq("workspace", workspaceID).Where("is_active = true").Find(App, "finance_income_sources", "name", 100, 0)
 	if err != nil {
 		return []domain.IncomeSource{}, nil
 	}
 
 	var sources []domain.IncomeSource
 	for _, r := range records {
-		sources = append(sources, domain.IncomeSource{
-			ID:           r.Id,
-			Name:         r.GetString("name"),
-			IncomeType:   r.GetString("income_type"),
-			Amount:       r.GetFloat("amount"),
-			Currency:     r.GetString("currency"),
-			DefaultHours: r.GetFloat("default_hours"),
-			IsActive:     r.GetBool("is_active"),
-			Notes:        r.GetString("notes"),
-		})
+		sources = append(sources, IncomeSourceFromRecord(r))
 	}
 	return sources, nil
 }
 
-func computeIncomeStatus(workspaceID string, src domain.IncomeSource, startDate, endDate time.Time, months float64) domain.IncomeSourceStatus {
+func computeIncomeStatus(src domain.IncomeSource, hours monthHours, startDate, endDate time.Time, months float64) domain.IncomeSourceStatus {
 	status := domain.IncomeSourceStatus{
 		IncomeSource: src,
 	}
 
 	if src.IncomeType == "hourly" {
-		// Check for hour overrides for each month in the range
-		var totalHours float64
-		current := time.Date(startDate.Year(), startDate.Month(), 1, 0, 0, 0, 0, time.UTC)
-		end := time.Date(endDate.Year(), endDate.Month(), 1, 0, 0, 0, 0, time.UTC)
-
-		for !current.After(end) {
-			hours := getHoursForMonth(workspaceID, src.ID, current.Year(), int(current.Month()))
-			if hours == 0 {
-				hours = src.DefaultHours
-			}
-			totalHours += hours
-			current = current.AddDate(0, 1, 0)
-		}
-
+		// Months without an hour override are worked at the default hours
+		totalHours := hours.Hours + float64(monthSpan(startDate, endDate)-hours.Months)*src.DefaultHours
 		status.HoursThisMonth = totalHours
 		status.CalculatedAmount = src.Amount * totalHours
 	} else {
@@ -188,96 +196,220 @@ func computeIncomeStatus(workspaceID string, src domain.IncomeSource, startDate,
 	return status
 }
 
-func getHoursForMonth(workspaceID, incomeSourceID string, year, month int) float64 {
-	filter := "workspace = '" + workspaceID + "' && income_source = '" + incomeSourceID + "' && year = " + itoa(year) + " && month = " + itoa(month)
-	records, err := App.FindRecordsByFilter("finance_income_hours", filter, "", 1, 0)
-	if err != nil || len(records) == 0 {
+// monthHours sums the hour overrides of one income source
+type monthHours struct {
+	Source string  `db:"income_source"`
+	Hours  float64 `db:"hours"`
+	Months int     `db:"months"` // months with an override
+}
+
+// loadHours sums the non-zero hour overrides per income source over the
+// calendar months touched by [startDate, endDate]
+func loadHours(workspaceID string, startDate, endDate time.Time) map[string]monthHours {
+	var rows []monthHours
+	err := App.DB().
+		Select("income_source", "SUM(hours) AS hours", "COUNT(DISTINCT [[year]] * 12 + [[month]]) AS months").
+		From("finance_income_hours").
+		Where(dbx.NewExp("[[workspace]] = {:workspace} AND [[hours]] > 0 AND [[year]] * 12 + [[month]] BETWEEN {:from} AND {:to}", dbx.Params{
+			"workspace": workspaceID,
+			"from":      startDate.Year()*12 + int(startDate.Month()),
+			"to":        endDate.Year()*12 + int(endDate.Month()),
+		})).
+		GroupBy("income_source").
+		All(&rows)
+
+	hours := make(map[string]monthHours, len(rows))
+	if err != nil {
+		return hours
+	}
+	for _, r := range rows {
+		hours[r.Source] = r
+	}
+	return hours
+}
+
+// monthSpan counts the calendar months touched by [start, end]
+func monthSpan(start, end time.Time) int {
+	n := (end.Year()-start.Year())*12 + int(end.Month()) - int(start.Month()) + 1
+	if n < 0 {
 		return 0
 	}
-	return records[0].GetFloat("hours")
+	return n
 }
 
 func loadBudgets(workspaceID string) ([]domain.Budget, error) {
-	filter := "workspace = '" + workspaceID + "' && is_active = true"
-	records, err := App.FindRecordsByFilter("finance_budgets", filter, "sort_order", 100, 0)
+	records, err := filter.Eq("workspace", workspaceID).Where("is_active = true").Find(App, "finance_budgets", "sort_order", 100, 0)
 	if err != nil {
 		return []domain.Budget{}, nil
 	}
 
-	var budgets []domain.Budget
-	for _, r := range records {
-		b := domain.Budget{
-			ID:        r.Id,
-			Name:      r.GetString("name"),
-			Icon:      r.GetString("icon"),
-			Color:     r.GetString("color"),
-			SortOrder: int(r.GetFloat("sort_order")),
-			IsActive:  r.GetBool("is_active"),
-		}
-
-		// Load items for this budget
-		itemFilter := "budget = '" + r.Id + "' && workspace = '" + workspaceID + "'"
-		itemRecords, err := App.FindRecordsByFilter("finance_budget_items", itemFilter, "sort_order", 100, 0)
-		if err == nil {
-			for _, ir := range itemRecords {
-				b.Items = append(b.Items, domain.BudgetItem{
-					ID:               ir.Id,
-					BudgetID:         ir.GetString("budget"),
-					Name:             ir.GetString("name"),
-					BudgetedAmount:   ir.GetFloat("budgeted_amount"),
-					Currency:         ir.GetString("currency"),
-					Frequency:        ir.GetString("frequency"),
-					MatchPattern:     ir.GetString("match_pattern"),
-					MatchPatternType: ir.GetString("match_pattern_type"),
-					MatchField:       ir.GetString("match_field"),
-					MatchCategoryID:  ir.GetString("match_category"),
-					MatchMerchantID:  ir.GetString("match_merchant"),
-					MatchAccountID:   ir.GetString("match_account"),
-					IsExpense:        ir.GetBool("is_expense"),
-					SortOrder:        int(ir.GetFloat("sort_order")),
-					IsActive:         ir.GetBool("is_active"),
-					Notes:            ir.GetString("notes"),
-				})
-			}
+	// Items of all budgets in one query instead of one per budget
+	itemsByBudget := make(map[string][]domain.BudgetItem)
+	itemRecords, err := filter.Eq("workspace", workspaceID).Find(App, "finance_budget_items", "sort_order", 0, 0)
+	if err == nil {
+		for _, ir := range itemRecords {
+			itemsByBudget[ir.GetString("budget")] = append(itemsByBudget[ir.GetString("budget")], BudgetItemFromRecord(ir))
 		}
+	}
 
+	var budgets []domain.Budget
+	for _, r := range records {
+		b := BudgetFromRecord(r)
+		b.Items = itemsByBudget[r.Id]
 		budgets = append(budgets, b)
 	}
 	return budgets, nil
 }
 
-func loadTransactions(workspaceID string, startDate, endDate time.Time) ([]domain.FinancialRecord, error) {
-	startStr := startDate.Format("2006-01-02")
-	endStr := endDate.Format("2006-01-02")
-	filter := "workspace = '" + workspaceID + "' && date >= '" + startStr + "' && date <= '" + endStr + "'"
+// IncomeSourceFromRecord converts a finance_income_sources record
+func IncomeSourceFromRecord(r *core.Record) domain.IncomeSource {
+	return domain.IncomeSource{
+		ID:           r.Id,
+		Name:         r.GetString("name"),
+		IncomeType:   r.GetString("income_type"),
+		Amount:       r.GetFloat("amount"),
+		Currency:     r.GetString("currency"),
+		DefaultHours: r.GetFloat("default_hours"),
+		IsActive:     r.GetBool("is_active"),
+		Notes:        r.GetString("notes"),
+	}
+}
 
-	records, err := App.FindRecordsByFilter("finance_transactions", filter, "-date", 0, 0)
+// BudgetFromRecord converts a finance_budgets record, without its items
+func BudgetFromRecord(r *core.Record) domain.Budget {
+	return domain.Budget{
+		ID:        r.Id,
+		Name:      r.GetString("name"),
+		Icon:      r.GetString("icon"),
+		Color:     r.GetString("color"),
+		SortOrder: int(r.GetFloat("sort_order")),
+		IsActive:  r.GetBool("is_active"),
+	}
+}
+
+// BudgetItemFromRecord converts a finance_budget_items record
+func BudgetItemFromRecord(r *core.Record) domain.BudgetItem {
+	return domain.BudgetItem{
+		ID:               r.Id,
+		BudgetID:         r.GetString("budget"),
+		Name:             r.GetString("name"),
+		BudgetedAmount:   r.GetFloat("budgeted_amount"),
+		Currency:         r.GetString("currency"),
+		Frequency:        r.GetString("frequency"),
+		FrequencyDays:    int(r.GetFloat("frequency_days")),
+		DueMonth:         int(r.GetFloat("due_month")),
+		Rollover:         r.GetString("rollover"),
+		MatchPattern:     r.GetString("match_pattern"),
+		MatchPatternType: r.GetString("match_pattern_type"),
+		MatchField:       r.GetString("match_field"),
+		MatchCategoryID:  r.GetString("match_category"),
+		MatchMerchantID:  r.GetString("match_merchant"),
+		MatchAccountID:   r.GetString("match_account"),
+		MatchExpression:  matchExpression(r),
+		IsExpense:        r.GetBool("is_expense"),
+		Priority:         int(r.GetFloat("priority")),
+		SortOrder:        int(r.GetFloat("sort_order")),
+		IsActive:         r.GetBool("is_active"),
+		Notes:            r.GetString("notes"),
+	}
+}
+
+// transactionRow holds the transaction columns budget matching needs
+type transactionRow struct {
+	ID             string  `db:"id"`
+	Description    string  `db:"description"`
+	RawDescription string  `db:"raw_description"`
+	Amount         float64 `db:"amount"`
+	Type           string  `db:"type"`
+	Date           string  `db:"date"`
+	Account        string  `db:"account"`
+	Category       string  `db:"category_rel"`
+	Merchant       string  `db:"merchant"`
+	ExternalID     string  `db:"external_id"`
+	Counterparty   string  `db:"counterparty_account"`
+}
+
+func loadTransactions(workspaceID string, startDate, endDate time.Time) ([]domain.FinancialRecord, error) {
+	// Transfers between own accounts are neither spending nor income.
+	// Only the matched columns are selected, skipping record hydration.
+	var rows []transactionRow
+	err := App.DB().
+		Select("id", "description", "raw_description", "amount", "type", "date", "account", "category_rel", "merchant", "external_id", "counterparty_account").
+		From("finance_transactions").
+		Where(dbx.NewExp("[[workspace]] = {:workspace} AND [[date]] >= {:start} AND [[date]] <= {:end} AND [[is_transfer]] = FALSE", dbx.Params{
+			"workspace": workspaceID,
+			"start":     startDate.Format("2006-01-02"),
+			"end":       endDate.Format("2006-01-02"),
+		})).
+		OrderBy("date DESC").
+		All(&rows)
 	if err != nil {
 		return []domain.FinancialRecord{}, nil
 	}
 
-	var transactions []domain.FinancialRecord
-	for _, r := range records {
-		transactions = append(transactions, domain.FinancialRecord{
-			ID:             r.Id,
-			Description:    r.GetString("description"),
-			RawDescription: r.GetString("raw_description"),
-			Amount:         r.GetFloat("amount"),
-			Currency:       r.GetString("currency"),
-			IsExpense:      r.GetString("type") == "expense",
-			Date:           r.GetDateTime("date").Time(),
-			AccountID:      r.GetString("account"),
-			CategoryID:     r.GetString("category_rel"),
-			MerchantID:     r.GetString("merchant"),
-			ExternalID:     r.GetString("external_id"),
-		})
+	accountCurrencies := currency.AccountCurrencies(workspaceID)
+
+	// Split transactions are budgeted per allocation
+	splitsByTx, err := splits.LoadForWorkspace(workspaceID)
+	if err != nil {
+		splitsByTx = map[string][]domain.TransactionSplit{}
+	}
+
+	transactions := make([]domain.FinancialRecord, 0, len(rows))
+	for _, r := range rows {
+		date, _ := types.ParseDateTime(r.Date)
+		tx := domain.FinancialRecord{
+			ID:                  r.ID,
+			Description:         r.Description,
+			RawDescription:      r.RawDescription,
+			Amount:              r.Amount,
+			Currency:            accountCurrencies[r.Account],
+			IsExpense:           r.Type == "expense",
+			Date:                date.Time(),
+			AccountID:           r.Account,
+			CategoryID:          r.Category,
+			MerchantID:          r.Merchant,
+			ExternalID:          r.ExternalID,
+			CounterpartyAccount: r.Counterparty,
+		}
+		transactions = append(transactions, splits.Expand(tx, splitsByTx[r.ID])...)
 	}
 	return transactions, nil
 }
 
+// activeItems sorts the items of every budget and returns the active ones
+// in matching order: higher priority first, then in the order of budgets
+func activeItems(budgets []domain.Budget) []domain.BudgetItem {
+	var items []domain.BudgetItem
+	for i, b := range budgets {
+		budgets[i].Items = sortByOrder(b.Items)
+		for _, item := range budgets[i].Items {
+			if item.IsActive {
+				items = append(items, item)
+			}
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Priority > items[j].Priority })
+	return items
+}
+
+// allocationKey identifies a transaction, or one split allocation of it, for single-claim matching
+func allocationKey(tx domain.FinancialRecord) string {
+	if tx.SplitID == "" {
+		return tx.ID
+	}
+	return tx.ID + "/" + tx.SplitID
+}
+
 // matchesItem checks if a transaction matches a budget item's rules.
 // Uses same pattern as categorization.go: pattern match + category/merchant/account filters.
-func matchesItem(item domain.BudgetItem, tx domain.FinancialRecord) bool {
+// A category filter matches the category and all its descendants in tree.
+func matchesItem(item domain.BudgetItem, tx domain.FinancialRecord, tree *categories.Tree) bool {
+	// The expression is an AND constraint on top of the other criteria
+	if !item.MatchExpression.Match(matchFields(tx)) {
+		return false
+	}
+
 	// Account filter is an AND constraint - if set, tx must be from that account
 	if item.MatchAccountID != "" && tx.AccountID != item.MatchAccountID {
 		return false
@@ -285,7 +417,7 @@ func matchesItem(item domain.BudgetItem, tx domain.FinancialRecord) bool {
 
 	// Category match
 	if item.MatchCategoryID != "" {
-		if tx.CategoryID != item.MatchCategoryID {
+		if !tree.Contains(item.MatchCategoryID, tx.CategoryID) {
 			return false
 		}
 		// If only category match is defined (no pattern, no merchant), category match is sufficient
@@ -312,7 +444,7 @@ func matchesItem(item domain.BudgetItem, tx domain.FinancialRecord) bool {
 		case "raw_description":
 			fieldValue = tx.RawDescription
 		case "counterparty_account":
-			fieldValue = tx.ExternalID // counterparty_account maps to external_id in our model
+			fieldValue = tx.CounterpartyAccount
 		}
 
 		if fieldValue == "" {
@@ -338,8 +470,32 @@ func matchesItem(item domain.BudgetItem, tx domain.FinancialRecord) bool {
 		return matched
 	}
 
-	// No match criteria defined - never match
-	return false
+	// An expression alone is enough; no match criteria at all never match
+	return item.MatchExpression != nil
+}
+
+// matchFields are the fields of tx match expressions are evaluated against
+func matchFields(tx domain.FinancialRecord) match.Fields {
+	return match.Fields{
+		Description:         tx.Description,
+		RawDescription:      tx.RawDescription,
+		CounterpartyAccount: tx.CounterpartyAccount,
+		AccountID:           tx.AccountID,
+		CategoryID:          tx.CategoryID,
+		MerchantID:          tx.MerchantID,
+		Amount:              tx.Amount,
+	}
+}
+
+// matchExpression parses the match_expression of a record. Invalid ones,
+// which the API refuses to save, are left out.
+func matchExpression(r *core.Record) *match.Expr {
+	raw, _ := r.Get("match_expression").(types.JSONRaw)
+	expr, err := match.Parse(raw)
+	if err != nil {
+		return nil
+	}
+	return expr
 }
 
 func sortByOrder(items []domain.BudgetItem) []domain.BudgetItem {
@@ -356,23 +512,3 @@ func monthsBetween(start, end time.Time) float64 {
 	}
 	return float64(total)
 }
-
-func itoa(n int) string {
-	if n == 0 {
-		return "0"
-	}
-	s := ""
-	neg := false
-	if n < 0 {
-		neg = true
-		n = -n
-	}
-	for n > 0 {
-		s = string(rune('0'+n%10)) + s
-		n /= 10
-	}
-	if neg {
-		s = "-" + s
-	}
-	return s
-}
@@ -0,0 +1,72 @@
+package budget
+
+import (
+	"time"
+
+	"lifehub/backend/internal/domain"
+)
+
+// Frequencies are the periods a budget item's amount can be given for. A
+// custom frequency repeats every FrequencyDays days.
+var Frequencies = []string{"weekly", "monthly", "quarterly", "semiannual", "yearly", "custom"}
+
+// periodMonths is the length of the frequencies made of calendar months
+var periodMonths = map[string]int{
+	"monthly":    1,
+	"quarterly":  3,
+	"semiannual": 6,
+	"yearly":     12,
+}
+
+// budgetedAmount prorates what an item budgets to the days of [start, end],
+// both inclusive. Day-based frequencies accrue per day; month-based ones
+// per calendar month, in proportion to the days of each month covered. An
+// item with a due month expects its whole amount in the due months of its
+// period instead of spreading it.
+func budgetedAmount(item domain.BudgetItem, start, end time.Time) float64 {
+	start, end = day(start), day(end)
+	if end.Before(start) {
+		return 0
+	}
+	days := end.Sub(start).Hours()/24 + 1
+
+	switch item.Frequency {
+	case "weekly":
+		return item.BudgetedAmount * days / 7
+	case "custom":
+		if item.FrequencyDays < 1 {
+			return 0
+		}
+		return item.BudgetedAmount * days / float64(item.FrequencyDays)
+	}
+
+	period, ok := periodMonths[item.Frequency]
+	if !ok {
+		period = 1
+	}
+	var total float64
+	for month := monthIndex(start); month <= monthIndex(end); month++ {
+		first, last := monthStart(month), monthStart(month+1).AddDate(0, 0, -1)
+		covered := last.Sub(first).Hours()/24 + 1
+		if start.After(first) {
+			first = start
+		}
+		if end.Before(last) {
+			last = end
+		}
+		share := (last.Sub(first).Hours()/24 + 1) / covered
+
+		switch {
+		case item.DueMonth == 0 || period == 1:
+			total += item.BudgetedAmount / float64(period) * share
+		case (month%12+1-item.DueMonth+12)%period == 0:
+			total += item.BudgetedAmount * share
+		}
+	}
+	return total
+}
+
+// day truncates t to midnight UTC of its date
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,52 @@
+package budget
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"lifehub/backend/internal/domain"
+)
+
+func TestBudgetedAmount(t *testing.T) {
+	date := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	item := func(frequency string, amount float64, days, due int) domain.BudgetItem {
+		return domain.BudgetItem{Frequency: frequency, BudgetedAmount: amount, FrequencyDays: days, DueMonth: due}
+	}
+
+	tests := []struct {
+		name       string
+		item       domain.BudgetItem
+		start, end string
+		want       float64
+	}{
+		{"monthly, whole month", item("monthly", 3100, 0, 0), "2024-01-01", "2024-01-31", 3100},
+		{"monthly, half a month", item("monthly", 3100, 0, 0), "2024-01-01", "2024-01-15", 1500},
+		{"monthly, a quarter", item("monthly", 1000, 0, 0), "2024-01-01", "2024-03-31", 3000},
+		{"monthly, across months", item("monthly", 3100, 0, 0), "2024-01-17", "2024-02-29", 1500 + 3100},
+		{"unknown is monthly", item("", 1000, 0, 0), "2024-04-01", "2024-04-30", 1000},
+		{"weekly", item("weekly", 70, 0, 0), "2024-02-01", "2024-02-29", 290},
+		{"custom", item("custom", 100, 10, 0), "2024-01-01", "2024-01-05", 50},
+		{"custom without days", item("custom", 100, 0, 0), "2024-01-01", "2024-01-31", 0},
+		{"quarterly spread", item("quarterly", 900, 0, 0), "2024-05-01", "2024-05-31", 300},
+		{"semiannual spread", item("semiannual", 600, 0, 0), "2024-01-01", "2024-12-31", 1200},
+		{"yearly spread", item("yearly", 1200, 0, 0), "2024-06-01", "2024-06-30", 100},
+		{"yearly in its due month", item("yearly", 1200, 0, 9), "2024-09-01", "2024-09-30", 1200},
+		{"yearly outside its due month", item("yearly", 1200, 0, 9), "2024-01-01", "2024-08-31", 0},
+		{"quarterly due months", item("quarterly", 900, 0, 2), "2024-01-01", "2024-06-30", 1800},
+		{"semiannual due across the year end", item("semiannual", 500, 0, 11), "2024-06-01", "2025-05-31", 1000},
+		{"reversed range", item("monthly", 1000, 0, 0), "2024-02-01", "2024-01-01", 0},
+	}
+	for _, tt := range tests {
+		got := budgetedAmount(tt.item, date(tt.start), date(tt.end))
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: budgetedAmount() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
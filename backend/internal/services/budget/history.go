@@ -0,0 +1,88 @@
+package budget
+
+import (
+	"time"
+
+	"lifehub/backend/internal/domain"
+	"lifehub/backend/internal/services/categories"
+)
+
+// ComputeHistory compares the budgeted and actual amount of every active
+// budget item for each calendar month touched by [startDate, endDate],
+// loading budgets and transactions once for the whole range.
+func ComputeHistory(workspaceID string, startDate, endDate time.Time) (*domain.BudgetHistory, error) {
+	conv := newConverter(workspaceID)
+	first, last := monthIndex(startDate), monthIndex(endDate)
+
+	budgets, err := loadBudgets(workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	tree, _ := categories.Load(workspaceID)
+	a, err := newAllocator(workspaceID, activeItems(budgets), tree)
+	if err != nil {
+		return nil, err
+	}
+	spent, err := spentByMonth(workspaceID, a, conv, first, last)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &domain.BudgetHistory{
+		Months:   []string{},
+		Budgets:  []domain.BudgetGroupHistory{},
+		Currency: conv.Base,
+	}
+	for month := first; month <= last; month++ {
+		history.Months = append(history.Months, monthStart(month).Format("2006-01"))
+	}
+
+	for _, b := range budgets {
+		group := domain.BudgetGroupHistory{
+			Budget: b,
+			Months: make([]domain.BudgetMonth, len(history.Months)),
+			Items:  []domain.BudgetItemHistory{},
+		}
+		for i, label := range history.Months {
+			group.Months[i].Month = label
+		}
+
+		for _, item := range b.Items {
+			if !item.IsActive {
+				continue
+			}
+			h := domain.BudgetItemHistory{BudgetItem: item, Months: []domain.BudgetMonth{}}
+			var overspend float64
+			for month := first; month <= last; month++ {
+				monthEnd := monthStart(month+1).AddDate(0, 0, -1)
+				m := domain.BudgetMonth{
+					Month:    monthStart(month).Format("2006-01"),
+					Budgeted: conv.toBase(budgetedAmount(item, monthStart(month), monthEnd), item.Currency, monthEnd),
+					Actual:   spent[month][item.ID],
+				}
+				m.Difference = m.Budgeted - m.Actual
+				if m.Difference < 0 {
+					h.MonthsOverBudget++
+					overspend -= m.Difference
+				}
+				h.Months = append(h.Months, m)
+				h.TotalBudgeted += m.Budgeted
+				h.TotalActual += m.Actual
+
+				g := &group.Months[month-first]
+				g.Budgeted += m.Budgeted
+				g.Actual += m.Actual
+				g.Difference += m.Difference
+			}
+			h.AverageActual = h.TotalActual / float64(len(h.Months))
+			if h.MonthsOverBudget > 0 {
+				h.AverageOverspend = overspend / float64(h.MonthsOverBudget)
+			}
+			group.Items = append(group.Items, h)
+		}
+		history.Budgets = append(history.Budgets, group)
+	}
+
+	history.MissingRates = conv.missingRates()
+	return history, nil
+}
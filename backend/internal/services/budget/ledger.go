@@ -0,0 +1,332 @@
+package budget
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"lifehub/backend/internal/domain"
+	"lifehub/backend/internal/filter"
+	"lifehub/backend/internal/services/currency"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RolloverModes are what a budget item carries from one month into the
+// next: nothing, only unspent money, only overspending, or both
+var RolloverModes = []string{"none", "surplus", "deficit", "both"}
+
+// Carry returns the part of a month's closing balance the next month opens
+// with under the rollover mode
+func Carry(mode string, closing float64) float64 {
+	switch mode {
+	case "surplus":
+		return math.Max(closing, 0)
+	case "deficit":
+		return math.Min(closing, 0)
+	case "both":
+		return closing
+	}
+	return 0
+}
+
+// converter converts amounts to the workspace base currency and remembers
+// the currencies it found no rate for
+type converter struct {
+	*currency.Converter
+	missing map[string]bool
+}
+
+func newConverter(workspaceID string) converter {
+	return converter{Converter: currency.ForWorkspace(workspaceID), missing: map[string]bool{}}
+}
+
+func (c converter) toBase(amount float64, from string, on time.Time) float64 {
+	converted, ok := c.ToBase(amount, from, on)
+	if !ok {
+		c.missing[strings.ToUpper(from)] = true
+	}
+	return converted
+}
+
+func (c converter) normalize(tx *domain.FinancialRecord) {
+	if !c.Normalize(tx) {
+		c.missing[strings.ToUpper(tx.Currency)] = true
+	}
+}
+
+func (c converter) missingRates() []string {
+	rates := []string{}
+	for cur := range c.missing {
+		rates = append(rates, cur)
+	}
+	sort.Strings(rates)
+	return rates
+}
+
+// monthIndex numbers calendar months consecutively
+func monthIndex(t time.Time) int {
+	return t.Year()*12 + int(t.Month()) - 1
+}
+
+func monthStart(index int) time.Time {
+	return time.Date(index/12, time.Month(index%12+1), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func ledgerKey(itemID string, month int) string {
+	return fmt.Sprintf("%s/%d", itemID, month)
+}
+
+// spentByMonth sums the transactions matched to each item per calendar
+// month, for the months first through last
+func spentByMonth(workspaceID string, a allocator, conv converter, first, last int) (map[int]map[string]float64, error) {
+	// The day after the last month, as the range end is compared as a string
+	transactions, err := loadTransactions(workspaceID, monthStart(first), monthStart(last+1))
+	if err != nil {
+		return nil, err
+	}
+	byMonth := make(map[int][]domain.FinancialRecord)
+	for _, tx := range transactions {
+		if month := monthIndex(tx.Date); month >= first && month <= last {
+			conv.normalize(&tx)
+			byMonth[month] = append(byMonth[month], tx)
+		}
+	}
+
+	spent := make(map[int]map[string]float64, len(byMonth))
+	for month, txs := range byMonth {
+		matched := a.allocate(txs).matched
+		spent[month] = make(map[string]float64, len(matched))
+		for itemID, txs := range matched {
+			for _, tx := range txs {
+				spent[month][itemID] += tx.Amount
+			}
+		}
+	}
+	return spent, nil
+}
+
+// ledgerMonth returns the month index of a ledger record
+func ledgerMonth(r *core.Record) int {
+	return int(r.GetFloat("year"))*12 + int(r.GetFloat("month")) - 1
+}
+
+func ledgerEntry(r *core.Record) domain.BudgetLedgerEntry {
+	return domain.BudgetLedgerEntry{
+		ID:             r.Id,
+		BudgetItemID:   r.GetString("budget_item"),
+		Year:           int(r.GetFloat("year")),
+		Month:          int(r.GetFloat("month")),
+		OpeningBalance: r.GetFloat("opening"),
+		Assigned:       r.GetFloat("assigned"),
+		Spent:          r.GetFloat("spent"),
+		ClosingBalance: r.GetFloat("closing"),
+	}
+}
+
+// assignedIn returns what an item budgets for a calendar month, in the
+// base currency
+func assignedIn(item domain.BudgetItem, conv converter, month int) float64 {
+	monthEnd := monthStart(month+1).AddDate(0, 0, -1)
+	return conv.toBase(budgetedAmount(item, monthStart(month), monthEnd), item.Currency, monthEnd)
+}
+
+// writeLedger saves the monthly envelopes of items for the months first
+// through last, carrying the balance the ledger closed with the month
+// before. The ledger of an item starts with the first month it budgets for
+// or anything was spent on it, so unspent money builds up from the start and
+// the ledger doesn't depend on which months were looked at.
+// Saved months it no longer covers are deleted.
+func writeLedger(txApp core.App, workspaceID string, items []domain.BudgetItem, conv converter, spent map[int]map[string]float64, first, last int) error {
+	records, err := txApp.FindAllRecords("finance_budget_ledger", dbx.NewExp(
+		"[[workspace]] = {:workspace} AND [[year]] * 12 + [[month]] - 1 >= {:from}",
+		dbx.Params{"workspace": workspaceID, "from": first - 1},
+	))
+	if err != nil {
+		return fmt.Errorf("failed to load budget ledger: %w", err)
+	}
+	closed := make(map[string]float64) // item -> closing balance of the month before first
+	saved := make(map[string]*core.Record, len(records))
+	for _, r := range records {
+		if month := ledgerMonth(r); month < first {
+			closed[r.GetString("budget_item")] = r.GetFloat("closing")
+		} else {
+			saved[ledgerKey(r.GetString("budget_item"), month)] = r
+		}
+	}
+
+	collection, err := txApp.FindCollectionByNameOrId("finance_budget_ledger")
+	if err != nil {
+		return err
+	}
+
+	started := make(map[string]bool, len(items))
+	carried := make(map[string]float64, len(items))
+	for _, item := range items {
+		if closing, ok := closed[item.ID]; ok {
+			started[item.ID] = true
+			carried[item.ID] = Carry(item.Rollover, closing)
+		}
+	}
+
+	for month := first; month <= last; month++ {
+		for _, item := range items {
+			key := ledgerKey(item.ID, month)
+			record := saved[key]
+			delete(saved, key)
+
+			assigned := assignedIn(item, conv, month)
+			if !started[item.ID] && assigned == 0 && spent[month][item.ID] == 0 {
+				if record != nil {
+					if err := txApp.Delete(record); err != nil {
+						return fmt.Errorf("failed to save budget ledger: %w", err)
+					}
+				}
+				continue
+			}
+			started[item.ID] = true
+
+			entry := domain.BudgetLedgerEntry{
+				BudgetItemID:   item.ID,
+				Year:           month / 12,
+				Month:          month%12 + 1,
+				OpeningBalance: carried[item.ID],
+				Assigned:       assigned,
+				Spent:          spent[month][item.ID],
+			}
+			entry.ClosingBalance = entry.OpeningBalance + entry.Assigned - entry.Spent
+			carried[item.ID] = Carry(item.Rollover, entry.ClosingBalance)
+
+			if err := saveLedgerEntry(txApp, collection, record, workspaceID, entry); err != nil {
+				return fmt.Errorf("failed to save budget ledger: %w", err)
+			}
+		}
+	}
+
+	// Items no longer active and months past the last
+	for _, r := range saved {
+		if err := txApp.Delete(r); err != nil {
+			return fmt.Errorf("failed to save budget ledger: %w", err)
+		}
+	}
+	return nil
+}
+
+// ledgerEnvelopes returns the envelope of every item over the calendar months
+// touched by [start, end] from the saved ledger: the opening balance of the
+// first, the sums assigned and spent, and the closing balance of the last.
+// Months the ledger doesn't cover yet, such as ones past its last refresh,
+// carry the balance forward with nothing spent.
+func ledgerEnvelopes(workspaceID string, items []domain.BudgetItem, conv converter, start, end time.Time) (map[string]domain.BudgetLedgerEntry, error) {
+	from, through := monthIndex(start), monthIndex(end)
+
+	// The months in range and the last one of every item before them
+	records, err := App.FindAllRecords("finance_budget_ledger", dbx.NewExp(
+		"[[workspace]] = {:workspace} AND ([[year]] * 12 + [[month]] - 1 BETWEEN {:from} AND {:through}"+
+			" OR [[year]] * 12 + [[month]] - 1 = (SELECT MAX([[l.year]] * 12 + [[l.month]] - 1) FROM {{finance_budget_ledger}} [[l]]"+
+			" WHERE [[l.budget_item]] = {{finance_budget_ledger}}.[[budget_item]] AND [[l.year]] * 12 + [[l.month]] - 1 < {:from}))",
+		dbx.Params{"workspace": workspaceID, "from": from, "through": through},
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load budget ledger: %w", err)
+	}
+	before := make(map[string]*core.Record)
+	saved := make(map[string]*core.Record, len(records))
+	for _, r := range records {
+		if month := ledgerMonth(r); month < from {
+			before[r.GetString("budget_item")] = r
+		} else {
+			saved[ledgerKey(r.GetString("budget_item"), month)] = r
+		}
+	}
+
+	result := make(map[string]domain.BudgetLedgerEntry, len(items))
+	for _, item := range items {
+		started := false
+		var carried float64
+		if r, ok := before[item.ID]; ok {
+			started = true
+			carried = Carry(item.Rollover, r.GetFloat("closing"))
+			for month := ledgerMonth(r) + 1; month < from; month++ {
+				carried = Carry(item.Rollover, carried+assignedIn(item, conv, month))
+			}
+		}
+
+		var env domain.BudgetLedgerEntry
+		for month := from; month <= through; month++ {
+			var entry domain.BudgetLedgerEntry
+			if r, ok := saved[ledgerKey(item.ID, month)]; ok {
+				entry = ledgerEntry(r)
+				started = true
+			} else {
+				entry = domain.BudgetLedgerEntry{
+					BudgetItemID: item.ID,
+					Year:         month / 12,
+					Month:        month%12 + 1,
+					Assigned:     assignedIn(item, conv, month),
+				}
+				if started {
+					entry.OpeningBalance = carried
+				}
+				entry.ClosingBalance = entry.OpeningBalance + entry.Assigned
+			}
+			if started {
+				carried = Carry(item.Rollover, entry.ClosingBalance)
+			}
+
+			if month == from {
+				env = entry
+				env.ID = ""
+			} else {
+				env.Assigned += entry.Assigned
+				env.Spent += entry.Spent
+				env.ClosingBalance = entry.ClosingBalance
+			}
+		}
+		result[item.ID] = env
+	}
+	return result, nil
+}
+
+// saveLedgerEntry creates the record of a ledger month or updates record
+// when its amounts changed
+func saveLedgerEntry(app core.App, collection *core.Collection, record *core.Record, workspaceID string, entry domain.BudgetLedgerEntry) error {
+	if record == nil {
+		record = core.NewRecord(collection)
+		record.Set("workspace", workspaceID)
+		record.Set("budget_item", entry.BudgetItemID)
+		record.Set("year", entry.Year)
+		record.Set("month", entry.Month)
+	} else if record.GetFloat("opening") == entry.OpeningBalance &&
+		record.GetFloat("assigned") == entry.Assigned &&
+		record.GetFloat("spent") == entry.Spent &&
+		record.GetFloat("closing") == entry.ClosingBalance {
+		return nil
+	}
+	record.Set("opening", entry.OpeningBalance)
+	record.Set("assigned", entry.Assigned)
+	record.Set("spent", entry.Spent)
+	record.Set("closing", entry.ClosingBalance)
+	return app.Save(record)
+}
+
+// Ledger returns the saved monthly envelopes of a workspace's budget items,
+// oldest first, optionally limited to one item
+func Ledger(workspaceID, itemID string) ([]domain.BudgetLedgerEntry, error) {
+	if err := settle(workspaceID); err != nil {
+		return nil, fmt.Errorf("failed to refresh budget: %w", err)
+	}
+	records, err := filter.Eq("workspace", workspaceID).EqIf("budget_item", itemID).Find(App, "finance_budget_ledger", "year,month", 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load budget ledger: %w", err)
+	}
+
+	entries := make([]domain.BudgetLedgerEntry, 0, len(records))
+	for _, r := range records {
+		entries = append(entries, ledgerEntry(r))
+	}
+	return entries, nil
+}
@@ -0,0 +1,44 @@
+package budget
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCarry(t *testing.T) {
+	tests := []struct {
+		mode    string
+		closing float64
+		want    float64
+	}{
+		{"none", 300, 0},
+		{"none", -300, 0},
+		{"", 300, 0},
+		{"surplus", 300, 300},
+		{"surplus", -300, 0},
+		{"deficit", 300, 0},
+		{"deficit", -300, -300},
+		{"both", 300, 300},
+		{"both", -300, -300},
+	}
+	for _, tt := range tests {
+		if got := Carry(tt.mode, tt.closing); got != tt.want {
+			t.Errorf("Carry(%q, %v) = %v, want %v", tt.mode, tt.closing, got, tt.want)
+		}
+	}
+}
+
+func TestMonthIndex(t *testing.T) {
+	for _, d := range []time.Time{
+		time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		start := monthStart(monthIndex(d))
+		if start.Year() != d.Year() || start.Month() != d.Month() || start.Day() != 1 {
+			t.Errorf("monthStart(monthIndex(%s)) = %s", d.Format("2006-01-02"), start.Format("2006-01-02"))
+		}
+	}
+	if monthIndex(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))-monthIndex(time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)) != 1 {
+		t.Error("December and January are not consecutive")
+	}
+}
@@ -0,0 +1,270 @@
+package budget
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"lifehub/backend/internal/services/categories"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// RefreshDelay is how long changes are collected before the automatic
+// assignments and the ledger of their workspaces are brought up to date
+var RefreshDelay = 5 * time.Second
+
+// pending holds the workspaces whose budget changed since the last flush
+var pending = struct {
+	sync.Mutex
+	months map[string]int // workspace -> first changed month, 0 for all
+	timer  *time.Timer
+}{months: map[string]int{}}
+
+// locks holds a mutex per workspace, so concurrent refreshes don't write the
+// same rows and reads wait for the refresh under way
+var locks sync.Map
+
+// Changed records that the budget of a workspace changed from the month of
+// date on, or for all months when date is zero. The automatic assignments
+// and the ledger are refreshed before the workspace's budget is next read,
+// at the latest after RefreshDelay.
+func Changed(workspaceID string, date time.Time) {
+	if workspaceID == "" {
+		return
+	}
+	month := 0
+	if !date.IsZero() {
+		month = monthIndex(date)
+	}
+
+	pending.Lock()
+	defer pending.Unlock()
+	if first, ok := pending.months[workspaceID]; !ok || month < first {
+		pending.months[workspaceID] = month
+	}
+	if pending.timer == nil {
+		pending.timer = time.AfterFunc(RefreshDelay, func() {
+			if err := Flush(); err != nil && App != nil {
+				App.Logger().Error("failed to refresh budgets", "error", err)
+			}
+		})
+	}
+}
+
+// ItemChanged records the update of a budget item from old to item. What
+// it budgets counts in every month, but new match rules only change the
+// months of the transactions it was assigned or matches now.
+func ItemChanged(old, item *core.Record) {
+	workspaceID := item.GetString("workspace")
+	switch {
+	case changedFields(old, item, "budget", "budgeted_amount", "currency", "frequency", "frequency_days", "due_month", "rollover", "is_active"):
+		Changed(workspaceID, time.Time{})
+	case changedFields(old, item, "match_pattern", "match_pattern_type", "match_field", "match_category", "match_merchant", "match_account", "match_expression", "priority", "sort_order"):
+		tree, _ := categories.Load(workspaceID)
+		matched, ok := firstDate(App.DB().
+			Select("COALESCE(MIN([[t.date]]), '')").
+			From("finance_budget_assignments a").
+			InnerJoin("finance_transactions t", dbx.NewExp("[[t.id]] = [[a.transaction]]")).
+			Where(dbx.HashExp{"a.budget_item": item.Id}))
+		transactions, err := loadTransactions(workspaceID, time.Time{}, time.Now().AddDate(100, 0, 0))
+		if err != nil {
+			Changed(workspaceID, time.Time{})
+			return
+		}
+		rules := BudgetItemFromRecord(item)
+		for _, tx := range transactions {
+			if matchesItem(rules, tx, tree) && (!ok || tx.Date.Before(matched)) {
+				matched, ok = tx.Date, true
+			}
+		}
+		if ok {
+			Changed(workspaceID, matched)
+		}
+	}
+}
+
+// CategoryChanged records the update of a category from old to category.
+// Only moving it changes which items match, and only in the months of the
+// transactions filed under it.
+func CategoryChanged(old, category *core.Record) {
+	if !changedFields(old, category, "parent") {
+		return
+	}
+	workspaceID := category.GetString("workspace")
+	tree, _ := categories.Load(workspaceID)
+	var ids []any
+	for _, id := range tree.Descendants(category.Id) {
+		ids = append(ids, id)
+	}
+
+	filed, ok := firstDate(App.DB().
+		Select("COALESCE(MIN([[date]]), '')").
+		From("finance_transactions").
+		Where(dbx.HashExp{"workspace": workspaceID}).
+		AndWhere(dbx.In("category_rel", ids...)))
+	split, splitOK := firstDate(App.DB().
+		Select("COALESCE(MIN([[t.date]]), '')").
+		From("finance_transaction_splits s").
+		InnerJoin("finance_transactions t", dbx.NewExp("[[t.id]] = [[s.transaction]]")).
+		Where(dbx.HashExp{"s.workspace": workspaceID}).
+		AndWhere(dbx.In("s.category", ids...)))
+	switch {
+	case splitOK && (!ok || split.Before(filed)):
+		Changed(workspaceID, split)
+	case ok:
+		Changed(workspaceID, filed)
+	}
+}
+
+// changedFields reports whether any of fields differs between old and r
+func changedFields(old, r *core.Record, fields ...string) bool {
+	for _, field := range fields {
+		if fmt.Sprint(old.Get(field)) != fmt.Sprint(r.Get(field)) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstDate runs a query selecting the earliest date of some transactions.
+// It reports false when there are none; when the query fails, it returns
+// the zero date, so every month is refreshed.
+func firstDate(q *dbx.SelectQuery) (time.Time, bool) {
+	var first string
+	if err := q.Row(&first); err != nil {
+		return time.Time{}, true
+	}
+	if first == "" {
+		return time.Time{}, false
+	}
+	date, err := types.ParseDateTime(first)
+	if err != nil {
+		return time.Time{}, true
+	}
+	return date.Time(), true
+}
+
+// Flush refreshes the automatic assignments and the ledger of every
+// workspace changed since the last flush
+func Flush() error {
+	pending.Lock()
+	workspaces := make([]string, 0, len(pending.months))
+	for workspaceID := range pending.months {
+		workspaces = append(workspaces, workspaceID)
+	}
+	if pending.timer != nil {
+		pending.timer.Stop()
+		pending.timer = nil
+	}
+	pending.Unlock()
+
+	var errs []error
+	for _, workspaceID := range workspaces {
+		if err := settle(workspaceID); err != nil {
+			errs = append(errs, fmt.Errorf("workspace %s: %w", workspaceID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// settle refreshes a workspace if it changed since its last refresh, and
+// waits for a refresh of it already under way
+func settle(workspaceID string) error {
+	mu, _ := locks.LoadOrStore(workspaceID, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	defer mu.(*sync.Mutex).Unlock()
+
+	pending.Lock()
+	first, ok := pending.months[workspaceID]
+	delete(pending.months, workspaceID)
+	pending.Unlock()
+	if !ok {
+		return nil
+	}
+	return refresh(workspaceID, first)
+}
+
+// refresh recomputes the automatic assignments and the ledger of a
+// workspace from month first on, or from its first transaction when first
+// is 0, through the current month
+func refresh(workspaceID string, first int) error {
+	if App == nil {
+		return fmt.Errorf("PocketBase app not initialized")
+	}
+	if _, err := App.FindRecordById("workspaces", workspaceID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil // deleted since
+		}
+		return fmt.Errorf("failed to load workspace: %w", err)
+	}
+
+	var span struct {
+		First string `db:"first"`
+		Last  string `db:"last"`
+	}
+	err := App.DB().
+		Select("COALESCE(MIN([[date]]), '') AS first", "COALESCE(MAX([[date]]), '') AS last").
+		From("finance_transactions").
+		Where(dbx.HashExp{"workspace": workspaceID}).
+		One(&span)
+	if err != nil {
+		return fmt.Errorf("failed to load transactions: %w", err)
+	}
+
+	through := monthIndex(time.Now())
+	if span.First != "" {
+		earliest, _ := types.ParseDateTime(span.First)
+		latest, _ := types.ParseDateTime(span.Last)
+		if first == 0 {
+			first = monthIndex(earliest.Time())
+		}
+		through = max(through, monthIndex(latest.Time()))
+	} else if first == 0 {
+		first = through
+	}
+
+	budgets, err := loadBudgets(workspaceID)
+	if err != nil {
+		return err
+	}
+	tree, _ := categories.Load(workspaceID)
+	a, err := newAllocator(workspaceID, activeItems(budgets), tree)
+	if err != nil {
+		return err
+	}
+
+	// The day after the last month, as the range end is compared as a string
+	start := monthStart(first)
+	transactions, err := loadTransactions(workspaceID, start, monthStart(through+1))
+	if err != nil {
+		return err
+	}
+	conv := newConverter(workspaceID)
+	for i := range transactions {
+		conv.normalize(&transactions[i])
+	}
+	result := a.allocate(transactions)
+
+	spent := make(map[int]map[string]float64)
+	for itemID, txs := range result.matched {
+		for _, tx := range txs {
+			month := monthIndex(tx.Date)
+			if spent[month] == nil {
+				spent[month] = make(map[string]float64)
+			}
+			spent[month][itemID] += tx.Amount
+		}
+	}
+
+	return App.RunInTransaction(func(txApp core.App) error {
+		if err := a.save(txApp, workspaceID, start, transactions, result); err != nil {
+			return err
+		}
+		return writeLedger(txApp, workspaceID, a.items, conv, spent, first, through)
+	})
+}
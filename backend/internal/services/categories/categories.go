@@ -0,0 +1,213 @@
+package categories
+
+import (
+	"fmt"
+	"sort"
+
+	"lifehub/backend/internal/domain"
+
+	"github.com/pocketbase/pocketbase"
+)
+
+// App holds the PocketBase instance
+var App *pocketbase.PocketBase
+
+// Tree indexes a workspace's categories by their parent relation
+type Tree struct {
+	byID     map[string]domain.Category
+	children map[string][]string // parent ID -> child IDs, "" for roots
+}
+
+// NewTree builds a tree from flat categories. Categories whose parent is
+// missing, or that sit on a parent cycle, become roots.
+func NewTree(cats []domain.Category) *Tree {
+	t := &Tree{byID: map[string]domain.Category{}, children: map[string][]string{}}
+	for _, c := range cats {
+		t.byID[c.ID] = c
+	}
+	for _, c := range cats {
+		parent := c.ParentID
+		if _, ok := t.byID[parent]; !ok || t.onCycle(c.ID) {
+			parent = ""
+		}
+		t.children[parent] = append(t.children[parent], c.ID)
+	}
+	return t
+}
+
+// onCycle reports whether following parents from id leads back to id
+func (t *Tree) onCycle(id string) bool {
+	seen := map[string]bool{}
+	for cur := t.byID[id].ParentID; cur != ""; cur = t.byID[cur].ParentID {
+		if cur == id {
+			return true
+		}
+		if seen[cur] {
+			return false
+		}
+		seen[cur] = true
+	}
+	return false
+}
+
+// Parent returns the effective parent of a category, "" for roots
+func (t *Tree) Parent(id string) string {
+	parent := t.byID[id].ParentID
+	if _, ok := t.byID[parent]; !ok || t.onCycle(id) {
+		return ""
+	}
+	return parent
+}
+
+// Name returns the name of a category, "" for unknown IDs
+func (t *Tree) Name(id string) string {
+	if t == nil {
+		return ""
+	}
+	return t.byID[id].Name
+}
+
+// Find returns the ID of a category with the given name, preferring a root, or ""
+func (t *Tree) Find(name string) string {
+	if t == nil {
+		return ""
+	}
+	if id := t.rootNamed(name); id != "" {
+		return id
+	}
+	ids := make([]string, 0, len(t.byID))
+	for id, c := range t.byID {
+		if c.Name == name {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if len(ids) == 0 {
+		return ""
+	}
+	return ids[0]
+}
+
+// Contains reports whether id is ancestorID or one of its descendants
+func (t *Tree) Contains(ancestorID, id string) bool {
+	if t == nil || id == "" {
+		return id == ancestorID
+	}
+	for cur, depth := id, 0; cur != "" && depth <= len(t.byID); cur, depth = t.Parent(cur), depth+1 {
+		if cur == ancestorID {
+			return true
+		}
+	}
+	return false
+}
+
+// Descendants returns the IDs of id and all categories below it
+func (t *Tree) Descendants(id string) []string {
+	ids := []string{id}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, t.children[ids[i]]...)
+	}
+	return ids
+}
+
+// Rollup arranges per-category amounts into the category tree. Each node's
+// Amount is booked directly on it and Total adds all descendants. Amounts
+// under unknown category IDs, including "", are reported in an
+// "Uncategorized" root. Branches without any amount are left out; siblings
+// are ordered by total, largest first.
+func (t *Tree) Rollup(amounts map[string]float64) []domain.CategoryTotal {
+	if t == nil {
+		t = NewTree(nil)
+	}
+	var build func(id string) (domain.CategoryTotal, bool)
+	build = func(id string) (domain.CategoryTotal, bool) {
+		c := t.byID[id]
+		node := domain.CategoryTotal{
+			CategoryID: c.ID,
+			Name:       c.Name,
+			Icon:       c.Icon,
+			Color:      c.Color,
+			ParentID:   t.Parent(id),
+			Amount:     amounts[id],
+			Total:      amounts[id],
+		}
+		for _, childID := range t.children[id] {
+			if child, ok := build(childID); ok {
+				node.Children = append(node.Children, child)
+				node.Total += child.Total
+			}
+		}
+		sortByTotal(node.Children)
+		return node, node.Total != 0 || len(node.Children) > 0
+	}
+
+	// Unknown IDs join the workspace's own "Uncategorized" category when it has one
+	var unknown float64
+	for id, amount := range amounts {
+		if _, ok := t.byID[id]; !ok {
+			unknown += amount
+		}
+	}
+	if unknown != 0 {
+		if uncatID := t.rootNamed("Uncategorized"); uncatID != "" {
+			merged := make(map[string]float64, len(amounts))
+			for id, amount := range amounts {
+				merged[id] = amount
+			}
+			merged[uncatID] += unknown
+			amounts, unknown = merged, 0
+		}
+	}
+
+	roots := []domain.CategoryTotal{}
+	for _, id := range t.children[""] {
+		if node, ok := build(id); ok {
+			roots = append(roots, node)
+		}
+	}
+	if unknown != 0 {
+		roots = append(roots, domain.CategoryTotal{Name: "Uncategorized", Amount: unknown, Total: unknown})
+	}
+
+	sortByTotal(roots)
+	return roots
+}
+
+func (t *Tree) rootNamed(name string) string {
+	for _, id := range t.children[""] {
+		if t.byID[id].Name == name {
+			return id
+		}
+	}
+	return ""
+}
+
+func sortByTotal(nodes []domain.CategoryTotal) {
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].Total > nodes[j].Total })
+}
+
+// Load returns the category tree of a workspace
+func Load(workspaceID string) (*Tree, error) {
+	if App == nil {
+		return nil, fmt.Errorf("PocketBase app not initialized")
+	}
+
+	records, err := App.FindRecordsByFilter("finance_categories", "workspace = {:workspace}", "name", 0, 0,
+		map[string]any{"workspace": workspaceID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load categories: %w", err)
+	}
+
+	cats := make([]domain.Category, 0, len(records))
+	for _, r := range records {
+		cats = append(cats, domain.Category{
+			ID:       r.Id,
+			Name:     r.GetString("name"),
+			Icon:     r.GetString("icon"),
+			Color:    r.GetString("color"),
+			ParentID: r.GetString("parent"),
+			IsSystem: r.GetBool("is_system"),
+		})
+	}
+	return NewTree(cats), nil
+}
@@ -0,0 +1,76 @@
+package categories
+
+import (
+	"testing"
+
+	"lifehub/backend/internal/domain"
+)
+
+func testTree() *Tree {
+	return NewTree([]domain.Category{
+		{ID: "food", Name: "Food"},
+		{ID: "groceries", Name: "Groceries", ParentID: "food"},
+		{ID: "restaurants", Name: "Restaurants", ParentID: "food"},
+		{ID: "fastfood", Name: "Fast food", ParentID: "restaurants"},
+		{ID: "transport", Name: "Transport"},
+		{ID: "uncat", Name: "Uncategorized"},
+		{ID: "orphan", Name: "Orphan", ParentID: "deleted"},
+		{ID: "loop-a", Name: "Loop A", ParentID: "loop-b"},
+		{ID: "loop-b", Name: "Loop B", ParentID: "loop-a"},
+	})
+}
+
+func TestContains(t *testing.T) {
+	tree := testTree()
+	tests := []struct {
+		ancestor, id string
+		want         bool
+	}{
+		{"food", "food", true},
+		{"food", "groceries", true},
+		{"food", "fastfood", true},
+		{"restaurants", "groceries", false},
+		{"groceries", "food", false},
+		{"transport", "fastfood", false},
+		{"deleted", "orphan", false},
+		{"loop-a", "loop-b", false},
+		{"food", "", false},
+	}
+	for _, tt := range tests {
+		if got := tree.Contains(tt.ancestor, tt.id); got != tt.want {
+			t.Errorf("Contains(%q, %q) = %v, want %v", tt.ancestor, tt.id, got, tt.want)
+		}
+	}
+
+	if got := tree.Descendants("food"); len(got) != 4 {
+		t.Errorf("Descendants(food) = %v, want food and its 3 descendants", got)
+	}
+}
+
+func TestRollup(t *testing.T) {
+	roots := testTree().Rollup(map[string]float64{
+		"groceries": 1200,
+		"fastfood":  300,
+		"food":      50,
+		"transport": 400,
+		"":          80, // no category
+		"gone":      20, // deleted category
+	})
+
+	if len(roots) != 3 {
+		t.Fatalf("got %d roots, want food, transport and uncategorized: %+v", len(roots), roots)
+	}
+	food := roots[0]
+	if food.CategoryID != "food" || food.Amount != 50 || food.Total != 1550 || len(food.Children) != 2 {
+		t.Errorf("food = %+v", food)
+	}
+	if food.Children[0].CategoryID != "groceries" || food.Children[1].Total != 300 || food.Children[1].Amount != 0 {
+		t.Errorf("food children = %+v", food.Children)
+	}
+	if roots[1].CategoryID != "transport" || roots[1].Total != 400 {
+		t.Errorf("second root = %+v", roots[1])
+	}
+	if roots[2].CategoryID != "uncat" || roots[2].Total != 100 {
+		t.Errorf("uncategorized = %+v, want the workspace category with 100", roots[2])
+	}
+}
@@ -6,8 +6,13 @@ import (
 	"sort"
 	"strings"
 
+	"lifehub/backend/internal/filter"
+	"lifehub/backend/internal/match"
+	"lifehub/backend/internal/services/splits"
+
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
 )
 
 // App holds the PocketBase instance
@@ -23,6 +28,7 @@ type Rule struct {
 	CategoryID  string
 	MerchantID  string
 	Priority    int
+	Expression  *match.Expr // further conditions, or the only ones when Pattern is empty
 	compiled    *regexp.Regexp
 }
 
@@ -78,8 +84,7 @@ func (e *Engine) LoadRules(workspaceID string) error {
 	}
 
 	// Load import rules
-	filter := fmt.Sprintf("workspace = '%s' && active = true", workspaceID)
-	records, err := App.FindRecordsByFilter("finance_import_rules", filter, "-priority", 1000, 0)
+	records, err := filter.Eq("workspace", workspaceID).Where("active = true").Find(App, "finance_import_rules", "-priority", 1000, 0)
 	if err != nil {
 		// Collection might not exist yet
 		return nil
@@ -108,6 +113,17 @@ func (e *Engine) LoadRules(workspaceID string) error {
 			rule.compiled, _ = regexp.Compile(rule.Pattern)
 		}
 
+		// Rules with an invalid expression are skipped rather than matching too much
+		raw, _ := r.Get("match_expression").(types.JSONRaw)
+		expr, err := match.Parse(raw)
+		if err != nil {
+			continue
+		}
+		rule.Expression = expr
+		if rule.Pattern == "" && rule.Expression == nil {
+			continue
+		}
+
 		e.rules = append(e.rules, rule)
 	}
 
@@ -125,8 +141,7 @@ func (e *Engine) LoadMerchants(workspaceID string) error {
 		return fmt.Errorf("PocketBase app not initialized")
 	}
 
-	filter := fmt.Sprintf("workspace = '%s'", workspaceID)
-	records, err := App.FindRecordsByFilter("finance_merchants", filter, "", 1000, 0)
+	records, err := filter.Eq("workspace", workspaceID).Find(App, "finance_merchants", "", 1000, 0)
 	if err != nil {
 		return nil
 	}
@@ -170,6 +185,10 @@ type TransactionFields struct {
 	RawDescription     string
 	CounterpartyAccount string
 	BankCategory       string
+	AccountID          string
+	CategoryID         string // the category already set, for rules narrowing it down
+	MerchantID         string
+	Amount             float64
 }
 
 // Categorize attempts to categorize a transaction based on its fields
@@ -211,36 +230,7 @@ func (e *Engine) CategorizeWithFields(fields TransactionFields) *CategorizationR
 
 	// 2. Try import rules (medium-high confidence)
 	for _, rule := range e.rules {
-		// Get the field to match against based on rule's MatchField
-		var fieldValue string
-		switch rule.MatchField {
-		case "counterparty_account":
-			fieldValue = fields.CounterpartyAccount
-		case "raw_description":
-			fieldValue = fields.RawDescription
-		default: // "description" or empty
-			fieldValue = fields.Description
-		}
-
-		if fieldValue == "" {
-			continue
-		}
-
-		upperField := strings.ToUpper(strings.TrimSpace(fieldValue))
-		matched := false
-
-		switch rule.PatternType {
-		case "exact":
-			matched = strings.EqualFold(fieldValue, rule.Pattern)
-		case "regex":
-			if rule.compiled != nil {
-				matched = rule.compiled.MatchString(fieldValue)
-			}
-		default: // "contains"
-			matched = strings.Contains(upperField, strings.ToUpper(rule.Pattern))
-		}
-
-		if matched {
+		if rule.matches(fields) {
 			result.CategoryID = rule.CategoryID
 			result.MerchantID = rule.MerchantID
 			result.Confidence = 0.8
@@ -268,6 +258,51 @@ func (e *Engine) CategorizeWithFields(fields TransactionFields) *CategorizationR
 	return result
 }
 
+// matches reports whether a transaction meets both the pattern and the
+// expression of the rule, whichever of them are set
+func (rule Rule) matches(fields TransactionFields) bool {
+	if !rule.Expression.Match(match.Fields{
+		Description:         fields.Description,
+		RawDescription:      fields.RawDescription,
+		CounterpartyAccount: fields.CounterpartyAccount,
+		AccountID:           fields.AccountID,
+		CategoryID:          fields.CategoryID,
+		MerchantID:          fields.MerchantID,
+		Amount:              fields.Amount,
+	}) {
+		return false
+	}
+	if rule.Pattern == "" {
+		return rule.Expression != nil
+	}
+
+	// Get the field to match against based on rule's MatchField
+	var fieldValue string
+	switch rule.MatchField {
+	case "counterparty_account":
+		fieldValue = fields.CounterpartyAccount
+	case "raw_description":
+		fieldValue = fields.RawDescription
+	default: // "description" or empty
+		fieldValue = fields.Description
+	}
+
+	if fieldValue == "" {
+		return false
+	}
+
+	upperField := strings.ToUpper(strings.TrimSpace(fieldValue))
+
+	switch rule.PatternType {
+	case "exact":
+		return strings.EqualFold(fieldValue, rule.Pattern)
+	case "regex":
+		return rule.compiled != nil && rule.compiled.MatchString(fieldValue)
+	default: // "contains"
+		return strings.Contains(upperField, strings.ToUpper(rule.Pattern))
+	}
+}
+
 // getCategoryName looks up category name by ID
 func (e *Engine) getCategoryName(categoryID string) string {
 	if App == nil {
@@ -307,21 +342,25 @@ func GetSuggestions(workspaceID string, accountID string) ([]Suggestion, error)
 	}
 
 	// Find uncategorized transactions
-	filter := fmt.Sprintf("workspace = '%s' && category_rel = ''", workspaceID)
-	if accountID != "" {
-		filter = fmt.Sprintf("workspace = '%s' && account = '%s' && category_rel = ''", workspaceID, accountID)
-	}
-
-	records, err := App.FindRecordsByFilter("finance_transactions", filter, "-date", 500, 0)
+	records, err := filter.Eq("workspace", workspaceID).
+		EqIf("account", accountID).
+		Where("category_rel = ''").
+		Find(App, "finance_transactions", "-date", 500, 0)
 	if err != nil {
 		return nil, err
 	}
 
+	// Split transactions are categorized per split, not as a whole
+	splitsByTx, _ := splits.LoadForWorkspace(workspaceID)
+
 	// Group by similar patterns
 	patterns := make(map[string][]string) // pattern -> transaction IDs
 	samples := make(map[string]string)    // pattern -> sample description
 
 	for _, r := range records {
+		if len(splitsByTx[r.Id]) > 0 {
+			continue
+		}
 		desc := r.GetString("description")
 		rawDesc := r.GetString("raw_description")
 
@@ -485,16 +524,13 @@ func ApplyRulesToTransactions(workspaceID string, overrideExisting bool) (checke
 	}
 
 	// Build filter based on override setting
-	var filter string
-	if overrideExisting {
-		// Get all transactions in workspace
-		filter = fmt.Sprintf("workspace = '%s'", workspaceID)
-	} else {
+	f := filter.Eq("workspace", workspaceID)
+	if !overrideExisting {
 		// Only get transactions without a category
-		filter = fmt.Sprintf("workspace = '%s' && category_rel = ''", workspaceID)
+		f.Where("category_rel = ''")
 	}
 
-	records, err := App.FindRecordsByFilter("finance_transactions", filter, "-date", 0, 0)
+	records, err := f.Find(App, "finance_transactions", "-date", 0, 0)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -507,6 +543,10 @@ func ApplyRulesToTransactions(workspaceID string, overrideExisting bool) (checke
 			RawDescription:      r.GetString("raw_description"),
 			CounterpartyAccount: r.GetString("counterparty_account"),
 			BankCategory:        r.GetString("category"),
+			AccountID:           r.GetString("account"),
+			CategoryID:          r.GetString("category_rel"),
+			MerchantID:          r.GetString("merchant"),
+			Amount:              r.GetFloat("amount"),
 		}
 
 		// Try categorizing with all fields
@@ -549,8 +589,7 @@ func MapBankCategory(workspaceID, bankCategory string, categoryMapping map[strin
 	}
 
 	// Look up category by name
-	filter := fmt.Sprintf("workspace = '%s' && name = '%s'", workspaceID, mappedName)
-	records, err := App.FindRecordsByFilter("finance_categories", filter, "", 1, 0)
+	records, err := filter.Eq("workspace", workspaceID).Eq("name", mappedName).Find(App, "finance_categories", "", 1, 0)
 	if err != nil || len(records) == 0 {
 		return ""
 	}
@@ -0,0 +1,167 @@
+package csvimport
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"lifehub/backend/internal/domain"
+	"lifehub/backend/internal/filter"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ImportBatch describes the uploaded file an import run originates from
+type ImportBatch struct {
+	FileName     string
+	FileHash     string
+	TemplateCode string
+	TemplateName string
+}
+
+// FileHash returns the fingerprint used to recognise a re-uploaded statement
+func FileHash(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// FindImportByHash returns an earlier import of the same file, or nil. An empty
+// accountID matches imports into any account of the workspace.
+func FindImportByHash(workspaceID, accountID, hash string) (*domain.FinanceImport, error) {
+	if App == nil {
+		return nil, fmt.Errorf("PocketBase app not initialized")
+	}
+
+	records, err := filter.Eq("workspace", workspaceID).
+		Eq("file_hash", hash).
+		EqIf("account", accountID).
+		Find(App, "finance_imports", "-imported_at", 1, 0)
+	if err != nil || len(records) == 0 {
+		return nil, nil
+	}
+	imp := ImportRecordToDomain(records[0])
+	return &imp, nil
+}
+
+// ListImports returns the workspace's import batches, newest first
+func ListImports(workspaceID string) ([]domain.FinanceImport, error) {
+	if App == nil {
+		return nil, fmt.Errorf("PocketBase app not initialized")
+	}
+
+	records, err := App.FindRecordsByFilter("finance_imports", "workspace = {:workspace}", "-imported_at", 0, 0,
+		map[string]any{"workspace": workspaceID})
+	if err != nil {
+		return []domain.FinanceImport{}, nil
+	}
+
+	imports := []domain.FinanceImport{}
+	for _, r := range records {
+		imports = append(imports, ImportRecordToDomain(r))
+	}
+	return imports, nil
+}
+
+// ImportRecordToDomain converts a finance_imports record
+func ImportRecordToDomain(r *core.Record) domain.FinanceImport {
+	return domain.FinanceImport{
+		ID:                   r.Id,
+		WorkspaceID:          r.GetString("workspace"),
+		AccountID:            r.GetString("account"),
+		SourceID:             r.GetString("source"),
+		Name:                 r.GetString("name"),
+		BankName:             r.GetString("bank_name"),
+		Template:             r.GetString("template"),
+		FileHash:             r.GetString("file_hash"),
+		TransactionsImported: r.GetInt("transactions_imported"),
+		TransactionsSkipped:  r.GetInt("transactions_skipped"),
+		DuplicatesFound:      r.GetInt("duplicates_found"),
+		ImportedAt:           r.GetDateTime("imported_at").Time(),
+	}
+}
+
+// RollbackImport deletes an import batch together with every transaction it
+// created, in one DB transaction. Their transfer partners outside the batch
+// are unlinked. Returns the number of deleted transactions.
+func RollbackImport(importID string) (int, error) {
+	if App == nil {
+		return 0, fmt.Errorf("PocketBase app not initialized")
+	}
+
+	deleted := 0
+	err := App.RunInTransaction(func(txApp core.App) error {
+		batch, err := txApp.FindRecordById("finance_imports", importID)
+		if err != nil {
+			return fmt.Errorf("import not found: %w", err)
+		}
+
+		records, err := txApp.FindRecordsByFilter("finance_transactions", "import_ref = {:import}", "", 0, 0,
+			map[string]any{"import": importID})
+		if err != nil {
+			return err
+		}
+		// Transfer partners outside the batch become regular transactions again
+		batchIDs := make(map[string]bool, len(records))
+		for _, r := range records {
+			batchIDs[r.Id] = true
+		}
+		for _, r := range records {
+			partnerID := r.GetString("transfer_pair")
+			if partnerID == "" || batchIDs[partnerID] {
+				continue
+			}
+			partner, err := txApp.FindRecordById("finance_transactions", partnerID)
+			if err != nil {
+				continue
+			}
+			partner.Set("is_transfer", false)
+			partner.Set("transfer_pair", "")
+			if err := txApp.Save(partner); err != nil {
+				return fmt.Errorf("failed to unlink transfer %s: %w", partner.Id, err)
+			}
+		}
+
+		for _, r := range records {
+			if err := txApp.Delete(r); err != nil {
+				return fmt.Errorf("failed to delete transaction %s: %w", r.Id, err)
+			}
+			deleted++
+		}
+
+		return txApp.Delete(batch)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// createImportRecord records the start of an import batch
+func createImportRecord(app core.App, batch ImportBatch, accountID, workspaceID, sourceID string) (*core.Record, error) {
+	collection, err := app.FindCollectionByNameOrId("finance_imports")
+	if err != nil {
+		return nil, fmt.Errorf("finance_imports collection not found: %w", err)
+	}
+
+	name := batch.FileName
+	if name == "" {
+		name = "Import " + time.Now().Format("2006-01-02 15:04")
+	}
+
+	record := core.NewRecord(collection)
+	record.Set("name", name)
+	record.Set("bank_name", batch.TemplateName)
+	record.Set("template", batch.TemplateCode)
+	record.Set("file_hash", batch.FileHash)
+	record.Set("account", accountID)
+	record.Set("workspace", workspaceID)
+	if sourceID != "" {
+		record.Set("source", sourceID)
+	}
+	record.Set("imported_at", time.Now())
+	if err := app.Save(record); err != nil {
+		return nil, fmt.Errorf("failed to record import: %w", err)
+	}
+	return record, nil
+}
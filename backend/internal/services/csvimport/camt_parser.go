@@ -0,0 +1,295 @@
+package csvimport
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// camtDocument covers both camt.053 (BkToCstmrStmt/Stmt) and camt.054
+// (BkToCstmrDbtCdtNtfctn/Ntfctn). Tags are matched by local name so any
+// message version namespace (001.02 .. 001.10) is accepted.
+type camtDocument struct {
+	Statements    []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+	Notifications []camtStatement `xml:"BkToCstmrDbtCdtNtfctn>Ntfctn"`
+	Reports       []camtStatement `xml:"BkToCstmrAcctRpt>Rpt"`
+}
+
+type camtStatement struct {
+	Account struct {
+		IBAN     string `xml:"Id>IBAN"`
+		Currency string `xml:"Ccy"`
+	} `xml:"Acct"`
+	Balances []camtBalance `xml:"Bal"`
+	Entries  []camtEntry   `xml:"Ntry"`
+}
+
+type camtBalance struct {
+	Code        string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount      camtAmount `xml:"Amt"`
+	CreditDebit string     `xml:"CdtDbtInd"`
+}
+
+type camtAmount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+type camtEntry struct {
+	Amount         camtAmount      `xml:"Amt"`
+	CreditDebit    string          `xml:"CdtDbtInd"`
+	Status         camtStatus      `xml:"Sts"`
+	BookingDate    camtDate        `xml:"BookgDt"`
+	ValueDate      camtDate        `xml:"ValDt"`
+	ServicerRef    string          `xml:"AcctSvcrRef"`
+	BankTxCode     string          `xml:"BkTxCd>Prtry>Cd"`
+	AdditionalInfo string          `xml:"AddtlNtryInf"`
+	Details        []camtTxDetails `xml:"NtryDtls>TxDtls"`
+}
+
+// camtStatus holds the entry status: plain text up to camt.05x.001.06, <Cd> afterwards
+type camtStatus struct {
+	Text string `xml:",chardata"`
+	Code string `xml:"Cd"`
+}
+
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+type camtTxDetails struct {
+	EndToEndID  string      `xml:"Refs>EndToEndId"`
+	ServicerRef string      `xml:"Refs>AcctSvcrRef"`
+	Amount      *camtAmount `xml:"Amt"`
+	Parties     struct {
+		DebtorName        string `xml:"Dbtr>Nm"`
+		DebtorPartyName   string `xml:"Dbtr>Pty>Nm"`
+		DebtorIBAN        string `xml:"DbtrAcct>Id>IBAN"`
+		DebtorOther       string `xml:"DbtrAcct>Id>Othr>Id"`
+		CreditorName      string `xml:"Cdtr>Nm"`
+		CreditorPartyName string `xml:"Cdtr>Pty>Nm"`
+		CreditorIBAN      string `xml:"CdtrAcct>Id>IBAN"`
+		CreditorOther     string `xml:"CdtrAcct>Id>Othr>Id"`
+	} `xml:"RltdPties"`
+	Unstructured   []string `xml:"RmtInf>Ustrd"`
+	CreditorRef    string   `xml:"RmtInf>Strd>CdtrRefInf>Ref"`
+	AdditionalInfo string   `xml:"AddtlTxInf"`
+}
+
+// CamtTemplate returns the ISO 20022 camt.053 / camt.054 XML statement template.
+// Column mappings are unused; the template exists so camt files can be selected
+// and detected alongside the CSV templates.
+func CamtTemplate() BankTemplate {
+	return BankTemplate{
+		Code:                    "camt",
+		Name:                    "ISO 20022 camt.053 / camt.054 (XML)",
+		Format:                  FormatCamt,
+		Encoding:                "utf-8",
+		DateFormat:              "2006-01-02",
+		AmountNegativeIsExpense: true,
+		DecimalSeparator:        ".",
+	}
+}
+
+// ParseCamt parses an ISO 20022 camt.053 statement or camt.054 notification.
+// Only booked entries are returned; batch entries with per-transaction amounts
+// are split into one transaction per TxDtls.
+func ParseCamt(data []byte, template BankTemplate) (*PreviewResult, error) {
+	var doc camtDocument
+	if err := xml.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse camt XML: %w", err)
+	}
+
+	statements := append(append(doc.Statements, doc.Notifications...), doc.Reports...)
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("no camt statement or notification found")
+	}
+
+	result := &PreviewResult{
+		Transactions: []ParsedTransaction{},
+		Errors:       []ImportError{},
+	}
+
+	rowNum := 0
+	for _, stmt := range statements {
+		opening, closing := camtBalances(stmt.Balances)
+		if opening != nil && result.OpeningBalance == nil {
+			result.OpeningBalance = opening
+		}
+		if closing != nil {
+			result.ClosingBalance = closing
+		}
+
+		for _, entry := range stmt.Entries {
+			rowNum++
+			result.TotalRows++
+
+			txs, err := parseCamtEntry(entry, stmt.Account.Currency, rowNum)
+			if err != nil {
+				result.Errors = append(result.Errors, ImportError{
+					Row:     rowNum,
+					Message: err.Error(),
+				})
+				continue
+			}
+			result.Transactions = append(result.Transactions, txs...)
+		}
+	}
+
+	return result, nil
+}
+
+// parseCamtEntry converts a single Ntry into one or more transactions
+func parseCamtEntry(entry camtEntry, accountCurrency string, rowNum int) ([]ParsedTransaction, error) {
+	status := firstNonEmpty(entry.Status.Code, entry.Status.Text)
+	if status != "" && status != "BOOK" {
+		return nil, fmt.Errorf("skipped: status is %q, required %q", status, "BOOK")
+	}
+
+	date, err := entry.BookingDate.parse()
+	if err != nil {
+		date, err = entry.ValueDate.parse()
+		if err != nil {
+			return nil, fmt.Errorf("invalid booking date: %w", err)
+		}
+	}
+
+	isExpense := strings.TrimSpace(entry.CreditDebit) == "DBIT"
+
+	// A batch entry is split only when every detail carries its own amount
+	details := entry.Details
+	split := len(details) > 1
+	for _, d := range details {
+		if d.Amount == nil {
+			split = false
+		}
+	}
+	if !split {
+		var first camtTxDetails
+		if len(details) > 0 {
+			first = details[0]
+		}
+		first.Amount = &entry.Amount
+		details = []camtTxDetails{first}
+	}
+
+	var txs []ParsedTransaction
+	for i, d := range details {
+		amount, err := strconv.ParseFloat(strings.TrimSpace(d.Amount.Value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid amount '%s': %w", d.Amount.Value, err)
+		}
+
+		currency := d.Amount.Currency
+		if currency == "" {
+			currency = accountCurrency
+		}
+		if currency == "" {
+			currency = "CZK"
+		}
+
+		// The counterparty is the creditor for outgoing payments and the debtor for incoming ones
+		p := d.Parties
+		name := firstNonEmpty(p.DebtorName, p.DebtorPartyName)
+		account := firstNonEmpty(p.DebtorIBAN, p.DebtorOther)
+		if isExpense {
+			name = firstNonEmpty(p.CreditorName, p.CreditorPartyName)
+			account = firstNonEmpty(p.CreditorIBAN, p.CreditorOther)
+		}
+
+		remittance := strings.TrimSpace(strings.Join(d.Unstructured, " "))
+		if remittance == "" {
+			remittance = strings.TrimSpace(d.CreditorRef)
+		}
+
+		var parts []string
+		for _, part := range []string{name, remittance, d.AdditionalInfo, entry.AdditionalInfo} {
+			if part = strings.TrimSpace(part); part != "" {
+				parts = append(parts, part)
+			}
+		}
+		rawDescription := strings.Join(parts, " | ")
+		description := "Unknown transaction"
+		if len(parts) > 0 {
+			description = parts[0]
+		}
+
+		externalID := strings.TrimSpace(d.EndToEndID)
+		if externalID == "" || externalID == "NOTPROVIDED" {
+			externalID = firstNonEmpty(strings.TrimSpace(d.ServicerRef), strings.TrimSpace(entry.ServicerRef))
+			if externalID != "" && split {
+				externalID = fmt.Sprintf("%s-%d", externalID, i+1)
+			}
+		}
+
+		tx := ParsedTransaction{
+			Date:                date,
+			Description:         description,
+			RawDescription:      rawDescription,
+			Amount:              amount,
+			Currency:            currency,
+			IsExpense:           isExpense,
+			ExternalID:          externalID,
+			BankCategory:        strings.TrimSpace(entry.BankTxCode),
+			MerchantName:        name,
+			CounterpartyAccount: account,
+			RowNumber:           rowNum,
+		}
+		if tx.ExternalID == "" {
+			tx.ExternalID = GenerateTransactionHash(tx.Date, tx.RawDescription, tx.Amount, tx.IsExpense)
+		}
+		txs = append(txs, tx)
+	}
+
+	return txs, nil
+}
+
+// camtBalances extracts the opening (OPBD, falling back to PRCD) and closing (CLBD) booked balances
+func camtBalances(balances []camtBalance) (opening, closing *float64) {
+	for _, b := range balances {
+		value, err := strconv.ParseFloat(strings.TrimSpace(b.Amount.Value), 64)
+		if err != nil {
+			continue
+		}
+		if strings.TrimSpace(b.CreditDebit) == "DBIT" {
+			value = -value
+		}
+		switch strings.TrimSpace(b.Code) {
+		case "OPBD":
+			opening = &value
+		case "PRCD":
+			if opening == nil {
+				opening = &value
+			}
+		case "CLBD":
+			closing = &value
+		}
+	}
+	return opening, closing
+}
+
+func (d camtDate) parse() (time.Time, error) {
+	if s := strings.TrimSpace(d.Date); s != "" {
+		return time.Parse("2006-01-02", s)
+	}
+	if s := strings.TrimSpace(d.DateTime); s != "" {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t, nil
+		}
+		return time.Parse("2006-01-02T15:04:05", s)
+	}
+	return time.Time{}, fmt.Errorf("date missing")
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
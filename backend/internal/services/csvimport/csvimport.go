@@ -5,11 +5,15 @@ import (
 	"encoding/csv"
 	"encoding/hex"
 	"fmt"
-	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"lifehub/backend/internal/domain"
+	"lifehub/backend/internal/filter"
+	"lifehub/backend/internal/services/transfers"
+
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
 )
@@ -30,12 +34,28 @@ type FieldMapping struct {
 	Message            int    `json:"message,omitempty"`
 	Category           int    `json:"category,omitempty"`
 	ExternalID         int    `json:"external_id,omitempty"`
+	// BankCode is appended to CounterpartyAccount as "account/code" for banks
+	// that export the counterparty bank code in its own column (e.g. Fio)
+	BankCode           int    `json:"bank_code,omitempty"`
+	VariableSymbol     int    `json:"variable_symbol,omitempty"`
+	// Comment is a note of the account holder (e.g. Fio "Komentář"), added
+	// to the raw description like the message
+	Comment            int    `json:"comment,omitempty"`
 }
 
+// Statement formats understood by Parse. An empty Format means FormatCSV.
+const (
+	FormatCSV  = "csv"
+	FormatCamt = "camt"
+	FormatOFX  = "ofx"
+	FormatQIF  = "qif"
+)
+
 // BankTemplate defines a bank's CSV export format
 type BankTemplate struct {
 	Code               string            `json:"code"`
 	Name               string            `json:"name"`
+	Format             string            `json:"format,omitempty"`
 	Delimiter          rune              `json:"delimiter"`
 	Encoding           string            `json:"encoding"`
 	SkipRows           int               `json:"skip_rows"`
@@ -48,6 +68,12 @@ type BankTemplate struct {
 	// StateColumn and StateRequired filter rows: only rows where StateColumn == StateRequired are imported
 	StateColumn        int               `json:"state_column,omitempty"`
 	StateRequired      string            `json:"state_required,omitempty"`
+	// HeaderMarker, when set, overrides SkipRows: data starts after the first row whose
+	// first cell equals the marker. Used for exports with a variable-length preamble.
+	HeaderMarker       string            `json:"header_marker,omitempty"`
+	// ExternalIDFirstColumn marks exports whose first column is the bank's movement ID.
+	// Needed because column 0 means "unmapped" for optional FieldMapping entries.
+	ExternalIDFirstColumn bool           `json:"external_id_first_column,omitempty"`
 }
 
 // MerchantExtraction defines how to extract merchant from transaction
@@ -70,16 +96,22 @@ type ParsedTransaction struct {
 	BankCategory       string    `json:"bank_category"`
 	MerchantName       string    `json:"merchant_name"`
 	CounterpartyAccount string   `json:"counterparty_account"`
+	VariableSymbol     string    `json:"variable_symbol,omitempty"`
 	RowNumber          int       `json:"row_number"`
 }
 
 // ImportResult contains the result of a CSV import operation
 type ImportResult struct {
+	ImportID             string        `json:"import_id"`
 	TransactionsTotal    int           `json:"transactions_total"`
 	TransactionsImported int           `json:"transactions_imported"`
 	TransactionsSkipped  int           `json:"transactions_skipped"`
 	DuplicatesFound      int           `json:"duplicates_found"`
 	Errors               []ImportError `json:"errors"`
+	// Filled in by the import handler: transfers a counterparty account
+	// confirmed, and equal-amount pairs left for the user to confirm
+	TransfersLinked    int              `json:"transfers_linked"`
+	TransferCandidates []transfers.Pair `json:"transfer_candidates,omitempty"`
 }
 
 // ImportError represents an error during import
@@ -94,6 +126,17 @@ type PreviewResult struct {
 	TotalRows    int                 `json:"total_rows"`
 	Errors       []ImportError       `json:"errors"`
 	DetectedTemplate string          `json:"detected_template,omitempty"`
+	// PossibleDuplicates lists rows resembling transactions already stored in the account
+	PossibleDuplicates []DuplicateCandidate `json:"possible_duplicates,omitempty"`
+	// PreviousImport is set when the same file was already imported into the workspace
+	PreviousImport *domain.FinanceImport `json:"previous_import,omitempty"`
+	// Encoding is the character encoding the file was decoded with (CSV only)
+	Encoding       string            `json:"encoding,omitempty"`
+	// Statement balances, only reported by formats that carry them (camt.053)
+	OpeningBalance *float64          `json:"opening_balance,omitempty"`
+	ClosingBalance *float64          `json:"closing_balance,omitempty"`
+	// Inference is the proposed template for exports no known template matched
+	Inference      *InferenceResult  `json:"inference,omitempty"`
 }
 
 // CSOBTemplate returns the CSOB bank template
@@ -217,19 +260,90 @@ func RevolutTemplate() BankTemplate {
 	}
 }
 
+// FioTemplate returns the Fio banka template.
+// Covers the CSV export (account info preamble followed by the movement table);
+// the JSON statement format is handled by ParseFioJSON.
+func FioTemplate() BankTemplate {
+	return BankTemplate{
+		Code:      "fio",
+		Name:      "Fio banka",
+		Delimiter: ';',
+		Encoding:  "utf-8",
+		SkipRows:  1,
+		DateFormat: "02.01.2006",
+		FieldMapping: FieldMapping{
+			Date:                1,
+			Amount:              2,
+			Currency:            3,
+			CounterpartyAccount: 4,
+			CounterpartyName:    5,
+			BankCode:            6,
+			VariableSymbol:      9,
+			Message:             11, // Poznámka (user identification)
+			Description:         12, // Zpráva pro příjemce
+			OperationType:       13,
+			Category:            13, // Fio has no bank category; the movement type is mapped instead
+			Comment:             16, // Komentář
+		},
+		CategoryMapping: map[string]string{
+			"Příjem převodem uvnitř banky": "Income",
+			"Bezhotovostní příjem":         "Income",
+			"Vklad pokladnou":              "Income",
+			"Platba kartou":                "Shopping",
+			"Výběr z bankomatu":            "Uncategorized",
+			"Poplatek":                     "Subscriptions",
+		},
+		MerchantExtraction: MerchantExtraction{
+			CardTransactionField:   11, // message field: "Nákup: <merchant>, ..." for card payments
+			CardTransactionPattern: `Nákup:\s*([^,]+)`,
+			TransferField:          5,
+		},
+		AmountNegativeIsExpense: true,
+		DecimalSeparator:        ",",
+		HeaderMarker:            "ID pohybu",
+		ExternalIDFirstColumn:   true,
+	}
+}
+
 // GetTemplates returns all available templates
 func GetTemplates() map[string]BankTemplate {
 	return map[string]BankTemplate{
 		"csob":    CSOBTemplate(),
 		"revolut": RevolutTemplate(),
+		"fio":     FioTemplate(),
+		"camt":    CamtTemplate(),
+		"ofx":     OFXTemplate(),
+		"qif":     QIFTemplate(),
 		"generic": GenericTemplate(),
 	}
 }
 
+// Parse parses statement data using the specified template, dispatching to
+// the format-specific parser for exports that are not delimited text.
+func Parse(data []byte, template BankTemplate) (*PreviewResult, error) {
+	switch template.Format {
+	case FormatCamt:
+		return ParseCamt(data, template)
+	case FormatOFX:
+		return ParseOFX(data, template)
+	case FormatQIF:
+		return ParseQIF(data, template)
+	}
+	if template.Code == "fio" && isJSON(data) {
+		return ParseFioJSON(data, template)
+	}
+	return ParseCSV(data, template)
+}
+
 // ParseCSV parses CSV data using the specified template
 func ParseCSV(data []byte, template BankTemplate) (*PreviewResult, error) {
-	// Replace delimiter if needed and create reader
-	content := string(data)
+	// Convert to UTF-8 first so diacritics survive cp1250/ISO-8859-2 exports
+	decoded, encoding, err := DecodeText(data, template.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	content := string(decoded)
 	reader := csv.NewReader(strings.NewReader(content))
 	reader.Comma = template.Delimiter
 	reader.LazyQuotes = true
@@ -240,17 +354,26 @@ func ParseCSV(data []byte, template BankTemplate) (*PreviewResult, error) {
 		return nil, fmt.Errorf("failed to parse CSV: %w", err)
 	}
 
+	skipRows := template.SkipRows
+	if template.HeaderMarker != "" {
+		skipRows = findHeaderRow(records, template.HeaderMarker) + 1
+	}
+	if skipRows > len(records) {
+		skipRows = len(records)
+	}
+
 	result := &PreviewResult{
 		Transactions: []ParsedTransaction{},
-		TotalRows:    len(records) - template.SkipRows,
+		TotalRows:    len(records) - skipRows,
 		Errors:       []ImportError{},
+		Encoding:     encoding,
 	}
 
 	// Skip header rows
-	dataRows := records[template.SkipRows:]
+	dataRows := records[skipRows:]
 
 	for i, row := range dataRows {
-		rowNum := i + template.SkipRows + 1 // 1-indexed row number
+		rowNum := i + skipRows + 1 // 1-indexed row number
 
 		tx, err := parseRow(row, template, rowNum)
 		if err != nil {
@@ -325,6 +448,8 @@ func parseRow(row []string, template BankTemplate, rowNum int) (*ParsedTransacti
 	var externalID string
 	if fm.ExternalID > 0 && fm.ExternalID < len(row) {
 		externalID = strings.TrimSpace(row[fm.ExternalID])
+	} else if template.ExternalIDFirstColumn {
+		externalID = strings.TrimSpace(row[0])
 	}
 
 	// Get bank category
@@ -338,6 +463,17 @@ func parseRow(row []string, template BankTemplate, rowNum int) (*ParsedTransacti
 	if fm.CounterpartyAccount > 0 && fm.CounterpartyAccount < len(row) {
 		counterpartyAccount = strings.TrimSpace(row[fm.CounterpartyAccount])
 	}
+	if counterpartyAccount != "" && fm.BankCode > 0 && fm.BankCode < len(row) {
+		if code := strings.TrimSpace(row[fm.BankCode]); code != "" {
+			counterpartyAccount += "/" + code
+		}
+	}
+
+	// Get variable symbol
+	var variableSymbol string
+	if fm.VariableSymbol > 0 && fm.VariableSymbol < len(row) {
+		variableSymbol = strings.TrimSpace(row[fm.VariableSymbol])
+	}
 
 	// Build description and extract merchant
 	description, rawDescription, merchantName := buildDescription(row, template)
@@ -354,6 +490,7 @@ func parseRow(row []string, template BankTemplate, rowNum int) (*ParsedTransacti
 		BankCategory:       bankCategory,
 		MerchantName:       merchantName,
 		CounterpartyAccount: counterpartyAccount,
+		VariableSymbol:     variableSymbol,
 		RowNumber:          rowNum,
 	}
 
@@ -388,29 +525,26 @@ func buildDescription(row []string, template BankTemplate) (description, rawDesc
 		}
 	}
 
-	// Message field - may contain merchant for card transactions
+	// Message field
 	if fm.Message > 0 && fm.Message < len(row) {
 		if msg := strings.TrimSpace(row[fm.Message]); msg != "" {
 			parts = append(parts, msg)
+		}
+	}
 
-			// Try to extract merchant from card transaction
-			if me.CardTransactionPattern != "" && me.CardTransactionField == fm.Message {
-				re, err := regexp.Compile(me.CardTransactionPattern)
-				if err == nil {
-					matches := re.FindStringSubmatch(msg)
-					if len(matches) > 1 {
-						merchantName = strings.TrimSpace(matches[1])
-					}
-				}
-			}
+	// Card transaction field - may contain merchant for card transactions
+	if me.CardTransactionField > 0 && me.CardTransactionField < len(row) {
+		if merchant := extractCardMerchant(strings.TrimSpace(row[me.CardTransactionField]), me.CardTransactionPattern); merchant != "" {
+			merchantName = merchant
 		}
 	}
 
-	// Description field (for generic templates)
-	if fm.Description > 0 && fm.Description < len(row) {
-		if desc := strings.TrimSpace(row[fm.Description]); desc != "" {
-			if len(parts) == 0 {
-				parts = append(parts, desc)
+	// Description and comment fields, unless they repeat a part already
+	// there; for generic templates the description is all there is
+	for _, col := range []int{fm.Description, fm.Comment} {
+		if col > 0 && col < len(row) {
+			if v := strings.TrimSpace(row[col]); v != "" && !slices.Contains(parts, v) {
+				parts = append(parts, v)
 			}
 		}
 	}
@@ -446,8 +580,7 @@ func CheckDuplicate(accountID, externalID string) (bool, *core.Record, error) {
 		return false, nil, fmt.Errorf("PocketBase app not initialized")
 	}
 
-	filter := fmt.Sprintf("account = '%s' && external_id = '%s'", accountID, externalID)
-	records, err := App.FindRecordsByFilter("finance_transactions", filter, "", 1, 0)
+	records, err := filter.Eq("account", accountID).Eq("external_id", externalID).Find(App, "finance_transactions", "", 1, 0)
 	if err != nil {
 		// Collection might not exist or other error - assume not duplicate
 		return false, nil, nil
@@ -459,13 +592,18 @@ func CheckDuplicate(accountID, externalID string) (bool, *core.Record, error) {
 	return false, nil, nil
 }
 
-// ImportTransactions imports parsed transactions into the database
+// ImportTransactions imports parsed transactions into the database as one
+// finance_imports batch; every created transaction references the batch.
+// All writes happen in a single DB transaction, so a failure leaves nothing
+// behind. progress, when set, is called after each processed row.
 func ImportTransactions(
 	transactions []ParsedTransaction,
 	accountID string,
 	workspaceID string,
 	sourceID string,
+	batch ImportBatch,
 	categoryResolver func(bankCategory string) string,
+	progress func(processed, total int),
 ) (*ImportResult, error) {
 	if App == nil {
 		return nil, fmt.Errorf("PocketBase app not initialized")
@@ -481,73 +619,118 @@ func ImportTransactions(
 		return nil, fmt.Errorf("finance_transactions collection not found: %w", err)
 	}
 
-	for _, tx := range transactions {
-		// Check for duplicate
-		isDup, existingRecord, _ := CheckDuplicate(accountID, tx.ExternalID)
-		if isDup {
-			result.DuplicatesFound++
+	// One query for the account's known external IDs instead of one per row
+	existing, err := loadExistingTransactions(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = App.RunInTransaction(func(txApp core.App) error {
+		importRecord, err := createImportRecord(txApp, batch, accountID, workspaceID, sourceID)
+		if err != nil {
+			return err
+		}
+		result.ImportID = importRecord.Id
+
+		for i, tx := range transactions {
+			if progress != nil {
+				progress(i, len(transactions))
+			}
+
+			if existingRecord, isDup := existing[tx.ExternalID]; isDup {
+				result.DuplicatesFound++
+				result.TransactionsSkipped++
 
-			// Update existing record with missing fields (like counterparty_account)
-			updated := false
-			if existingRecord != nil {
-				if tx.CounterpartyAccount != "" && existingRecord.GetString("counterparty_account") == "" {
+				// Update existing record with missing fields (like counterparty_account)
+				if existingRecord != nil && tx.CounterpartyAccount != "" && existingRecord.GetString("counterparty_account") == "" {
 					existingRecord.Set("counterparty_account", tx.CounterpartyAccount)
-					updated = true
-				}
-				if updated {
-					App.Save(existingRecord)
+					if err := txApp.Save(existingRecord); err != nil {
+						return fmt.Errorf("row %d: failed to update existing transaction: %w", tx.RowNumber, err)
+					}
 				}
+				continue
 			}
 
-			result.TransactionsSkipped++
-			continue
-		}
+			// Create record
+			record := core.NewRecord(collection)
+			record.Set("description", tx.Description)
+			record.Set("raw_description", tx.RawDescription)
+			record.Set("amount", tx.Amount)
+			record.Set("type", map[bool]string{true: "expense", false: "income"}[tx.IsExpense])
+			record.Set("date", tx.Date)
+			record.Set("account", accountID)
+			record.Set("workspace", workspaceID)
+			if sourceID != "" {
+				record.Set("source", sourceID)
+			}
+			record.Set("external_id", tx.ExternalID)
+			record.Set("import_ref", importRecord.Id)
+			record.Set("balance_after", tx.BalanceAfter)
+			if tx.CounterpartyAccount != "" {
+				record.Set("counterparty_account", tx.CounterpartyAccount)
+			}
+			if tx.VariableSymbol != "" {
+				record.Set("variable_symbol", tx.VariableSymbol)
+			}
 
-		// Create record
-		record := core.NewRecord(collection)
-		record.Set("description", tx.Description)
-		record.Set("raw_description", tx.RawDescription)
-		record.Set("amount", tx.Amount)
-		record.Set("type", map[bool]string{true: "expense", false: "income"}[tx.IsExpense])
-		record.Set("date", tx.Date)
-		record.Set("account", accountID)
-		record.Set("workspace", workspaceID)
-		if sourceID != "" {
-			record.Set("source", sourceID)
-		}
-		record.Set("external_id", tx.ExternalID)
-		record.Set("balance_after", tx.BalanceAfter)
-		if tx.CounterpartyAccount != "" {
-			record.Set("counterparty_account", tx.CounterpartyAccount)
-		}
+			// Map bank category if resolver provided
+			if categoryResolver != nil && tx.BankCategory != "" {
+				if catID := categoryResolver(tx.BankCategory); catID != "" {
+					record.Set("category_rel", catID)
+				}
+			}
 
-		// Map bank category if resolver provided
-		if categoryResolver != nil && tx.BankCategory != "" {
-			if catID := categoryResolver(tx.BankCategory); catID != "" {
-				record.Set("category_rel", catID)
+			// Store bank category as text fallback
+			if tx.BankCategory != "" {
+				record.Set("category", tx.BankCategory)
 			}
-		}
 
-		// Store bank category as text fallback
-		if tx.BankCategory != "" {
-			record.Set("category", tx.BankCategory)
-		}
+			if err := txApp.Save(record); err != nil {
+				return fmt.Errorf("row %d: %w", tx.RowNumber, err)
+			}
 
-		if err := App.Save(record); err != nil {
-			result.Errors = append(result.Errors, ImportError{
-				Row:     tx.RowNumber,
-				Message: err.Error(),
-			})
-			result.TransactionsSkipped++
-			continue
+			// Repeated rows within the same file are duplicates too
+			existing[tx.ExternalID] = record
+			result.TransactionsImported++
 		}
 
-		result.TransactionsImported++
+		importRecord.Set("transactions_imported", result.TransactionsImported)
+		importRecord.Set("transactions_skipped", result.TransactionsSkipped)
+		importRecord.Set("duplicates_found", result.DuplicatesFound)
+		if err := txApp.Save(importRecord); err != nil {
+			return fmt.Errorf("failed to update import %s: %w", importRecord.Id, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("import rolled back: %w", err)
 	}
 
+	if progress != nil {
+		progress(len(transactions), len(transactions))
+	}
 	return result, nil
 }
 
+// loadExistingTransactions returns the account's transactions keyed by external ID
+func loadExistingTransactions(accountID string) (map[string]*core.Record, error) {
+	records, err := App.FindRecordsByFilter("finance_transactions", "account = {:account} && external_id != ''", "", 0, 0,
+		map[string]any{"account": accountID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load existing transactions: %w", err)
+	}
+
+	existing := make(map[string]*core.Record, len(records))
+	for _, r := range records {
+		existing[r.GetString("external_id")] = r
+		// IDs of duplicates merged into this record (see MergeTransactions)
+		for _, id := range r.GetStringSlice("merged_external_ids") {
+			existing[id] = r
+		}
+	}
+	return existing, nil
+}
+
 // Helper functions
 
 func parseDate(dateStr, format string) (time.Time, error) {
@@ -604,7 +787,8 @@ func abs(x float64) float64 {
 func maxColumn(fm FieldMapping) int {
 	max := fm.Date
 	cols := []int{fm.Description, fm.Amount, fm.Currency, fm.BalanceAfter,
-		fm.CounterpartyName, fm.OperationType, fm.Message, fm.Category, fm.ExternalID}
+		fm.CounterpartyName, fm.OperationType, fm.Message, fm.Category, fm.ExternalID,
+		fm.BankCode, fm.VariableSymbol, fm.Comment}
 	for _, c := range cols {
 		if c > max {
 			max = c
@@ -613,10 +797,50 @@ func maxColumn(fm FieldMapping) int {
 	return max
 }
 
+// findHeaderRow returns the index of the first row whose first cell equals marker, or -1
+func findHeaderRow(records [][]string, marker string) int {
+	for i, row := range records {
+		if len(row) > 0 && strings.TrimSpace(strings.TrimPrefix(row[0], "\ufeff")) == marker {
+			return i
+		}
+	}
+	return -1
+}
+
+// isJSON reports whether data looks like a JSON document
+func isJSON(data []byte) bool {
+	trimmed := strings.TrimSpace(strings.TrimPrefix(string(data), "\ufeff"))
+	return strings.HasPrefix(trimmed, "{")
+}
+
 // DetectTemplate attempts to detect the bank template from CSV content
 func DetectTemplate(data []byte) string {
+	if decoded, _, err := DecodeText(data, ""); err == nil {
+		data = decoded
+	}
 	content := string(data)
 
+	// Check for ISO 20022 camt.053 / camt.054 XML
+	if strings.Contains(content, "urn:iso:std:iso:20022:tech:xsd:camt.05") {
+		return "camt"
+	}
+
+	// Check for OFX/QFX (SGML header or XML processing instruction) and QIF
+	if strings.Contains(content, "OFXHEADER") || strings.Contains(strings.ToUpper(content), "<OFX>") {
+		return "ofx"
+	}
+	if strings.HasPrefix(strings.TrimSpace(strings.TrimPrefix(content, "\ufeff")), "!Type:") {
+		return "qif"
+	}
+
+	// Check for Fio markers (JSON statement or CSV export).
+	// Checked before CSOB since Fio statements can mention /0300 counterparties.
+	if strings.Contains(content, "\"accountStatement\"") ||
+	   strings.Contains(content, "\"ID pohybu\";\"Datum\"") ||
+	   strings.Contains(content, "ID pohybu;Datum;Objem") {
+		return "fio"
+	}
+
 	// Check for CSOB markers
 	if strings.Contains(content, "Pohyby na účtu") ||
 	   strings.Contains(content, "číslo účtu;datum zaúčtování") ||
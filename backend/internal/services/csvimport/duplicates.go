@@ -0,0 +1,362 @@
+package csvimport
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// DefaultDuplicateDays is the date window (± days) for fuzzy duplicate matching.
+// Booking and value dates of the same payment differ by a few days across exports.
+const DefaultDuplicateDays = 3
+
+// minDescriptionSimilarity is the text similarity from which two equal amounts count as one payment
+const minDescriptionSimilarity = 0.5
+
+// StoredTransaction is the subset of a finance_transactions record used for duplicate matching
+type StoredTransaction struct {
+	ID                  string    `json:"id"`
+	Date                time.Time `json:"date"`
+	Description         string    `json:"description"`
+	RawDescription      string    `json:"raw_description"`
+	Amount              float64   `json:"amount"`
+	IsExpense           bool      `json:"is_expense"`
+	CounterpartyAccount string    `json:"counterparty_account,omitempty"`
+	ExternalID          string    `json:"external_id"`
+}
+
+// DuplicateCandidate links a transaction to a stored one that likely records the same payment.
+// RowNumber is set for incoming preview rows, TransactionID for stored transactions.
+type DuplicateCandidate struct {
+	RowNumber     int     `json:"row_number,omitempty"`
+	TransactionID string  `json:"transaction_id,omitempty"`
+	DuplicateOfID string  `json:"duplicate_of_id"`
+	Score         float64 `json:"score"` // 0..1
+	Reason        string  `json:"reason"`
+}
+
+// mergeFields are copied from the removed record when the kept one lacks them.
+// The import is not, so rolling it back doesn't delete the kept record.
+var mergeFields = []string{
+	"raw_description", "counterparty_account", "variable_symbol", "category_rel",
+	"merchant", "category", "source",
+}
+
+// duplicateStopwords carry no information about the counterparty
+var duplicateStopwords = map[string]bool{
+	"platba": true, "kartou": true, "nakup": true, "prevod": true, "uhrada": true, "dne": true,
+	"castka": true, "card": true, "payment": true, "transfer": true, "czk": true, "eur": true, "usd": true,
+}
+
+// FindFuzzyDuplicates flags incoming rows resembling a stored transaction of the
+// same account: equal amount and direction, dates within ±days and a similar
+// description or counterparty. Rows with an exact external ID match are left
+// to the regular duplicate check.
+func FindFuzzyDuplicates(incoming []ParsedTransaction, existing []StoredTransaction, days int) []DuplicateCandidate {
+	candidates := []DuplicateCandidate{}
+	for _, tx := range incoming {
+		in := StoredTransaction{
+			Date:                tx.Date,
+			Description:         tx.Description,
+			RawDescription:      tx.RawDescription,
+			Amount:              tx.Amount,
+			IsExpense:           tx.IsExpense,
+			CounterpartyAccount: tx.CounterpartyAccount,
+			ExternalID:          tx.ExternalID,
+		}
+
+		var best *DuplicateCandidate
+		exact := false
+		for _, stored := range existing {
+			if stored.ExternalID != "" && stored.ExternalID == in.ExternalID {
+				exact = true
+				break
+			}
+			score, reason, ok := matchDuplicate(in, stored, days)
+			if ok && (best == nil || score > best.Score) {
+				best = &DuplicateCandidate{RowNumber: tx.RowNumber, DuplicateOfID: stored.ID, Score: score, Reason: reason}
+			}
+		}
+		if best != nil && !exact {
+			candidates = append(candidates, *best)
+		}
+	}
+	return candidates
+}
+
+// FindStoredDuplicates pairs already stored transactions of one account that
+// look like the same payment imported twice. The later-listed transaction of a
+// pair is reported as the duplicate of the earlier one.
+func FindStoredDuplicates(transactions []StoredTransaction, days int) []DuplicateCandidate {
+	sorted := append([]StoredTransaction(nil), transactions...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	candidates := []DuplicateCandidate{}
+	window := time.Duration(days) * 24 * time.Hour
+	for i, a := range sorted {
+		for _, b := range sorted[i+1:] {
+			if b.Date.Sub(a.Date) > window {
+				break
+			}
+			reason, score := "", 0.0
+			if a.ExternalID != "" && a.ExternalID == b.ExternalID {
+				reason, score = "same external ID", 1
+			} else if s, r, ok := matchDuplicate(a, b, days); ok {
+				reason, score = r, s
+			} else {
+				continue
+			}
+			candidates = append(candidates, DuplicateCandidate{
+				TransactionID: b.ID,
+				DuplicateOfID: a.ID,
+				Score:         score,
+				Reason:        reason,
+			})
+		}
+	}
+	return candidates
+}
+
+// matchDuplicate scores two transactions as the same payment
+func matchDuplicate(a, b StoredTransaction, days int) (float64, string, bool) {
+	if a.IsExpense != b.IsExpense || math.Abs(a.Amount-b.Amount) >= 0.005 {
+		return 0, "", false
+	}
+	dayDiff := math.Abs(a.Date.Sub(b.Date).Hours()) / 24
+	if dayDiff > float64(days) {
+		return 0, "", false
+	}
+
+	similarity, reason := 0.0, ""
+	if accountA, accountB := normalizeAccount(a.CounterpartyAccount), normalizeAccount(b.CounterpartyAccount); accountA != "" && accountA == accountB {
+		similarity, reason = 1, "same counterparty account"
+	} else {
+		for _, pair := range [][2]string{
+			{a.Description, b.Description},
+			{a.RawDescription, b.RawDescription},
+			{a.Description, b.RawDescription},
+			{a.RawDescription, b.Description},
+		} {
+			if s := descriptionSimilarity(pair[0], pair[1]); s > similarity {
+				similarity, reason = s, "similar description"
+			}
+		}
+	}
+	if similarity < minDescriptionSimilarity {
+		return 0, "", false
+	}
+
+	closeness := 1 - dayDiff/float64(days+1)
+	score := math.Round((0.7*similarity+0.3*closeness)*100) / 100
+	return score, reason, true
+}
+
+// descriptionSimilarity is the overlap coefficient of the informative words of
+// two descriptions, so "ALBERT" matches "Nákup: ALBERT 0123, Praha"
+func descriptionSimilarity(a, b string) float64 {
+	ta, tb := descriptionTokens(a), descriptionTokens(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	return float64(shared) / math.Min(float64(len(ta)), float64(len(tb)))
+}
+
+// descriptionTokens returns lowercase words without diacritics, numbers and stopwords
+func descriptionTokens(s string) map[string]bool {
+	s = diacritics.Replace(strings.ToLower(s))
+	tokens := map[string]bool{}
+	for _, word := range strings.FieldsFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		if len(word) < 2 || duplicateStopwords[word] || strings.IndexFunc(word, unicode.IsLetter) < 0 {
+			continue
+		}
+		tokens[word] = true
+	}
+	return tokens
+}
+
+// normalizeAccount strips formatting and leading zeros from a counterparty account
+func normalizeAccount(account string) string {
+	account = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(account), " ", ""))
+	return strings.TrimLeft(account, "0")
+}
+
+// LoadStoredTransactions returns the account's transactions dated within [from, to]
+func LoadStoredTransactions(accountID string, from, to time.Time) ([]StoredTransaction, error) {
+	if App == nil {
+		return nil, fmt.Errorf("PocketBase app not initialized")
+	}
+
+	records, err := App.FindRecordsByFilter("finance_transactions",
+		"account = {:account} && date >= {:from} && date <= {:to}", "date", 0, 0,
+		map[string]any{
+			"account": accountID,
+			"from":    from.UTC().Format("2006-01-02 15:04:05"),
+			"to":      to.UTC().Format("2006-01-02 15:04:05"),
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load transactions: %w", err)
+	}
+
+	transactions := make([]StoredTransaction, 0, len(records))
+	for _, r := range records {
+		transactions = append(transactions, StoredTransaction{
+			ID:                  r.Id,
+			Date:                r.GetDateTime("date").Time(),
+			Description:         r.GetString("description"),
+			RawDescription:      r.GetString("raw_description"),
+			Amount:              math.Abs(r.GetFloat("amount")),
+			IsExpense:           r.GetString("type") == "expense",
+			CounterpartyAccount: r.GetString("counterparty_account"),
+			ExternalID:          r.GetString("external_id"),
+		})
+	}
+	return transactions, nil
+}
+
+// FindPreviewDuplicates flags preview rows that fuzzily match the account's stored transactions
+func FindPreviewDuplicates(accountID string, incoming []ParsedTransaction) ([]DuplicateCandidate, error) {
+	if len(incoming) == 0 {
+		return []DuplicateCandidate{}, nil
+	}
+
+	from, to := incoming[0].Date, incoming[0].Date
+	for _, tx := range incoming {
+		if tx.Date.Before(from) {
+			from = tx.Date
+		}
+		if tx.Date.After(to) {
+			to = tx.Date
+		}
+	}
+	window := DefaultDuplicateDays * 24 * time.Hour
+	existing, err := LoadStoredTransactions(accountID, from.Add(-window), to.Add(window))
+	if err != nil {
+		return nil, err
+	}
+	return FindFuzzyDuplicates(incoming, existing, DefaultDuplicateDays), nil
+}
+
+// MergeTransactions folds two duplicate transactions into one. Both must be
+// of the same account, direction and amount, dated within
+// DefaultDuplicateDays of each other. The record with more populated fields
+// is kept, gaps in it are filled from the other one, and the other record is
+// deleted; its external ID is remembered on the kept record so re-importing
+// its statement does not recreate it. A record merged from two imports
+// belongs to neither, so rolling back one of them doesn't delete it. A
+// transfer link of the deleted record moves to the kept one, or is undone
+// when that one is linked already. Returns the kept record ID.
+func MergeTransactions(firstID, secondID string) (string, error) {
+	if App == nil {
+		return "", fmt.Errorf("PocketBase app not initialized")
+	}
+	if firstID == secondID {
+		return "", fmt.Errorf("cannot merge a transaction with itself")
+	}
+
+	var keptID string
+	err := App.RunInTransaction(func(txApp core.App) error {
+		first, err := txApp.FindRecordById("finance_transactions", firstID)
+		if err != nil {
+			return fmt.Errorf("transaction %s not found", firstID)
+		}
+		second, err := txApp.FindRecordById("finance_transactions", secondID)
+		if err != nil {
+			return fmt.Errorf("transaction %s not found", secondID)
+		}
+		if first.GetString("workspace") != second.GetString("workspace") {
+			return fmt.Errorf("transactions belong to different workspaces")
+		}
+		if first.GetString("account") != second.GetString("account") {
+			return fmt.Errorf("transactions belong to different accounts")
+		}
+		if first.GetString("type") != second.GetString("type") ||
+			math.Abs(math.Abs(first.GetFloat("amount"))-math.Abs(second.GetFloat("amount"))) >= 0.005 {
+			return fmt.Errorf("transactions differ in amount or direction")
+		}
+		if days := math.Abs(first.GetDateTime("date").Time().Sub(second.GetDateTime("date").Time()).Hours()) / 24; days > DefaultDuplicateDays {
+			return fmt.Errorf("transactions are more than %d days apart", DefaultDuplicateDays)
+		}
+
+		keep, remove := first, second
+		if recordRichness(second) > recordRichness(first) {
+			keep, remove = second, first
+		}
+
+		for _, field := range mergeFields {
+			if keep.GetString(field) == "" && remove.GetString(field) != "" {
+				keep.Set(field, remove.Get(field))
+			}
+		}
+		if keep.GetFloat("balance_after") == 0 && remove.GetFloat("balance_after") != 0 {
+			keep.Set("balance_after", remove.GetFloat("balance_after"))
+		}
+
+		merged := append(keep.GetStringSlice("merged_external_ids"), remove.GetStringSlice("merged_external_ids")...)
+		if id := remove.GetString("external_id"); id != "" && id != keep.GetString("external_id") {
+			merged = append(merged, id)
+		}
+		keep.Set("merged_external_ids", merged)
+
+		if keep.GetString("import_ref") != remove.GetString("import_ref") {
+			keep.Set("import_ref", "")
+		}
+
+		if partnerID := remove.GetString("transfer_pair"); partnerID != "" && partnerID != keep.Id {
+			partner, err := txApp.FindRecordById("finance_transactions", partnerID)
+			if err == nil {
+				if keep.GetString("transfer_pair") == "" {
+					keep.Set("is_transfer", true)
+					keep.Set("transfer_pair", partner.Id)
+					partner.Set("transfer_pair", keep.Id)
+				} else {
+					partner.Set("is_transfer", false)
+					partner.Set("transfer_pair", "")
+				}
+				if err := txApp.Save(partner); err != nil {
+					return fmt.Errorf("failed to update transfer partner: %w", err)
+				}
+			}
+		}
+
+		if err := txApp.Save(keep); err != nil {
+			return fmt.Errorf("failed to update transaction: %w", err)
+		}
+		if err := txApp.Delete(remove); err != nil {
+			return fmt.Errorf("failed to delete duplicate: %w", err)
+		}
+		keptID = keep.Id
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return keptID, nil
+}
+
+// recordRichness counts the populated optional fields of a transaction record
+func recordRichness(r *core.Record) int {
+	n := 0
+	for _, field := range mergeFields {
+		if r.GetString(field) != "" {
+			n++
+		}
+	}
+	if r.GetFloat("balance_after") != 0 {
+		n++
+	}
+	if len(r.GetStringSlice("tags")) > 0 {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,109 @@
+package csvimport
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Character encodings understood by ParseCSV. An empty Encoding means auto-detect.
+const (
+	EncodingUTF8        = "utf-8"
+	EncodingUTF16       = "utf-16"
+	EncodingWindows1250 = "windows-1250"
+	EncodingISO88592    = "iso-8859-2"
+)
+
+// encodingAliases maps the spellings found in bank docs and stored templates
+// to the canonical encoding names
+var encodingAliases = map[string]string{
+	"utf-8": EncodingUTF8, "utf8": EncodingUTF8,
+	"utf-16": EncodingUTF16, "utf16": EncodingUTF16, "utf-16le": EncodingUTF16, "utf-16be": EncodingUTF16,
+	"windows-1250": EncodingWindows1250, "cp1250": EncodingWindows1250, "win1250": EncodingWindows1250, "cp-1250": EncodingWindows1250,
+	"iso-8859-2": EncodingISO88592, "iso8859-2": EncodingISO88592, "latin2": EncodingISO88592, "latin-2": EncodingISO88592,
+}
+
+// NormalizeEncoding returns the canonical name of a supported encoding, ""
+// for auto-detection and an error for anything else
+func NormalizeEncoding(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || name == "auto" {
+		return "", nil
+	}
+	if canonical, ok := encodingAliases[name]; ok {
+		return canonical, nil
+	}
+	return "", fmt.Errorf("unsupported encoding %q", name)
+}
+
+// DetectEncoding guesses the encoding of a bank export. A BOM is authoritative;
+// otherwise valid UTF-8 wins and anything else is a Central European single-byte
+// code page, told apart by the bytes where cp1250 and ISO-8859-2 place Š/Ť/Ž/š/ť/ž.
+func DetectEncoding(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return EncodingUTF8
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}), bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return EncodingUTF16
+	case utf8.Valid(data):
+		return EncodingUTF8
+	}
+
+	cp1250, iso := 0, 0
+	for _, b := range data {
+		switch {
+		// C1 control range: unused by ISO-8859-2, letters in cp1250
+		case b >= 0x80 && b <= 0x9F:
+			cp1250++
+		case b == 0xA9 || b == 0xAB || b == 0xAE || b == 0xB9 || b == 0xBB || b == 0xBE:
+			iso++
+		}
+	}
+	if iso > 0 && cp1250 == 0 {
+		return EncodingISO88592
+	}
+	return EncodingWindows1250
+}
+
+// DecodeText converts data to UTF-8 and strips any BOM. An empty encoding is
+// auto-detected; a declared UTF-8 that does not validate is detected as well,
+// since "utf-8" is the stored default even for banks exporting cp1250.
+// Returns the encoding actually used.
+func DecodeText(data []byte, enc string) ([]byte, string, error) {
+	enc, err := NormalizeEncoding(enc)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// A BOM overrides the declared encoding
+	if bytes.HasPrefix(data, []byte{0xFF, 0xFE}) || bytes.HasPrefix(data, []byte{0xFE, 0xFF}) {
+		enc = EncodingUTF16
+	}
+	if enc == "" || (enc == EncodingUTF8 && !utf8.Valid(data)) {
+		enc = DetectEncoding(data)
+	}
+
+	var decoder *encoding.Decoder
+	switch enc {
+	case EncodingUTF8:
+		return bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF}), enc, nil
+	case EncodingUTF16:
+		// Little-endian unless the BOM says otherwise, as exported by Excel
+		decoder = unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewDecoder()
+	case EncodingWindows1250:
+		decoder = charmap.Windows1250.NewDecoder()
+	case EncodingISO88592:
+		decoder = charmap.ISO8859_2.NewDecoder()
+	}
+
+	decoded, err := decoder.Bytes(data)
+	if err != nil {
+		return nil, enc, fmt.Errorf("failed to decode %s: %w", enc, err)
+	}
+	return bytes.TrimPrefix(decoded, []byte{0xEF, 0xBB, 0xBF}), enc, nil
+}
@@ -0,0 +1,200 @@
+package csvimport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Fio JSON statement column IDs (see Fio API documentation)
+const (
+	fioColDate             = "column0"
+	fioColAmount           = "column1"
+	fioColAccount          = "column2"
+	fioColBankCode         = "column3"
+	fioColVariableSymbol   = "column5"
+	fioColUserID           = "column7"
+	fioColType             = "column8"
+	fioColCounterpartyName = "column10"
+	fioColCurrency         = "column14"
+	fioColMessage          = "column16"
+	fioColMovementID       = "column22"
+	fioColComment          = "column25"
+)
+
+// fioColumn is a single "columnN" entry of a Fio transaction
+type fioColumn struct {
+	Value any    `json:"value"`
+	Name  string `json:"name"`
+	ID    int    `json:"id"`
+}
+
+// fioStatement mirrors the Fio API JSON statement ("accountStatement")
+type fioStatement struct {
+	AccountStatement struct {
+		Info struct {
+			AccountID      string  `json:"accountId"`
+			BankID         string  `json:"bankId"`
+			Currency       string  `json:"currency"`
+			IBAN           string  `json:"iban"`
+			OpeningBalance float64 `json:"openingBalance"`
+			ClosingBalance float64 `json:"closingBalance"`
+		} `json:"info"`
+		TransactionList struct {
+			Transaction []map[string]*fioColumn `json:"transaction"`
+		} `json:"transactionList"`
+	} `json:"accountStatement"`
+}
+
+// ParseFioJSON parses a Fio banka JSON statement (accountStatement/transactionList)
+func ParseFioJSON(data []byte, template BankTemplate) (*PreviewResult, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber() // movement IDs exceed float64 precision in string form
+
+	var statement fioStatement
+	if err := decoder.Decode(&statement); err != nil {
+		return nil, fmt.Errorf("failed to parse Fio JSON: %w", err)
+	}
+
+	rows := statement.AccountStatement.TransactionList.Transaction
+	result := &PreviewResult{
+		Transactions: []ParsedTransaction{},
+		TotalRows:    len(rows),
+		Errors:       []ImportError{},
+	}
+
+	for i, row := range rows {
+		rowNum := i + 1
+
+		tx, err := parseFioTransaction(row, template, statement.AccountStatement.Info.Currency, rowNum)
+		if err != nil {
+			result.Errors = append(result.Errors, ImportError{
+				Row:     rowNum,
+				Message: err.Error(),
+			})
+			continue
+		}
+
+		result.Transactions = append(result.Transactions, *tx)
+	}
+
+	return result, nil
+}
+
+// parseFioTransaction converts a single Fio JSON transaction into a ParsedTransaction
+func parseFioTransaction(row map[string]*fioColumn, template BankTemplate, defaultCurrency string, rowNum int) (*ParsedTransaction, error) {
+	dateStr := fioValue(row, fioColDate)
+	date, err := time.Parse("2006-01-02-0700", dateStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date '%s': %w", dateStr, err)
+	}
+
+	amountStr := fioValue(row, fioColAmount)
+	amount, err := parseAmount(amountStr, ".")
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount '%s': %w", amountStr, err)
+	}
+
+	isExpense := amount < 0
+	amount = abs(amount)
+
+	currency := fioValue(row, fioColCurrency)
+	if currency == "" {
+		currency = defaultCurrency
+	}
+	if currency == "" {
+		currency = "CZK"
+	}
+
+	counterpartyAccount := fioValue(row, fioColAccount)
+	if code := fioValue(row, fioColBankCode); counterpartyAccount != "" && code != "" {
+		counterpartyAccount += "/" + code
+	}
+
+	// Build description the same way buildDescription does for CSV rows
+	var parts []string
+	counterpartyName := fioValue(row, fioColCounterpartyName)
+	merchantName := counterpartyName
+	if counterpartyName != "" {
+		parts = append(parts, counterpartyName)
+	}
+	opType := fioValue(row, fioColType)
+	if opType != "" {
+		parts = append(parts, opType)
+	}
+	if userID := fioValue(row, fioColUserID); userID != "" {
+		parts = append(parts, userID)
+		if merchant := extractCardMerchant(userID, template.MerchantExtraction.CardTransactionPattern); merchant != "" {
+			merchantName = merchant
+		}
+	}
+	for _, col := range []string{fioColMessage, fioColComment} {
+		if v := fioValue(row, col); v != "" && !slices.Contains(parts, v) {
+			parts = append(parts, v)
+		}
+	}
+
+	rawDescription := strings.Join(parts, " | ")
+	description := "Unknown transaction"
+	if merchantName != "" {
+		description = merchantName
+	} else if len(parts) > 0 {
+		description = parts[0]
+	}
+
+	tx := &ParsedTransaction{
+		Date:                date,
+		Description:         description,
+		RawDescription:      rawDescription,
+		Amount:              amount,
+		Currency:            currency,
+		IsExpense:           isExpense,
+		ExternalID:          fioValue(row, fioColMovementID),
+		BankCategory:        opType,
+		MerchantName:        merchantName,
+		CounterpartyAccount: counterpartyAccount,
+		VariableSymbol:      fioValue(row, fioColVariableSymbol),
+		RowNumber:           rowNum,
+	}
+
+	if tx.ExternalID == "" {
+		tx.ExternalID = GenerateTransactionHash(tx.Date, tx.RawDescription, tx.Amount, tx.IsExpense)
+	}
+
+	return tx, nil
+}
+
+// fioValue returns the string form of a Fio column value, or "" if the column is null
+func fioValue(row map[string]*fioColumn, column string) string {
+	col, ok := row[column]
+	if !ok || col == nil || col.Value == nil {
+		return ""
+	}
+	switch v := col.Value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	default:
+		return strings.TrimSpace(fmt.Sprint(v))
+	}
+}
+
+// extractCardMerchant applies a card transaction pattern and returns the first capture group
+func extractCardMerchant(text, pattern string) string {
+	if pattern == "" {
+		return ""
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return ""
+	}
+	if matches := re.FindStringSubmatch(text); len(matches) > 1 {
+		return strings.TrimSpace(matches[1])
+	}
+	return ""
+}
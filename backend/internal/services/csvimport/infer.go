@@ -0,0 +1,513 @@
+package csvimport
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"lifehub/backend/internal/domain"
+)
+
+// inferSampleRows is the number of lines inspected when inferring a template
+const inferSampleRows = 50
+
+// MinInferenceConfidence is the overall confidence from which an inferred
+// template is used to parse the preview instead of the generic one
+const MinInferenceConfidence = 0.5
+
+// InferredField describes the column proposed for a transaction field
+type InferredField struct {
+	Column     int     `json:"column"`
+	Header     string  `json:"header,omitempty"`
+	Confidence float64 `json:"confidence"` // 0..1
+}
+
+// InferenceResult is a proposed template for an unknown CSV layout.
+// Template can be saved as-is through the custom template endpoints.
+type InferenceResult struct {
+	Template   domain.BankTemplate      `json:"template"`
+	HasHeader  bool                     `json:"has_header"`
+	Headers    []string                 `json:"headers,omitempty"`
+	Fields     map[string]InferredField `json:"fields"`
+	Confidence float64                  `json:"confidence"` // overall, the weaker of date and amount
+}
+
+// columnProfile holds per-column statistics gathered from the sample rows
+type columnProfile struct {
+	index      int
+	header     string
+	values     []string // non-empty values
+	dateLayout string
+	dateScore  float64
+	numScore   float64
+	negatives  int
+	decimals   int
+	longIDs    int
+	currency   float64
+	account    float64
+	avgLen     float64
+	unique     float64
+}
+
+var (
+	inferDelimiters = []rune{';', ',', '\t', '|'}
+
+	numberRe   = regexp.MustCompile(`^[+-]?\s?[\d\s\x{00a0}.,']*\d([.,]\d+)?\s?-?$`)
+	currencyRe = regexp.MustCompile(`^[A-Z]{3}$`)
+	accountRe  = regexp.MustCompile(`^(\d{1,6}-)?\d{2,10}/\d{4}$|^[A-Z]{2}\d{2}[A-Z0-9]{10,30}$`)
+
+	// Candidate date layouts; padded layouts first so they win ties
+	inferDateLayouts = []string{
+		"02.01.2006", "2.1.2006", "02. 01. 2006", "2. 1. 2006",
+		"2006-01-02", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05",
+		"02.01.2006 15:04:05", "02.01.2006 15:04", "2.1.2006 15:04",
+		"02/01/2006", "01/02/2006", "2/1/2006", "1/2/2006",
+		"2006/01/02", "02-01-2006", "02.01.06",
+	}
+
+	knownCurrencies = map[string]bool{
+		"CZK": true, "EUR": true, "USD": true, "GBP": true, "PLN": true, "HUF": true,
+		"CHF": true, "SEK": true, "NOK": true, "DKK": true, "JPY": true, "CAD": true, "AUD": true,
+	}
+
+	// headerHints maps field names to normalized header keywords (CZ/EN/DE/SK)
+	headerHints = map[string][]string{
+		"date":                 {"datum zauctovani", "datum", "date", "booking date", "completed date", "transaction date", "valuta", "buchungstag"},
+		"amount":               {"castka", "objem", "amount", "betrag", "suma", "value"},
+		"currency":             {"mena", "currency", "wahrung"},
+		"balance_after":        {"zustatek", "zostatok", "balance", "kontostand"},
+		"description":          {"popis", "description", "zprava", "message", "details", "poznamka", "note", "verwendungszweck", "text"},
+		"counterparty_name":    {"nazev protiuctu", "nazov protiuctu", "protistrana", "prijemce", "counterparty", "payee", "merchant", "obchodnik", "name", "nazev"},
+		"counterparty_account": {"protiucet", "cislo protiuctu", "counterparty account", "iban", "account"},
+		"external_id":          {"id pohybu", "id transakce", "transaction id", "reference", "id"},
+	}
+
+	diacritics = strings.NewReplacer(
+		"á", "a", "č", "c", "ď", "d", "é", "e", "ě", "e", "í", "i", "ň", "n", "ó", "o",
+		"ř", "r", "š", "s", "ť", "t", "ú", "u", "ů", "u", "ý", "y", "ž", "z",
+		"ä", "a", "ö", "o", "ü", "u", "ß", "ss", "ľ", "l", "ĺ", "l", "ŕ", "r", "ô", "o",
+	)
+)
+
+// InferTemplate sniffs delimiter, decimal separator, date format and column
+// roles from the first rows of an unknown CSV export
+func InferTemplate(data []byte) (*InferenceResult, error) {
+	data, encoding, err := DecodeText(data, "")
+	if err != nil {
+		return nil, err
+	}
+	lines := sampleLines(data, inferSampleRows)
+	if len(lines) < 2 {
+		return nil, fmt.Errorf("not enough rows to infer a template")
+	}
+
+	delimiter, records, width := inferDelimiter(lines)
+	if width < 2 {
+		return nil, fmt.Errorf("could not detect a column delimiter")
+	}
+
+	// The table starts at the first row with the dominant width; anything
+	// before it is an account-info preamble
+	start := 0
+	for start < len(records) && len(records[start]) != width {
+		start++
+	}
+	var rows [][]string
+	for _, r := range records[start:] {
+		if len(r) == width {
+			rows = append(rows, r)
+		}
+	}
+
+	profiles := profileColumns(rows, width)
+	hasHeader := looksLikeHeader(rows[0], profiles)
+	skipRows := start
+	var headers []string
+	if hasHeader {
+		headers = rows[0]
+		skipRows = start + 1
+		profiles = profileColumns(rows[1:], width)
+		for i := range profiles {
+			profiles[i].header = strings.TrimSpace(headers[i])
+		}
+	}
+	if len(rows) < 2 && hasHeader {
+		return nil, fmt.Errorf("no data rows after the header")
+	}
+
+	decimalSep := inferDecimalSeparator(profiles, delimiter)
+
+	fields := map[string]InferredField{}
+	used := map[int]bool{}
+	pick := func(field string, score func(p columnProfile) float64) {
+		best, bestScore := -1, 0.0
+		for _, p := range profiles {
+			if used[p.index] {
+				continue
+			}
+			s := score(p)
+			if hint := headerHintScore(field, p.header); hint > 0 {
+				s = 0.6*s + 0.4*hint
+			} else if hasHeader {
+				s *= 0.8
+				// A header naming another field outright should not be claimed here
+				if hintsOtherField(field, p.header) {
+					s *= 0.5
+				}
+			}
+			if s > bestScore {
+				best, bestScore = p.index, s
+			}
+		}
+		if best < 0 || bestScore < 0.3 {
+			return
+		}
+		used[best] = true
+		fields[field] = InferredField{
+			Column:     best,
+			Header:     profiles[best].header,
+			Confidence: math.Round(bestScore*100) / 100,
+		}
+	}
+
+	pick("date", func(p columnProfile) float64 { return p.dateScore })
+	pick("amount", func(p columnProfile) float64 {
+		if p.numScore < 0.9 || p.dateScore > 0.5 {
+			return 0
+		}
+		s := p.numScore * 0.7
+		if p.negatives > 0 {
+			s += 0.2
+		}
+		if p.decimals > 0 {
+			s += 0.1
+		}
+		if p.longIDs > 0 {
+			s *= 0.3
+		}
+		if headerHintScore("balance_after", p.header) > 0 {
+			s *= 0.5
+		}
+		return s
+	})
+	pick("currency", func(p columnProfile) float64 { return p.currency })
+	pick("counterparty_account", func(p columnProfile) float64 { return p.account })
+	pick("balance_after", func(p columnProfile) float64 {
+		if p.numScore < 0.9 || p.longIDs > 0 || headerHintScore("balance_after", p.header) == 0 {
+			return 0
+		}
+		return p.numScore
+	})
+	pick("external_id", func(p columnProfile) float64 {
+		if headerHintScore("external_id", p.header) == 0 || p.unique < 1 {
+			return 0
+		}
+		return 1
+	})
+	textScore := func(p columnProfile) float64 {
+		if p.numScore > 0.5 || p.dateScore > 0.5 || p.currency > 0.5 || len(p.values) == 0 {
+			return 0
+		}
+		// Longer, more varied text is more likely a description
+		return math.Min(1, p.avgLen/25) * (0.5 + 0.5*p.unique)
+	}
+	pick("description", textScore)
+	pick("counterparty_name", textScore)
+
+	// Column 0 means "unmapped" for optional fields, so those cannot be proposed there
+	for field, f := range fields {
+		if f.Column == 0 && field != "date" && field != "amount" {
+			delete(fields, field)
+		}
+	}
+
+	result := &InferenceResult{
+		HasHeader: hasHeader,
+		Headers:   headers,
+		Fields:    fields,
+	}
+
+	date, hasDate := fields["date"]
+	amount, hasAmount := fields["amount"]
+	if hasDate && hasAmount {
+		result.Confidence = math.Min(date.Confidence, amount.Confidence)
+	}
+
+	mapping := map[string]int{}
+	for field, f := range fields {
+		mapping[field] = f.Column
+	}
+	dateLayout := "2006-01-02"
+	if hasDate {
+		dateLayout = profiles[date.Column].dateLayout
+	}
+
+	result.Template = domain.BankTemplate{
+		Name:                    "Inferred CSV",
+		Code:                    "inferred",
+		Format:                  FormatCSV,
+		Delimiter:               string(delimiter),
+		Encoding:                encoding,
+		SkipRows:                skipRows,
+		DateFormat:              dateLayout,
+		DecimalSeparator:        decimalSep,
+		AmountNegativeIsExpense: true,
+		FieldMapping:            mapping,
+	}
+
+	return result, nil
+}
+
+// sampleLines returns up to n non-empty lines of data
+func sampleLines(data []byte, n int) []string {
+	content := strings.TrimPrefix(string(data), "\ufeff")
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, line)
+		if len(lines) >= n {
+			break
+		}
+	}
+	return lines
+}
+
+// inferDelimiter picks the delimiter producing the most rows of one consistent width
+func inferDelimiter(lines []string) (rune, [][]string, int) {
+	var (
+		bestDelim   = ','
+		bestRecords [][]string
+		bestWidth   int
+		bestScore   float64
+	)
+
+	for _, delim := range inferDelimiters {
+		reader := csv.NewReader(strings.NewReader(strings.Join(lines, "\n")))
+		reader.Comma = delim
+		reader.LazyQuotes = true
+		reader.FieldsPerRecord = -1
+		records, err := reader.ReadAll()
+		if err != nil || len(records) == 0 {
+			continue
+		}
+
+		widths := map[int]int{}
+		for _, r := range records {
+			widths[len(r)]++
+		}
+		width, count := 0, 0
+		for w, c := range widths {
+			if w > 1 && (c > count || (c == count && w > width)) {
+				width, count = w, c
+			}
+		}
+		if width < 2 {
+			continue
+		}
+
+		// Consistency matters most; wider tables break ties
+		score := float64(count)/float64(len(records)) + float64(width)/1000
+		if score > bestScore {
+			bestDelim, bestRecords, bestWidth, bestScore = delim, records, width, score
+		}
+	}
+
+	return bestDelim, bestRecords, bestWidth
+}
+
+// profileColumns collects value statistics for each column
+func profileColumns(rows [][]string, width int) []columnProfile {
+	profiles := make([]columnProfile, width)
+	for col := range profiles {
+		p := columnProfile{index: col}
+		seen := map[string]bool{}
+		totalLen := 0
+		for _, row := range rows {
+			v := strings.TrimSpace(row[col])
+			if v == "" {
+				continue
+			}
+			p.values = append(p.values, v)
+			seen[v] = true
+			totalLen += len([]rune(v))
+		}
+
+		n := float64(len(p.values))
+		if n == 0 {
+			profiles[col] = p
+			continue
+		}
+		p.avgLen = float64(totalLen) / n
+		p.unique = float64(len(seen)) / n
+
+		p.dateLayout, p.dateScore = bestDateLayout(p.values)
+
+		var numeric, currency, account float64
+		for _, v := range p.values {
+			if numberRe.MatchString(v) {
+				numeric++
+				if strings.HasPrefix(v, "-") || strings.HasSuffix(v, "-") {
+					p.negatives++
+				}
+				if hasDecimalPart(v) {
+					p.decimals++
+				}
+				digits := strings.Trim(v, "+- ")
+				if !strings.ContainsAny(digits, ".,'  ") && len(digits) >= 6 {
+					p.longIDs++
+				}
+			}
+			if currencyRe.MatchString(v) {
+				currency += 0.7
+				if knownCurrencies[v] {
+					currency += 0.3
+				}
+			}
+			if accountRe.MatchString(strings.ReplaceAll(v, " ", "")) {
+				account++
+			}
+		}
+		p.numScore = numeric / n
+		p.currency = currency / n
+		p.account = account / n
+		profiles[col] = p
+	}
+	return profiles
+}
+
+// bestDateLayout returns the layout parsing most values, resolving DD/MM vs MM/DD
+// ambiguity by the values themselves and preferring day-first otherwise
+func bestDateLayout(values []string) (string, float64) {
+	best, bestCount := "", 0
+	for _, layout := range inferDateLayouts {
+		count := 0
+		for _, v := range values {
+			if _, err := time.Parse(layout, v); err == nil {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = layout, count
+		}
+	}
+	if bestCount == 0 {
+		return "", 0
+	}
+	return best, float64(bestCount) / float64(len(values))
+}
+
+// hasDecimalPart reports whether a number ends in a 1-2 digit fraction
+func hasDecimalPart(v string) bool {
+	v = strings.TrimRight(v, "- ")
+	i := strings.LastIndexAny(v, ".,")
+	return i >= 0 && len(v)-i-1 >= 1 && len(v)-i-1 <= 2
+}
+
+// inferDecimalSeparator votes across numeric columns on the trailing separator
+func inferDecimalSeparator(profiles []columnProfile, delimiter rune) string {
+	if delimiter == ',' {
+		return "."
+	}
+	comma, dot := 0, 0
+	for _, p := range profiles {
+		if p.numScore < 0.9 || p.dateScore > 0.5 {
+			continue
+		}
+		for _, v := range p.values {
+			if !hasDecimalPart(v) {
+				continue
+			}
+			v = strings.TrimRight(v, "- ")
+			if v[strings.LastIndexAny(v, ".,")] == ',' {
+				comma++
+			} else {
+				dot++
+			}
+		}
+	}
+	if comma > dot {
+		return ","
+	}
+	return "."
+}
+
+// looksLikeHeader reports whether the first table row names the columns
+// rather than holding data
+func looksLikeHeader(row []string, profiles []columnProfile) bool {
+	hints := 0
+	for i, cell := range row {
+		cell = strings.TrimSpace(cell)
+		if _, score := bestDateLayout([]string{cell}); score > 0 && profiles[i].dateScore > 0.5 {
+			return false
+		}
+		for field := range headerHints {
+			if headerHintScore(field, cell) > 0 {
+				hints++
+				break
+			}
+		}
+	}
+	if hints > 0 {
+		return true
+	}
+	// Without known keywords, a header has no numbers where the data is numeric
+	for i, cell := range row {
+		if profiles[i].numScore > 0.9 && numberRe.MatchString(strings.TrimSpace(cell)) {
+			return false
+		}
+	}
+	return true
+}
+
+// headerHintScore rates how well a header names field: 1 for an exact
+// keyword, 0.7 when the header contains one, 0 otherwise
+func headerHintScore(field, header string) float64 {
+	h := normalizeHeader(header)
+	if h == "" {
+		return 0
+	}
+	keywords := headerHints[field]
+	best := 0.0
+	for _, k := range keywords {
+		if h == k {
+			return 1
+		}
+		// Short keywords like "id" only count as whole words
+		if len(k) <= 3 {
+			for _, word := range strings.Fields(h) {
+				if word == k {
+					best = 0.7
+				}
+			}
+			continue
+		}
+		if strings.Contains(h, k) {
+			best = 0.7
+		}
+	}
+	return best
+}
+
+// hintsOtherField reports whether header exactly names a field other than field
+func hintsOtherField(field, header string) bool {
+	for other := range headerHints {
+		if other != field && headerHintScore(other, header) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeHeader(s string) string {
+	s = strings.ToLower(strings.TrimSpace(strings.Trim(s, "\"\ufeff")))
+	s = diacritics.Replace(s)
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return r == ' ' || r == '_' || r == '-' || r == '.' || r == '(' || r == ')' || r == ':'
+	}), " ")
+}
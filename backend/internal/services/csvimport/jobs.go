@@ -0,0 +1,100 @@
+package csvimport
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Import job states
+const (
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// jobRetention is how long finished jobs stay pollable
+const jobRetention = time.Hour
+
+// ImportJob tracks an import running in the background so clients can poll its progress
+type ImportJob struct {
+	ID          string        `json:"id"`
+	WorkspaceID string        `json:"workspace_id"`
+	Status      string        `json:"status"`
+	Processed   int           `json:"processed"`
+	Total       int           `json:"total"`
+	Result      *ImportResult `json:"result,omitempty"`
+	Error       string        `json:"error,omitempty"`
+	finishedAt  time.Time
+}
+
+var (
+	jobsMu sync.Mutex
+	jobs   = map[string]*ImportJob{}
+)
+
+// StartImportJob runs an import in the background. run receives a progress
+// callback suitable for ImportTransactions.
+func StartImportJob(workspaceID string, total int, run func(progress func(processed, total int)) (*ImportResult, error)) ImportJob {
+	job := &ImportJob{
+		ID:          newJobID(),
+		WorkspaceID: workspaceID,
+		Status:      JobRunning,
+		Total:       total,
+	}
+
+	jobsMu.Lock()
+	pruneJobs()
+	jobs[job.ID] = job
+	snapshot := *job
+	jobsMu.Unlock()
+
+	go func() {
+		result, err := run(func(processed, total int) {
+			jobsMu.Lock()
+			job.Processed, job.Total = processed, total
+			jobsMu.Unlock()
+		})
+
+		jobsMu.Lock()
+		defer jobsMu.Unlock()
+		job.finishedAt = time.Now()
+		if err != nil {
+			job.Status = JobFailed
+			job.Error = err.Error()
+			return
+		}
+		job.Status = JobDone
+		job.Result = result
+		job.Processed = job.Total
+	}()
+
+	return snapshot
+}
+
+// GetImportJob returns a snapshot of the job's current state
+func GetImportJob(id string) (ImportJob, bool) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	job, ok := jobs[id]
+	if !ok {
+		return ImportJob{}, false
+	}
+	return *job, true
+}
+
+// pruneJobs drops jobs finished longer than jobRetention ago; callers hold jobsMu
+func pruneJobs() {
+	for id, job := range jobs {
+		if !job.finishedAt.IsZero() && time.Since(job.finishedAt) > jobRetention {
+			delete(jobs, id)
+		}
+	}
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,185 @@
+package csvimport
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ofxTransactionStartRe = regexp.MustCompile(`(?i)<STMTTRN>`)
+	ofxTransactionEndRe   = regexp.MustCompile(`(?i)</STMTTRN>|<STMTTRN>|</BANKTRANLIST>`)
+	ofxLedgerRe           = regexp.MustCompile(`(?is)<LEDGERBAL>(.*?)(?:</LEDGERBAL>|<AVAILBAL>|</STMTRS>|</CCSTMTRS>)`)
+	ofxAccountToRe        = regexp.MustCompile(`(?is)<(?:BANKACCTTO|CCACCTTO)>(.*?)</(?:BANKACCTTO|CCACCTTO)>`)
+)
+
+// ofxElementRes caches the element patterns of ofxElement by tag
+var ofxElementRes sync.Map
+
+// OFXTemplate returns the OFX/QFX (Open Financial Exchange) statement template
+func OFXTemplate() BankTemplate {
+	return BankTemplate{
+		Code:                    "ofx",
+		Name:                    "OFX / QFX",
+		Format:                  FormatOFX,
+		Encoding:                "utf-8",
+		DateFormat:              "20060102",
+		AmountNegativeIsExpense: true,
+		DecimalSeparator:        ".",
+	}
+}
+
+// ParseOFX parses <STMTTRN> blocks from an OFX 1.x (SGML) or 2.x (XML) statement.
+// Element values are read up to the next tag, so unclosed SGML elements work too.
+func ParseOFX(data []byte, template BankTemplate) (*PreviewResult, error) {
+	content := string(data)
+	if !strings.Contains(strings.ToUpper(content), "<OFX>") {
+		return nil, fmt.Errorf("failed to parse OFX: <OFX> root element not found")
+	}
+
+	defaultCurrency := ofxElement(content, "CURDEF")
+	if defaultCurrency == "" {
+		defaultCurrency = "CZK"
+	}
+
+	blocks := ofxTransactionBlocks(content)
+	result := &PreviewResult{
+		Transactions: []ParsedTransaction{},
+		TotalRows:    len(blocks),
+		Errors:       []ImportError{},
+	}
+
+	if m := ofxLedgerRe.FindStringSubmatch(content); m != nil {
+		if balance, err := parseAmount(ofxElement(m[1], "BALAMT"), "."); err == nil {
+			result.ClosingBalance = &balance
+		}
+	}
+
+	for i, block := range blocks {
+		rowNum := i + 1
+
+		tx, err := parseOFXTransaction(block, defaultCurrency, rowNum)
+		if err != nil {
+			result.Errors = append(result.Errors, ImportError{
+				Row:     rowNum,
+				Message: err.Error(),
+			})
+			continue
+		}
+
+		result.Transactions = append(result.Transactions, *tx)
+	}
+
+	return result, nil
+}
+
+// ofxTransactionBlocks returns the bodies of the <STMTTRN> blocks. An unclosed
+// block ends where the next one or the transaction list starts.
+func ofxTransactionBlocks(content string) []string {
+	var blocks []string
+	for _, start := range ofxTransactionStartRe.FindAllStringIndex(content, -1) {
+		body := content[start[1]:]
+		if end := ofxTransactionEndRe.FindStringIndex(body); end != nil {
+			body = body[:end[0]]
+		}
+		blocks = append(blocks, body)
+	}
+	return blocks
+}
+
+// parseOFXTransaction converts the body of a single <STMTTRN> block
+func parseOFXTransaction(block, defaultCurrency string, rowNum int) (*ParsedTransaction, error) {
+	dateStr := ofxElement(block, "DTPOSTED")
+	date, err := parseOFXDate(dateStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date '%s': %w", dateStr, err)
+	}
+
+	amountStr := ofxElement(block, "TRNAMT")
+	amount, err := parseAmount(amountStr, ".")
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount '%s': %w", amountStr, err)
+	}
+
+	isExpense := amount < 0
+	amount = abs(amount)
+
+	currency := defaultCurrency
+	if c := ofxElement(block, "CURSYM"); c != "" {
+		currency = c
+	}
+
+	name := ofxElement(block, "NAME")
+	if name == "" {
+		name = ofxElement(block, "PAYEE")
+	}
+	memo := ofxElement(block, "MEMO")
+
+	var parts []string
+	for _, part := range []string{name, memo} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	rawDescription := strings.Join(parts, " | ")
+	description := "Unknown transaction"
+	if len(parts) > 0 {
+		description = parts[0]
+	}
+
+	var counterpartyAccount string
+	if m := ofxAccountToRe.FindStringSubmatch(block); m != nil {
+		counterpartyAccount = ofxElement(m[1], "ACCTID")
+		if bankID := ofxElement(m[1], "BANKID"); counterpartyAccount != "" && bankID != "" {
+			counterpartyAccount += "/" + bankID
+		}
+	}
+
+	tx := &ParsedTransaction{
+		Date:                date,
+		Description:         description,
+		RawDescription:      rawDescription,
+		Amount:              amount,
+		Currency:            currency,
+		IsExpense:           isExpense,
+		ExternalID:          ofxElement(block, "FITID"),
+		BankCategory:        ofxElement(block, "TRNTYPE"),
+		MerchantName:        name,
+		CounterpartyAccount: counterpartyAccount,
+		RowNumber:           rowNum,
+	}
+
+	if tx.ExternalID == "" {
+		tx.ExternalID = GenerateTransactionHash(tx.Date, tx.RawDescription, tx.Amount, tx.IsExpense)
+	}
+
+	return tx, nil
+}
+
+// ofxElement returns the text of the first <TAG> element, closed or not
+func ofxElement(content, tag string) string {
+	re, ok := ofxElementRes.Load(tag)
+	if !ok {
+		re, _ = ofxElementRes.LoadOrStore(tag, regexp.MustCompile(`(?i)<`+tag+`>([^<\r\n]*)`))
+	}
+	m := re.(*regexp.Regexp).FindStringSubmatch(content)
+	if m == nil {
+		return ""
+	}
+	return unescapeSGML(strings.TrimSpace(m[1]))
+}
+
+// parseOFXDate parses OFX datetimes such as 20250305, 20250305120000 or 20250305120000.000[-5:EST].
+// Only the calendar date is kept.
+func parseOFXDate(s string) (time.Time, error) {
+	if len(s) < 8 {
+		return time.Time{}, fmt.Errorf("could not parse date")
+	}
+	return time.Parse("20060102", s[:8])
+}
+
+func unescapeSGML(s string) string {
+	return strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&apos;", "'").Replace(s)
+}
@@ -0,0 +1,609 @@
+package csvimport
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"lifehub/backend/internal/domain"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func loadTestDataBytes(t *testing.T, filename string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + filename)
+	if err != nil {
+		t.Fatalf("Failed to load test data %s: %v", filename, err)
+	}
+	return data
+}
+
+// checkFioTransactions asserts the shared expectations for the Fio CSV and JSON samples,
+// which describe the same three movements.
+func checkFioTransactions(t *testing.T, result *PreviewResult) {
+	t.Helper()
+
+	if len(result.Errors) > 0 {
+		t.Fatalf("Expected no errors, got %+v", result.Errors)
+	}
+	if len(result.Transactions) != 3 {
+		t.Fatalf("Expected 3 transactions, got %d", len(result.Transactions))
+	}
+
+	card := result.Transactions[0]
+	if card.ExternalID != "26512345001" {
+		t.Errorf("Expected external ID 26512345001, got %q", card.ExternalID)
+	}
+	if !card.IsExpense || card.Amount != 328.50 {
+		t.Errorf("Expected expense 328.50, got expense=%v amount=%.2f", card.IsExpense, card.Amount)
+	}
+	if card.MerchantName != "ALBERT 0123" {
+		t.Errorf("Expected merchant 'ALBERT 0123', got %q", card.MerchantName)
+	}
+	if card.Date.Format("2006-01-02") != "2025-03-03" {
+		t.Errorf("Expected date 2025-03-03, got %s", card.Date.Format("2006-01-02"))
+	}
+
+	// The message for the recipient and the comment are kept next to the
+	// user identification, so both formats describe a movement alike
+	for i, want := range []string{
+		"Platba kartou | Nákup: ALBERT 0123, Praha, CZ, dne 1.3.2025, částka 328.50 CZK | Týdenní nákup",
+		"Bytové družstvo | Bezhotovostní platba | Nájem březen | Nájem 03/2025",
+		"ACME s.r.o. | Příjem převodem uvnitř banky | Mzda",
+	} {
+		if got := result.Transactions[i].RawDescription; got != want {
+			t.Errorf("Expected raw description %q, got %q", want, got)
+		}
+	}
+
+	rent := result.Transactions[1]
+	if rent.CounterpartyAccount != "123456789/0300" {
+		t.Errorf("Expected counterparty 123456789/0300, got %q", rent.CounterpartyAccount)
+	}
+	if rent.VariableSymbol != "20250301" {
+		t.Errorf("Expected VS 20250301, got %q", rent.VariableSymbol)
+	}
+	if rent.Amount != 12000 {
+		t.Errorf("Expected amount 12000, got %.2f", rent.Amount)
+	}
+
+	salary := result.Transactions[2]
+	if salary.IsExpense {
+		t.Errorf("Expected salary to be income")
+	}
+	if salary.Description != "ACME s.r.o." {
+		t.Errorf("Expected description 'ACME s.r.o.', got %q", salary.Description)
+	}
+	if salary.BankCategory != "Příjem převodem uvnitř banky" {
+		t.Errorf("Expected bank category from type, got %q", salary.BankCategory)
+	}
+}
+
+func TestParseFio_CSV(t *testing.T) {
+	data := loadTestDataBytes(t, "fio_sample.csv")
+
+	if code := DetectTemplate(data); code != "fio" {
+		t.Fatalf("Expected detected template 'fio', got %q", code)
+	}
+
+	result, err := Parse(data, FioTemplate())
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	checkFioTransactions(t, result)
+}
+
+func TestParseFio_JSON(t *testing.T) {
+	data := loadTestDataBytes(t, "fio_sample.json")
+
+	if code := DetectTemplate(data); code != "fio" {
+		t.Fatalf("Expected detected template 'fio', got %q", code)
+	}
+
+	result, err := Parse(data, FioTemplate())
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	checkFioTransactions(t, result)
+}
+
+func TestParseCamt053(t *testing.T) {
+	data := loadTestDataBytes(t, "camt053_sample.xml")
+
+	if code := DetectTemplate(data); code != "camt" {
+		t.Fatalf("Expected detected template 'camt', got %q", code)
+	}
+
+	result, err := Parse(data, CamtTemplate())
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if result.TotalRows != 4 {
+		t.Errorf("Expected 4 entries, got %d", result.TotalRows)
+	}
+	// The pending entry is reported as skipped, not imported
+	if len(result.Errors) != 1 {
+		t.Errorf("Expected 1 skipped entry, got %+v", result.Errors)
+	}
+	// The batch entry is split into its two TxDtls
+	if len(result.Transactions) != 4 {
+		t.Fatalf("Expected 4 transactions, got %d", len(result.Transactions))
+	}
+
+	if result.OpeningBalance == nil || *result.OpeningBalance != 25000 {
+		t.Errorf("Expected opening balance 25000, got %v", result.OpeningBalance)
+	}
+	if result.ClosingBalance == nil || *result.ClosingBalance != 33100 {
+		t.Errorf("Expected closing balance 33100, got %v", result.ClosingBalance)
+	}
+
+	electricity := result.Transactions[0]
+	if !electricity.IsExpense || electricity.Amount != 1900 {
+		t.Errorf("Expected expense 1900, got expense=%v amount=%.2f", electricity.IsExpense, electricity.Amount)
+	}
+	if electricity.ExternalID != "E2E-ELECTRICITY-03" {
+		t.Errorf("Expected end-to-end ID as external ID, got %q", electricity.ExternalID)
+	}
+	if electricity.CounterpartyAccount != "CZ5508000000001234567899" {
+		t.Errorf("Expected creditor IBAN, got %q", electricity.CounterpartyAccount)
+	}
+	if electricity.RawDescription != "ČEZ Prodej, a.s. | Záloha elektřina 03/2025" {
+		t.Errorf("Unexpected raw description %q", electricity.RawDescription)
+	}
+
+	salary := result.Transactions[1]
+	if salary.IsExpense {
+		t.Errorf("Expected salary to be income")
+	}
+	if salary.ExternalID != "BANKREF-0002-TX" {
+		t.Errorf("Expected servicer reference fallback for NOTPROVIDED, got %q", salary.ExternalID)
+	}
+	if salary.CounterpartyAccount != "CZ2720100000002900111222" {
+		t.Errorf("Expected debtor IBAN, got %q", salary.CounterpartyAccount)
+	}
+
+	if result.Transactions[2].Amount != 30000 || result.Transactions[3].Amount != 5000 {
+		t.Errorf("Expected batch split 30000 + 5000, got %.2f + %.2f",
+			result.Transactions[2].Amount, result.Transactions[3].Amount)
+	}
+}
+
+func TestParseCamt054(t *testing.T) {
+	data := loadTestDataBytes(t, "camt054_sample.xml")
+
+	if code := DetectTemplate(data); code != "camt" {
+		t.Fatalf("Expected detected template 'camt', got %q", code)
+	}
+
+	result, err := Parse(data, CamtTemplate())
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(result.Transactions) != 1 {
+		t.Fatalf("Expected 1 transaction, got %d (errors: %+v)", len(result.Transactions), result.Errors)
+	}
+
+	tx := result.Transactions[0]
+	if tx.Currency != "EUR" || tx.Amount != 42.90 || !tx.IsExpense {
+		t.Errorf("Expected EUR 42.90 expense, got %s %.2f expense=%v", tx.Currency, tx.Amount, tx.IsExpense)
+	}
+	if tx.Description != "Spotify AB" {
+		t.Errorf("Expected description 'Spotify AB', got %q", tx.Description)
+	}
+	if tx.Date.Format("2006-01-02") != "2025-03-20" {
+		t.Errorf("Expected date 2025-03-20, got %s", tx.Date.Format("2006-01-02"))
+	}
+	if result.OpeningBalance != nil {
+		t.Errorf("Expected no balances for camt.054")
+	}
+}
+
+func TestParseOFX(t *testing.T) {
+	data := loadTestDataBytes(t, "ofx_sample.ofx")
+
+	if code := DetectTemplate(data); code != "ofx" {
+		t.Fatalf("Expected detected template 'ofx', got %q", code)
+	}
+
+	result, err := Parse(data, OFXTemplate())
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if result.TotalRows != 3 {
+		t.Errorf("Expected 3 STMTTRN blocks, got %d", result.TotalRows)
+	}
+	if len(result.Errors) != 1 {
+		t.Errorf("Expected 1 error for the invalid date, got %+v", result.Errors)
+	}
+	if len(result.Transactions) != 2 {
+		t.Fatalf("Expected 2 transactions, got %d", len(result.Transactions))
+	}
+
+	purchase := result.Transactions[0]
+	if purchase.ExternalID != "20250303-0001" {
+		t.Errorf("Expected FITID as external ID, got %q", purchase.ExternalID)
+	}
+	if !purchase.IsExpense || purchase.Amount != 23.40 || purchase.Currency != "EUR" {
+		t.Errorf("Expected EUR 23.40 expense, got %s %.2f expense=%v", purchase.Currency, purchase.Amount, purchase.IsExpense)
+	}
+	if purchase.RawDescription != "LIDL DEKUJE ZA NAKUP | Card 1111 & PIN" {
+		t.Errorf("Unexpected raw description %q", purchase.RawDescription)
+	}
+	if purchase.Date.Format("2006-01-02") != "2025-03-03" {
+		t.Errorf("Expected date 2025-03-03, got %s", purchase.Date.Format("2006-01-02"))
+	}
+
+	if result.Transactions[1].IsExpense {
+		t.Errorf("Expected credit to be income")
+	}
+	if result.ClosingBalance == nil || *result.ClosingBalance != -812.55 {
+		t.Errorf("Expected ledger balance -812.55, got %v", result.ClosingBalance)
+	}
+}
+
+func TestParseOFX_UnclosedTransactions(t *testing.T) {
+	data := []byte("<OFX><BANKMSGSRSV1><STMTRS><CURDEF>CZK<BANKTRANLIST>\n" +
+		"<STMTTRN><DTPOSTED>20250301<TRNAMT>-1.00<FITID>A\n" +
+		"<STMTTRN><DTPOSTED>20250302<TRNAMT>-2.00<FITID>B\n" +
+		"<STMTTRN><DTPOSTED>20250303<TRNAMT>-3.00<FITID>C\n" +
+		"</BANKTRANLIST></STMTRS></BANKMSGSRSV1></OFX>")
+
+	result, err := ParseOFX(data, OFXTemplate())
+	if err != nil {
+		t.Fatalf("ParseOFX failed: %v", err)
+	}
+	var ids []string
+	for _, tx := range result.Transactions {
+		ids = append(ids, tx.ExternalID)
+	}
+	if strings.Join(ids, ",") != "A,B,C" || len(result.Errors) > 0 {
+		t.Errorf("Expected transactions A,B,C, got %v (errors: %+v)", ids, result.Errors)
+	}
+}
+
+func TestParseQIF(t *testing.T) {
+	data := loadTestDataBytes(t, "qif_sample.qif")
+
+	if code := DetectTemplate(data); code != "qif" {
+		t.Fatalf("Expected detected template 'qif', got %q", code)
+	}
+
+	result, err := Parse(data, QIFTemplate())
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	// The !Type:Memorized block must be ignored
+	if len(result.Transactions) != 3 {
+		t.Fatalf("Expected 3 transactions, got %d (errors: %+v)", len(result.Transactions), result.Errors)
+	}
+
+	groceries := result.Transactions[0]
+	if !groceries.IsExpense || groceries.Amount != 1250 {
+		t.Errorf("Expected expense 1250, got expense=%v amount=%.2f", groceries.IsExpense, groceries.Amount)
+	}
+	if groceries.BankCategory != "Groceries" {
+		t.Errorf("Expected bank category 'Groceries', got %q", groceries.BankCategory)
+	}
+
+	salary := result.Transactions[1]
+	if salary.Date.Format("2006-01-02") != "2025-03-12" {
+		t.Errorf("Expected Quicken date 3/12'25 to parse as 2025-03-12, got %s", salary.Date.Format("2006-01-02"))
+	}
+	if salary.IsExpense || salary.Amount != 25000 {
+		t.Errorf("Expected income 25000, got expense=%v amount=%.2f", salary.IsExpense, salary.Amount)
+	}
+
+	if result.Transactions[2].Amount != 89.90 {
+		t.Errorf("Expected U amount 89.90, got %.2f", result.Transactions[2].Amount)
+	}
+	if result.Transactions[0].ExternalID == result.Transactions[2].ExternalID {
+		t.Errorf("Expected distinct hashed external IDs")
+	}
+}
+
+func TestTemplateFromDomain(t *testing.T) {
+	d := domain.BankTemplate{
+		Name:             "My bank",
+		Code:             "my-bank",
+		Delimiter:        ";",
+		DateFormat:       "DD.MM.YYYY",
+		DecimalSeparator: ",",
+		FieldMapping:     map[string]int{"date": 0, "amount": 1, "message": 4},
+		MerchantExtraction: map[string]string{
+			"card_transaction_field":   "message",
+			"card_transaction_pattern": `Místo:\s*(.+)`,
+		},
+	}
+
+	if problems := ValidateTemplate(d); len(problems) > 0 {
+		t.Fatalf("Expected valid template, got %v", problems)
+	}
+
+	tmpl, err := TemplateFromDomain(d)
+	if err != nil {
+		t.Fatalf("TemplateFromDomain failed: %v", err)
+	}
+	if tmpl.Delimiter != ';' || tmpl.DateFormat != "02.01.2006" {
+		t.Errorf("Expected ';' and Go layout, got %q and %q", tmpl.Delimiter, tmpl.DateFormat)
+	}
+	if tmpl.MerchantExtraction.CardTransactionField != 4 {
+		t.Errorf("Expected field name resolved to column 4, got %d", tmpl.MerchantExtraction.CardTransactionField)
+	}
+
+	result, err := ParseCSV([]byte("Datum;Částka;x;y;Zpráva\n05.03.2025;-120,50;;;Místo: BILLA\n"), tmpl)
+	if err != nil {
+		t.Fatalf("ParseCSV failed: %v", err)
+	}
+	if len(result.Transactions) != 1 || result.Transactions[0].MerchantName != "BILLA" {
+		t.Errorf("Expected one BILLA transaction, got %+v (errors: %+v)", result.Transactions, result.Errors)
+	}
+}
+
+func TestTemplateFromDomain_CardTransactionField(t *testing.T) {
+	d := domain.BankTemplate{
+		Name:             "My bank",
+		Code:             "my-bank",
+		Delimiter:        ";",
+		DateFormat:       "DD.MM.YYYY",
+		DecimalSeparator: ",",
+		FieldMapping:     map[string]int{"date": 0, "amount": 1, "description": 2, "message": 3},
+		MerchantExtraction: map[string]string{
+			"card_transaction_field":   "description",
+			"card_transaction_pattern": `Místo:\s*(.+)`,
+		},
+	}
+
+	// Any mapped column may hold the card details, not only the message
+	if problems := ValidateTemplate(d); len(problems) > 0 {
+		t.Fatalf("Expected valid template, got %v", problems)
+	}
+	tmpl, err := TemplateFromDomain(d)
+	if err != nil {
+		t.Fatalf("TemplateFromDomain failed: %v", err)
+	}
+	result, err := ParseCSV([]byte("Datum;Částka;Popis;Zpráva\n05.03.2025;-120,50;Místo: BILLA;Nákup\n"), tmpl)
+	if err != nil {
+		t.Fatalf("ParseCSV failed: %v", err)
+	}
+	if len(result.Transactions) != 1 || result.Transactions[0].MerchantName != "BILLA" {
+		t.Errorf("Expected one BILLA transaction, got %+v (errors: %+v)", result.Transactions, result.Errors)
+	}
+
+	d.MerchantExtraction["card_transaction_field"] = "7"
+	problems := strings.Join(ValidateTemplate(d), "\n")
+	if !strings.Contains(problems, "merchant_extraction.card_transaction_field must be a mapped column") {
+		t.Errorf("Expected unmapped card transaction column to be rejected, got:\n%s", problems)
+	}
+}
+
+func TestValidateTemplate_Invalid(t *testing.T) {
+	d := domain.BankTemplate{
+		Code:             "Bad Code",
+		Delimiter:        ";;",
+		DateFormat:       "YYYY",
+		DecimalSeparator: ",",
+		FieldMapping:     map[string]int{"date": 0},
+	}
+
+	problems := strings.Join(ValidateTemplate(d), "\n")
+	for _, want := range []string{"name is required", "code must be", "delimiter must be a single character"} {
+		if !strings.Contains(problems, want) {
+			t.Errorf("Expected problem %q, got:\n%s", want, problems)
+		}
+	}
+
+	d.Delimiter = ","
+	d.FieldMapping["external_id"] = 0
+	problems = strings.Join(ValidateTemplate(d), "\n")
+	for _, want := range []string{"date_format", "field_mapping.amount is required", "decimal_separator must differ", "field_mapping.external_id can't be column 0"} {
+		if !strings.Contains(problems, want) {
+			t.Errorf("Expected problem %q, got:\n%s", want, problems)
+		}
+	}
+}
+
+func TestInferTemplate(t *testing.T) {
+	data := loadTestDataBytes(t, "unknown_bank.csv")
+
+	result, err := InferTemplate(data)
+	if err != nil {
+		t.Fatalf("InferTemplate failed: %v", err)
+	}
+
+	tmpl := result.Template
+	if tmpl.Delimiter != ";" || tmpl.DecimalSeparator != "," || tmpl.DateFormat != "02.01.2006" {
+		t.Errorf("Expected ';', ',' and 02.01.2006, got %q, %q and %q", tmpl.Delimiter, tmpl.DecimalSeparator, tmpl.DateFormat)
+	}
+	if !result.HasHeader || tmpl.SkipRows != 3 {
+		t.Errorf("Expected header with 3 skipped rows, got header=%v skip=%d", result.HasHeader, tmpl.SkipRows)
+	}
+
+	want := map[string]int{"date": 0, "amount": 1, "currency": 2, "counterparty_account": 3, "counterparty_name": 4, "description": 5, "balance_after": 6}
+	for field, col := range want {
+		if got, ok := result.Fields[field]; !ok || got.Column != col {
+			t.Errorf("Expected %s in column %d, got %+v", field, col, result.Fields[field])
+		}
+	}
+	if result.Confidence < MinInferenceConfidence {
+		t.Errorf("Expected confident inference, got %.2f", result.Confidence)
+	}
+	if problems := ValidateTemplate(tmpl); len(problems) > 0 {
+		t.Errorf("Expected inferred template to be valid, got %v", problems)
+	}
+
+	parser, err := TemplateFromDomain(tmpl)
+	if err != nil {
+		t.Fatalf("TemplateFromDomain failed: %v", err)
+	}
+	parsed, err := ParseCSV(data, parser)
+	if err != nil {
+		t.Fatalf("ParseCSV failed: %v", err)
+	}
+	if len(parsed.Errors) > 0 || len(parsed.Transactions) != 4 {
+		t.Fatalf("Expected 4 transactions, got %d (errors: %+v)", len(parsed.Transactions), parsed.Errors)
+	}
+	salary := parsed.Transactions[2]
+	if salary.IsExpense || salary.Amount != 2150 || salary.Currency != "EUR" || salary.BalanceAfter != 3454.10 {
+		t.Errorf("Unexpected salary transaction %+v", salary)
+	}
+}
+
+func TestInferTemplate_CommaSeparated(t *testing.T) {
+	data := []byte("Transaction ID,Date,Payee,Amount,Currency\n" +
+		"A-1001,03/14/2025,Coffee Shop,-4.50,USD\n" +
+		"A-1002,03/15/2025,Employer Inc,2500.00,USD\n" +
+		"A-1003,03/20/2025,Grocery Store,-82.17,USD\n")
+
+	result, err := InferTemplate(data)
+	if err != nil {
+		t.Fatalf("InferTemplate failed: %v", err)
+	}
+
+	tmpl := result.Template
+	if tmpl.Delimiter != "," || tmpl.DecimalSeparator != "." || tmpl.DateFormat != "01/02/2006" {
+		t.Errorf("Expected ',', '.' and 01/02/2006, got %q, %q and %q", tmpl.Delimiter, tmpl.DecimalSeparator, tmpl.DateFormat)
+	}
+	if tmpl.SkipRows != 1 {
+		t.Errorf("Expected the header row to be skipped, got %d", tmpl.SkipRows)
+	}
+	for field, col := range map[string]int{"date": 1, "amount": 3, "currency": 4, "counterparty_name": 2} {
+		if got := result.Fields[field]; got.Column != col {
+			t.Errorf("Expected %s in column %d, got %+v", field, col, got)
+		}
+	}
+}
+
+func TestParseCSV_Encodings(t *testing.T) {
+	const content = "Datum;Částka;Popis\n05.03.2025;-120,50;Nákup Žabka Šumperk, ťuk\n"
+	tmpl := BankTemplate{
+		Delimiter:               ';',
+		SkipRows:                1,
+		DateFormat:              "02.01.2006",
+		DecimalSeparator:        ",",
+		AmountNegativeIsExpense: true,
+		FieldMapping:            FieldMapping{Date: 0, Amount: 1, Description: 2},
+	}
+
+	cp1250, _ := charmap.Windows1250.NewEncoder().String(content)
+	iso, _ := charmap.ISO8859_2.NewEncoder().String(content)
+	utf16, _ := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().String(content)
+
+	tests := []struct {
+		name     string
+		data     string
+		declared string
+		want     string
+	}{
+		{"utf-8", content, "", EncodingUTF8},
+		{"utf-8 BOM", "\ufeff" + content, "utf-8", EncodingUTF8},
+		{"cp1250 detected", cp1250, "", EncodingWindows1250},
+		{"cp1250 declared as utf-8", cp1250, "utf-8", EncodingWindows1250},
+		{"cp1250 declared", cp1250, "cp1250", EncodingWindows1250},
+		{"iso-8859-2 detected", iso, "", EncodingISO88592},
+		{"iso-8859-2 declared", iso, "latin2", EncodingISO88592},
+		{"utf-16 BOM", utf16, "", EncodingUTF16},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl.Encoding = tt.declared
+			result, err := ParseCSV([]byte(tt.data), tmpl)
+			if err != nil {
+				t.Fatalf("ParseCSV failed: %v", err)
+			}
+			if result.Encoding != tt.want {
+				t.Errorf("Expected encoding %s, got %s", tt.want, result.Encoding)
+			}
+			if len(result.Transactions) != 1 {
+				t.Fatalf("Expected 1 transaction, got %d (errors: %+v)", len(result.Transactions), result.Errors)
+			}
+			if got := result.Transactions[0].Description; got != "Nákup Žabka Šumperk, ťuk" {
+				t.Errorf("Expected decoded description, got %q", got)
+			}
+		})
+	}
+
+	if _, err := ParseCSV([]byte(content), BankTemplate{Encoding: "ebcdic", Delimiter: ';'}); err == nil {
+		t.Errorf("Expected an error for an unsupported encoding")
+	}
+}
+
+func TestImportJobProgress(t *testing.T) {
+	release := make(chan struct{})
+	job := StartImportJob("ws1", 3, func(progress func(processed, total int)) (*ImportResult, error) {
+		progress(2, 3)
+		<-release
+		return &ImportResult{TransactionsImported: 3}, nil
+	})
+	if job.Status != JobRunning || job.Total != 3 {
+		t.Fatalf("Expected running job of 3 rows, got %+v", job)
+	}
+
+	waitForJob := func(done func(ImportJob) bool) ImportJob {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if current, ok := GetImportJob(job.ID); ok && done(current) {
+				return current
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("Timed out waiting for job %s", job.ID)
+		return ImportJob{}
+	}
+
+	waitForJob(func(j ImportJob) bool { return j.Processed == 2 })
+	close(release)
+	finished := waitForJob(func(j ImportJob) bool { return j.Status != JobRunning })
+	if finished.Status != JobDone || finished.Processed != 3 || finished.Result.TransactionsImported != 3 {
+		t.Errorf("Expected finished job with 3 imported rows, got %+v", finished)
+	}
+
+	if _, ok := GetImportJob("missing"); ok {
+		t.Errorf("Expected unknown job to be reported missing")
+	}
+}
+
+func TestFindFuzzyDuplicates(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 3, d, 0, 0, 0, 0, time.UTC) }
+	existing := []StoredTransaction{
+		{ID: "csv1", Date: day(3), Description: "ALBERT 0123", RawDescription: "Nákup: ALBERT 0123, Praha, CZ", Amount: 328.50, IsExpense: true, ExternalID: "h1"},
+		{ID: "csv2", Date: day(10), Description: "Bytové družstvo", Amount: 12000, IsExpense: true, CounterpartyAccount: "123456789/0300", ExternalID: "h2"},
+		{ID: "csv3", Date: day(15), Description: "ACME s.r.o.", Amount: 11000, ExternalID: "26512345003"},
+	}
+	incoming := []ParsedTransaction{
+		// Same card payment from a camt export: booked a day later, different wording
+		{RowNumber: 1, Date: day(4), Description: "Albert Praha", Amount: 328.50, IsExpense: true, ExternalID: "camt-1"},
+		// Rent matched by counterparty account despite an unrelated description
+		{RowNumber: 2, Date: day(11), Description: "Trvalý příkaz", Amount: 12000, IsExpense: true, CounterpartyAccount: "0123456789/0300", ExternalID: "camt-2"},
+		// Same amount, but outside the date window
+		{RowNumber: 3, Date: day(20), Description: "ALBERT 0123", Amount: 328.50, IsExpense: true, ExternalID: "camt-3"},
+		// Exact external ID match is left to the regular duplicate check
+		{RowNumber: 4, Date: day(15), Description: "ACME s.r.o.", Amount: 11000, ExternalID: "26512345003"},
+		// Same amount and date, different merchant
+		{RowNumber: 5, Date: day(3), Description: "BILLA Brno", Amount: 328.50, IsExpense: true, ExternalID: "camt-5"},
+	}
+
+	got := FindFuzzyDuplicates(incoming, existing, DefaultDuplicateDays)
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 candidates, got %+v", got)
+	}
+	if got[0].RowNumber != 1 || got[0].DuplicateOfID != "csv1" || got[0].Reason != "similar description" {
+		t.Errorf("Unexpected card payment candidate %+v", got[0])
+	}
+	if got[1].RowNumber != 2 || got[1].DuplicateOfID != "csv2" || got[1].Reason != "same counterparty account" {
+		t.Errorf("Unexpected rent candidate %+v", got[1])
+	}
+
+	stored := append(existing, StoredTransaction{ID: "camt1", Date: day(4), Description: "Albert Praha", Amount: 328.50, IsExpense: true, ExternalID: "camt-1"})
+	pairs := FindStoredDuplicates(stored, DefaultDuplicateDays)
+	if len(pairs) != 1 || pairs[0].TransactionID != "camt1" || pairs[0].DuplicateOfID != "csv1" {
+		t.Errorf("Expected camt1 paired with csv1, got %+v", pairs)
+	}
+}
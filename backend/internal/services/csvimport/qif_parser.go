@@ -0,0 +1,187 @@
+package csvimport
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// QIFTemplate returns the QIF (Quicken Interchange Format) template.
+// DateFormat is the preferred date layout; QIF files in the wild use US month-first dates.
+func QIFTemplate() BankTemplate {
+	return BankTemplate{
+		Code:                    "qif",
+		Name:                    "QIF (Quicken)",
+		Format:                  FormatQIF,
+		Encoding:                "utf-8",
+		DateFormat:              "01/02/2006",
+		AmountNegativeIsExpense: true,
+		DecimalSeparator:        ".",
+	}
+}
+
+// qifAccountTypes lists the !Type headers holding bank-style transactions
+var qifAccountTypes = map[string]bool{
+	"bank":  true,
+	"ccard": true,
+	"cash":  true,
+	"oth a": true,
+	"oth l": true,
+}
+
+// ParseQIF parses !Type:Bank (and CCard/Cash) records from a QIF file.
+// QIF carries no transaction IDs, so ExternalID is always a content hash.
+func ParseQIF(data []byte, template BankTemplate) (*PreviewResult, error) {
+	result := &PreviewResult{
+		Transactions: []ParsedTransaction{},
+		Errors:       []ImportError{},
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	inAccount := false
+	sawHeader := false
+	record := map[byte][]string{}
+	lineNum, recordStart := 0, 0
+
+	flush := func() {
+		if len(record) == 0 {
+			return
+		}
+		result.TotalRows++
+		tx, err := parseQIFRecord(record, template, recordStart)
+		if err != nil {
+			result.Errors = append(result.Errors, ImportError{
+				Row:     recordStart,
+				Message: err.Error(),
+			})
+		} else {
+			result.Transactions = append(result.Transactions, *tx)
+		}
+		record = map[byte][]string{}
+	}
+
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if lineNum == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "!") {
+			flush()
+			header := strings.ToLower(strings.TrimSpace(line))
+			if strings.HasPrefix(header, "!type:") {
+				sawHeader = true
+				inAccount = qifAccountTypes[strings.TrimPrefix(header, "!type:")]
+			} else {
+				// !Account, !Option and similar blocks are not transactions
+				inAccount = false
+			}
+			continue
+		}
+		if !inAccount {
+			continue
+		}
+
+		if line[0] == '^' {
+			flush()
+			continue
+		}
+		if len(record) == 0 {
+			recordStart = lineNum
+		}
+		record[line[0]] = append(record[line[0]], strings.TrimSpace(line[1:]))
+	}
+	flush()
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read QIF: %w", err)
+	}
+	if !sawHeader {
+		return nil, fmt.Errorf("failed to parse QIF: !Type header not found")
+	}
+
+	return result, nil
+}
+
+// parseQIFRecord converts the fields of a single ^-terminated record.
+// Split lines (S/E/$) are ignored; the record total (T/U) is imported.
+func parseQIFRecord(record map[byte][]string, template BankTemplate, rowNum int) (*ParsedTransaction, error) {
+	first := func(code byte) string {
+		if values := record[code]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	dateStr := first('D')
+	date, err := parseQIFDate(dateStr, template.DateFormat)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date '%s': %w", dateStr, err)
+	}
+
+	amountStr := first('T')
+	if amountStr == "" {
+		amountStr = first('U')
+	}
+	amount, err := parseAmount(amountStr, template.DecimalSeparator)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount '%s': %w", amountStr, err)
+	}
+
+	isExpense := amount < 0
+	amount = abs(amount)
+
+	payee := first('P')
+	memo := first('M')
+
+	var parts []string
+	for _, part := range []string{payee, memo} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	rawDescription := strings.Join(parts, " | ")
+	description := "Unknown transaction"
+	if len(parts) > 0 {
+		description = parts[0]
+	}
+
+	tx := &ParsedTransaction{
+		Date:           date,
+		Description:    description,
+		RawDescription: rawDescription,
+		Amount:         amount,
+		Currency:       "CZK",
+		IsExpense:      isExpense,
+		BankCategory:   first('L'),
+		MerchantName:   payee,
+		RowNumber:      rowNum,
+	}
+	// Include the check number so same-day identical payments stay distinct
+	tx.ExternalID = GenerateTransactionHash(tx.Date, tx.RawDescription+first('N'), tx.Amount, tx.IsExpense)
+
+	return tx, nil
+}
+
+// parseQIFDate handles Quicken's date quirks: "3/ 5/25", "03/05'2025", "03-05-2025"
+func parseQIFDate(s, format string) (time.Time, error) {
+	s = strings.ReplaceAll(s, " ", "")
+	s = strings.ReplaceAll(s, "'", "/")
+	s = strings.ReplaceAll(s, "-", "/")
+	if format == "" {
+		format = "01/02/2006"
+	}
+
+	for _, f := range []string{format, "1/2/2006", "1/2/06", "2006/01/02"} {
+		if t, err := time.Parse(f, s); err == nil {
+			return t, nil
+		}
+	}
+	return parseDate(s, format)
+}
@@ -0,0 +1,346 @@
+package csvimport
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"lifehub/backend/internal/domain"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+var templateCodeRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// knownMappingFields lists the FieldMapping JSON keys accepted in stored templates
+var knownMappingFields = map[string]bool{
+	"date": true, "description": true, "amount": true, "currency": true,
+	"balance_after": true, "counterparty_name": true, "counterparty_account": true,
+	"operation_type": true, "message": true, "category": true, "external_id": true,
+	"bank_code": true, "variable_symbol": true, "comment": true,
+	"account_number": true, // present in the seeded CSOB definition, ignored by the parser
+}
+
+// humanDateTokens converts user-facing date formats (DD.MM.YYYY) into Go layouts
+var humanDateTokens = strings.NewReplacer(
+	"YYYY", "2006",
+	"YY", "06",
+	"MM", "01",
+	"DD", "02",
+	"HH", "15",
+	"mm", "04",
+	"ss", "05",
+)
+
+// NormalizeDateFormat accepts either a Go layout ("02.01.2006") or a
+// user-facing pattern ("DD.MM.YYYY") and returns the Go layout
+func NormalizeDateFormat(format string) string {
+	if strings.Contains(format, "YY") || strings.Contains(format, "DD") {
+		return humanDateTokens.Replace(format)
+	}
+	return format
+}
+
+// ResolveTemplate returns the template for code, preferring the workspace's
+// custom templates and falling back to the built-ins. Unknown codes resolve
+// to the generic template, matching the previous endpoint behaviour. A
+// stored template that can't be used is an error rather than skipped.
+func ResolveTemplate(workspaceID, code string) (BankTemplate, error) {
+	if workspaceID != "" && code != "" && App != nil {
+		filter := "workspace = {:workspace} && code = {:code}"
+		records, err := App.FindRecordsByFilter("finance_bank_templates", filter, "", 1, 0,
+			map[string]any{"workspace": workspaceID, "code": code})
+		if err != nil {
+			return BankTemplate{}, fmt.Errorf("failed to load template %q: %w", code, err)
+		}
+		if len(records) > 0 {
+			t, err := TemplateFromDomain(TemplateRecordToDomain(records[0]))
+			if err != nil {
+				return BankTemplate{}, fmt.Errorf("template %q is invalid: %w", code, err)
+			}
+			return t, nil
+		}
+	}
+
+	templates := GetTemplates()
+	if t, ok := templates[code]; ok {
+		return t, nil
+	}
+	return templates["generic"], nil
+}
+
+// LoadCustomTemplates returns the workspace's user-defined templates
+func LoadCustomTemplates(workspaceID string) ([]domain.BankTemplate, error) {
+	if App == nil {
+		return nil, fmt.Errorf("PocketBase app not initialized")
+	}
+
+	records, err := App.FindRecordsByFilter("finance_bank_templates", "workspace = {:workspace}", "name", 0, 0,
+		map[string]any{"workspace": workspaceID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load templates: %w", err)
+	}
+
+	templates := []domain.BankTemplate{}
+	for _, r := range records {
+		templates = append(templates, TemplateRecordToDomain(r))
+	}
+	return templates, nil
+}
+
+// TemplateRecordToDomain converts a finance_bank_templates record
+func TemplateRecordToDomain(r *core.Record) domain.BankTemplate {
+	t := domain.BankTemplate{
+		ID:                      r.Id,
+		WorkspaceID:             r.GetString("workspace"),
+		Name:                    r.GetString("name"),
+		Code:                    r.GetString("code"),
+		Format:                  r.GetString("format"),
+		Delimiter:               r.GetString("delimiter"),
+		Encoding:                r.GetString("encoding"),
+		SkipRows:                r.GetInt("skip_rows"),
+		HeaderMarker:            r.GetString("header_marker"),
+		DateFormat:              r.GetString("date_format"),
+		DecimalSeparator:        r.GetString("decimal_separator"),
+		AmountNegativeIsExpense: r.GetBool("amount_negative_is_expense"),
+		StateColumn:             r.GetInt("state_column"),
+		StateRequired:           r.GetString("state_required"),
+		IsSystem:                r.GetBool("is_system"),
+	}
+	decodeJSONField(r, "field_mapping", &t.FieldMapping)
+	decodeJSONField(r, "category_mapping", &t.CategoryMapping)
+	decodeJSONField(r, "merchant_extraction", &t.MerchantExtraction)
+	return t
+}
+
+// ApplyTemplateToRecord copies a template definition onto a finance_bank_templates record
+func ApplyTemplateToRecord(r *core.Record, t domain.BankTemplate) {
+	r.Set("name", t.Name)
+	r.Set("code", t.Code)
+	r.Set("format", t.Format)
+	r.Set("delimiter", t.Delimiter)
+	r.Set("encoding", t.Encoding)
+	r.Set("skip_rows", t.SkipRows)
+	r.Set("header_marker", t.HeaderMarker)
+	r.Set("date_format", t.DateFormat)
+	r.Set("decimal_separator", t.DecimalSeparator)
+	r.Set("amount_negative_is_expense", t.AmountNegativeIsExpense)
+	r.Set("field_mapping", t.FieldMapping)
+	r.Set("category_mapping", t.CategoryMapping)
+	r.Set("merchant_extraction", t.MerchantExtraction)
+	r.Set("state_column", t.StateColumn)
+	r.Set("state_required", t.StateRequired)
+	r.Set("is_system", false)
+	if t.WorkspaceID != "" {
+		r.Set("workspace", t.WorkspaceID)
+	}
+}
+
+// TemplateFromDomain converts a stored template definition into a parser template.
+// Merchant extraction fields may reference a column index ("15") or a mapped
+// field name ("message"), as in the seeded CSOB definition.
+func TemplateFromDomain(d domain.BankTemplate) (BankTemplate, error) {
+	t := BankTemplate{
+		Code:                    d.Code,
+		Name:                    d.Name,
+		Format:                  d.Format,
+		Delimiter:               ',',
+		Encoding:                d.Encoding,
+		SkipRows:                d.SkipRows,
+		DateFormat:              NormalizeDateFormat(d.DateFormat),
+		CategoryMapping:         d.CategoryMapping,
+		AmountNegativeIsExpense: d.AmountNegativeIsExpense,
+		DecimalSeparator:        d.DecimalSeparator,
+		StateColumn:             d.StateColumn,
+		StateRequired:           d.StateRequired,
+		HeaderMarker:            d.HeaderMarker,
+	}
+
+	if d.Delimiter != "" {
+		delimiter := d.Delimiter
+		if delimiter == `\t` || strings.EqualFold(delimiter, "tab") {
+			delimiter = "\t"
+		}
+		if utf8.RuneCountInString(delimiter) != 1 {
+			return t, fmt.Errorf("delimiter must be a single character, got %q", d.Delimiter)
+		}
+		t.Delimiter, _ = utf8.DecodeRuneInString(delimiter)
+	}
+	if t.DecimalSeparator == "" {
+		t.DecimalSeparator = "."
+	}
+
+	// FieldMapping JSON tags match the stored keys
+	raw, _ := json.Marshal(d.FieldMapping)
+	if err := json.Unmarshal(raw, &t.FieldMapping); err != nil {
+		return t, fmt.Errorf("invalid field mapping: %w", err)
+	}
+
+	me := d.MerchantExtraction
+	var err error
+	if t.MerchantExtraction.CardTransactionField, err = resolveColumnRef(me["card_transaction_field"], d.FieldMapping); err != nil {
+		return t, fmt.Errorf("merchant_extraction.card_transaction_field: %w", err)
+	}
+	if t.MerchantExtraction.TransferField, err = resolveColumnRef(me["transfer_field"], d.FieldMapping); err != nil {
+		return t, fmt.Errorf("merchant_extraction.transfer_field: %w", err)
+	}
+	t.MerchantExtraction.CardTransactionPattern = me["card_transaction_pattern"]
+
+	return t, nil
+}
+
+// ValidateTemplate checks a template definition for consistency and returns
+// human-readable problems (empty when valid)
+func ValidateTemplate(d domain.BankTemplate) []string {
+	var problems []string
+
+	if strings.TrimSpace(d.Name) == "" {
+		problems = append(problems, "name is required")
+	}
+	if !templateCodeRe.MatchString(d.Code) {
+		problems = append(problems, "code must be lowercase letters, digits, '-' or '_'")
+	}
+	switch d.Format {
+	case "", FormatCSV, FormatCamt, FormatOFX, FormatQIF:
+	default:
+		problems = append(problems, fmt.Sprintf("unsupported format %q", d.Format))
+	}
+
+	t, err := TemplateFromDomain(d)
+	if err != nil {
+		return append(problems, err.Error())
+	}
+
+	// Non-CSV formats carry their own structure; column settings are ignored
+	if t.Format != "" && t.Format != FormatCSV {
+		return problems
+	}
+
+	if _, err := NormalizeEncoding(t.Encoding); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if t.SkipRows < 0 {
+		problems = append(problems, "skip_rows must not be negative")
+	}
+	if t.DecimalSeparator != "," && t.DecimalSeparator != "." {
+		problems = append(problems, "decimal_separator must be ',' or '.'")
+	}
+	if t.DecimalSeparator == string(t.Delimiter) {
+		problems = append(problems, "decimal_separator must differ from delimiter")
+	}
+	if !isValidDateLayout(t.DateFormat) {
+		problems = append(problems, fmt.Sprintf("date_format %q must contain a year, month and day", d.DateFormat))
+	}
+
+	_, hasDate := d.FieldMapping["date"]
+	_, hasAmount := d.FieldMapping["amount"]
+	if !hasDate {
+		problems = append(problems, "field_mapping.date is required")
+	}
+	if !hasAmount {
+		problems = append(problems, "field_mapping.amount is required")
+	}
+	for field, col := range d.FieldMapping {
+		if !knownMappingFields[field] {
+			problems = append(problems, fmt.Sprintf("field_mapping.%s is not a known field", field))
+		}
+		if col < 0 {
+			problems = append(problems, fmt.Sprintf("field_mapping.%s must not be negative", field))
+		}
+	}
+
+	// Column 0 leaves optional fields unmapped. Only built-in templates read a
+	// leading movement ID, through ExternalIDFirstColumn.
+	if col, ok := d.FieldMapping["external_id"]; ok && col == 0 {
+		problems = append(problems, "field_mapping.external_id can't be column 0, which leaves it unmapped")
+	}
+
+	fm := t.FieldMapping
+	if hasDate && hasAmount && fm.Date == fm.Amount {
+		problems = append(problems, "field_mapping.date and field_mapping.amount must be different columns")
+	}
+	optional := map[string]int{
+		"description": fm.Description, "currency": fm.Currency, "balance_after": fm.BalanceAfter,
+		"counterparty_name": fm.CounterpartyName, "counterparty_account": fm.CounterpartyAccount,
+		"message": fm.Message, "external_id": fm.ExternalID, "comment": fm.Comment,
+	}
+	for field, col := range optional {
+		if col > 0 && (col == fm.Date || col == fm.Amount) {
+			problems = append(problems, fmt.Sprintf("field_mapping.%s overlaps the date or amount column", field))
+		}
+	}
+
+	if t.StateRequired != "" && t.StateColumn <= 0 {
+		problems = append(problems, "state_column is required when state_required is set")
+	}
+
+	me := t.MerchantExtraction
+	if me.CardTransactionPattern != "" {
+		re, err := regexp.Compile(me.CardTransactionPattern)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("merchant_extraction.card_transaction_pattern: %v", err))
+		} else if re.NumSubexp() < 1 {
+			problems = append(problems, "merchant_extraction.card_transaction_pattern needs a capture group for the merchant")
+		}
+		if !mapsColumn(d.FieldMapping, me.CardTransactionField) {
+			problems = append(problems, "merchant_extraction.card_transaction_field must be a mapped column")
+		}
+	}
+
+	return problems
+}
+
+// resolveColumnRef turns a column reference (index or mapped field name) into an index
+func resolveColumnRef(ref string, mapping map[string]int) (int, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return 0, nil
+	}
+	if n, err := strconv.Atoi(ref); err == nil {
+		return n, nil
+	}
+	if col, ok := mapping[ref]; ok {
+		return col, nil
+	}
+	return 0, fmt.Errorf("%q is neither a column index nor a mapped field", ref)
+}
+
+// mapsColumn reports whether the field mapping maps any field to col
+func mapsColumn(mapping map[string]int, col int) bool {
+	for _, c := range mapping {
+		if c == col {
+			return true
+		}
+	}
+	return false
+}
+
+// isValidDateLayout reports whether a Go layout contains year, month and day components
+func isValidDateLayout(layout string) bool {
+	if layout == "" {
+		return false
+	}
+	ref := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+	parsed, err := time.Parse(layout, ref.Format(layout))
+	if err != nil {
+		return false
+	}
+	return parsed.Year() == 2006 && parsed.Month() == time.January && parsed.Day() == 2
+}
+
+// decodeJSONField decodes a json field, tolerating values stored as JSON-encoded strings
+func decodeJSONField(r *core.Record, field string, target any) {
+	raw := []byte(r.GetString(field))
+	if len(raw) == 0 {
+		return
+	}
+	var nested string
+	if json.Unmarshal(raw, &nested) == nil {
+		raw = []byte(nested)
+	}
+	json.Unmarshal(raw, target)
+}
@@ -0,0 +1,242 @@
+package currency
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"lifehub/backend/internal/domain"
+
+	"github.com/pocketbase/pocketbase"
+)
+
+// App holds the PocketBase instance
+var App *pocketbase.PocketBase
+
+// DefaultBase is used for workspaces without a base currency
+const DefaultBase = "CZK"
+
+// Rate is one stored exchange rate: 1 Base = Rate Target on Date
+type Rate struct {
+	Base   string
+	Target string
+	Rate   float64
+	Date   time.Time
+}
+
+type datedRate struct {
+	date time.Time
+	rate float64
+}
+
+// Converter converts amounts with the rate closest to the amount's date
+type Converter struct {
+	Base   string
+	rates  map[string][]datedRate // "EUR>CZK" -> rates sorted by date
+	pivots []string
+}
+
+// NewConverter indexes rates for conversion into base
+func NewConverter(base string, rates []Rate) *Converter {
+	c := &Converter{Base: normalize(base), rates: map[string][]datedRate{}}
+	if c.Base == "" {
+		c.Base = DefaultBase
+	}
+
+	currencies := map[string]bool{}
+	for _, r := range rates {
+		from, to := normalize(r.Base), normalize(r.Target)
+		if from == "" || to == "" || from == to || r.Rate <= 0 {
+			continue
+		}
+		key := from + ">" + to
+		c.rates[key] = append(c.rates[key], datedRate{date: r.Date, rate: r.Rate})
+		currencies[from], currencies[to] = true, true
+	}
+	for key := range c.rates {
+		sort.Slice(c.rates[key], func(i, j int) bool { return c.rates[key][i].date.Before(c.rates[key][j].date) })
+	}
+	for cur := range currencies {
+		c.pivots = append(c.pivots, cur)
+	}
+	sort.Strings(c.pivots)
+	return c
+}
+
+// Convert converts amount between currencies using the rate nearest to on.
+// Pairs without a stored rate are crossed through a common currency. Reports
+// false, with the amount unchanged, when no rate connects the currencies.
+func (c *Converter) Convert(amount float64, from, to string, on time.Time) (float64, bool) {
+	from, to = normalize(from), normalize(to)
+	if from == "" || to == "" || from == to {
+		return amount, true
+	}
+	if rate, ok := c.rate(from, to, on); ok {
+		return amount * rate, true
+	}
+	for _, pivot := range c.pivots {
+		if pivot == from || pivot == to {
+			continue
+		}
+		first, ok := c.rate(from, pivot, on)
+		if !ok {
+			continue
+		}
+		if second, ok := c.rate(pivot, to, on); ok {
+			return amount * first * second, true
+		}
+	}
+	return amount, false
+}
+
+// ToBase converts amount into the base currency
+func (c *Converter) ToBase(amount float64, from string, on time.Time) (float64, bool) {
+	return c.Convert(amount, from, c.Base, on)
+}
+
+// Normalize converts a transaction into the base currency in place, keeping
+// the original amount and currency when they differ. Reports false and leaves
+// the record untouched when no rate is known.
+func (c *Converter) Normalize(tx *domain.FinancialRecord) bool {
+	from := normalize(tx.Currency)
+	if from == "" || from == c.Base {
+		tx.Currency = c.Base
+		return true
+	}
+	converted, ok := c.ToBase(tx.Amount, from, tx.Date)
+	if !ok {
+		return false
+	}
+	tx.OriginalAmount, tx.OriginalCurrency = tx.Amount, from
+	tx.Amount, tx.Currency = converted, c.Base
+	return true
+}
+
+// rate returns the from>to rate nearest to on, falling back to the inverse pair
+func (c *Converter) rate(from, to string, on time.Time) (float64, bool) {
+	if r, ok := nearest(c.rates[from+">"+to], on); ok {
+		return r, true
+	}
+	if r, ok := nearest(c.rates[to+">"+from], on); ok {
+		return 1 / r, true
+	}
+	return 0, false
+}
+
+// nearest picks the rate dated closest to on from rates sorted by date
+func nearest(rates []datedRate, on time.Time) (float64, bool) {
+	if len(rates) == 0 {
+		return 0, false
+	}
+	i := sort.Search(len(rates), func(i int) bool { return !rates[i].date.Before(on) })
+	switch {
+	case i == 0:
+		return rates[0].rate, true
+	case i == len(rates):
+		return rates[len(rates)-1].rate, true
+	}
+	if rates[i].date.Sub(on) < on.Sub(rates[i-1].date) {
+		return rates[i].rate, true
+	}
+	return rates[i-1].rate, true
+}
+
+func normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// LoadRates returns the stored exchange rates quoting any of the given
+// currencies, or every rate when none are given. Cross rates through a pivot
+// currency stay available since both legs quote one of the currencies.
+func LoadRates(currencies ...string) ([]Rate, error) {
+	if App == nil {
+		return nil, fmt.Errorf("PocketBase app not initialized")
+	}
+
+	filter := "rate > 0"
+	params := map[string]any{}
+	if len(currencies) > 0 {
+		var terms []string
+		for i, cur := range currencies {
+			key := "c" + strconv.Itoa(i)
+			terms = append(terms, "base_currency = {:"+key+"} || target_currency = {:"+key+"}")
+			params[key] = normalize(cur)
+		}
+		filter += " && (" + strings.Join(terms, " || ") + ")"
+	}
+
+	records, err := App.FindRecordsByFilter("finance_exchange_rates", filter, "date", 0, 0, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load exchange rates: %w", err)
+	}
+	rates := make([]Rate, 0, len(records))
+	for _, r := range records {
+		rates = append(rates, Rate{
+			Base:   r.GetString("base_currency"),
+			Target: r.GetString("target_currency"),
+			Rate:   r.GetFloat("rate"),
+			Date:   r.GetDateTime("date").Time(),
+		})
+	}
+	return rates, nil
+}
+
+// BaseCurrency returns the workspace's base currency
+func BaseCurrency(workspaceID string) string {
+	if App == nil {
+		return DefaultBase
+	}
+	ws, err := App.FindRecordById("workspaces", workspaceID)
+	if err != nil {
+		return DefaultBase
+	}
+	if base := normalize(ws.GetString("base_currency")); base != "" {
+		return base
+	}
+	return DefaultBase
+}
+
+// ForWorkspace returns a converter into the workspace's base currency. Without
+// stored rates only same-currency amounts convert.
+func ForWorkspace(workspaceID string) *Converter {
+	base := BaseCurrency(workspaceID)
+	rates, err := LoadRates(workspaceCurrencies(workspaceID, base)...)
+	if err != nil {
+		rates = nil
+	}
+	return NewConverter(base, rates)
+}
+
+// workspaceCurrencies returns base followed by the other account currencies, sorted
+func workspaceCurrencies(workspaceID, base string) []string {
+	seen := map[string]bool{base: true}
+	var others []string
+	for _, cur := range AccountCurrencies(workspaceID) {
+		if cur != "" && !seen[cur] {
+			seen[cur] = true
+			others = append(others, cur)
+		}
+	}
+	sort.Strings(others)
+	return append([]string{base}, others...)
+}
+
+// AccountCurrencies maps the workspace's account IDs to their currency.
+// Transactions carry no currency of their own and inherit their account's.
+func AccountCurrencies(workspaceID string) map[string]string {
+	currencies := map[string]string{}
+	if App == nil {
+		return currencies
+	}
+	records, err := App.FindRecordsByFilter("finance_accounts", "workspace = {:workspace}", "", 0, 0,
+		map[string]any{"workspace": workspaceID})
+	if err != nil {
+		return currencies
+	}
+	for _, r := range records {
+		currencies[r.Id] = normalize(r.GetString("currency"))
+	}
+	return currencies
+}
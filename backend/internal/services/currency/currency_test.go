@@ -0,0 +1,191 @@
+package currency
+
+import (
+	"archive/zip"
+	"bytes"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"lifehub/backend/internal/domain"
+)
+
+func TestConverter(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 3, d, 0, 0, 0, 0, time.UTC) }
+	conv := NewConverter("czk", []Rate{
+		{Base: "EUR", Target: "CZK", Rate: 25.0, Date: day(1)},
+		{Base: "EUR", Target: "CZK", Rate: 25.5, Date: day(10)},
+		{Base: "CZK", Target: "USD", Rate: 0.04, Date: day(5)},
+		{Base: "EUR", Target: "GBP", Rate: 0.8, Date: day(5)},
+	})
+
+	tests := []struct {
+		name   string
+		amount float64
+		from   string
+		on     time.Time
+		want   float64
+		ok     bool
+	}{
+		{"same currency", 100, "CZK", day(3), 100, true},
+		{"exact date", 10, "EUR", day(1), 250, true},
+		{"nearest earlier date", 10, "EUR", day(4), 250, true},
+		{"nearest later date", 10, "EUR", day(8), 255, true},
+		{"after last rate", 10, "eur", day(30), 255, true},
+		{"inverse pair", 100, "USD", day(5), 2500, true},
+		{"cross rate", 8, "GBP", day(1), 250, true},
+		{"unknown currency", 100, "PLN", day(5), 100, false},
+	}
+	for _, tt := range tests {
+		got, ok := conv.ToBase(tt.amount, tt.from, tt.on)
+		if ok != tt.ok || math.Abs(got-tt.want) > 0.0001 {
+			t.Errorf("%s: ToBase(%v, %s) = %v, %v; want %v, %v", tt.name, tt.amount, tt.from, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	conv := NewConverter("CZK", []Rate{{Base: "EUR", Target: "CZK", Rate: 25, Date: time.Now()}})
+
+	tx := domain.FinancialRecord{Amount: 4, Currency: "EUR", Date: time.Now()}
+	if !conv.Normalize(&tx) {
+		t.Fatal("Normalize reported a missing rate")
+	}
+	if tx.Amount != 100 || tx.Currency != "CZK" || tx.OriginalAmount != 4 || tx.OriginalCurrency != "EUR" {
+		t.Errorf("converted record = %+v", tx)
+	}
+
+	local := domain.FinancialRecord{Amount: 50, Currency: "CZK"}
+	conv.Normalize(&local)
+	if local.Amount != 50 || local.OriginalCurrency != "" {
+		t.Errorf("base currency record = %+v, want unchanged", local)
+	}
+
+	unknown := domain.FinancialRecord{Amount: 50, Currency: "PLN"}
+	if conv.Normalize(&unknown) || unknown.Amount != 50 || unknown.Currency != "PLN" {
+		t.Errorf("record without rate = %+v, want untouched and false", unknown)
+	}
+}
+
+func loadTestData(t *testing.T, filename string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + filename)
+	if err != nil {
+		t.Fatalf("Failed to load test data %s: %v", filename, err)
+	}
+	return data
+}
+
+// rateOf finds the rate of a pair on a day in parsed rates
+func rateOf(rates []Rate, base, target string, day time.Time) (float64, bool) {
+	for _, r := range rates {
+		if r.Base == base && r.Target == target && r.Date.Equal(day) {
+			return r.Rate, true
+		}
+	}
+	return 0, false
+}
+
+func TestParseRates(t *testing.T) {
+	zipped := new(bytes.Buffer)
+	zw := zip.NewWriter(zipped)
+	w, _ := zw.Create("eurofxref-hist.csv")
+	w.Write(loadTestData(t, "eurofxref-hist.csv"))
+	zw.Close()
+
+	day := func(y, m, d int) time.Time { return time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		file   string
+		data   []byte
+		source string
+		count  int
+		checks []Rate
+	}{
+		{"denni_kurz.txt", loadTestData(t, "denni_kurz.txt"), SourceCNB, 5, []Rate{
+			{Base: "EUR", Target: "CZK", Rate: 24.365, Date: day(2025, 10, 16)},
+			{Base: "JPY", Target: "CZK", Rate: 0.13823, Date: day(2025, 10, 16)}, // quoted per 100 units
+			{Base: "HUF", Target: "CZK", Rate: 0.06195, Date: day(2025, 10, 16)},
+		}},
+		{"rok.txt", loadTestData(t, "rok.txt"), SourceCNB, 6, []Rate{
+			{Base: "EUR", Target: "CZK", Rate: 25.185, Date: day(2025, 1, 3)},
+			{Base: "JPY", Target: "CZK", Rate: 0.15424, Date: day(2025, 1, 2)},
+		}},
+		{"eurofxref-hist.csv", loadTestData(t, "eurofxref-hist.csv"), SourceECB, 6, []Rate{
+			{Base: "EUR", Target: "CZK", Rate: 24.338, Date: day(2025, 10, 15)},
+			{Base: "EUR", Target: "USD", Rate: 1.1689, Date: day(2025, 10, 16)},
+		}},
+		{"eurofxref-hist.zip", zipped.Bytes(), SourceECB, 6, []Rate{
+			{Base: "EUR", Target: "JPY", Rate: 176.24, Date: day(2025, 10, 16)},
+		}},
+		{"eurofxref-hist.xml", loadTestData(t, "eurofxref-hist.xml"), SourceECB, 4, []Rate{
+			{Base: "EUR", Target: "CZK", Rate: 24.327, Date: day(2025, 10, 16)},
+			{Base: "EUR", Target: "USD", Rate: 1.1642, Date: day(2025, 10, 15)},
+		}},
+	}
+
+	for _, tt := range tests {
+		if got := DetectSource(tt.data); got != tt.source {
+			t.Errorf("%s: DetectSource() = %q, want %q", tt.file, got, tt.source)
+		}
+		rates, err := ParseRates("", tt.data)
+		if err != nil {
+			t.Errorf("%s: ParseRates() error = %v", tt.file, err)
+			continue
+		}
+		if len(rates) != tt.count {
+			t.Errorf("%s: got %d rates, want %d", tt.file, len(rates), tt.count)
+		}
+		for _, want := range tt.checks {
+			got, ok := rateOf(rates, want.Base, want.Target, want.Date)
+			if !ok || math.Abs(got-want.Rate) > 1e-9 {
+				t.Errorf("%s: %s>%s on %s = %v (found %v), want %v", tt.file, want.Base, want.Target, want.Date.Format("2006-01-02"), got, ok, want.Rate)
+			}
+		}
+	}
+}
+
+func TestParseCNBInvalid(t *testing.T) {
+	tests := []struct {
+		name, data, err string
+	}{
+		{"empty", "", "CNB file too short"},
+		{"blank first line", "\nzemě|měna|množství|kód|kurz\nEMU|euro|1|EUR|24,365", "invalid CNB header"},
+		{"whitespace first line", " \t\nEMU|euro|1|EUR|24,365", "invalid CNB header"},
+		{"header only", "16.10.2025 #201\nzemě|měna|množství|kód|kurz", "no rates found in CNB file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCNB([]byte(tt.data)); err == nil || err.Error() != tt.err {
+				t.Errorf("ParseCNB() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestMissingRates(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 10, d, 0, 0, 0, 0, time.UTC) }
+	rates := []Rate{
+		// Direct CNB fixing on Mon 6th and Tue 7th
+		{Base: "EUR", Target: "CZK", Rate: 24.3, Date: day(6)},
+		{Base: "EUR", Target: "CZK", Rate: 24.4, Date: day(7)},
+		// USD only crossable through EUR on the 7th
+		{Base: "EUR", Target: "USD", Rate: 1.17, Date: day(7)},
+	}
+
+	// Mon 6th to Mon 13th: the weekend is not a gap
+	got := MissingRates(rates, "EUR", "CZK", day(6), day(13))
+	want := []Gap{{Currency: "EUR", From: "2025-10-08", To: "2025-10-13", Days: 4}}
+	if len(got) != 1 || got[0] != want[0] {
+		t.Errorf("EUR gaps = %+v, want %+v", got, want)
+	}
+
+	got = MissingRates(rates, "usd", "CZK", day(6), day(8))
+	want = []Gap{
+		{Currency: "USD", From: "2025-10-06", To: "2025-10-06", Days: 1},
+		{Currency: "USD", From: "2025-10-08", To: "2025-10-08", Days: 1},
+	}
+	if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("USD gaps = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,99 @@
+package currency
+
+import (
+	"fmt"
+	"time"
+)
+
+// Gap is a run of business days without a usable rate for a currency
+type Gap struct {
+	Currency string `json:"currency"`
+	From     string `json:"from"` // 2006-01-02
+	To       string `json:"to"`
+	Days     int    `json:"days"`
+}
+
+// MissingRates lists the weekdays in [from, to] on which currency cannot be
+// converted to base from rates of that same day, either directly or crossed
+// through a common currency. Consecutive days (ignoring weekends) are merged
+// into one gap. Bank holidays show up as one-day gaps since no fixing is
+// published on them.
+func MissingRates(rates []Rate, cur, base string, from, to time.Time) []Gap {
+	cur, base = normalize(cur), normalize(base)
+
+	// day -> currency -> currencies quoted against it that day
+	quoted := map[string]map[string]map[string]bool{}
+	for _, r := range rates {
+		day := r.Date.UTC().Format("2006-01-02")
+		if quoted[day] == nil {
+			quoted[day] = map[string]map[string]bool{}
+		}
+		a, b := normalize(r.Base), normalize(r.Target)
+		if quoted[day][a] == nil {
+			quoted[day][a] = map[string]bool{}
+		}
+		if quoted[day][b] == nil {
+			quoted[day][b] = map[string]bool{}
+		}
+		quoted[day][a][b], quoted[day][b][a] = true, true
+	}
+
+	covered := func(day string) bool {
+		pairs := quoted[day]
+		if pairs[cur][base] {
+			return true
+		}
+		for pivot := range pairs[cur] {
+			if pairs[pivot][base] {
+				return true
+			}
+		}
+		return false
+	}
+
+	gaps := []Gap{}
+	var open *Gap
+	for d := dayStart(from); !d.After(dayStart(to)); d = d.AddDate(0, 0, 1) {
+		if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+			continue
+		}
+		day := d.Format("2006-01-02")
+		if covered(day) {
+			if open != nil {
+				gaps = append(gaps, *open)
+				open = nil
+			}
+			continue
+		}
+		if open == nil {
+			open = &Gap{Currency: cur, From: day}
+		}
+		open.To = day
+		open.Days++
+	}
+	if open != nil {
+		gaps = append(gaps, *open)
+	}
+	return gaps
+}
+
+// FindGaps reports missing rate days in [from, to] for every currency used by
+// the workspace's accounts other than its base currency
+func FindGaps(workspaceID string, from, to time.Time) ([]Gap, error) {
+	if to.Before(from) {
+		return nil, fmt.Errorf("invalid date range")
+	}
+
+	base := BaseCurrency(workspaceID)
+	currencies := workspaceCurrencies(workspaceID, base)
+	rates, err := LoadRates(currencies...)
+	if err != nil {
+		return nil, err
+	}
+
+	gaps := []Gap{}
+	for _, cur := range currencies[1:] {
+		gaps = append(gaps, MissingRates(rates, cur, base, from, to)...)
+	}
+	return gaps, nil
+}
@@ -0,0 +1,459 @@
+package currency
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Rate file sources
+const (
+	SourceCNB = "cnb" // Czech National Bank fixing, CZK per unit(s) of foreign currency
+	SourceECB = "ecb" // European Central Bank reference rates, foreign currency per EUR
+)
+
+// Default download locations, overridable with CNB_RATES_URL and ECB_RATES_URL
+const (
+	defaultCNBURL = "https://www.cnb.cz/cs/financni-trhy/devizovy-trh/kurzy-devizoveho-trhu/kurzy-devizoveho-trhu/denni_kurz.txt"
+	defaultECBURL = "https://www.ecb.europa.eu/stats/eurofxref/eurofxref-hist.xml"
+)
+
+// IngestResult summarizes a rate file import
+type IngestResult struct {
+	Source   string `json:"source"`
+	Parsed   int    `json:"parsed"`
+	Inserted int    `json:"inserted"`
+	Updated  int    `json:"updated"`
+}
+
+// DetectSource guesses the format of a rate file
+func DetectSource(data []byte) string {
+	data = bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	switch {
+	case bytes.HasPrefix(data, []byte("PK")), bytes.HasPrefix(data, []byte("<")), bytes.HasPrefix(data, []byte("Date,")):
+		return SourceECB
+	case bytes.Contains(firstLine(data), []byte("|")) || bytes.Contains(data, []byte("|kurz")):
+		return SourceCNB
+	}
+	return ""
+}
+
+// ParseRates parses a CNB or ECB rate file; an empty source is detected from the content
+func ParseRates(source string, data []byte) ([]Rate, error) {
+	if source == "" {
+		source = DetectSource(data)
+	}
+	switch source {
+	case SourceCNB:
+		return ParseCNB(data)
+	case SourceECB:
+		return ParseECB(data)
+	}
+	return nil, fmt.Errorf("unrecognized rate file format")
+}
+
+// ParseCNB parses the CNB daily fixing (denni_kurz.txt) and the yearly history
+// (rok.txt). Rates are quoted per "množství" units, so 100 JPY = 15,123 CZK
+// becomes 1 JPY = 0.15123 CZK.
+func ParseCNB(data []byte) ([]Rate, error) {
+	lines := strings.Split(strings.ReplaceAll(string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))), "\r\n", "\n"), "\n")
+	if len(lines) < 2 {
+		return nil, fmt.Errorf("CNB file too short")
+	}
+
+	// Yearly history: "Datum|1 AUD|1 BGN|100 JPY|..." followed by one line per day
+	if strings.HasPrefix(strings.TrimSpace(lines[0]), "Datum|") {
+		return parseCNBHistory(lines)
+	}
+
+	// Daily fixing: "16.10.2025 #201", a header, then "země|měna|množství|kód|kurz"
+	header := strings.Fields(lines[0])
+	if len(header) == 0 {
+		return nil, fmt.Errorf("invalid CNB header")
+	}
+	date, err := time.Parse("02.01.2006", header[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid CNB date line %q", lines[0])
+	}
+
+	rates := []Rate{}
+	for _, line := range lines[1:] {
+		cols := strings.Split(strings.TrimSpace(line), "|")
+		if len(cols) != 5 {
+			continue
+		}
+		amount, err := strconv.Atoi(strings.TrimSpace(cols[2]))
+		if err != nil || amount <= 0 {
+			continue // header
+		}
+		rate, err := parseDecimal(cols[4])
+		if err != nil {
+			return nil, fmt.Errorf("invalid CNB rate for %s: %w", cols[3], err)
+		}
+		rates = append(rates, Rate{Base: normalize(cols[3]), Target: "CZK", Rate: rate / float64(amount), Date: date})
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("no rates found in CNB file")
+	}
+	return rates, nil
+}
+
+func parseCNBHistory(lines []string) ([]Rate, error) {
+	type column struct {
+		code   string
+		amount float64
+	}
+	var columns []column
+	rates := []Rate{}
+	for _, line := range lines {
+		cols := strings.Split(strings.TrimSpace(line), "|")
+		if len(cols) < 2 {
+			continue
+		}
+		// The header repeats whenever the currency basket changes during the year
+		if cols[0] == "Datum" {
+			columns = make([]column, len(cols))
+			for i, h := range cols[1:] {
+				parts := strings.Fields(h)
+				if len(parts) != 2 {
+					continue
+				}
+				amount, err := strconv.Atoi(parts[0])
+				if err != nil || amount <= 0 {
+					continue
+				}
+				columns[i+1] = column{code: normalize(parts[1]), amount: float64(amount)}
+			}
+			continue
+		}
+
+		date, err := time.Parse("02.01.2006", cols[0])
+		if err != nil || columns == nil {
+			continue
+		}
+		for i, value := range cols[1:] {
+			if i+1 >= len(columns) || columns[i+1].code == "" {
+				continue
+			}
+			rate, err := parseDecimal(value)
+			if err != nil || rate <= 0 {
+				continue
+			}
+			rates = append(rates, Rate{Base: columns[i+1].code, Target: "CZK", Rate: rate / columns[i+1].amount, Date: date})
+		}
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("no rates found in CNB file")
+	}
+	return rates, nil
+}
+
+// ParseECB parses the ECB reference rates as CSV (eurofxref-hist.csv, also
+// inside the published zip) or XML (eurofxref-hist.xml, eurofxref-daily.xml)
+func ParseECB(data []byte) ([]Rate, error) {
+	if bytes.HasPrefix(data, []byte("PK")) {
+		unzipped, err := firstZipEntry(data)
+		if err != nil {
+			return nil, err
+		}
+		data = unzipped
+	}
+	data = bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if bytes.HasPrefix(data, []byte("<")) {
+		return parseECBXML(data)
+	}
+	return parseECBCSV(data)
+}
+
+func parseECBCSV(data []byte) ([]Rate, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid ECB CSV: %w", err)
+	}
+	if len(records) < 2 || !strings.EqualFold(strings.TrimSpace(records[0][0]), "Date") {
+		return nil, fmt.Errorf("ECB CSV header not found")
+	}
+
+	header := records[0]
+	rates := []Rate{}
+	for _, row := range records[1:] {
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(row[0]))
+		if err != nil {
+			continue
+		}
+		for i := 1; i < len(row) && i < len(header); i++ {
+			code := normalize(header[i])
+			rate, err := strconv.ParseFloat(strings.TrimSpace(row[i]), 64)
+			if code == "" || err != nil || rate <= 0 { // "N/A" for discontinued currencies
+				continue
+			}
+			rates = append(rates, Rate{Base: "EUR", Target: code, Rate: rate, Date: date})
+		}
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("no rates found in ECB file")
+	}
+	return rates, nil
+}
+
+// ecbEnvelope mirrors <gesmes:Envelope><Cube><Cube time=""><Cube currency="" rate=""/>
+type ecbEnvelope struct {
+	Days []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string `xml:"currency,attr"`
+			Rate     string `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+func parseECBXML(data []byte) ([]Rate, error) {
+	var envelope ecbEnvelope
+	if err := xml.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("invalid ECB XML: %w", err)
+	}
+
+	rates := []Rate{}
+	for _, day := range envelope.Days {
+		date, err := time.Parse("2006-01-02", day.Time)
+		if err != nil {
+			continue
+		}
+		for _, r := range day.Rates {
+			rate, err := strconv.ParseFloat(r.Rate, 64)
+			if err != nil || rate <= 0 {
+				continue
+			}
+			rates = append(rates, Rate{Base: "EUR", Target: normalize(r.Currency), Rate: rate, Date: date})
+		}
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("no rates found in ECB file")
+	}
+	return rates, nil
+}
+
+// SourceURL returns the configured download URL of a rate source
+func SourceURL(source string) (string, error) {
+	switch source {
+	case SourceCNB:
+		if url := os.Getenv("CNB_RATES_URL"); url != "" {
+			return url, nil
+		}
+		return defaultCNBURL, nil
+	case SourceECB:
+		if url := os.Getenv("ECB_RATES_URL"); url != "" {
+			return url, nil
+		}
+		return defaultECBURL, nil
+	}
+	return "", fmt.Errorf("unknown rate source %q", source)
+}
+
+// FetchRates downloads the rate file of a source from its configured URL
+func FetchRates(source string) ([]byte, error) {
+	url, err := SourceURL(source)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download rates: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download rates: %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+}
+
+// RatesChanged, when set, is called after UpsertRates stored new or changed
+// rates, with the earliest date whose conversion they can change, or zero
+// when they can change every date
+var RatesChanged func(from time.Time)
+
+// UpsertRates stores rates in finance_exchange_rates, one record per currency
+// pair and day, updating rates that changed
+func UpsertRates(rates []Rate) (inserted, updated int, err error) {
+	if App == nil {
+		return 0, 0, fmt.Errorf("PocketBase app not initialized")
+	}
+	if len(rates) == 0 {
+		return 0, 0, nil
+	}
+
+	from, to := rates[0].Date, rates[0].Date
+	for _, r := range rates {
+		if r.Date.Before(from) {
+			from = r.Date
+		}
+		if r.Date.After(to) {
+			to = r.Date
+		}
+	}
+
+	var changedFrom time.Time
+	err = App.RunInTransaction(func(txApp core.App) error {
+		collection, err := txApp.FindCollectionByNameOrId("finance_exchange_rates")
+		if err != nil {
+			return fmt.Errorf("finance_exchange_rates collection not found: %w", err)
+		}
+
+		existing, err := txApp.FindRecordsByFilter("finance_exchange_rates", "date >= {:from} && date <= {:to}", "", 0, 0,
+			map[string]any{
+				"from": dayStart(from).Format("2006-01-02 15:04:05"),
+				"to":   dayStart(to).Add(24*time.Hour - time.Second).Format("2006-01-02 15:04:05"),
+			})
+		if err != nil {
+			return fmt.Errorf("failed to load exchange rates: %w", err)
+		}
+		byKey := make(map[string]*core.Record, len(existing))
+		for _, r := range existing {
+			byKey[rateKey(r.GetString("base_currency"), r.GetString("target_currency"), r.GetDateTime("date").Time())] = r
+		}
+
+		changed := map[string]bool{} // pairs with a new or changed rate
+		var first time.Time
+		for _, rate := range rates {
+			key := rateKey(rate.Base, rate.Target, rate.Date)
+			record, ok := byKey[key]
+			if ok {
+				if record.GetFloat("rate") == rate.Rate {
+					continue
+				}
+				updated++
+			} else {
+				record = core.NewRecord(collection)
+				record.Set("base_currency", normalize(rate.Base))
+				record.Set("target_currency", normalize(rate.Target))
+				record.Set("date", dayStart(rate.Date))
+				byKey[key] = record
+				inserted++
+			}
+			record.Set("rate", rate.Rate)
+			if err := txApp.Save(record); err != nil {
+				return fmt.Errorf("failed to save rate %s: %w", key, err)
+			}
+			changed[normalize(rate.Base)+">"+normalize(rate.Target)] = true
+			if first.IsZero() || rate.Date.Before(first) {
+				first = rate.Date
+			}
+		}
+		if len(changed) == 0 {
+			return nil
+		}
+		changedFrom, err = previousRateDate(txApp, changed, first)
+		return err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	if inserted+updated > 0 && RatesChanged != nil {
+		RatesChanged(changedFrom)
+	}
+	return inserted, updated, nil
+}
+
+// previousRateDate returns the latest date before first with a stored rate
+// for every pair in pairs, or zero when a pair has none. Dates in between
+// convert with the nearest rate, which may be one saved from first on.
+func previousRateDate(app core.App, pairs map[string]bool, first time.Time) (time.Time, error) {
+	var rows []struct {
+		Base   string `db:"base_currency"`
+		Target string `db:"target_currency"`
+		Last   string `db:"last"`
+	}
+	err := app.DB().
+		Select("base_currency", "target_currency", "MAX([[date]]) AS last").
+		From("finance_exchange_rates").
+		Where(dbx.NewExp("[[date]] < {:first}", dbx.Params{"first": dayStart(first).Format("2006-01-02 15:04:05")})).
+		GroupBy("base_currency", "target_currency").
+		All(&rows)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to load exchange rates: %w", err)
+	}
+
+	var from time.Time
+	found := 0
+	for _, row := range rows {
+		if !pairs[normalize(row.Base)+">"+normalize(row.Target)] {
+			continue
+		}
+		last, err := types.ParseDateTime(row.Last)
+		if err != nil {
+			return time.Time{}, nil
+		}
+		if found == 0 || last.Time().Before(from) {
+			from = last.Time()
+		}
+		found++
+	}
+	if found < len(pairs) {
+		return time.Time{}, nil
+	}
+	return from, nil
+}
+
+// Ingest parses a rate file and upserts its rates
+func Ingest(source string, data []byte) (*IngestResult, error) {
+	if source == "" {
+		source = DetectSource(data)
+	}
+	rates, err := ParseRates(source, data)
+	if err != nil {
+		return nil, err
+	}
+	inserted, updated, err := UpsertRates(rates)
+	if err != nil {
+		return nil, err
+	}
+	return &IngestResult{Source: source, Parsed: len(rates), Inserted: inserted, Updated: updated}, nil
+}
+
+func rateKey(base, target string, date time.Time) string {
+	return normalize(base) + ">" + normalize(target) + "@" + date.UTC().Format("2006-01-02")
+}
+
+func dayStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// parseDecimal parses "15,123" as well as "15.123"
+func parseDecimal(s string) (float64, error) {
+	return strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(s), ",", "."), 64)
+}
+
+func firstLine(data []byte) []byte {
+	line, _, _ := bufio.NewReader(bytes.NewReader(data)).ReadLine()
+	return line
+}
+
+func firstZipEntry(data []byte) ([]byte, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil || len(archive.File) == 0 {
+		return nil, fmt.Errorf("invalid zip archive")
+	}
+	f, err := archive.File[0].Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", archive.File[0].Name, err)
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
@@ -0,0 +1,260 @@
+package members
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"lifehub/backend/internal/domain"
+	"lifehub/backend/internal/filter"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// App holds the PocketBase instance
+var App *pocketbase.PocketBase
+
+// Role is what a member may do in a workspace. Each role includes the ones
+// ranked below it.
+type Role string
+
+const (
+	Owner  Role = "owner"  // manages members on top of editing
+	Editor Role = "editor" // imports, edits and recategorizes
+	Viewer Role = "viewer" // reads stats, budgets and transactions
+	Device Role = "device" // only feeds e-ink displays
+)
+
+var rank = map[Role]int{Device: 1, Viewer: 2, Editor: 3, Owner: 4}
+
+// ParseRole validates a role name
+func ParseRole(s string) (Role, error) {
+	r := Role(strings.ToLower(strings.TrimSpace(s)))
+	if rank[r] == 0 {
+		return "", fmt.Errorf("unknown role %q, expected owner, editor, viewer or device", s)
+	}
+	return r, nil
+}
+
+// Includes reports whether r grants everything min does
+func (r Role) Includes(min Role) bool {
+	return rank[r] > 0 && rank[r] >= rank[min]
+}
+
+// RoleOf returns the user's role in the workspace: Owner for the workspace
+// owner, the membership role for members and "" for everybody else,
+// including unknown workspaces
+func RoleOf(workspaceID, userID string) (Role, error) {
+	if App == nil {
+		return "", fmt.Errorf("PocketBase app not initialized")
+	}
+	if workspaceID == "" || userID == "" {
+		return "", nil
+	}
+
+	ws, err := App.FindRecordById("workspaces", workspaceID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load workspace: %w", err)
+	}
+	if ws.GetString("owner") == userID {
+		return Owner, nil
+	}
+
+	member, err := filter.Eq("workspace", workspaceID).Eq("user", userID).First(App, "workspace_members")
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load membership: %w", err)
+	}
+	return Role(member.GetString("role")), nil
+}
+
+// Workspaces returns the IDs of the workspaces the user owns or is a member
+// of with at least the role min
+func Workspaces(userID string, min Role) ([]string, error) {
+	if App == nil {
+		return nil, fmt.Errorf("PocketBase app not initialized")
+	}
+
+	owned, err := filter.Eq("owner", userID).Find(App, "workspaces", "name", 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workspaces: %w", err)
+	}
+	ids := make([]string, 0, len(owned))
+	for _, r := range owned {
+		ids = append(ids, r.Id)
+	}
+
+	memberships, err := filter.Eq("user", userID).Find(App, "workspace_members", "", 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load memberships: %w", err)
+	}
+	for _, m := range memberships {
+		if Role(m.GetString("role")).Includes(min) {
+			ids = append(ids, m.GetString("workspace"))
+		}
+	}
+	return ids, nil
+}
+
+// List returns the workspace owner followed by the members and pending
+// invitations
+func List(workspaceID string) ([]domain.WorkspaceMember, error) {
+	if App == nil {
+		return nil, fmt.Errorf("PocketBase app not initialized")
+	}
+
+	ws, err := App.FindRecordById("workspaces", workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("workspace not found")
+	}
+
+	result := []domain.WorkspaceMember{}
+	if owner, err := App.FindRecordById("users", ws.GetString("owner")); err == nil {
+		result = append(result, domain.WorkspaceMember{
+			WorkspaceID: workspaceID,
+			UserID:      owner.Id,
+			Email:       owner.Email(),
+			Name:        owner.GetString("name"),
+			Role:        string(Owner),
+		})
+	}
+
+	records, err := filter.Eq("workspace", workspaceID).Find(App, "workspace_members", "", 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load members: %w", err)
+	}
+	for _, r := range records {
+		m := recordToMember(r)
+		if user, err := App.FindRecordById("users", m.UserID); err == nil {
+			m.Email = user.Email()
+			m.Name = user.GetString("name")
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+// Invite adds a member by user ID or by email. An email no verified user has
+// yet becomes a pending invitation, claimed by ClaimInvitations.
+func Invite(workspaceID, invitedBy, userID, email string, role Role) (*domain.WorkspaceMember, error) {
+	if App == nil {
+		return nil, fmt.Errorf("PocketBase app not initialized")
+	}
+	email = strings.ToLower(strings.TrimSpace(email))
+	if (userID == "") == (email == "") {
+		return nil, fmt.Errorf("either user_id or email required")
+	}
+
+	if email != "" {
+		if user, err := App.FindAuthRecordByEmail("users", email); err == nil && user.Verified() {
+			userID = user.Id
+		}
+	} else if _, err := App.FindRecordById("users", userID); err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	if userID != "" {
+		current, err := RoleOf(workspaceID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if current != "" {
+			return nil, fmt.Errorf("the user is already a member of the workspace")
+		}
+	} else if _, err := filter.Eq("workspace", workspaceID).Eq("email", email).First(App, "workspace_members"); err == nil {
+		return nil, fmt.Errorf("%s is already invited", email)
+	}
+
+	collection, err := App.FindCollectionByNameOrId("workspace_members")
+	if err != nil {
+		return nil, err
+	}
+	record := core.NewRecord(collection)
+	record.Set("workspace", workspaceID)
+	record.Set("user", userID)
+	record.Set("role", string(role))
+	record.Set("invited_by", invitedBy)
+	if userID == "" {
+		record.Set("email", email)
+	}
+	if err := App.Save(record); err != nil {
+		return nil, fmt.Errorf("failed to save member: %w", err)
+	}
+
+	m := recordToMember(record)
+	if m.Email == "" {
+		m.Email = email
+	}
+	return &m, nil
+}
+
+// Find returns a membership or invitation of the workspace
+func Find(workspaceID, memberID string) (*core.Record, error) {
+	if App == nil {
+		return nil, fmt.Errorf("PocketBase app not initialized")
+	}
+	return filter.Eq("workspace", workspaceID).Eq("id", memberID).First(App, "workspace_members")
+}
+
+// SetRole changes the role of a member
+func SetRole(member *core.Record, role Role) error {
+	member.Set("role", string(role))
+	if err := App.Save(member); err != nil {
+		return fmt.Errorf("failed to save member: %w", err)
+	}
+	return nil
+}
+
+// ClaimInvitations turns the pending invitations sent to email into
+// memberships of the user. Only call it once the user verified email.
+func ClaimInvitations(userID, email string) error {
+	if App == nil {
+		return fmt.Errorf("PocketBase app not initialized")
+	}
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return nil
+	}
+
+	pending, err := filter.Eq("email", email).Eq("user", "").Find(App, "workspace_members", "", 0, 0)
+	if err != nil {
+		return fmt.Errorf("failed to load invitations: %w", err)
+	}
+	for _, r := range pending {
+		// Already in the workspace through another invitation or as its owner
+		role, err := RoleOf(r.GetString("workspace"), userID)
+		if err != nil {
+			return err
+		}
+		if role != "" {
+			if err := App.Delete(r); err != nil {
+				return fmt.Errorf("failed to drop invitation %s: %w", r.Id, err)
+			}
+			continue
+		}
+		r.Set("user", userID)
+		r.Set("email", "")
+		if err := App.Save(r); err != nil {
+			return fmt.Errorf("failed to claim invitation %s: %w", r.Id, err)
+		}
+	}
+	return nil
+}
+
+func recordToMember(r *core.Record) domain.WorkspaceMember {
+	return domain.WorkspaceMember{
+		ID:          r.Id,
+		WorkspaceID: r.GetString("workspace"),
+		UserID:      r.GetString("user"),
+		Email:       r.GetString("email"),
+		Role:        r.GetString("role"),
+		Pending:     r.GetString("user") == "",
+	}
+}
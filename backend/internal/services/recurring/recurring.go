@@ -6,6 +6,8 @@ import (
 	"sort"
 	"time"
 
+	"lifehub/backend/internal/filter"
+
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
 )
@@ -81,12 +83,10 @@ func DetectRecurring(workspaceID string, accountID string, minOccurrences int) (
 
 // getTransactionsByMerchant groups transactions by merchant
 func getTransactionsByMerchant(workspaceID, accountID string) ([]TransactionGroup, error) {
-	filter := fmt.Sprintf("workspace = '%s' && merchant != '' && type = 'expense'", workspaceID)
-	if accountID != "" {
-		filter = fmt.Sprintf("workspace = '%s' && account = '%s' && merchant != '' && type = 'expense'", workspaceID, accountID)
-	}
-
-	records, err := App.FindRecordsByFilter("finance_transactions", filter, "-date", 1000, 0)
+	records, err := filter.Eq("workspace", workspaceID).
+		EqIf("account", accountID).
+		Where("merchant != '' && type = 'expense'").
+		Find(App, "finance_transactions", "-date", 1000, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -314,10 +314,10 @@ func GetUpcomingPayments(workspaceID string, daysAhead int) ([]map[string]any, e
 	}
 
 	cutoff := time.Now().AddDate(0, 0, daysAhead)
-	filter := fmt.Sprintf("workspace = '%s' && status = 'active' && next_due <= '%s'",
-		workspaceID, cutoff.Format("2006-01-02 15:04:05"))
-
-	records, err := App.FindRecordsByFilter("finance_recurring", filter, "next_due", 50, 0)
+	records, err := filter.Eq("workspace", workspaceID).
+		Where("status = 'active'").
+		Lte("next_due", cutoff.Format("2006-01-02 15:04:05")).
+		Find(App, "finance_recurring", "next_due", 50, 0)
 	if err != nil {
 		return nil, err
 	}
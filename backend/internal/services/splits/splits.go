@@ -0,0 +1,174 @@
+package splits
+
+import (
+	"fmt"
+	"math"
+
+	"lifehub/backend/internal/domain"
+
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// App holds the PocketBase instance
+var App *pocketbase.PocketBase
+
+// amountTolerance absorbs rounding when comparing split sums to the parent amount
+const amountTolerance = 0.005
+
+// Validate checks that splits are positive and add up to the parent amount.
+// A split needs at least two parts; a single category belongs on the parent.
+func Validate(parentAmount float64, splits []domain.TransactionSplit) error {
+	if len(splits) < 2 {
+		return fmt.Errorf("a split needs at least two parts")
+	}
+
+	var sum float64
+	for i, s := range splits {
+		if s.Amount <= 0 {
+			return fmt.Errorf("split %d: amount must be positive", i+1)
+		}
+		sum += s.Amount
+	}
+
+	parent := math.Abs(parentAmount)
+	if math.Abs(sum-parent) >= amountTolerance {
+		return fmt.Errorf("splits sum to %.2f but the transaction amount is %.2f", sum, parent)
+	}
+	return nil
+}
+
+// Expand returns one record per split allocation of tx, each carrying the
+// split's amount, category and merchant. The parent merchant is kept for
+// splits without their own. Without splits tx is returned unchanged.
+func Expand(tx domain.FinancialRecord, splits []domain.TransactionSplit) []domain.FinancialRecord {
+	if len(splits) == 0 {
+		return []domain.FinancialRecord{tx}
+	}
+
+	allocations := make([]domain.FinancialRecord, 0, len(splits))
+	for _, s := range splits {
+		alloc := tx
+		alloc.SplitID = s.ID
+		alloc.Amount = s.Amount
+		alloc.CategoryID = s.CategoryID
+		if s.MerchantID != "" {
+			alloc.MerchantID = s.MerchantID
+		}
+		if s.Note != "" {
+			alloc.Description = tx.Description + " (" + s.Note + ")"
+		}
+		allocations = append(allocations, alloc)
+	}
+	return allocations
+}
+
+// Get returns the splits of a transaction in their stored order
+func Get(transactionID string) ([]domain.TransactionSplit, error) {
+	if App == nil {
+		return nil, fmt.Errorf("PocketBase app not initialized")
+	}
+
+	records, err := App.FindRecordsByFilter("finance_transaction_splits", "transaction = {:transaction}", "sort_order", 0, 0,
+		map[string]any{"transaction": transactionID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load splits: %w", err)
+	}
+
+	splits := make([]domain.TransactionSplit, 0, len(records))
+	for _, r := range records {
+		splits = append(splits, recordToSplit(r))
+	}
+	return splits, nil
+}
+
+// LoadForWorkspace returns all splits of the workspace keyed by transaction ID
+func LoadForWorkspace(workspaceID string) (map[string][]domain.TransactionSplit, error) {
+	if App == nil {
+		return nil, fmt.Errorf("PocketBase app not initialized")
+	}
+
+	records, err := App.FindRecordsByFilter("finance_transaction_splits", "workspace = {:workspace}", "sort_order", 0, 0,
+		map[string]any{"workspace": workspaceID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load splits: %w", err)
+	}
+
+	byTransaction := make(map[string][]domain.TransactionSplit)
+	for _, r := range records {
+		s := recordToSplit(r)
+		byTransaction[s.TransactionID] = append(byTransaction[s.TransactionID], s)
+	}
+	return byTransaction, nil
+}
+
+// Replace swaps the splits of a transaction for the given ones in one DB
+// transaction. An empty list removes the split and the parent's own category
+// applies again.
+func Replace(transactionID string, splits []domain.TransactionSplit) ([]domain.TransactionSplit, error) {
+	if App == nil {
+		return nil, fmt.Errorf("PocketBase app not initialized")
+	}
+
+	saved := []domain.TransactionSplit{}
+	err := App.RunInTransaction(func(txApp core.App) error {
+		parent, err := txApp.FindRecordById("finance_transactions", transactionID)
+		if err != nil {
+			return fmt.Errorf("transaction %s not found", transactionID)
+		}
+		if len(splits) > 0 {
+			if err := Validate(parent.GetFloat("amount"), splits); err != nil {
+				return err
+			}
+		}
+
+		existing, err := txApp.FindRecordsByFilter("finance_transaction_splits", "transaction = {:transaction}", "", 0, 0,
+			map[string]any{"transaction": transactionID})
+		if err != nil {
+			return fmt.Errorf("failed to load splits: %w", err)
+		}
+		for _, r := range existing {
+			if err := txApp.Delete(r); err != nil {
+				return fmt.Errorf("failed to delete split: %w", err)
+			}
+		}
+
+		if len(splits) == 0 {
+			return nil
+		}
+		collection, err := txApp.FindCollectionByNameOrId("finance_transaction_splits")
+		if err != nil {
+			return fmt.Errorf("finance_transaction_splits collection not found: %w", err)
+		}
+		for i, s := range splits {
+			record := core.NewRecord(collection)
+			record.Set("transaction", transactionID)
+			record.Set("workspace", parent.GetString("workspace"))
+			record.Set("amount", s.Amount)
+			record.Set("category", s.CategoryID)
+			record.Set("merchant", s.MerchantID)
+			record.Set("note", s.Note)
+			record.Set("sort_order", i)
+			if err := txApp.Save(record); err != nil {
+				return fmt.Errorf("failed to save split %d: %w", i+1, err)
+			}
+			saved = append(saved, recordToSplit(record))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+func recordToSplit(r *core.Record) domain.TransactionSplit {
+	return domain.TransactionSplit{
+		ID:            r.Id,
+		TransactionID: r.GetString("transaction"),
+		Amount:        r.GetFloat("amount"),
+		CategoryID:    r.GetString("category"),
+		MerchantID:    r.GetString("merchant"),
+		Note:          r.GetString("note"),
+	}
+}
@@ -0,0 +1,52 @@
+package splits
+
+import (
+	"testing"
+
+	"lifehub/backend/internal/domain"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		parent float64
+		splits []domain.TransactionSplit
+		ok     bool
+	}{
+		{"exact sum", 100, []domain.TransactionSplit{{Amount: 60}, {Amount: 40}}, true},
+		{"rounding", 100, []domain.TransactionSplit{{Amount: 33.33}, {Amount: 33.33}, {Amount: 33.34}}, true},
+		{"negative parent", -100, []domain.TransactionSplit{{Amount: 60}, {Amount: 40}}, true},
+		{"short", 100, []domain.TransactionSplit{{Amount: 60}, {Amount: 30}}, false},
+		{"over", 100, []domain.TransactionSplit{{Amount: 60}, {Amount: 50}}, false},
+		{"zero part", 100, []domain.TransactionSplit{{Amount: 100}, {Amount: 0}}, false},
+		{"single part", 100, []domain.TransactionSplit{{Amount: 100}}, false},
+	}
+	for _, tt := range tests {
+		err := Validate(tt.parent, tt.splits)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: Validate() error = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	tx := domain.FinancialRecord{ID: "tx1", Description: "ALBERT", Amount: 500, CategoryID: "groceries", MerchantID: "albert", IsExpense: true}
+
+	if got := Expand(tx, nil); len(got) != 1 || got[0].Amount != 500 || got[0].SplitID != "" {
+		t.Fatalf("Expand without splits = %+v, want the transaction unchanged", got)
+	}
+
+	got := Expand(tx, []domain.TransactionSplit{
+		{ID: "s1", Amount: 350, CategoryID: "groceries"},
+		{ID: "s2", Amount: 150, CategoryID: "household", MerchantID: "dm", Note: "detergent"},
+	})
+	if len(got) != 2 {
+		t.Fatalf("got %d allocations, want 2", len(got))
+	}
+	if got[0].ID != "tx1" || got[0].SplitID != "s1" || got[0].Amount != 350 || got[0].MerchantID != "albert" {
+		t.Errorf("first allocation = %+v", got[0])
+	}
+	if got[1].CategoryID != "household" || got[1].MerchantID != "dm" || got[1].Description != "ALBERT (detergent)" || !got[1].IsExpense {
+		t.Errorf("second allocation = %+v", got[1])
+	}
+}
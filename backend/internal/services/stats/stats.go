@@ -0,0 +1,243 @@
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"lifehub/backend/internal/domain"
+	"lifehub/backend/internal/services/categories"
+	"lifehub/backend/internal/services/currency"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+)
+
+// App holds the PocketBase instance
+var App *pocketbase.PocketBase
+
+// UncategorizedFilter selects transactions without a category or in the "Uncategorized" one
+const UncategorizedFilter = "__uncategorized"
+
+// Query selects the transactions summarized by Compute
+type Query struct {
+	WorkspaceID string
+	AccountID   string
+	CategoryID  string // includes subcategories; UncategorizedFilter for uncategorized
+	StartDate   string // 2006-01-02
+	EndDate     string
+}
+
+// Sum is the total of one group of transactions or split allocations
+type Sum struct {
+	Account  string  `db:"account"`
+	Type     string  `db:"type"`
+	Category string  `db:"category"`
+	Day      string  `db:"day"` // 2006-01-02, empty for accounts in the base currency
+	Total    float64 `db:"total"`
+}
+
+// dayExpr groups amounts of foreign-currency accounts per day so each day can
+// be converted with its own rate. Base currency amounts need no rate.
+const dayExpr = "(CASE WHEN a.[[currency]] IS NULL OR UPPER(TRIM(a.[[currency]])) IN ('', {:base}) THEN '' ELSE substr(t.[[date]], 1, 10) END)"
+
+// Sums totals the matching transactions by account, type and category in SQL.
+// Transfers are left out and split transactions are counted per split.
+func Sums(q Query, base string) ([]Sum, error) {
+	if App == nil {
+		return nil, fmt.Errorf("PocketBase app not initialized")
+	}
+
+	where := []dbx.Expression{
+		dbx.NewExp("t.[[workspace]] = {:workspace} AND t.[[is_transfer]] = FALSE", dbx.Params{"workspace": q.WorkspaceID}),
+	}
+	if q.AccountID != "" {
+		where = append(where, dbx.NewExp("t.[[account]] = {:account}", dbx.Params{"account": q.AccountID}))
+	}
+	if q.StartDate != "" {
+		where = append(where, dbx.NewExp("t.[[date]] >= {:start}", dbx.Params{"start": q.StartDate}))
+	}
+	if q.EndDate != "" {
+		where = append(where, dbx.NewExp("t.[[date]] <= {:end}", dbx.Params{"end": q.EndDate}))
+	}
+	params := dbx.Params{"base": strings.ToUpper(base)}
+
+	var sums []Sum
+	err := App.DB().
+		Select("t.account AS account", "t.type AS type", "t.category_rel AS category", dayExpr+" AS day", "SUM(t.[[amount]]) AS total").
+		From("finance_transactions t").
+		LeftJoin("finance_accounts a", dbx.NewExp("a.[[id]] = t.[[account]]")).
+		Where(dbx.And(where...)).
+		AndWhere(dbx.NewExp("t.[[id]] NOT IN (SELECT [[transaction]] FROM {{finance_transaction_splits}})")).
+		GroupBy("t.account", "t.type", "t.category_rel", dayExpr).
+		Bind(params).
+		All(&sums)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum transactions: %w", err)
+	}
+
+	var splitSums []Sum
+	err = App.DB().
+		Select("t.account AS account", "t.type AS type", "s.category AS category", dayExpr+" AS day", "SUM(s.[[amount]]) AS total").
+		From("finance_transaction_splits s").
+		InnerJoin("finance_transactions t", dbx.NewExp("t.[[id]] = s.[[transaction]]")).
+		LeftJoin("finance_accounts a", dbx.NewExp("a.[[id]] = t.[[account]]")).
+		Where(dbx.And(where...)).
+		GroupBy("t.account", "t.type", "s.category", dayExpr).
+		Bind(params).
+		All(&splitSums)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum splits: %w", err)
+	}
+
+	return append(sums, splitSums...), nil
+}
+
+// Balances returns income minus expenses per account of the workspace over
+// all of their transactions, transfers included
+func Balances(workspaceID string) (map[string]float64, error) {
+	if App == nil {
+		return nil, fmt.Errorf("PocketBase app not initialized")
+	}
+
+	var rows []struct {
+		Account string  `db:"account"`
+		Total   float64 `db:"total"`
+	}
+	err := App.DB().
+		Select("account", "SUM(CASE WHEN [[type]] = 'expense' THEN -[[amount]] ELSE [[amount]] END) AS total").
+		From("finance_transactions").
+		Where(dbx.NewExp("[[account]] IN (SELECT [[id]] FROM {{finance_accounts}} WHERE [[workspace]] = {:workspace})", dbx.Params{"workspace": workspaceID})).
+		GroupBy("account").
+		All(&rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum balances: %w", err)
+	}
+
+	balances := make(map[string]float64, len(rows))
+	for _, r := range rows {
+		balances[r.Account] = r.Total
+	}
+	return balances, nil
+}
+
+// Compute summarizes the workspace's income, expenses, categories and account
+// balances. Totals are converted to the workspace base currency; ByCurrency
+// keeps the unconverted sums.
+func Compute(q Query) (*domain.FinanceStats, error) {
+	if App == nil {
+		return nil, fmt.Errorf("PocketBase app not initialized")
+	}
+
+	conv := currency.ForWorkspace(q.WorkspaceID)
+	accountCurrencies := currency.AccountCurrencies(q.WorkspaceID)
+	tree, _ := categories.Load(q.WorkspaceID)
+
+	sums, err := Sums(q, conv.Base)
+	if err != nil {
+		return nil, err
+	}
+
+	// Filtering by a parent category includes its subcategories
+	uncatID := ""
+	if q.CategoryID == UncategorizedFilter {
+		uncatID = tree.Find("Uncategorized")
+	}
+	matches := func(catID string) bool {
+		switch q.CategoryID {
+		case "":
+			return true
+		case UncategorizedFilter:
+			return catID == "" || catID == uncatID
+		}
+		return tree.Contains(q.CategoryID, catID)
+	}
+
+	stats := &domain.FinanceStats{
+		ByCategory:   map[string]float64{},
+		Currency:     conv.Base,
+		ByCurrency:   map[string]domain.CurrencyTotals{},
+		MissingRates: []string{},
+	}
+	missing := map[string]bool{}
+	expensesByCategoryID := map[string]float64{}
+
+	for _, s := range sums {
+		if !matches(s.Category) {
+			continue
+		}
+		isExpense := s.Type == "expense"
+
+		cur := accountCurrencies[s.Account]
+		if cur == "" {
+			cur = conv.Base
+		}
+		totals := stats.ByCurrency[cur]
+		if isExpense {
+			totals.Expenses += s.Total
+		} else {
+			totals.Income += s.Total
+		}
+		stats.ByCurrency[cur] = totals
+
+		amount := s.Total
+		if cur != conv.Base {
+			on, _ := time.Parse("2006-01-02", s.Day)
+			converted, ok := conv.ToBase(amount, cur, on)
+			if !ok {
+				missing[cur] = true
+			}
+			amount = converted
+		}
+
+		if !isExpense {
+			stats.TotalIncome += amount
+			continue
+		}
+		stats.TotalExpenses += amount
+		name := tree.Name(s.Category)
+		if name == "" {
+			name = "Uncategorized"
+		}
+		stats.ByCategory[name] += amount
+		expensesByCategoryID[s.Category] += amount
+	}
+	stats.NetBalance = stats.TotalIncome - stats.TotalExpenses
+	stats.CategoryTree = tree.Rollup(expensesByCategoryID)
+
+	balances, err := Balances(q.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+	params := map[string]any{"workspace": q.WorkspaceID}
+	now := time.Now()
+	accounts, _ := App.FindRecordsByFilter("finance_accounts", "workspace = {:workspace}", "name", 0, 0, params)
+	for _, acc := range accounts {
+		balance := acc.GetFloat("initial_balance") + balances[acc.Id]
+		balanceBase, ok := conv.ToBase(balance, acc.GetString("currency"), now)
+		if !ok {
+			missing[strings.ToUpper(acc.GetString("currency"))] = true
+		}
+		stats.AccountBalances = append(stats.AccountBalances, domain.AccountBalance{
+			AccountID:   acc.Id,
+			AccountName: acc.GetString("name"),
+			Balance:     balance,
+			Currency:    acc.GetString("currency"),
+			BalanceBase: balanceBase,
+		})
+	}
+
+	recurring, _ := App.FindRecordsByFilter("finance_recurring", "workspace = {:workspace} && status = 'active'", "", 0, 0, params)
+	for _, r := range recurring {
+		amount, _ := conv.ToBase(r.GetFloat("expected_amount"), accountCurrencies[r.GetString("account")], now)
+		stats.RecurringTotal += amount
+	}
+	stats.RecurringCount = len(recurring)
+
+	for cur := range missing {
+		stats.MissingRates = append(stats.MissingRates, cur)
+	}
+	sort.Strings(stats.MissingRates)
+	return stats, nil
+}
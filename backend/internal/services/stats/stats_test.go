@@ -0,0 +1,324 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"lifehub/backend/internal/domain"
+	"lifehub/backend/internal/services/categories"
+	"lifehub/backend/internal/services/currency"
+	"lifehub/backend/internal/services/splits"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// newTestApp bootstraps a throwaway app with the columns stats reads
+func newTestApp(tb testing.TB) *pocketbase.PocketBase {
+	tb.Helper()
+	app := pocketbase.NewWithConfig(pocketbase.Config{DefaultDataDir: tb.TempDir()})
+	if err := app.Bootstrap(); err != nil {
+		tb.Fatalf("Bootstrap() error = %v", err)
+	}
+	tb.Cleanup(func() { app.ResetBootstrapState() })
+
+	schema := map[string][]core.Field{
+		"workspaces":                 {&core.TextField{Name: "base_currency"}},
+		"finance_accounts":           {&core.TextField{Name: "workspace"}, &core.TextField{Name: "name"}, &core.TextField{Name: "currency"}, &core.NumberField{Name: "initial_balance"}},
+		"finance_categories":         {&core.TextField{Name: "workspace"}, &core.TextField{Name: "name"}, &core.TextField{Name: "parent"}, &core.TextField{Name: "icon"}, &core.TextField{Name: "color"}, &core.BoolField{Name: "is_system"}},
+		"finance_transactions":       {&core.TextField{Name: "workspace"}, &core.TextField{Name: "account"}, &core.TextField{Name: "type"}, &core.NumberField{Name: "amount"}, &core.DateField{Name: "date"}, &core.TextField{Name: "category_rel"}, &core.TextField{Name: "merchant"}, &core.BoolField{Name: "is_transfer"}},
+		"finance_transaction_splits": {&core.TextField{Name: "transaction"}, &core.TextField{Name: "workspace"}, &core.NumberField{Name: "amount"}, &core.TextField{Name: "category"}, &core.TextField{Name: "merchant"}, &core.TextField{Name: "note"}, &core.NumberField{Name: "sort_order"}},
+		"finance_exchange_rates":     {&core.TextField{Name: "base_currency"}, &core.TextField{Name: "target_currency"}, &core.NumberField{Name: "rate"}, &core.DateField{Name: "date"}},
+		"finance_recurring":          {&core.TextField{Name: "workspace"}, &core.TextField{Name: "account"}, &core.TextField{Name: "status"}, &core.NumberField{Name: "expected_amount"}},
+	}
+	// Same indexes as the 5000000080_finance_aggregate_indexes migration
+	indexes := map[string][]string{
+		"finance_transactions":       {"workspace, date", "account, type, amount"},
+		"finance_transaction_splits": {"`transaction`"},
+	}
+	for name, fields := range schema {
+		c := core.NewBaseCollection(name)
+		c.Fields.Add(fields...)
+		for i, columns := range indexes[name] {
+			c.AddIndex(fmt.Sprintf("idx_%s_%d", name, i), false, columns, "")
+		}
+		if err := app.Save(c); err != nil {
+			tb.Fatalf("failed to create %s: %v", name, err)
+		}
+	}
+
+	App, currency.App, categories.App, splits.App = app, app, app, app
+	return app
+}
+
+// seed inserts n transactions, dated at midnight like imported statements,
+// spread over 2024 and 2025 into workspace ws1,
+// plus a few in another workspace that must never be counted
+func seed(tb testing.TB, app *pocketbase.PocketBase, n int) {
+	tb.Helper()
+	day := func(t time.Time) string { return t.Format("2006-01-02 15:04:05.000Z") }
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rng := rand.New(rand.NewSource(1))
+
+	err := app.RunInTransaction(func(txApp core.App) error {
+		db := txApp.DB()
+		insert := func(table string, row dbx.Params) error {
+			_, err := db.Insert(table, row).Execute()
+			return err
+		}
+
+		rows := []struct {
+			table string
+			row   dbx.Params
+		}{
+			{"workspaces", dbx.Params{"id": "ws1", "base_currency": "CZK"}},
+			{"workspaces", dbx.Params{"id": "ws2", "base_currency": "CZK"}},
+			{"finance_accounts", dbx.Params{"id": "czk", "workspace": "ws1", "name": "Checking", "currency": "CZK", "initial_balance": 1000}},
+			{"finance_accounts", dbx.Params{"id": "eur", "workspace": "ws1", "name": "Euro", "currency": "EUR", "initial_balance": 50}},
+			{"finance_accounts", dbx.Params{"id": "usd", "workspace": "ws1", "name": "Dollar", "currency": "usd", "initial_balance": 0}},
+			{"finance_accounts", dbx.Params{"id": "other", "workspace": "ws2", "name": "Other", "currency": "CZK"}},
+			{"finance_categories", dbx.Params{"id": "food", "workspace": "ws1", "name": "Food"}},
+			{"finance_categories", dbx.Params{"id": "groceries", "workspace": "ws1", "name": "Groceries", "parent": "food"}},
+			{"finance_categories", dbx.Params{"id": "transport", "workspace": "ws1", "name": "Transport"}},
+			{"finance_categories", dbx.Params{"id": "uncat", "workspace": "ws1", "name": "Uncategorized"}},
+			{"finance_recurring", dbx.Params{"id": "rec1", "workspace": "ws1", "account": "eur", "status": "active", "expected_amount": 10}},
+			{"finance_recurring", dbx.Params{"id": "rec2", "workspace": "ws1", "account": "czk", "status": "paused", "expected_amount": 99}},
+			{"finance_transactions", dbx.Params{"id": "foreign", "workspace": "ws2", "account": "other", "type": "expense", "amount": 1e6, "date": day(start)}},
+		}
+		for _, r := range rows {
+			if err := insert(r.table, r.row); err != nil {
+				return err
+			}
+		}
+
+		// EUR fixings on Mondays; USD is left without rates
+		for d := start; d.Year() < 2026; d = d.AddDate(0, 0, 7) {
+			if err := insert("finance_exchange_rates", dbx.Params{
+				"id": "rate" + d.Format("20060102"), "base_currency": "EUR", "target_currency": "CZK",
+				"rate": 24 + rng.Float64(), "date": day(d),
+			}); err != nil {
+				return err
+			}
+		}
+
+		accounts := []string{"czk", "czk", "czk", "eur", "usd"}
+		cats := []string{"food", "groceries", "transport", "uncat", ""}
+		for i := 0; i < n; i++ {
+			id := fmt.Sprintf("tx%013d", i)
+			txType := "expense"
+			if i%5 == 0 {
+				txType = "income"
+			}
+			amount := math.Round(rng.Float64()*50000) / 100
+			if err := insert("finance_transactions", dbx.Params{
+				"id":           id,
+				"workspace":    "ws1",
+				"account":      accounts[rng.Intn(len(accounts))],
+				"type":         txType,
+				"amount":       amount,
+				"date":         day(start.AddDate(0, 0, rng.Intn(730))),
+				"category_rel": cats[rng.Intn(len(cats))],
+				"is_transfer":  i%17 == 0,
+			}); err != nil {
+				return err
+			}
+			if i%23 == 0 {
+				first := math.Round(amount*30) / 100
+				for j, part := range []float64{first, amount - first} {
+					if err := insert("finance_transaction_splits", dbx.Params{
+						"id": fmt.Sprintf("sp%011d%02d", i, j), "transaction": id, "workspace": "ws1",
+						"amount": part, "category": cats[(i+j)%len(cats)], "sort_order": j,
+					}); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		tb.Fatalf("failed to seed: %v", err)
+	}
+}
+
+// legacyCompute is the record-by-record computation the stats endpoint used
+// before aggregating in SQL, kept as the reference for Compute
+func legacyCompute(q Query) *domain.FinanceStats {
+	filter := "workspace = {:workspace} && is_transfer != true"
+	params := map[string]any{"workspace": q.WorkspaceID, "account": q.AccountID, "start": q.StartDate, "end": q.EndDate}
+	if q.AccountID != "" {
+		filter += " && account = {:account}"
+	}
+	if q.StartDate != "" {
+		filter += " && date >= {:start}"
+	}
+	if q.EndDate != "" {
+		filter += " && date <= {:end}"
+	}
+	records, _ := App.FindRecordsByFilter("finance_transactions", filter, "-date", 0, 0, params)
+
+	tree, _ := categories.Load(q.WorkspaceID)
+	splitsByTx, _ := splits.LoadForWorkspace(q.WorkspaceID)
+	conv := currency.ForWorkspace(q.WorkspaceID)
+	accountCurrencies := currency.AccountCurrencies(q.WorkspaceID)
+	uncatID := tree.Find("Uncategorized")
+
+	stats := &domain.FinanceStats{ByCategory: map[string]float64{}, ByCurrency: map[string]domain.CurrencyTotals{}}
+	for _, r := range records {
+		tx := domain.FinancialRecord{
+			ID:         r.Id,
+			Amount:     r.GetFloat("amount"),
+			Currency:   accountCurrencies[r.GetString("account")],
+			IsExpense:  r.GetString("type") == "expense",
+			Date:       r.GetDateTime("date").Time(),
+			CategoryID: r.GetString("category_rel"),
+		}
+		for _, alloc := range splits.Expand(tx, splitsByTx[r.Id]) {
+			catID := alloc.CategoryID
+			if q.CategoryID == UncategorizedFilter && catID != "" && catID != uncatID {
+				continue
+			}
+			if q.CategoryID != "" && q.CategoryID != UncategorizedFilter && !tree.Contains(q.CategoryID, catID) {
+				continue
+			}
+			cur := conv.Base
+			if tx.Currency != "" {
+				cur = tx.Currency
+			}
+			totals := stats.ByCurrency[cur]
+			if alloc.IsExpense {
+				totals.Expenses += alloc.Amount
+			} else {
+				totals.Income += alloc.Amount
+			}
+			stats.ByCurrency[cur] = totals
+			conv.Normalize(&alloc)
+			if !alloc.IsExpense {
+				stats.TotalIncome += alloc.Amount
+				continue
+			}
+			stats.TotalExpenses += alloc.Amount
+			name := tree.Name(catID)
+			if name == "" {
+				name = "Uncategorized"
+			}
+			stats.ByCategory[name] += alloc.Amount
+		}
+	}
+
+	accounts, _ := App.FindRecordsByFilter("finance_accounts", "workspace = {:workspace}", "name", 0, 0, params)
+	for _, acc := range accounts {
+		balance := acc.GetFloat("initial_balance")
+		txs, _ := App.FindRecordsByFilter("finance_transactions", "account = {:account}", "", 0, 0, map[string]any{"account": acc.Id})
+		for _, tx := range txs {
+			if tx.GetString("type") == "expense" {
+				balance -= tx.GetFloat("amount")
+			} else {
+				balance += tx.GetFloat("amount")
+			}
+		}
+		stats.AccountBalances = append(stats.AccountBalances, domain.AccountBalance{AccountID: acc.Id, Balance: balance})
+	}
+	return stats
+}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 0.01
+}
+
+func TestCompute(t *testing.T) {
+	seed(t, newTestApp(t), 3000)
+
+	queries := []Query{
+		{WorkspaceID: "ws1"},
+		{WorkspaceID: "ws1", AccountID: "eur"},
+		{WorkspaceID: "ws1", CategoryID: "food"},
+		{WorkspaceID: "ws1", CategoryID: UncategorizedFilter},
+		{WorkspaceID: "ws1", StartDate: "2025-03-01", EndDate: "2025-06-30"},
+		{WorkspaceID: "ws1", AccountID: "czk", CategoryID: "groceries", StartDate: "2024-06-15"},
+	}
+	for _, q := range queries {
+		got, err := Compute(q)
+		if err != nil {
+			t.Fatalf("%+v: Compute() error = %v", q, err)
+		}
+		want := legacyCompute(q)
+
+		if got.TotalIncome == 0 && got.TotalExpenses == 0 {
+			t.Errorf("%+v: no transactions summed", q)
+		}
+		if !approx(got.TotalIncome, want.TotalIncome) || !approx(got.TotalExpenses, want.TotalExpenses) {
+			t.Errorf("%+v: income/expenses = %.2f/%.2f, want %.2f/%.2f", q, got.TotalIncome, got.TotalExpenses, want.TotalIncome, want.TotalExpenses)
+		}
+		if !approx(got.NetBalance, want.TotalIncome-want.TotalExpenses) {
+			t.Errorf("%+v: net balance = %.2f", q, got.NetBalance)
+		}
+		if len(got.ByCategory) != len(want.ByCategory) {
+			t.Errorf("%+v: by_category = %v, want %v", q, got.ByCategory, want.ByCategory)
+		}
+		for name, amount := range want.ByCategory {
+			if !approx(got.ByCategory[name], amount) {
+				t.Errorf("%+v: by_category[%s] = %.2f, want %.2f", q, name, got.ByCategory[name], amount)
+			}
+		}
+		for cur, totals := range want.ByCurrency {
+			if g := got.ByCurrency[cur]; !approx(g.Income, totals.Income) || !approx(g.Expenses, totals.Expenses) {
+				t.Errorf("%+v: by_currency[%s] = %+v, want %+v", q, cur, g, totals)
+			}
+		}
+		if len(got.AccountBalances) != len(want.AccountBalances) {
+			t.Fatalf("%+v: %d account balances, want %d", q, len(got.AccountBalances), len(want.AccountBalances))
+		}
+		for i, b := range want.AccountBalances {
+			if g := got.AccountBalances[i]; g.AccountID != b.AccountID || !approx(g.Balance, b.Balance) {
+				t.Errorf("%+v: balance of %s = %.2f, want %s %.2f", q, g.AccountID, g.Balance, b.AccountID, b.Balance)
+			}
+		}
+	}
+
+	got, _ := Compute(Query{WorkspaceID: "ws1"})
+	if got.Currency != "CZK" || len(got.MissingRates) != 1 || got.MissingRates[0] != "USD" {
+		t.Errorf("currency = %s, missing rates = %v; want CZK, [USD]", got.Currency, got.MissingRates)
+	}
+	if got.RecurringCount != 1 || got.RecurringTotal < 240 || got.RecurringTotal > 250 {
+		t.Errorf("recurring = %.2f over %d, want 10 EUR converted over 1", got.RecurringTotal, got.RecurringCount)
+	}
+	var rolled float64
+	for _, c := range got.CategoryTree {
+		rolled += c.Total
+	}
+	if !approx(rolled, got.TotalExpenses) {
+		t.Errorf("category tree sums to %.2f, want %.2f", rolled, got.TotalExpenses)
+	}
+}
+
+// BenchmarkStats compares the record-by-record computation with the SQL
+// aggregation over 30k transactions:
+//
+//	go test ./internal/services/stats -run '^$' -bench Stats
+func BenchmarkStats(b *testing.B) {
+	seed(b, newTestApp(b), 30000)
+	queries := map[string]Query{
+		"all":   {WorkspaceID: "ws1"},
+		"month": {WorkspaceID: "ws1", StartDate: "2025-05-01", EndDate: "2025-05-31"},
+	}
+
+	for name, q := range queries {
+		b.Run(name+"/records", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				legacyCompute(q)
+			}
+		})
+		b.Run(name+"/aggregate", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := Compute(q); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,400 @@
+package transfers
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"lifehub/backend/internal/filter"
+	"lifehub/backend/internal/services/currency"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// App holds the PocketBase instance
+var App *pocketbase.PocketBase
+
+const (
+	// DefaultWindowDays is how far apart (± days) both legs of a transfer may be booked
+	DefaultWindowDays = 3
+	// FXTolerance is the relative amount difference accepted for cross-currency
+	// transfers, covering the bank's spread over the stored reference rate
+	FXTolerance = 0.03
+)
+
+// Account is an own account transfers can be made between
+type Account struct {
+	ID       string
+	Number   string
+	Currency string
+}
+
+// Transaction simplified for transfer matching
+type Transaction struct {
+	ID                  string
+	AccountID           string
+	Date                time.Time
+	Amount              float64
+	IsExpense           bool
+	CounterpartyAccount string
+	IsTransfer          bool
+}
+
+// Pair is a detected transfer: an expense in one own account and the matching
+// income in another
+type Pair struct {
+	OutgoingID string  `json:"outgoing_id"`
+	IncomingID string  `json:"incoming_id"`
+	Amount     float64 `json:"amount"`
+	DayDiff    int     `json:"day_diff"`
+	Score      float64 `json:"score"` // 0-1
+	Reason     string  `json:"reason"`
+	// Set when a leg names the other account as its counterparty
+	OwnCounterparty bool `json:"own_counterparty"`
+}
+
+// RateFunc converts an amount between currencies at the rate of a date,
+// reporting false when no rate is known
+type RateFunc func(amount float64, from, to string, on time.Time) (float64, bool)
+
+// MatchTransfers pairs opposite-sign transactions of equal amount in different
+// own accounts booked within windowDays of each other. A counterparty account
+// equal to one of our account numbers is the strongest evidence; without it
+// only the amount and date decide. Transactions already marked as transfers
+// are ignored. Each transaction ends up in at most one pair, best score first.
+func MatchTransfers(accounts []Account, transactions []Transaction, windowDays int, convert RateFunc) []Pair {
+	byID := make(map[string]Account, len(accounts))
+	byNumber := make(map[string]string, len(accounts))
+	for _, a := range accounts {
+		byID[a.ID] = a
+		if key := AccountKey(a.Number); key != "" {
+			byNumber[key] = a.ID
+		}
+	}
+
+	var outgoing, incoming []Transaction
+	for _, tx := range transactions {
+		if tx.IsTransfer || tx.AccountID == "" {
+			continue
+		}
+		if tx.IsExpense {
+			outgoing = append(outgoing, tx)
+		} else {
+			incoming = append(incoming, tx)
+		}
+	}
+
+	var candidates []Pair
+	for _, out := range outgoing {
+		for _, in := range incoming {
+			if in.AccountID == out.AccountID {
+				continue
+			}
+			dayDiff := math.Abs(in.Date.Sub(out.Date).Hours()) / 24
+			if dayDiff > float64(windowDays) {
+				continue
+			}
+			if !amountsMatch(out, in, byID, convert) {
+				continue
+			}
+
+			evidence, reason := 0.0, "equal amount"
+			if byNumber[AccountKey(out.CounterpartyAccount)] == in.AccountID {
+				evidence += 0.5
+				reason = "counterparty is own account"
+			}
+			if byNumber[AccountKey(in.CounterpartyAccount)] == out.AccountID {
+				evidence += 0.5
+				reason = "counterparty is own account"
+			}
+
+			closeness := 1 - dayDiff/float64(windowDays+1)
+			candidates = append(candidates, Pair{
+				OutgoingID:      out.ID,
+				IncomingID:      in.ID,
+				Amount:          out.Amount,
+				DayDiff:         int(math.Round(dayDiff)),
+				Score:           math.Round((0.4+0.4*evidence+0.2*closeness)*100) / 100,
+				Reason:          reason,
+				OwnCounterparty: evidence > 0,
+			})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+
+	used := map[string]bool{}
+	pairs := []Pair{}
+	for _, c := range candidates {
+		if used[c.OutgoingID] || used[c.IncomingID] {
+			continue
+		}
+		used[c.OutgoingID], used[c.IncomingID] = true, true
+		pairs = append(pairs, c)
+	}
+	return pairs
+}
+
+// amountsMatch compares both legs in the outgoing account's currency, at
+// the rate of the day the money left
+func amountsMatch(out, in Transaction, accounts map[string]Account, convert RateFunc) bool {
+	fromCur, toCur := accounts[in.AccountID].Currency, accounts[out.AccountID].Currency
+	if fromCur == "" || toCur == "" || strings.EqualFold(fromCur, toCur) {
+		return math.Abs(out.Amount-in.Amount) < 0.005
+	}
+	if convert == nil {
+		return false
+	}
+	converted, ok := convert(in.Amount, fromCur, toCur, out.Date)
+	if !ok || out.Amount == 0 {
+		return false
+	}
+	return math.Abs(converted-out.Amount)/out.Amount <= FXTolerance
+}
+
+// AccountKey normalizes an account number for comparison: spaces and leading
+// zeros are dropped and Czech/Slovak IBANs become "prefix-number/bank"
+func AccountKey(number string) string {
+	n := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(number), " ", ""))
+	if n == "" {
+		return ""
+	}
+
+	if len(n) == 24 && (strings.HasPrefix(n, "CZ") || strings.HasPrefix(n, "SK")) {
+		bank, prefix, base := n[4:8], strings.TrimLeft(n[8:14], "0"), strings.TrimLeft(n[14:], "0")
+		if prefix != "" {
+			return prefix + "-" + base + "/" + bank
+		}
+		return base + "/" + bank
+	}
+
+	if slash := strings.Index(n, "/"); slash > 0 {
+		account, bank := n[:slash], n[slash+1:]
+		prefix, base := "", account
+		if dash := strings.Index(account, "-"); dash >= 0 {
+			prefix, base = strings.TrimLeft(account[:dash], "0"), account[dash+1:]
+		}
+		base = strings.TrimLeft(base, "0")
+		if prefix != "" {
+			return prefix + "-" + base + "/" + bank
+		}
+		return base + "/" + bank
+	}
+	return strings.TrimLeft(n, "0")
+}
+
+// DetectTransfers finds unpaired transfers between the workspace's accounts and,
+// unless dryRun is set, marks both legs of each pair
+func DetectTransfers(workspaceID string, windowDays int, dryRun bool) ([]Pair, error) {
+	if App == nil {
+		return nil, fmt.Errorf("PocketBase app not initialized")
+	}
+
+	pairs, err := detect(workspaceID, windowDays, "")
+	if err != nil || dryRun {
+		return pairs, err
+	}
+	for _, p := range pairs {
+		if err := LinkTransfer(p.OutgoingID, p.IncomingID); err != nil {
+			return nil, err
+		}
+	}
+	return pairs, nil
+}
+
+// DetectImported looks for transfers completed by the transactions of an
+// import. Only pairs a counterparty account confirms are marked; the others
+// are returned as candidates for the user to confirm.
+func DetectImported(workspaceID, importID string) (linked, candidates []Pair, err error) {
+	if App == nil {
+		return nil, nil, fmt.Errorf("PocketBase app not initialized")
+	}
+
+	pairs, err := detect(workspaceID, DefaultWindowDays, importID)
+	if err != nil {
+		return nil, nil, err
+	}
+	linked, candidates = []Pair{}, []Pair{}
+	for _, p := range pairs {
+		if !p.OwnCounterparty {
+			candidates = append(candidates, p)
+			continue
+		}
+		if err := LinkTransfer(p.OutgoingID, p.IncomingID); err != nil {
+			return nil, nil, err
+		}
+		linked = append(linked, p)
+	}
+	return linked, candidates, nil
+}
+
+// detect matches the workspace's unpaired transactions. With an importID it
+// only considers the dates of that import, widened by windowDays, and only
+// returns pairs with a leg from it.
+func detect(workspaceID string, windowDays int, importID string) ([]Pair, error) {
+	accountRecords, err := App.FindRecordsByFilter("finance_accounts", "workspace = {:workspace}", "", 0, 0,
+		map[string]any{"workspace": workspaceID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load accounts: %w", err)
+	}
+	if len(accountRecords) < 2 {
+		return []Pair{}, nil
+	}
+	accounts := make([]Account, 0, len(accountRecords))
+	for _, r := range accountRecords {
+		accounts = append(accounts, Account{
+			ID:       r.Id,
+			Number:   r.GetString("account_number"),
+			Currency: r.GetString("currency"),
+		})
+	}
+
+	txFilter := filter.Eq("workspace", workspaceID).Where("account != '' && is_transfer != true")
+	if importID != "" {
+		var span struct {
+			First string `db:"first"`
+			Last  string `db:"last"`
+		}
+		err := App.DB().Select("MIN([[date]]) AS first", "MAX([[date]]) AS last").
+			From("finance_transactions").
+			Where(dbx.HashExp{"workspace": workspaceID, "import_ref": importID}).
+			One(&span)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the import's dates: %w", err)
+		}
+		first, err1 := types.ParseDateTime(span.First)
+		last, err2 := types.ParseDateTime(span.Last)
+		if span.First == "" || err1 != nil || err2 != nil {
+			return []Pair{}, nil
+		}
+		// Dates compare as strings, so the bare day after the window ends it
+		txFilter.Gte("date", first.Time().AddDate(0, 0, -windowDays).Format("2006-01-02")).
+			Lte("date", last.Time().AddDate(0, 0, windowDays+1).Format("2006-01-02"))
+	}
+	records, err := txFilter.Find(App, "finance_transactions", "date", 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load transactions: %w", err)
+	}
+	imported := make(map[string]bool)
+	transactions := make([]Transaction, 0, len(records))
+	for _, r := range records {
+		if importID != "" && r.GetString("import_ref") == importID {
+			imported[r.Id] = true
+		}
+		transactions = append(transactions, Transaction{
+			ID:                  r.Id,
+			AccountID:           r.GetString("account"),
+			Date:                r.GetDateTime("date").Time(),
+			Amount:              math.Abs(r.GetFloat("amount")),
+			IsExpense:           r.GetString("type") == "expense",
+			CounterpartyAccount: r.GetString("counterparty_account"),
+		})
+	}
+
+	pairs := MatchTransfers(accounts, transactions, windowDays, loadRates())
+	if importID == "" {
+		return pairs, nil
+	}
+	ofImport := []Pair{}
+	for _, p := range pairs {
+		if imported[p.OutgoingID] || imported[p.IncomingID] {
+			ofImport = append(ofImport, p)
+		}
+	}
+	return ofImport, nil
+}
+
+// LinkTransfer marks two transactions as the legs of one transfer: an
+// expense and an income in different accounts. Earlier partners of either
+// leg are unlinked.
+func LinkTransfer(firstID, secondID string) error {
+	if App == nil {
+		return fmt.Errorf("PocketBase app not initialized")
+	}
+
+	return App.RunInTransaction(func(txApp core.App) error {
+		first, err := txApp.FindRecordById("finance_transactions", firstID)
+		if err != nil {
+			return fmt.Errorf("transaction %s not found", firstID)
+		}
+		second, err := txApp.FindRecordById("finance_transactions", secondID)
+		if err != nil {
+			return fmt.Errorf("transaction %s not found", secondID)
+		}
+		switch {
+		case first.Id == second.Id:
+			return fmt.Errorf("a transaction cannot be its own transfer")
+		case first.GetString("workspace") != second.GetString("workspace"):
+			return fmt.Errorf("transactions belong to different workspaces")
+		case first.GetString("account") == second.GetString("account"):
+			return fmt.Errorf("both legs of a transfer cannot be in the same account")
+		case (first.GetString("type") == "expense") == (second.GetString("type") == "expense"):
+			return fmt.Errorf("a transfer needs one expense and one income")
+		}
+
+		for _, leg := range []*core.Record{first, second} {
+			pairID := leg.GetString("transfer_pair")
+			if pairID == "" || pairID == first.Id || pairID == second.Id {
+				continue
+			}
+			if old, err := txApp.FindRecordById("finance_transactions", pairID); err == nil {
+				old.Set("is_transfer", false)
+				old.Set("transfer_pair", "")
+				if err := txApp.Save(old); err != nil {
+					return err
+				}
+			}
+		}
+
+		first.Set("is_transfer", true)
+		first.Set("transfer_pair", second.Id)
+		second.Set("is_transfer", true)
+		second.Set("transfer_pair", first.Id)
+		if err := txApp.Save(first); err != nil {
+			return err
+		}
+		return txApp.Save(second)
+	})
+}
+
+// UnlinkTransfer clears the transfer flag from a transaction and its paired leg
+func UnlinkTransfer(id string) error {
+	if App == nil {
+		return fmt.Errorf("PocketBase app not initialized")
+	}
+
+	return App.RunInTransaction(func(txApp core.App) error {
+		record, err := txApp.FindRecordById("finance_transactions", id)
+		if err != nil {
+			return fmt.Errorf("transaction %s not found", id)
+		}
+		legs := []*core.Record{record}
+		if pairID := record.GetString("transfer_pair"); pairID != "" {
+			if pair, err := txApp.FindRecordById("finance_transactions", pairID); err == nil {
+				legs = append(legs, pair)
+			}
+		}
+		for _, leg := range legs {
+			leg.Set("is_transfer", false)
+			leg.Set("transfer_pair", "")
+			if err := txApp.Save(leg); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// loadRates returns a converter using the stored rate nearest to the date
+func loadRates() RateFunc {
+	rates, err := currency.LoadRates()
+	if err != nil {
+		return nil
+	}
+	return currency.NewConverter(currency.DefaultBase, rates).Convert
+}
@@ -0,0 +1,77 @@
+package transfers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccountKey(t *testing.T) {
+	tests := map[string]string{
+		"CZ65 0800 0000 1920 0014 5399": "19-2000145399/0800",
+		"19-2000145399/0800":            "19-2000145399/0800",
+		"000000-0123456789/0300":        "123456789/0300",
+		"CZ6803000000000123456789":      "123456789/0300",
+		"lt12 1000 0111 0100 1000":      "LT121000011101001000",
+		"":                              "",
+	}
+	for in, want := range tests {
+		if got := AccountKey(in); got != want {
+			t.Errorf("AccountKey(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMatchTransfers(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 3, d, 0, 0, 0, 0, time.UTC) }
+	accounts := []Account{
+		{ID: "csob", Number: "123456789/0300", Currency: "CZK"},
+		{ID: "revolut", Number: "LT12 1000 0111 0100 1000", Currency: "CZK"},
+		{ID: "revolut-eur", Currency: "EUR"},
+	}
+	transactions := []Transaction{
+		// Top-up from CSOB to Revolut, with the counterparty on the outgoing leg
+		{ID: "out1", AccountID: "csob", Date: day(3), Amount: 5000, IsExpense: true, CounterpartyAccount: "LT121000011101001000"},
+		{ID: "in1", AccountID: "revolut", Date: day(4), Amount: 5000},
+		// Unrelated income of the same amount in the same window loses to the evidenced pair
+		{ID: "in-salary", AccountID: "csob", Date: day(4), Amount: 5000},
+		// Cross-currency top-up within the FX tolerance
+		{ID: "out2", AccountID: "csob", Date: day(10), Amount: 2530, IsExpense: true},
+		{ID: "in2", AccountID: "revolut-eur", Date: day(10), Amount: 100},
+		// Converted at the rate of its own day
+		{ID: "out5", AccountID: "csob", Date: day(2), Amount: 3000, IsExpense: true},
+		{ID: "in5", AccountID: "revolut-eur", Date: day(2), Amount: 100},
+		// Too far apart
+		{ID: "out3", AccountID: "revolut", Date: day(1), Amount: 777, IsExpense: true},
+		{ID: "in3", AccountID: "csob", Date: day(20), Amount: 777},
+		// Already paired earlier
+		{ID: "out4", AccountID: "csob", Date: day(12), Amount: 300, IsExpense: true, IsTransfer: true},
+		{ID: "in4", AccountID: "revolut", Date: day(12), Amount: 300},
+	}
+	convert := func(amount float64, from, to string, on time.Time) (float64, bool) {
+		if from != "EUR" || to != "CZK" {
+			return 0, false
+		}
+		if on.Before(day(5)) {
+			return amount * 30, true
+		}
+		return amount * 25, true
+	}
+
+	pairs := MatchTransfers(accounts, transactions, DefaultWindowDays, convert)
+	if len(pairs) != 3 {
+		t.Fatalf("Expected 2 pairs, got %+v", pairs)
+	}
+	got := map[string]Pair{}
+	for _, p := range pairs {
+		got[p.OutgoingID] = p
+	}
+	if p := got["out1"]; p.IncomingID != "in1" || p.Reason != "counterparty is own account" || !p.OwnCounterparty || p.DayDiff != 1 {
+		t.Errorf("Unexpected pair for out1: %+v", p)
+	}
+	if p := got["out2"]; p.IncomingID != "in2" || p.OwnCounterparty {
+		t.Errorf("Expected out2 paired with in2 across currencies, got %+v", p)
+	}
+	if p := got["out5"]; p.IncomingID != "in5" {
+		t.Errorf("Expected out5 paired with in5 at the rate of its day, got %+v", p)
+	}
+}
@@ -0,0 +1,332 @@
+package trends
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"lifehub/backend/internal/domain"
+	"lifehub/backend/internal/filter"
+	"lifehub/backend/internal/services/currency"
+	"lifehub/backend/internal/services/splits"
+
+	"github.com/pocketbase/pocketbase"
+)
+
+// App holds the PocketBase instance
+var App *pocketbase.PocketBase
+
+// Bucket intervals
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+	IntervalYear  = "year"
+)
+
+// Grouping dimensions
+const (
+	GroupCategory = "category"
+	GroupMerchant = "merchant"
+	GroupAccount  = "account"
+)
+
+// DefaultWindow is the number of buckets averaged by the rolling average
+const DefaultWindow = 3
+
+// maxBuckets caps the series length so a day interval over decades stays bounded
+const maxBuckets = 3660
+
+// Query selects the transactions and bucketing of a trend report
+type Query struct {
+	From      time.Time
+	To        time.Time
+	Interval  string
+	GroupBy   string
+	Type      string // expense (default) or income
+	AccountID string
+	Window    int
+}
+
+// Entry is one amount to be bucketed under a group key
+type Entry struct {
+	Key    string
+	Date   time.Time
+	Amount float64
+}
+
+// BucketStart returns the start of the bucket containing t. Weeks start on Monday.
+func BucketStart(t time.Time, interval string) time.Time {
+	y, m, d := t.Date()
+	switch interval {
+	case IntervalDay:
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	case IntervalWeek:
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, d-offset, 0, 0, 0, 0, time.UTC)
+	case IntervalYear:
+		return time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// BucketLabel formats a bucket start: 2025-03-17 for days and weeks, 2025-03 for months, 2025 for years
+func BucketLabel(start time.Time, interval string) string {
+	switch interval {
+	case IntervalMonth:
+		return start.Format("2006-01")
+	case IntervalYear:
+		return start.Format("2006")
+	}
+	return start.Format("2006-01-02")
+}
+
+func nextBucket(start time.Time, interval string) time.Time {
+	switch interval {
+	case IntervalDay:
+		return start.AddDate(0, 0, 1)
+	case IntervalWeek:
+		return start.AddDate(0, 0, 7)
+	case IntervalYear:
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// ValidInterval reports whether interval is a supported bucket size
+func ValidInterval(interval string) bool {
+	switch interval {
+	case IntervalDay, IntervalWeek, IntervalMonth, IntervalYear:
+		return true
+	}
+	return false
+}
+
+// Build buckets entries per key over [from, to]. Every series covers the same
+// buckets, empty ones included, so charts line up. Each point carries the
+// change against the previous bucket and the average of the last window buckets.
+func Build(entries []Entry, interval string, from, to time.Time, window int) map[string][]domain.TrendPoint {
+	if window < 1 {
+		window = 1
+	}
+
+	var labels []string
+	index := map[string]int{}
+	for b := BucketStart(from, interval); !b.After(to) && len(labels) < maxBuckets; b = nextBucket(b, interval) {
+		label := BucketLabel(b, interval)
+		index[label] = len(labels)
+		labels = append(labels, label)
+	}
+
+	sums := map[string][]float64{}
+	for _, e := range entries {
+		i, ok := index[BucketLabel(BucketStart(e.Date, interval), interval)]
+		if !ok {
+			continue
+		}
+		if sums[e.Key] == nil {
+			sums[e.Key] = make([]float64, len(labels))
+		}
+		sums[e.Key][i] += e.Amount
+	}
+
+	series := make(map[string][]domain.TrendPoint, len(sums))
+	for key, amounts := range sums {
+		points := make([]domain.TrendPoint, len(labels))
+		var windowSum float64
+		for i, amount := range amounts {
+			p := domain.TrendPoint{Date: labels[i], Amount: round(amount)}
+			if i > 0 {
+				prev := amounts[i-1]
+				p.Delta = round(amount - prev)
+				if prev != 0 {
+					p.DeltaPct = round((amount - prev) / math.Abs(prev) * 100)
+				}
+			}
+			windowSum += amount
+			n := window
+			if i >= window {
+				windowSum -= amounts[i-window]
+			} else {
+				n = i + 1
+			}
+			p.RollingAvg = round(windowSum / float64(n))
+			points[i] = p
+		}
+		series[key] = points
+	}
+	return series
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// Load computes a trend report for the workspace. Amounts are converted to the
+// base currency, split transactions count per split and transfers are left out.
+// Amounts in a currency without a rate are counted as they are and the
+// currency is listed in MissingRates.
+func Load(workspaceID string, q Query) (*domain.TrendReport, error) {
+	if App == nil {
+		return nil, fmt.Errorf("PocketBase app not initialized")
+	}
+	if q.To.IsZero() {
+		q.To = time.Now()
+	}
+	if q.From.IsZero() {
+		q.From = BucketStart(q.To.AddDate(-1, 0, 0), IntervalMonth)
+	}
+	if q.Interval == "" {
+		q.Interval = IntervalMonth
+	}
+	if !ValidInterval(q.Interval) {
+		return nil, fmt.Errorf("invalid interval %q", q.Interval)
+	}
+	switch q.GroupBy {
+	case "":
+		q.GroupBy = GroupCategory
+	case GroupCategory, GroupMerchant, GroupAccount:
+	default:
+		return nil, fmt.Errorf("invalid group_by %q", q.GroupBy)
+	}
+	switch q.Type {
+	case "":
+		q.Type = "expense"
+	case "expense", "income":
+	default:
+		return nil, fmt.Errorf("invalid type %q", q.Type)
+	}
+	if q.Window == 0 {
+		q.Window = DefaultWindow
+	}
+
+	records, err := filter.Eq("workspace", workspaceID).
+		Eq("type", q.Type).
+		Gte("date", q.From.Format("2006-01-02")).
+		Lte("date", q.To.Format("2006-01-02")+" 23:59:59").
+		Where("is_transfer != true").
+		EqIf("account", q.AccountID).
+		Find(App, "finance_transactions", "date", 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load transactions: %w", err)
+	}
+
+	conv := currency.ForWorkspace(workspaceID)
+	accountCurrencies := currency.AccountCurrencies(workspaceID)
+	splitsByTx, _ := splits.LoadForWorkspace(workspaceID)
+
+	var entries []Entry
+	missing := map[string]bool{}
+	for _, r := range records {
+		tx := domain.FinancialRecord{
+			ID:         r.Id,
+			Amount:     r.GetFloat("amount"),
+			Currency:   accountCurrencies[r.GetString("account")],
+			Date:       r.GetDateTime("date").Time(),
+			AccountID:  r.GetString("account"),
+			CategoryID: r.GetString("category_rel"),
+			MerchantID: r.GetString("merchant"),
+		}
+		for _, alloc := range splits.Expand(tx, splitsByTx[r.Id]) {
+			if !conv.Normalize(&alloc) {
+				missing[strings.ToUpper(alloc.Currency)] = true
+			}
+			key := alloc.CategoryID
+			switch q.GroupBy {
+			case GroupMerchant:
+				key = alloc.MerchantID
+			case GroupAccount:
+				key = alloc.AccountID
+			}
+			entries = append(entries, Entry{Key: key, Date: alloc.Date, Amount: alloc.Amount})
+		}
+	}
+
+	names := groupNames(workspaceID, q.GroupBy)
+	report := &domain.TrendReport{
+		Interval:     q.Interval,
+		GroupBy:      q.GroupBy,
+		Type:         q.Type,
+		Currency:     conv.Base,
+		From:         q.From.Format("2006-01-02"),
+		To:           q.To.Format("2006-01-02"),
+		Series:       []domain.TrendSeries{},
+		MissingRates: []string{},
+	}
+	for cur := range missing {
+		report.MissingRates = append(report.MissingRates, cur)
+	}
+	sort.Strings(report.MissingRates)
+	for key, points := range Build(entries, q.Interval, q.From, q.To, q.Window) {
+		s := domain.TrendSeries{Key: key, Name: names[key], Points: points}
+		if s.Name == "" {
+			s.Name = "Uncategorized"
+			if q.GroupBy != GroupCategory {
+				s.Name = "Unknown"
+			}
+		}
+		for _, p := range points {
+			s.Total += p.Amount
+		}
+		s.Total = round(s.Total)
+		report.Series = append(report.Series, s)
+	}
+	sort.Slice(report.Series, func(i, j int) bool { return report.Series[i].Total > report.Series[j].Total })
+	return report, nil
+}
+
+// ByName indexes series by their display name, the shape of FinanceStats.ByCategoryTrend.
+// Series sharing a name, like an "Uncategorized" category and transactions
+// without one, are added together.
+func ByName(report *domain.TrendReport) map[string][]domain.TrendPoint {
+	byName := make(map[string][]domain.TrendPoint, len(report.Series))
+	for _, s := range report.Series {
+		existing, ok := byName[s.Name]
+		if !ok {
+			byName[s.Name] = s.Points
+			continue
+		}
+		merged := make([]domain.TrendPoint, len(existing))
+		for i := range existing {
+			merged[i] = domain.TrendPoint{
+				Date:       existing[i].Date,
+				Amount:     round(existing[i].Amount + s.Points[i].Amount),
+				Delta:      round(existing[i].Delta + s.Points[i].Delta),
+				RollingAvg: round(existing[i].RollingAvg + s.Points[i].RollingAvg),
+			}
+			if prev := merged[i].Amount - merged[i].Delta; i > 0 && prev != 0 {
+				merged[i].DeltaPct = round(merged[i].Delta / math.Abs(prev) * 100)
+			}
+		}
+		byName[s.Name] = merged
+	}
+	return byName
+}
+
+// groupNames maps category, merchant or account IDs to display names
+func groupNames(workspaceID, groupBy string) map[string]string {
+	collection, field := "finance_categories", "name"
+	switch groupBy {
+	case GroupMerchant:
+		collection, field = "finance_merchants", "display_name"
+	case GroupAccount:
+		collection = "finance_accounts"
+	}
+
+	names := map[string]string{}
+	records, err := App.FindRecordsByFilter(collection, "workspace = {:workspace}", "", 0, 0,
+		map[string]any{"workspace": workspaceID})
+	if err != nil {
+		return names
+	}
+	for _, r := range records {
+		name := r.GetString(field)
+		if name == "" {
+			name = r.GetString("name")
+		}
+		names[r.Id] = name
+	}
+	return names
+}
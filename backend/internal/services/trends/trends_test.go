@@ -0,0 +1,79 @@
+package trends
+
+import (
+	"testing"
+	"time"
+
+	"lifehub/backend/internal/domain"
+)
+
+func TestBucketStart(t *testing.T) {
+	// Wednesday
+	at := time.Date(2025, 3, 19, 15, 30, 0, 0, time.UTC)
+	tests := map[string]string{
+		IntervalDay:   "2025-03-19",
+		IntervalWeek:  "2025-03-17",
+		IntervalMonth: "2025-03",
+		IntervalYear:  "2025",
+	}
+	for interval, want := range tests {
+		if got := BucketLabel(BucketStart(at, interval), interval); got != want {
+			t.Errorf("%s bucket of %v = %s, want %s", interval, at, got, want)
+		}
+	}
+
+	// Sunday belongs to the week started on the previous Monday
+	sunday := time.Date(2025, 3, 23, 0, 0, 0, 0, time.UTC)
+	if got := BucketLabel(BucketStart(sunday, IntervalWeek), IntervalWeek); got != "2025-03-17" {
+		t.Errorf("week of Sunday = %s, want 2025-03-17", got)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	day := func(m, d int) time.Time { return time.Date(2025, time.Month(m), d, 12, 0, 0, 0, time.UTC) }
+	entries := []Entry{
+		{Key: "food", Date: day(1, 5), Amount: 100},
+		{Key: "food", Date: day(1, 20), Amount: 100},
+		{Key: "food", Date: day(3, 2), Amount: 300},
+		{Key: "food", Date: day(4, 10), Amount: 150},
+		{Key: "rent", Date: day(2, 1), Amount: 1000},
+		{Key: "rent", Date: day(6, 1), Amount: 1000}, // outside the range
+	}
+
+	series := Build(entries, IntervalMonth, day(1, 1), day(4, 30), 3)
+	if len(series) != 2 {
+		t.Fatalf("got %d series, want 2", len(series))
+	}
+
+	want := []domain.TrendPoint{
+		{Date: "2025-01", Amount: 200, Delta: 0, DeltaPct: 0, RollingAvg: 200},
+		{Date: "2025-02", Amount: 0, Delta: -200, DeltaPct: -100, RollingAvg: 100},
+		{Date: "2025-03", Amount: 300, Delta: 300, DeltaPct: 0, RollingAvg: 166.67},
+		{Date: "2025-04", Amount: 150, Delta: -150, DeltaPct: -50, RollingAvg: 150},
+	}
+	food := series["food"]
+	if len(food) != len(want) {
+		t.Fatalf("food has %d points, want %d", len(food), len(want))
+	}
+	for i := range want {
+		if food[i] != want[i] {
+			t.Errorf("food[%d] = %+v, want %+v", i, food[i], want[i])
+		}
+	}
+
+	rent := series["rent"]
+	if len(rent) != 4 || rent[1].Amount != 1000 || rent[3].Amount != 0 {
+		t.Errorf("rent = %+v, want 1000 in February only", rent)
+	}
+}
+
+func TestByName(t *testing.T) {
+	report := &domain.TrendReport{Series: []domain.TrendSeries{
+		{Key: "", Name: "Uncategorized", Points: []domain.TrendPoint{{Date: "2025-01", Amount: 10}, {Date: "2025-02", Amount: 30, Delta: 20}}},
+		{Key: "uncat", Name: "Uncategorized", Points: []domain.TrendPoint{{Date: "2025-01", Amount: 10}, {Date: "2025-02", Amount: 10}}},
+	}}
+	got := ByName(report)["Uncategorized"]
+	if len(got) != 2 || got[0].Amount != 20 || got[1].Amount != 40 || got[1].DeltaPct != 100 {
+		t.Errorf("merged series = %+v", got)
+	}
+}
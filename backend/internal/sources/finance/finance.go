@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 	"lifehub/backend/internal/domain"
+	"lifehub/backend/internal/filter"
 	"lifehub/backend/internal/sources"
 	"github.com/pocketbase/pocketbase"
 )
@@ -37,9 +38,9 @@ func (s *FinanceSource) FetchTypedData(ctx context.Context, cfg sources.SourceCo
 		}
 	}
 
-	filter := "source = '" + cfg.SourceID + "'"
-	log.Printf("FinanceSource: Using filter: %s", filter)
-	records, err := App.FindRecordsByFilter("finance_transactions", filter, "-date", 5, 0)
+	f := filter.Eq("source", cfg.SourceID)
+	log.Printf("FinanceSource: Using filter: %s %v", f, f.Params())
+	records, err := f.Find(App, "finance_transactions", "-date", 5, 0)
 	if err != nil {
 		log.Printf("FinanceSource: Error fetching records: %v", err)
 		return domain.Result{}, err
@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 	"lifehub/backend/internal/domain"
+	"lifehub/backend/internal/filter"
 	"lifehub/backend/internal/sources"
 	"github.com/pocketbase/pocketbase"
 )
@@ -44,9 +45,9 @@ func (s *InternalTasksSource) FetchTypedData(ctx context.Context, cfg sources.So
 	}
 
 	// 2. Fetch records
-	filter := "source = '" + cfg.SourceID + "' && completed = false"
-	log.Printf("InternalTasksSource: Using filter: %s", filter)
-	records, err := App.FindRecordsByFilter("tasks", filter, "-priority", 10, 0)
+	f := filter.Eq("source", cfg.SourceID).Where("completed = false")
+	log.Printf("InternalTasksSource: Using filter: %s %v", f, f.Params())
+	records, err := f.Find(App, "tasks", "-priority", 10, 0)
 	if err != nil {
 		log.Printf("InternalTasksSource: Error fetching records: %v", err)
 		return domain.Result{}, err
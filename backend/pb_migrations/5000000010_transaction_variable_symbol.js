@@ -0,0 +1,13 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+    // Variable symbol (VS) from Czech bank statements, used for payment matching
+    const transactions = app.findCollectionByNameOrId('finance_transactions');
+    if (!transactions.fields.getByName('variable_symbol')) {
+        transactions.fields.add(new TextField({ name: 'variable_symbol' }));
+        app.save(transactions);
+    }
+}, (app) => {
+    const transactions = app.findCollectionByNameOrId('finance_transactions');
+    transactions.fields.removeByName('variable_symbol');
+    app.save(transactions);
+});
@@ -0,0 +1,36 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+    const templates = app.findCollectionByNameOrId('finance_bank_templates');
+
+    // Custom templates belong to a workspace; system templates leave it empty
+    templates.fields.add(new RelationField({
+        name: 'workspace',
+        collectionId: 'pbc_workspaces',
+        maxSelect: 1,
+    }));
+    templates.fields.add(new TextField({ name: 'format' }));           // csv (default), camt, ofx, qif
+    templates.fields.add(new TextField({ name: 'header_marker' }));
+    templates.fields.add(new TextField({ name: 'decimal_separator' })); // "," or "."
+    templates.fields.add(new BoolField({ name: 'amount_negative_is_expense' }));
+    templates.fields.add(new NumberField({ name: 'state_column' }));
+    templates.fields.add(new TextField({ name: 'state_required' }));
+
+    templates.listRule = "workspace = '' || workspace.owner = @request.auth.id";
+    templates.viewRule = "workspace = '' || workspace.owner = @request.auth.id";
+    templates.createRule = "workspace.owner = @request.auth.id";
+    templates.updateRule = "workspace.owner = @request.auth.id";
+    templates.deleteRule = "workspace.owner = @request.auth.id";
+    app.save(templates);
+}, (app) => {
+    const templates = app.findCollectionByNameOrId('finance_bank_templates');
+    const fields = ['workspace', 'format', 'header_marker', 'decimal_separator', 'amount_negative_is_expense', 'state_column', 'state_required'];
+    for (const name of fields) {
+        templates.fields.removeByName(name);
+    }
+    templates.listRule = "";
+    templates.viewRule = "";
+    templates.createRule = "@request.auth.id != ''";
+    templates.updateRule = "@request.auth.id != ''";
+    templates.deleteRule = "@request.auth.id != ''";
+    app.save(templates);
+});
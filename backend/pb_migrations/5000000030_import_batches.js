@@ -0,0 +1,33 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+    const imports = app.findCollectionByNameOrId('finance_imports');
+
+    // Batch metadata needed to list and undo imports
+    if (!imports.fields.getByName('imported_at')) {
+        imports.fields.add(new DateField({ name: 'imported_at' }));
+    }
+    if (!imports.fields.getByName('template')) {
+        imports.fields.add(new TextField({ name: 'template' }));
+    }
+    if (!imports.fields.getByName('duplicates_found')) {
+        imports.fields.add(new NumberField({ name: 'duplicates_found' }));
+    }
+
+    imports.listRule = "workspace.owner = @request.auth.id";
+    imports.viewRule = "workspace.owner = @request.auth.id";
+    imports.createRule = "workspace.owner = @request.auth.id";
+    imports.updateRule = "workspace.owner = @request.auth.id";
+    imports.deleteRule = "workspace.owner = @request.auth.id";
+    app.save(imports);
+}, (app) => {
+    const imports = app.findCollectionByNameOrId('finance_imports');
+    for (const name of ['imported_at', 'template', 'duplicates_found']) {
+        imports.fields.removeByName(name);
+    }
+    imports.listRule = "@request.auth.id != ''";
+    imports.viewRule = "@request.auth.id != ''";
+    imports.createRule = "@request.auth.id != ''";
+    imports.updateRule = "@request.auth.id != ''";
+    imports.deleteRule = "@request.auth.id != ''";
+    app.save(imports);
+});
@@ -0,0 +1,13 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+    // External IDs of duplicates merged into a transaction, so re-imports still skip them
+    const transactions = app.findCollectionByNameOrId('finance_transactions');
+    if (!transactions.fields.getByName('merged_external_ids')) {
+        transactions.fields.add(new JSONField({ name: 'merged_external_ids' }));
+        app.save(transactions);
+    }
+}, (app) => {
+    const transactions = app.findCollectionByNameOrId('finance_transactions');
+    transactions.fields.removeByName('merged_external_ids');
+    app.save(transactions);
+});
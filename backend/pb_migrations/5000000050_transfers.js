@@ -0,0 +1,21 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+    // Internal transfers between own accounts are excluded from income/expense stats and budgets
+    const transactions = app.findCollectionByNameOrId('finance_transactions');
+    if (!transactions.fields.getByName('is_transfer')) {
+        transactions.fields.add(new BoolField({ name: 'is_transfer' }));
+    }
+    if (!transactions.fields.getByName('transfer_pair')) {
+        transactions.fields.add(new RelationField({
+            name: 'transfer_pair',
+            collectionId: transactions.id,
+            maxSelect: 1,
+        }));
+    }
+    app.save(transactions);
+}, (app) => {
+    const transactions = app.findCollectionByNameOrId('finance_transactions');
+    transactions.fields.removeByName('transfer_pair');
+    transactions.fields.removeByName('is_transfer');
+    app.save(transactions);
+});
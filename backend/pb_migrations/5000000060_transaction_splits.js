@@ -0,0 +1,29 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+    const transactions = app.findCollectionByNameOrId('finance_transactions');
+
+    // Child allocations of one transaction across several categories; amounts sum to the parent
+    const splits = new Collection({
+        id: 'pbc_finance_tx_splits',
+        name: 'finance_transaction_splits',
+        type: 'base',
+        fields: [
+            { name: 'transaction', type: 'relation', required: true, collectionId: transactions.id, maxSelect: 1, cascadeDelete: true },
+            { name: 'workspace', type: 'relation', required: true, collectionId: 'pbc_workspaces', maxSelect: 1 },
+            { name: 'amount', type: 'number', required: true },
+            { name: 'category', type: 'relation', collectionId: 'pbc_finance_categories', maxSelect: 1 },
+            { name: 'merchant', type: 'relation', collectionId: 'pbc_finance_merchants', maxSelect: 1 },
+            { name: 'note', type: 'text' },
+            { name: 'sort_order', type: 'number' },
+        ],
+        listRule: "workspace.owner = @request.auth.id",
+        viewRule: "workspace.owner = @request.auth.id",
+        createRule: "workspace.owner = @request.auth.id",
+        updateRule: "workspace.owner = @request.auth.id",
+        deleteRule: "workspace.owner = @request.auth.id",
+    });
+    app.save(splits);
+}, (app) => {
+    const splits = app.findCollectionByNameOrId('finance_transaction_splits');
+    app.delete(splits);
+});
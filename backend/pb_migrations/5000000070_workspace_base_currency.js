@@ -0,0 +1,27 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+    // Currency all stats, budgets and net worth are converted to
+    const workspaces = app.findCollectionByNameOrId('workspaces');
+    if (!workspaces.fields.getByName('base_currency')) {
+        workspaces.fields.add(new TextField({ name: 'base_currency', max: 3 }));
+    }
+    app.save(workspaces);
+
+    // Start from the currency the workspace is already displayed in
+    const records = app.findRecordsByFilter('workspaces', "base_currency = ''", '', 0, 0);
+    for (const ws of records) {
+        let currency = 'CZK';
+        try {
+            const settings = JSON.parse(ws.getString('settings') || '{}');
+            if (settings && settings.display_currency) {
+                currency = String(settings.display_currency).toUpperCase();
+            }
+        } catch (_) { }
+        ws.set('base_currency', currency);
+        app.save(ws);
+    }
+}, (app) => {
+    const workspaces = app.findCollectionByNameOrId('workspaces');
+    workspaces.fields.removeByName('base_currency');
+    app.save(workspaces);
+});
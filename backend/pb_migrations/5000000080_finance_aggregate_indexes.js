@@ -0,0 +1,23 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+    // Stats and budgets sum transactions per workspace and date range
+    const transactions = app.findCollectionByNameOrId('finance_transactions');
+    transactions.addIndex('idx_finance_transactions_workspace_date', false, 'workspace, date', '');
+    // Covers account balances so they are summed from the index alone
+    transactions.addIndex('idx_finance_transactions_account', false, 'account, type, amount', '');
+    app.save(transactions);
+
+    // Looked up per transaction to tell split transactions apart
+    const splits = app.findCollectionByNameOrId('finance_transaction_splits');
+    splits.addIndex('idx_finance_transaction_splits_transaction', false, '`transaction`', '');
+    app.save(splits);
+}, (app) => {
+    const transactions = app.findCollectionByNameOrId('finance_transactions');
+    transactions.removeIndex('idx_finance_transactions_workspace_date');
+    transactions.removeIndex('idx_finance_transactions_account');
+    app.save(transactions);
+
+    const splits = app.findCollectionByNameOrId('finance_transaction_splits');
+    splits.removeIndex('idx_finance_transaction_splits_transaction');
+    app.save(splits);
+});
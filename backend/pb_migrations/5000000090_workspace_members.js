@@ -0,0 +1,43 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+    // Users sharing a workspace besides its owner. An invitation by email stays
+    // pending (empty user) until a user with that email signs up.
+    const members = new Collection({
+        id: 'pbc_workspace_members',
+        name: 'workspace_members',
+        type: 'base',
+        fields: [
+            { name: 'workspace', type: 'relation', required: true, collectionId: 'pbc_workspaces', maxSelect: 1, cascadeDelete: true },
+            { name: 'user', type: 'relation', collectionId: '_pb_users_auth_', maxSelect: 1, cascadeDelete: true },
+            { name: 'email', type: 'email' },
+            { name: 'role', type: 'select', required: true, maxSelect: 1, values: ['owner', 'editor', 'viewer', 'device'] },
+            { name: 'invited_by', type: 'relation', collectionId: '_pb_users_auth_', maxSelect: 1 },
+        ],
+        indexes: [
+            "CREATE UNIQUE INDEX idx_workspace_members_user ON workspace_members (`workspace`, `user`) WHERE `user` != ''",
+            "CREATE UNIQUE INDEX idx_workspace_members_email ON workspace_members (`workspace`, `email`) WHERE `email` != ''",
+            "CREATE INDEX idx_workspace_members_member ON workspace_members (`user`)",
+        ],
+        // Members see each other; changes go through /api/workspaces/{id}/members
+        listRule: "user = @request.auth.id || workspace.owner = @request.auth.id || workspace.workspace_members_via_workspace.user ?= @request.auth.id",
+        viewRule: "user = @request.auth.id || workspace.owner = @request.auth.id || workspace.workspace_members_via_workspace.user ?= @request.auth.id",
+        createRule: null,
+        updateRule: null,
+        deleteRule: null,
+    });
+    app.save(members);
+
+    // Shared workspaces show up for their members too
+    const workspaces = app.findCollectionByNameOrId('workspaces');
+    workspaces.listRule = "owner = @request.auth.id || workspace_members_via_workspace.user ?= @request.auth.id";
+    workspaces.viewRule = "owner = @request.auth.id || workspace_members_via_workspace.user ?= @request.auth.id";
+    app.save(workspaces);
+}, (app) => {
+    const workspaces = app.findCollectionByNameOrId('workspaces');
+    workspaces.listRule = "owner = @request.auth.id";
+    workspaces.viewRule = "owner = @request.auth.id";
+    app.save(workspaces);
+
+    const members = app.findCollectionByNameOrId('workspace_members');
+    app.delete(members);
+});
@@ -0,0 +1,44 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+    // What an item carries into the next month: nothing, unspent money, overspending or both
+    const items = app.findCollectionByNameOrId('finance_budget_items');
+    if (!items.fields.getByName('rollover')) {
+        items.fields.add(new SelectField({ name: 'rollover', maxSelect: 1, values: ['none', 'surplus', 'deficit', 'both'] }));
+    }
+    app.save(items);
+
+    // Envelope of a budget item per calendar month, kept up to date by the budget status
+    const ledger = new Collection({
+        id: 'pbc_finance_budget_ledger',
+        name: 'finance_budget_ledger',
+        type: 'base',
+        fields: [
+            { name: 'workspace', type: 'relation', required: true, collectionId: 'pbc_workspaces', maxSelect: 1, cascadeDelete: true },
+            { name: 'budget_item', type: 'relation', required: true, collectionId: items.id, maxSelect: 1, cascadeDelete: true },
+            { name: 'year', type: 'number', required: true },
+            { name: 'month', type: 'number', required: true },
+            { name: 'opening', type: 'number' },
+            { name: 'assigned', type: 'number' },
+            { name: 'spent', type: 'number' },
+            { name: 'closing', type: 'number' },
+        ],
+        indexes: [
+            'CREATE UNIQUE INDEX idx_finance_budget_ledger_month ON finance_budget_ledger (`budget_item`, `year`, `month`)',
+            'CREATE INDEX idx_finance_budget_ledger_workspace ON finance_budget_ledger (`workspace`)',
+        ],
+        // Written by the server only
+        listRule: "workspace.owner = @request.auth.id || workspace.workspace_members_via_workspace.user ?= @request.auth.id",
+        viewRule: "workspace.owner = @request.auth.id || workspace.workspace_members_via_workspace.user ?= @request.auth.id",
+        createRule: null,
+        updateRule: null,
+        deleteRule: null,
+    });
+    app.save(ledger);
+}, (app) => {
+    const ledger = app.findCollectionByNameOrId('finance_budget_ledger');
+    app.delete(ledger);
+
+    const items = app.findCollectionByNameOrId('finance_budget_items');
+    items.fields.removeByName('rollover');
+    app.save(items);
+});
@@ -0,0 +1,56 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+    // Items with a higher priority claim the transactions they match first
+    const items = app.findCollectionByNameOrId('finance_budget_items');
+    if (!items.fields.getByName('priority')) {
+        items.fields.add(new NumberField({ name: 'priority' }));
+    }
+    app.save(items);
+
+    // A transaction matched by several items counts toward the first of them, or is split between them
+    const workspaces = app.findCollectionByNameOrId('workspaces');
+    if (!workspaces.fields.getByName('budget_match_mode')) {
+        workspaces.fields.add(new SelectField({ name: 'budget_match_mode', maxSelect: 1, values: ['first', 'split'] }));
+    }
+    app.save(workspaces);
+
+    // Budget item each transaction, or split allocation, counts toward. Automatic
+    // ones follow the match rules; manual ones override them, and a manual one
+    // without a budget item leaves the transaction out of budgets.
+    const transactions = app.findCollectionByNameOrId('finance_transactions');
+    const assignments = new Collection({
+        id: 'pbc_finance_budget_assign',
+        name: 'finance_budget_assignments',
+        type: 'base',
+        fields: [
+            { name: 'workspace', type: 'relation', required: true, collectionId: 'pbc_workspaces', maxSelect: 1, cascadeDelete: true },
+            { name: 'transaction', type: 'relation', required: true, collectionId: transactions.id, maxSelect: 1, cascadeDelete: true },
+            { name: 'split', type: 'text' },
+            { name: 'budget_item', type: 'relation', collectionId: items.id, maxSelect: 1, cascadeDelete: true },
+            { name: 'amount', type: 'number' },
+            { name: 'manual', type: 'bool' },
+        ],
+        indexes: [
+            'CREATE INDEX idx_finance_budget_assignments_tx ON finance_budget_assignments (`transaction`, `split`)',
+            'CREATE INDEX idx_finance_budget_assignments_workspace ON finance_budget_assignments (`workspace`, `manual`)',
+        ],
+        // Changed through /api/finance/transactions/{id}/budget-item
+        listRule: "workspace.owner = @request.auth.id || workspace.workspace_members_via_workspace.user ?= @request.auth.id",
+        viewRule: "workspace.owner = @request.auth.id || workspace.workspace_members_via_workspace.user ?= @request.auth.id",
+        createRule: null,
+        updateRule: null,
+        deleteRule: null,
+    });
+    app.save(assignments);
+}, (app) => {
+    const assignments = app.findCollectionByNameOrId('finance_budget_assignments');
+    app.delete(assignments);
+
+    const workspaces = app.findCollectionByNameOrId('workspaces');
+    workspaces.fields.removeByName('budget_match_mode');
+    app.save(workspaces);
+
+    const items = app.findCollectionByNameOrId('finance_budget_items');
+    items.fields.removeByName('priority');
+    app.save(items);
+});
@@ -0,0 +1,19 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+    // frequency also takes weekly, quarterly, semiannual and custom; a custom one
+    // repeats every frequency_days days. A quarterly or longer amount with a
+    // due_month is expected in its due months instead of spread evenly.
+    const items = app.findCollectionByNameOrId('finance_budget_items');
+    if (!items.fields.getByName('frequency_days')) {
+        items.fields.add(new NumberField({ name: 'frequency_days', min: 0, onlyInt: true }));
+    }
+    if (!items.fields.getByName('due_month')) {
+        items.fields.add(new NumberField({ name: 'due_month', min: 0, max: 12, onlyInt: true }));
+    }
+    app.save(items);
+}, (app) => {
+    const items = app.findCollectionByNameOrId('finance_budget_items');
+    items.fields.removeByName('frequency_days');
+    items.fields.removeByName('due_month');
+    app.save(items);
+});
@@ -0,0 +1,24 @@
+/// <reference path="../pb_data/types.d.ts" />
+migrate((app) => {
+    // match_expression combines conditions on several transaction fields, see
+    // internal/match. An import rule may use one instead of a pattern.
+    for (const name of ['finance_budget_items', 'finance_import_rules']) {
+        const collection = app.findCollectionByNameOrId(name);
+        if (!collection.fields.getByName('match_expression')) {
+            collection.fields.add(new JSONField({ name: 'match_expression' }));
+        }
+        if (name === 'finance_import_rules') {
+            collection.fields.getByName('pattern').required = false;
+        }
+        app.save(collection);
+    }
+}, (app) => {
+    for (const name of ['finance_budget_items', 'finance_import_rules']) {
+        const collection = app.findCollectionByNameOrId(name);
+        collection.fields.removeByName('match_expression');
+        if (name === 'finance_import_rules') {
+            collection.fields.getByName('pattern').required = true;
+        }
+        app.save(collection);
+    }
+});
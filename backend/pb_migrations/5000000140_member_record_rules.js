@@ -0,0 +1,43 @@
+/// <reference path="../pb_data/types.d.ts" />
+const MEMBER = "workspace.owner = @request.auth.id || workspace.workspace_members_via_workspace.user ?= @request.auth.id";
+
+// The @collection conditions match the same membership row, so the role is
+// the user's role in this workspace
+const EDITOR = "(workspace.owner = @request.auth.id || (@collection.workspace_members.workspace ?= workspace && " +
+    "@collection.workspace_members.user ?= @request.auth.id && " +
+    "(@collection.workspace_members.role ?= 'owner' || @collection.workspace_members.role ?= 'editor')))";
+
+// Records stay in their workspace
+const EDITOR_UPDATE = "(@request.body.workspace:isset = false || @request.body.workspace = workspace) && " + EDITOR;
+
+migrate((app) => {
+    // Every member reads the workspace's records through the records API;
+    // only owners and editors change them, as with /api/finance
+    for (const name of ['finance_transactions', 'finance_transaction_splits', 'finance_imports', 'finance_bank_templates']) {
+        const collection = app.findCollectionByNameOrId(name);
+        // System templates have no workspace and are shared by everyone
+        const read = name === 'finance_bank_templates' ? "workspace = '' || " + MEMBER : MEMBER;
+        collection.listRule = read;
+        collection.viewRule = read;
+        collection.createRule = EDITOR;
+        collection.updateRule = EDITOR_UPDATE;
+        collection.deleteRule = EDITOR;
+        app.save(collection);
+    }
+}, (app) => {
+    const rules = {
+        finance_transactions: ["@request.auth.id != ''", "@request.auth.id != ''"],
+        finance_transaction_splits: ["workspace.owner = @request.auth.id", "workspace.owner = @request.auth.id"],
+        finance_imports: ["workspace.owner = @request.auth.id", "workspace.owner = @request.auth.id"],
+        finance_bank_templates: ["workspace = '' || workspace.owner = @request.auth.id", "workspace.owner = @request.auth.id"],
+    };
+    for (const [name, [read, write]] of Object.entries(rules)) {
+        const collection = app.findCollectionByNameOrId(name);
+        collection.listRule = read;
+        collection.viewRule = read;
+        collection.createRule = write;
+        collection.updateRule = write;
+        collection.deleteRule = write;
+        app.save(collection);
+    }
+});
@@ -5,8 +5,13 @@ import { Account, BankTemplate, ImportPreview, ImportResult } from '@/types';
 interface ImportWizardProps {
   accounts: Account[];
   templates: BankTemplate[];
-  onPreview: (file: File, templateCode: string) => Promise<ImportPreview>;
-  onImport: (file: File, accountId: string, templateCode: string) => Promise<ImportResult>;
+  onPreview: (file: File, templateCode: string, accountId?: string) => Promise<ImportPreview>;
+  onImport: (
+    file: File,
+    accountId: string,
+    templateCode: string,
+    onProgress?: (processed: number, total: number) => void
+  ) => Promise<ImportResult>;
   onClose: () => void;
 }
 
@@ -26,6 +31,7 @@ export function ImportWizard({
   const [preview, setPreview] = useState<ImportPreview | null>(null);
   const [result, setResult] = useState<ImportResult | null>(null);
   const [loading, setLoading] = useState(false);
+  const [progress, setProgress] = useState<{ processed: number; total: number } | null>(null);
   const [error, setError] = useState<string | null>(null);
 
   const handleFileChange = useCallback(
@@ -38,7 +44,7 @@ export function ImportWizard({
       setLoading(true);
 
       try {
-        const previewResult = await onPreview(selectedFile, templateCode);
+        const previewResult = await onPreview(selectedFile, templateCode, accountId);
         setPreview(previewResult);
         setStep('preview');
       } catch (err) {
@@ -47,7 +53,7 @@ export function ImportWizard({
         setLoading(false);
       }
     },
-    [templateCode, onPreview]
+    [templateCode, accountId, onPreview]
   );
 
   const handleImport = async () => {
@@ -55,15 +61,19 @@ export function ImportWizard({
 
     setLoading(true);
     setError(null);
+    setProgress(null);
 
     try {
-      const importResult = await onImport(file, accountId, templateCode);
+      const importResult = await onImport(file, accountId, templateCode, (processed, total) =>
+        setProgress({ processed, total })
+      );
       setResult(importResult);
       setStep('result');
     } catch (err) {
       setError(err instanceof Error ? err.message : 'Import failed');
     } finally {
       setLoading(false);
+      setProgress(null);
     }
   };
 
@@ -152,7 +162,7 @@ export function ImportWizard({
             <div className="border-2 border-dashed border-slate-700 rounded-2xl p-8 hover:border-slate-600 transition-colors cursor-pointer text-center">
               <input
                 type="file"
-                accept=".csv"
+                accept=".csv,.json,.xml,.ofx,.qfx,.qif"
                 onChange={handleFileChange}
                 className="hidden"
                 disabled={loading}
@@ -193,6 +203,13 @@ export function ImportWizard({
             </div>
           </div>
 
+          {preview.possible_duplicates && preview.possible_duplicates.length > 0 && (
+            <div className="bg-amber-900/20 border border-amber-800 rounded-xl p-4 text-sm text-amber-300">
+              {preview.possible_duplicates.length} rows look like transactions already in this account
+              (e.g. imported from another export format) and may be duplicates.
+            </div>
+          )}
+
           {/* Transaction preview */}
           <div className="max-h-64 overflow-y-auto bg-slate-800/30 rounded-xl">
             <table className="w-full text-sm">
@@ -231,6 +248,20 @@ export function ImportWizard({
             )}
           </div>
 
+          {progress && progress.total > 0 && (
+            <div className="space-y-1">
+              <div className="h-2 bg-slate-800 rounded-full overflow-hidden">
+                <div
+                  className="h-full bg-blue-600 transition-all"
+                  style={{ width: `${Math.round((progress.processed / progress.total) * 100)}%` }}
+                />
+              </div>
+              <div className="text-xs text-slate-500 text-right">
+                {progress.processed} / {progress.total} rows
+              </div>
+            </div>
+          )}
+
           <div className="flex gap-3">
             <button
               onClick={() => {
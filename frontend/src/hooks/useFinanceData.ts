@@ -7,9 +7,11 @@ import {
   FinanceStats,
   ImportPreview,
   ImportResult,
+  ImportJob,
   ImportRule,
   CategorizationSuggestion,
   FinancialRecord,
+  TransactionSplit,
   InvestmentPortfolio,
   InvestmentSnapshot,
   InvestmentImportResult,
@@ -22,7 +24,11 @@ import {
   Goal,
   NetWorthSnapshot,
   ExchangeRate,
+  ExchangeRateImportResult,
+  ExchangeRateGap,
   RecurringPayment,
+  TrendInterval,
+  TrendReport,
 } from '@/types';
 import { pb, API_BASE } from '@/lib/pocketbase';
 
@@ -210,6 +216,35 @@ export function useFinanceData({ workspaceId, accountId, categoryId, period, dat
     }
   }, [workspaceId, accountId, categoryId, period, dateOffset, customDateRange, getAuthHeaders]);
 
+  const fetchTrends = useCallback(async (options: {
+    interval?: TrendInterval;
+    groupBy?: TrendReport['group_by'];
+    type?: TrendReport['type'];
+    startDate?: string;
+    endDate?: string;
+    window?: number;
+  } = {}): Promise<TrendReport | null> => {
+    if (!workspaceId) return null;
+    const url = new URL(`${API_BASE}/api/finance/trends`);
+    url.searchParams.append('workspace', workspaceId);
+    if (accountId) url.searchParams.append('account', accountId);
+    if (options.interval) url.searchParams.append('interval', options.interval);
+    if (options.groupBy) url.searchParams.append('group_by', options.groupBy);
+    if (options.type) url.searchParams.append('type', options.type);
+    if (options.startDate) url.searchParams.append('start_date', options.startDate);
+    if (options.endDate) url.searchParams.append('end_date', options.endDate);
+    if (options.window) url.searchParams.append('window', String(options.window));
+
+    try {
+      const res = await fetch(url.toString(), { headers: getAuthHeaders() });
+      if (!res.ok) throw new Error('Failed to fetch trends');
+      return await res.json();
+    } catch (err) {
+      console.error('Failed to fetch trends:', err);
+      return null;
+    }
+  }, [workspaceId, accountId, getAuthHeaders]);
+
   // Fetch unfiltered overview stats (no account/category filter, respects date range)
   const fetchOverviewStats = useCallback(async () => {
     if (!workspaceId) return;
@@ -272,6 +307,7 @@ export function useFinanceData({ workspaceId, accountId, categoryId, period, dat
         balance_after: r.balance_after,
         external_id: r.external_id,
         counterparty_account: r.counterparty_account,
+        is_transfer: r.is_transfer,
       })));
     } catch (err) {
       console.error('Failed to fetch overview transactions:', err);
@@ -328,6 +364,7 @@ export function useFinanceData({ workspaceId, accountId, categoryId, period, dat
         balance_after: r.balance_after,
         external_id: r.external_id,
         counterparty_account: r.counterparty_account,
+        is_transfer: r.is_transfer,
       })));
     } catch (err) {
       console.error('Failed to fetch transactions:', err);
@@ -524,7 +561,8 @@ export function useFinanceData({ workspaceId, accountId, categoryId, period, dat
 
   const fetchExchangeRates = useCallback(async () => {
     try {
-      const records = await pb.collection('finance_exchange_rates').getFullList<any>({
+      // Only the latest rates are used for display; the imported history can be large
+      const { items: records } = await pb.collection('finance_exchange_rates').getList<any>(1, 500, {
         sort: '-date',
       });
       setExchangeRates(records.map(r => ({
@@ -538,6 +576,40 @@ export function useFinanceData({ workspaceId, accountId, categoryId, period, dat
     }
   }, []);
 
+  // Imports a CNB/ECB rate file, or downloads the source's configured file when none is given
+  const importExchangeRates = useCallback(async (source: 'cnb' | 'ecb' | '', file?: File): Promise<ExchangeRateImportResult> => {
+    const formData = new FormData();
+    if (source) formData.append('source', source);
+    if (file) formData.append('file', file);
+
+    const res = await fetch(`${API_BASE}/api/finance/exchange-rates/import`, {
+      method: 'POST',
+      headers: { 'Authorization': pb.authStore.token },
+      body: formData,
+    });
+    const data = await res.json();
+    if (!res.ok) throw new Error(data.error || 'Failed to import exchange rates');
+    await fetchExchangeRates();
+    return data;
+  }, [fetchExchangeRates]);
+
+  const fetchExchangeRateGaps = useCallback(async (from?: string, to?: string): Promise<ExchangeRateGap[]> => {
+    if (!workspaceId) return [];
+    try {
+      const url = new URL(`${API_BASE}/api/finance/exchange-rates/gaps`);
+      url.searchParams.append('workspace', workspaceId);
+      if (from) url.searchParams.append('from', from);
+      if (to) url.searchParams.append('to', to);
+
+      const res = await fetch(url.toString(), { headers: getAuthHeaders() });
+      if (!res.ok) throw new Error('Failed to fetch exchange rate gaps');
+      return await res.json();
+    } catch (err) {
+      console.error('Failed to fetch exchange rate gaps:', err);
+      return [];
+    }
+  }, [workspaceId, getAuthHeaders]);
+
   const fetchRecurringPayments = useCallback(async () => {
     if (!workspaceId) return;
     try {
@@ -574,6 +646,31 @@ export function useFinanceData({ workspaceId, accountId, categoryId, period, dat
     }
   }, [workspaceId, getAuthHeaders]);
 
+  const fetchTransactionSplits = useCallback(async (transactionId: string): Promise<TransactionSplit[]> => {
+    try {
+      const res = await fetch(`${API_BASE}/api/finance/transactions/${transactionId}/splits`, {
+        headers: getAuthHeaders(),
+      });
+      if (!res.ok) throw new Error('Failed to fetch splits');
+      return await res.json();
+    } catch (err) {
+      console.error('Failed to fetch splits:', err);
+      return [];
+    }
+  }, [getAuthHeaders]);
+
+  // Replaces all splits of a transaction; pass an empty list to remove the split
+  const saveTransactionSplits = useCallback(async (transactionId: string, splits: TransactionSplit[]) => {
+    const res = await fetch(`${API_BASE}/api/finance/transactions/${transactionId}/splits`, {
+      method: 'PUT',
+      headers: getAuthHeaders(),
+      body: JSON.stringify({ splits }),
+    });
+    const data = await res.json();
+    if (!res.ok) throw new Error(data.error || 'Failed to save splits');
+    return data as TransactionSplit[];
+  }, [getAuthHeaders]);
+
   const createGoal = useCallback(async (data: Omit<Goal, 'id'>) => {
     if (!workspaceId || !pb.authStore.model) return;
     try {
@@ -715,10 +812,16 @@ export function useFinanceData({ workspaceId, accountId, categoryId, period, dat
   };
 
   // Preview CSV import
-  const previewCSV = async (file: File, templateCode: string): Promise<ImportPreview> => {
+  const previewCSV = async (
+    file: File,
+    templateCode: string,
+    targetAccountId?: string
+  ): Promise<ImportPreview> => {
     const formData = new FormData();
     formData.append('file', file);
     formData.append('template', templateCode);
+    if (workspaceId) formData.append('workspace', workspaceId);
+    if (targetAccountId) formData.append('account', targetAccountId);
 
     const res = await fetch(`${API_BASE}/api/finance/import/preview`, {
       method: 'POST',
@@ -733,7 +836,8 @@ export function useFinanceData({ workspaceId, accountId, categoryId, period, dat
   const importCSV = async (
     file: File,
     targetAccountId: string,
-    templateCode: string
+    templateCode: string,
+    onProgress?: (processed: number, total: number) => void
   ): Promise<ImportResult> => {
     if (!workspaceId) throw new Error('No workspace selected');
 
@@ -742,14 +846,31 @@ export function useFinanceData({ workspaceId, accountId, categoryId, period, dat
     formData.append('template', templateCode);
     formData.append('account', targetAccountId);
     formData.append('workspace', workspaceId);
+    formData.append('async', 'true');
 
     const res = await fetch(`${API_BASE}/api/finance/import`, {
       method: 'POST',
       headers: { 'Authorization': pb.authStore.token },
       body: formData,
     });
+    if (res.status === 409) {
+      const body = await res.json();
+      throw new Error(body.error || 'This file was already imported');
+    }
     if (!res.ok) throw new Error('Failed to import CSV');
-    const result = await res.json();
+    let job: ImportJob = await res.json();
+
+    // Poll the background job until the import finishes
+    while (job.status === 'running') {
+      onProgress?.(job.processed, job.total);
+      await new Promise((resolve) => setTimeout(resolve, 500));
+      const poll = await fetch(`${API_BASE}/api/finance/import/jobs/${job.id}`, { headers: getAuthHeaders() });
+      if (!poll.ok) throw new Error('Failed to get import progress');
+      job = await poll.json();
+    }
+    if (job.status === 'failed' || !job.result) throw new Error(job.error || 'Import failed');
+    onProgress?.(job.total, job.total);
+    const result = job.result;
 
     // Refresh data after import
     await Promise.all([fetchTransactions(), fetchStats()]);
@@ -1103,6 +1224,7 @@ export function useFinanceData({ workspaceId, accountId, categoryId, period, dat
 
     // Stats
     fetchStats,
+    fetchTrends,
 
     // Transactions
     fetchTransactions,
@@ -1152,6 +1274,8 @@ export function useFinanceData({ workspaceId, accountId, categoryId, period, dat
     // Exchange Rates
     exchangeRates,
     fetchExchangeRates,
+    importExchangeRates,
+    fetchExchangeRateGaps,
 
     // Recurring actions
     recurringPayments,
@@ -1160,6 +1284,8 @@ export function useFinanceData({ workspaceId, accountId, categoryId, period, dat
     updateRecurring,
     deleteRecurring,
     detectRecurring,
+    fetchTransactionSplits,
+    saveTransactionSplits,
 
     // Utility
     refreshAll,
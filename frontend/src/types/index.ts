@@ -23,6 +23,19 @@ export interface FinancialRecord {
   balance_after?: number;
   external_id?: string;
   counterparty_account?: string;
+  is_transfer?: boolean;
+  original_amount?: number;
+  original_currency?: string;
+  split_id?: string;
+}
+
+export interface TransactionSplit {
+  id?: string;
+  transaction_id?: string;
+  amount: number;
+  category_id?: string;
+  merchant_id?: string;
+  note?: string;
 }
 
 export interface Account {
@@ -116,6 +129,47 @@ export interface ImportPreview {
   total_rows: number;
   errors: ImportError[];
   detected_template?: string;
+  encoding?: string;
+  inference?: TemplateInference;
+  previous_import?: FinanceImport;
+  possible_duplicates?: DuplicateCandidate[];
+}
+
+export interface DuplicateCandidate {
+  row_number?: number;
+  transaction_id?: string;
+  duplicate_of_id: string;
+  score: number;
+  reason: string;
+}
+
+export interface FinanceImport {
+  id: string;
+  workspace_id: string;
+  account_id?: string;
+  source_id?: string;
+  name: string;
+  bank_name?: string;
+  template?: string;
+  file_hash: string;
+  transactions_imported: number;
+  transactions_skipped: number;
+  duplicates_found: number;
+  imported_at: string;
+}
+
+export interface InferredField {
+  column: number;
+  header?: string;
+  confidence: number;
+}
+
+export interface TemplateInference {
+  template: BankTemplate;
+  has_header: boolean;
+  headers?: string[];
+  fields: Record<string, InferredField>;
+  confidence: number;
 }
 
 export interface ParsedTransaction {
@@ -133,6 +187,7 @@ export interface ParsedTransaction {
 }
 
 export interface ImportResult {
+  import_id: string;
   transactions_total: number;
   transactions_imported: number;
   transactions_skipped: number;
@@ -140,6 +195,16 @@ export interface ImportResult {
   errors: ImportError[];
 }
 
+export interface ImportJob {
+  id: string;
+  workspace_id: string;
+  status: 'running' | 'done' | 'failed';
+  processed: number;
+  total: number;
+  result?: ImportResult;
+  error?: string;
+}
+
 export interface ImportError {
   row: number;
   message: string;
@@ -159,6 +224,29 @@ export interface CategorizationSuggestion {
 export interface TrendPoint {
   date: string;
   amount: number;
+  delta?: number; // change against the previous bucket
+  delta_pct?: number;
+  rolling_avg?: number;
+}
+
+export type TrendInterval = 'day' | 'week' | 'month' | 'year';
+
+export interface TrendSeries {
+  key: string;
+  name: string;
+  total: number;
+  points: TrendPoint[];
+}
+
+export interface TrendReport {
+  interval: TrendInterval;
+  group_by: 'category' | 'merchant' | 'account';
+  type: 'expense' | 'income';
+  currency: string;
+  from: string;
+  to: string;
+  series: TrendSeries[];
+  missing_rates?: string[];
 }
 
 export interface MerchantSpend {
@@ -173,6 +261,18 @@ export interface AccountBalance {
   account_name: string;
   balance: number;
   currency: string;
+  balance_base?: number;
+}
+
+export interface CategoryTotal {
+  category_id?: string;
+  name: string;
+  icon?: string;
+  color?: string;
+  parent_id?: string;
+  amount: number; // booked directly on this category
+  total: number; // including subcategories
+  children?: CategoryTotal[];
 }
 
 export interface FinanceStats {
@@ -180,11 +280,15 @@ export interface FinanceStats {
   total_expenses: number;
   net_balance: number;
   by_category: Record<string, number>;
+  category_tree?: CategoryTotal[];
   by_category_trend?: Record<string, TrendPoint[]>;
   recurring_total: number;
   recurring_count: number;
   top_merchants: MerchantSpend[];
   account_balances?: AccountBalance[];
+  currency?: string;
+  by_currency?: Record<string, { income: number; expenses: number }>;
+  missing_rates?: string[];
 }
 
 export interface RecurringDetectionResult {
@@ -370,6 +474,8 @@ export interface BudgetSummary {
   total_actual: number;
   remaining: number;
   unmatched_expenses: FinancialRecord[];
+  currency?: string;
+  missing_rates?: string[];
 }
 
 
@@ -421,6 +527,20 @@ export interface ExchangeRate {
   date: string;
 }
 
+export interface ExchangeRateImportResult {
+  source: 'cnb' | 'ecb';
+  parsed: number;
+  inserted: number;
+  updated: number;
+}
+
+export interface ExchangeRateGap {
+  currency: string;
+  from: string;
+  to: string;
+  days: number;
+}
+
 export type DomainType = 'task' | 'finance' | 'communication' | 'calendar';
 
 
@@ -441,6 +561,7 @@ export interface Workspace {
   slug: string;
   icon?: string;
   settings?: WorkspaceSettings;
+  base_currency?: string;
 }
 
 export interface User {